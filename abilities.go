@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+func init() {
+	analyses["abilities"] = printMobAbilities
+}
+
+// MobAbility is what one NPC did with one skill over an encounter.
+type MobAbility struct {
+	Mob     string
+	Skill   string
+	Uses    int // casts, see mobCasts
+	Hits    int
+	Damage  int
+	Targets map[string]int // damage per target
+}
+
+// HardestHit returns the target that took the most damage from the ability.
+func (a *MobAbility) HardestHit() (string, int) {
+	top, most := "", 0
+	for target, dmg := range a.Targets {
+		if dmg > most || dmg == most && target < top {
+			top, most = target, dmg
+		}
+	}
+	return top, most
+}
+
+// mobAbilities aggregates the NPC-sourced damage and debuffs of an encounter
+// per mob name and skill, most damaging first.
+func mobAbilities(enc *Encounter) []*MobAbility {
+	abilities := map[[2]string]*MobAbility{}
+	for _, cast := range mobCasts(enc) {
+		key := [2]string{enc.Entities[cast.Mob].Name, cast.Skill}
+		a, ok := abilities[key]
+		if !ok {
+			a = &MobAbility{Mob: key[0], Skill: key[1], Targets: map[string]int{}}
+			abilities[key] = a
+		}
+		a.Uses++
+		for _, entry := range cast.Hits {
+			a.Hits++
+			if entry.etype == DmgDealt {
+				a.Damage += entry.Value
+				if entry.TargetID != "" {
+					a.Targets[entry.TargetID] += entry.Value
+				}
+			}
+		}
+	}
+	result := make([]*MobAbility, 0, len(abilities))
+	for _, a := range abilities {
+		result = append(result, a)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Damage != result[j].Damage {
+			return result[i].Damage > result[j].Damage
+		}
+		return result[i].Mob+result[i].Skill < result[j].Mob+result[j].Skill
+	})
+	return result
+}
+
+func printMobAbilities(enc *Encounter) {
+	fmt.Println("boss abilities:")
+	for _, a := range mobAbilities(enc) {
+		target, dmg := a.HardestHit()
+		hardest := ""
+		if target != "" {
+			hardest = fmt.Sprintf("hardest on %v (%v)", target, dmg)
+		}
+		fmt.Printf("  %-24v %-28v %4d uses %5d hits %10v dmg  %v\n", a.Mob, a.Skill, a.Uses, a.Hits, a.Damage, hardest)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// how long after being applied a bubble is credited with absorbs
+	bubbleWindow = 20 * time.Second
+	// caster credited with absorbs no configured bubble explains
+	unknownBubble = "(unknown bubble)"
+)
+
+func init() {
+	analyses["absorbs"] = printAbsorbs
+}
+
+// Mitigation is what one caster prevented or healed on the logging
+// character.
+type Mitigation struct {
+	Caster    string
+	Absorbed  int // damage soaked by their bubbles
+	Effective int // healing that restored missing Morale
+	Overheal  int // healing past full, or of damage a bubble already soaked
+}
+
+// absorbOrder sorts the events within one second so damage lands first,
+// bubbles soak it next and heals only get what is left.
+func absorbOrder(t EventType) int {
+	switch t {
+	case DmgDealt:
+		return 0
+	case TempMoraleLost:
+		return 1
+	case Heal:
+		return 2
+	}
+	return 3
+}
+
+// mitigation attributes the logging character's temporary Morale losses to
+// the bubbles applied on them and their heals to the healers. Only the
+// logging character's temporary Morale is in the log and it does not say
+// whose bubble it was, so absorbs go to the latest of the bubbles listed
+// in absorb_skills applied within bubbleWindow.
+func mitigation(enc *Encounter, bubbleSkills []string) []Mitigation {
+	isBubble := map[string]bool{}
+	for _, skill := range bubbleSkills {
+		isBubble[skill] = true
+	}
+	self := enc.Character
+	if self == "" {
+		self = selfplaceholder
+	}
+	events := []*LogEntry{}
+	for _, entry := range enc.Entries {
+		if entry.etype == TempMoraleLost || entry.Target == self && entry.etype != Comment {
+			events = append(events, entry)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		if !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.Before(b.Timestamp)
+		}
+		return absorbOrder(a.etype) < absorbOrder(b.etype)
+	})
+
+	casters := map[string]*Mitigation{}
+	get := func(caster string) *Mitigation {
+		m, ok := casters[caster]
+		if !ok {
+			m = &Mitigation{Caster: caster}
+			casters[caster] = m
+		}
+		return m
+	}
+	type bubble struct {
+		caster string
+		at     time.Time
+	}
+	var latest *bubble
+	deficit := 0
+	for _, entry := range events {
+		switch entry.etype {
+		case DmgDealt:
+			deficit += entry.Value
+		case Benefit:
+			if isBubble[entry.Skill] {
+				latest = &bubble{casterName(entry.Source), entry.Timestamp}
+			}
+		case TempMoraleLost:
+			caster := unknownBubble
+			if latest != nil && entry.Timestamp.Sub(latest.at) <= bubbleWindow {
+				caster = latest.caster
+			}
+			soaked := min(entry.Value, deficit)
+			get(caster).Absorbed += soaked
+			deficit -= soaked
+		case Heal:
+			m := get(casterName(entry.Source))
+			effective := min(entry.Value, deficit)
+			m.Effective += effective
+			m.Overheal += entry.Value - effective
+			deficit -= effective
+		case Death:
+			deficit = 0
+		}
+	}
+	result := make([]Mitigation, 0, len(casters))
+	for _, m := range casters {
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Absorbed+a.Effective != b.Absorbed+b.Effective {
+			return a.Absorbed+a.Effective > b.Absorbed+b.Effective
+		}
+		return a.Caster < b.Caster
+	})
+	return result
+}
+
+// casterName is the name of a raw source, "(unknown)" when the log does not
+// say.
+func casterName(raw string) string {
+	if raw == "" {
+		return "(unknown)"
+	}
+	return entityName(raw)
+}
+
+func printAbsorbs(enc *Encounter) {
+	fmt.Println("absorbs and effective healing on the logging character:")
+	if len(config.AbsorbSkills) == 0 {
+		fmt.Println("  no absorb_skills configured, absorbs are not attributed")
+	}
+	for _, m := range mitigation(enc, config.AbsorbSkills) {
+		fmt.Printf("  %-18v absorbed %-9v healed %-9v overhealed %v\n", m.Caster, m.Absorbed, m.Effective, m.Overheal)
+	}
+}
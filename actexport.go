@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+func init() {
+	commands["act"] = runACTExport
+}
+
+// actTimeLayout is how ACT writes times in its table exports.
+const actTimeLayout = "2006-01-02 15:04:05"
+
+// Column layouts of ACT's encounter_table and combatant_table exports, which
+// other trackers and spreadsheets already import.
+var (
+	actEncounterColumns = []string{"encid", "title", "starttime", "endtime", "duration", "damage", "encdps", "zone", "kills", "deaths"}
+	actCombatantColumns = []string{"encid", "ally", "name", "starttime", "endtime", "duration", "damage", "damageperc", "kills",
+		"healed", "healedperc", "critheals", "heals", "curedispels", "powerdrain", "powerreplenish", "dps", "encdps", "enchps",
+		"hits", "crithits", "blocked", "misses", "swings", "healstaken", "damagetaken", "deaths", "tohit", "critdamperc",
+		"crithealperc", "threatstr", "threatdelta", "job"}
+)
+
+// Combatant is the ACT view of one actor in an encounter.
+type Combatant struct {
+	Name                       string
+	Ally                       bool
+	First, Last                time.Time
+	Damage, Healed, Power      int
+	Hits, Crits, Blocked, Miss int
+	Heals, CritHeals           int
+	HealsTaken, DamageTaken    int
+	Kills, Deaths              int
+}
+
+func (c *Combatant) swings() int {
+	return c.Hits + c.Miss
+}
+
+// combatants totals the ACT columns of every actor of an encounter.
+func combatants(enc *Encounter) []*Combatant {
+	all := map[string]*Combatant{}
+	get := func(id string, at time.Time) *Combatant {
+		c, ok := all[id]
+		if !ok {
+			c = &Combatant{Name: id, Ally: enc.kindOf(id) != NPC, First: at}
+			all[id] = c
+		}
+		c.Last = at
+		return c
+	}
+	for _, entry := range enc.Entries {
+		if entry.SourceID == "" || entry.TargetID == "" {
+			continue
+		}
+		switch entry.etype {
+		case DmgDealt, DmgTaken:
+			c := get(entry.SourceID, entry.Timestamp)
+			switch {
+			case entry.Value == 0:
+				c.Miss++
+			default:
+				c.Hits++
+				c.Damage += entry.Value
+				if entry.Crit {
+					c.Crits++
+				}
+			}
+			if entry.Avoided == Blocked {
+				c.Blocked++
+			}
+			get(entry.TargetID, entry.Timestamp).DamageTaken += entry.Value
+		case Heal:
+			c := get(entry.SourceID, entry.Timestamp)
+			c.Heals++
+			c.Healed += entry.Value
+			if entry.Crit {
+				c.CritHeals++
+			}
+			get(entry.TargetID, entry.Timestamp).HealsTaken += entry.Value
+		case PowerRestored:
+			get(entry.SourceID, entry.Timestamp).Power += entry.Value
+		case Death:
+			get(entry.SourceID, entry.Timestamp).Kills++
+			get(entry.TargetID, entry.Timestamp).Deaths++
+		}
+	}
+	list := make([]*Combatant, 0, len(all))
+	for _, c := range all {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Damage > list[j].Damage || list[i].Damage == list[j].Damage && list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// actTime puts a log time, which has no year, into the year of the log.
+func actTime(t time.Time, year int) string {
+	return t.AddDate(year-t.Year(), 0, 0).Format(actTimeLayout)
+}
+
+func percent(part, total int) string {
+	if total == 0 {
+		return "0%"
+	}
+	return fmt.Sprintf("%d%%", part*100/total)
+}
+
+func rate(total int, d time.Duration) string {
+	secs := d.Seconds()
+	if secs < 1 {
+		secs = 1
+	}
+	return strconv.FormatFloat(float64(total)/secs, 'f', 2, 64)
+}
+
+// actTitle names an encounter after its boss like ACT does, after its
+// label when there is one.
+func actTitle(enc *Encounter, n int) string {
+	if enc.Label != "" {
+		return enc.Label
+	}
+	if boss := encounterBoss(enc); boss != "" {
+		return boss
+	}
+	return fmt.Sprintf("Encounter %d", n)
+}
+
+// writeACTTables writes the encounter and combatant tables of the encounters
+// to encounter_table.csv and combatant_table.csv in dir.
+func writeACTTables(dir string, encounters []*Encounter, year int) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	encFile, err := os.Create(filepath.Join(dir, "encounter_table.csv"))
+	if err != nil {
+		return err
+	}
+	defer encFile.Close()
+	combFile, err := os.Create(filepath.Join(dir, "combatant_table.csv"))
+	if err != nil {
+		return err
+	}
+	defer combFile.Close()
+	encTable, combTable := csv.NewWriter(encFile), csv.NewWriter(combFile)
+	encTable.Write(actEncounterColumns)
+	combTable.Write(actCombatantColumns)
+
+	for n, enc := range encounters {
+		id := encounterID(enc)
+		list := combatants(enc)
+		damage, healed, kills, deaths := 0, 0, 0, 0
+		for _, c := range list {
+			if c.Ally {
+				damage += c.Damage
+				healed += c.Healed
+				kills += c.Kills
+				deaths += c.Deaths
+			}
+		}
+		duration := enc.Duration()
+		encTable.Write([]string{id, actTitle(enc, n+1), actTime(enc.Start, year), actTime(enc.Start.Add(duration), year),
+			strconv.Itoa(int(duration.Seconds())), strconv.Itoa(damage), rate(damage, duration), "", strconv.Itoa(kills), strconv.Itoa(deaths)})
+		for _, c := range list {
+			ally := "F"
+			if c.Ally {
+				ally = "T"
+			}
+			active := c.Last.Sub(c.First)
+			combTable.Write([]string{id, ally, c.Name, actTime(c.First, year), actTime(c.Last, year),
+				strconv.Itoa(int(active.Seconds())), strconv.Itoa(c.Damage), percent(c.Damage, damage), strconv.Itoa(c.Kills),
+				strconv.Itoa(c.Healed), percent(c.Healed, healed), strconv.Itoa(c.CritHeals), strconv.Itoa(c.Heals), "0", "0",
+				strconv.Itoa(c.Power), rate(c.Damage, active), rate(c.Damage, duration), rate(c.Healed, duration),
+				strconv.Itoa(c.Hits), strconv.Itoa(c.Crits), strconv.Itoa(c.Blocked), strconv.Itoa(c.Miss), strconv.Itoa(c.swings()),
+				strconv.Itoa(c.HealsTaken), strconv.Itoa(c.DamageTaken), strconv.Itoa(c.Deaths),
+				percent(c.Hits, c.swings()), percent(c.Crits, c.Hits), percent(c.CritHeals, c.Heals), "", "0", ""})
+		}
+	}
+	encTable.Flush()
+	combTable.Flush()
+	if err := encTable.Error(); err != nil {
+		return err
+	}
+	return combTable.Error()
+}
+
+// runACTExport writes a log's encounters in the table layout of ACT's
+// exports, for tools and spreadsheets built around it.
+func runACTExport(args []string) error {
+	fs := flag.NewFlagSet("act", flag.ExitOnError)
+	setup := commonFlags(fs)
+	out := fs.String("o", ".", "directory for encounter_table.csv and combatant_table.csv")
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	result, err := parseFile(inputPath(fs), ParserOptions{})
+	if err != nil {
+		return err
+	}
+	encounters := segmentEncounters(result.Entries)
+	if err := writeACTTables(*out, encounters, result.Profile.Date.Year()); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %d encounters to %v\n", len(encounters), *out)
+	return nil
+}
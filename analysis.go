@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// analysis prints a per-encounter report.
+type analysis func(enc *Encounter)
+
+// analyses are the reports selectable with -analyze.
+var analyses = map[string]analysis{}
+
+// runAnalyses runs the comma separated list of analyses on every encounter.
+func runAnalyses(names string, encounters []*Encounter) error {
+	selected := []analysis{}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		a, ok := analyses[name]
+		if !ok {
+			return fmt.Errorf("unknown analysis %q, have: %v", name, analysisNames())
+		}
+		selected = append(selected, a)
+	}
+	for i, enc := range encounters {
+		if len(selected) == 0 {
+			break
+		}
+		fmt.Printf("== encounter %d ==\n", i+1)
+		for _, a := range selected {
+			a(enc)
+		}
+	}
+	return nil
+}
+
+func analysisNames() []string {
+	names := make([]string, 0, len(analyses))
+	for name := range analyses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	commands["annotate"] = runAnnotate
+}
+
+// annotateLog inserts comment lines into the log file right before the first
+// line of an encounter.
+func annotateLog(path string, encounter int, comments []string) error {
+	result, err := parseFile(path, ParserOptions{})
+	if err != nil {
+		return err
+	}
+	encounters := segmentEncounters(result.Entries)
+	if encounter < 1 || encounter > len(encounters) {
+		return fmt.Errorf("log has %d encounters, asked for %d", len(encounters), encounter)
+	}
+	first := encounters[encounter-1].Entries[0]
+	// the same line can show up earlier in the log, skip those
+	skip := 0
+	for _, entry := range result.Entries {
+		if entry == first {
+			break
+		}
+		if entry.RawMessage == first.RawMessage {
+			skip++
+		}
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	scanner := newLineScanner(in)
+	inserted := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !inserted && line == first.RawMessage {
+			if skip == 0 {
+				for _, c := range comments {
+					fmt.Fprintln(w, c)
+				}
+				inserted = true
+			}
+			skip--
+		}
+		fmt.Fprintln(w, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if !inserted {
+		return fmt.Errorf("could not find the first line of encounter %d", encounter)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// runAnnotate records a build, label or note on an encounter by writing the
+// matching "###" comment into the log.
+func runAnnotate(args []string) error {
+	fs := flag.NewFlagSet("annotate", flag.ExitOnError)
+	setup := commonFlags(fs)
+	encounter := fs.Int("encounter", 1, "encounter to annotate (1-based)")
+	build := fs.String("build", "", "build used from this encounter on, e.g. traits and gear")
+	label := fs.String("label", "", "label of the encounter")
+	note := fs.String("note", "", "note on the encounter")
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	comments := []string{}
+	for _, kv := range [][2]string{{"label", *label}, {"build", *build}, {"note", *note}} {
+		if value := strings.TrimSpace(kv[1]); value != "" {
+			comments = append(comments, fmt.Sprintf("### %v: %v", kv[0], value))
+		}
+	}
+	if len(comments) == 0 {
+		return fmt.Errorf("nothing to annotate, use -build, -label or -note")
+	}
+	return annotateLog(inputPath(fs), *encounter, comments)
+}
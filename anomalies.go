@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+const (
+	// a hit this many times the skill's median is not believed
+	anomalyFactor = 100
+	// skills need this many hits before their median means anything
+	anomalyMinHits = 5
+	// live mode takes medians of this many recent hits per skill
+	anomalyWindowHits = 50
+)
+
+// Anomaly is an implausible entry kept out of the aggregates.
+type Anomaly struct {
+	Line   string `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// anomalyKey groups the hits that share a median. A boss and a player can
+// use skills of the same name for very different amounts, so the source is
+// part of it.
+type anomalyKey struct {
+	source string
+	etype  EventType
+	skill  string
+}
+
+func anomalyKeyOf(entry *LogEntry) anomalyKey {
+	return anomalyKey{entry.Source, entry.etype, entry.Skill}
+}
+
+// dropAnomalies removes entries with implausible values: negative amounts,
+// and hits far beyond the median of the same skill by the same source.
+func dropAnomalies(entries []*LogEntry) ([]*LogEntry, []Anomaly) {
+	values := map[anomalyKey][]int{}
+	for _, entry := range entries {
+		if entry.Value > 0 && entry.Skill != "" {
+			values[anomalyKeyOf(entry)] = append(values[anomalyKeyOf(entry)], entry.Value)
+		}
+	}
+	medians := map[anomalyKey]int{}
+	for k, v := range values {
+		if len(v) >= anomalyMinHits {
+			medians[k] = medianValue(v)
+		}
+	}
+
+	anomalies := []Anomaly{}
+	kept := entries[:0]
+	for _, entry := range entries {
+		if reason := anomalyReason(entry, medians[anomalyKeyOf(entry)]); reason != "" {
+			anomalies = append(anomalies, Anomaly{Line: entry.RawMessage, Reason: reason})
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	return kept, anomalies
+}
+
+// medianValue sorts values and returns their median.
+func medianValue(values []int) int {
+	sort.Ints(values)
+	return values[len(values)/2]
+}
+
+// anomalyReason says why an entry is implausible given the median of its
+// skill, zero when that is not known, or returns "".
+func anomalyReason(entry *LogEntry, median int) string {
+	if entry.Value < 0 {
+		return fmt.Sprintf("negative value %d", entry.Value)
+	}
+	if median > 0 && entry.Value > anomalyFactor*median {
+		return fmt.Sprintf("%d is %dx %v's median %v hit of %d", entry.Value, entry.Value/median, entry.Source, entry.Skill, median)
+	}
+	return ""
+}
+
+// anomalyWindow drops implausible entries one at a time, for live mode
+// where there is no whole log to take medians of. Each hit is checked
+// against the median of the recent hits before it.
+type anomalyWindow struct {
+	values map[anomalyKey][]int
+}
+
+func newAnomalyWindow() *anomalyWindow {
+	return &anomalyWindow{values: map[anomalyKey][]int{}}
+}
+
+// check returns why entry is implausible, or "" to keep it.
+func (w *anomalyWindow) check(entry *LogEntry) string {
+	if entry.Value <= 0 || entry.Skill == "" {
+		return anomalyReason(entry, 0)
+	}
+	k := anomalyKeyOf(entry)
+	recent := w.values[k]
+	median := 0
+	if len(recent) >= anomalyMinHits {
+		median = medianValue(slices.Clone(recent))
+	}
+	if len(recent) == anomalyWindowHits {
+		recent = recent[1:]
+	}
+	w.values[k] = append(recent, entry.Value)
+	return anomalyReason(entry, median)
+}
+
+// encounterAnomalies flags encounters that end before they start, which
+// only happens when merging went wrong.
+func encounterAnomalies(encounters []*Encounter) []Anomaly {
+	anomalies := []Anomaly{}
+	for i, enc := range encounters {
+		if enc.Duration() < 0 {
+			anomalies = append(anomalies, Anomaly{
+				Line:   enc.Entries[0].RawMessage,
+				Reason: fmt.Sprintf("encounter %d has negative duration %v", i+1, enc.Duration()),
+			})
+		}
+	}
+	return anomalies
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDropAnomalies(t *testing.T) {
+	hit := func(skill string, value int) *LogEntry {
+		entry := skillUseAt(0, "Starlaf", skill)
+		entry.Value, entry.RawMessage = value, skill
+		return entry
+	}
+	var entries []*LogEntry
+	for range anomalyMinHits {
+		entries = append(entries, hit("Thrash", 100))
+	}
+	entries = append(entries,
+		hit("Thrash", 100*anomalyFactor),
+		hit("Thrash", 100*anomalyFactor+1),
+		hit("Bash", -5),
+		// too few Rend hits to know its median
+		hit("Rend", 1), hit("Rend", 1000000),
+	)
+	kept, anomalies := dropAnomalies(entries)
+	if len(kept) != anomalyMinHits+3 || len(anomalies) != 2 {
+		t.Fatalf("got %d kept and anomalies %+v, want %d kept and 2 anomalies", len(kept), anomalies, anomalyMinHits+3)
+	}
+	if anomalies[0].Reason != "10001 is 100x Starlaf's median Thrash hit of 100" || anomalies[1].Reason != "negative value -5" {
+		t.Errorf("got %+v", anomalies)
+	}
+}
+
+func TestAnomaliesBySource(t *testing.T) {
+	var entries []*LogEntry
+	// the many weak Thrash hits of an add do not make a player's Thrash
+	// hits implausible
+	for i := range 2*anomalyMinHits + 1 {
+		entry := skillUseAt(float64(i), "Naxam", "Thrash")
+		entry.Value = 10
+		if i < anomalyMinHits {
+			entry.Source, entry.Value = "Starlaf", 10*anomalyFactor*2
+		}
+		entries = append(entries, entry)
+	}
+	if kept, anomalies := dropAnomalies(entries); len(kept) != len(entries) {
+		t.Errorf("got anomalies %+v, want the hits of both sources kept", anomalies)
+	}
+}
+
+func TestLiveAnomalies(t *testing.T) {
+	lines := make(chan string)
+	out := make(chan *LogEntry)
+	go parseLines(lines, out)
+	go func() {
+		for i, value := range []int{100, 100, 100, 100, 100, 100 * anomalyFactor * 2, 120} {
+			lines <- fmt.Sprintf("[07/08 05:35:%02d PM] Starlaf scored a hit with Thrash on Burkhad for %d Common damage to Morale.", i, value)
+		}
+		close(lines)
+	}()
+	var values []int
+	for entry := range out {
+		values = append(values, entry.Value)
+	}
+	if !slices.Equal(values, []int{100, 100, 100, 100, 100, 120}) {
+		t.Errorf("got values %v, want the implausible hit dropped", values)
+	}
+}
+
+func TestEncounterAnomalies(t *testing.T) {
+	enc := encounterOf(skillUseAt(5, "Starlaf", "Thrash"))
+	enc.Start = enc.End.Add(time.Second)
+	if got := encounterAnomalies([]*Encounter{encounterOf(skillUseAt(5, "Huya", "Bash")), enc}); len(got) != 1 {
+		t.Errorf("got %+v, want the second encounter flagged", got)
+	}
+}
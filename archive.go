@@ -0,0 +1,158 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// An archive is a gzipped tar of encounters.json, the index records, and
+// raw/<id>.log with the raw lines of every encounter that still has them.
+const archiveIndex = "encounters.json"
+
+// exportArchive writes every stored encounter to w.
+func exportArchive(store Store, w io.Writer) (int, error) {
+	list, err := store.ListEncounters()
+	if err != nil {
+		return 0, err
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	add := func(name string, data []byte) error {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: time.Now()}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	index, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := add(archiveIndex, index); err != nil {
+		return 0, err
+	}
+	for _, record := range list {
+		if record.RawPruned {
+			continue
+		}
+		lines, err := store.GetTimeline(record.ID)
+		if err != nil {
+			return 0, err
+		}
+		if err := add(path.Join("raw", record.ID+".log"), []byte(strings.Join(lines, "\n")+"\n")); err != nil {
+			return 0, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return 0, err
+	}
+	return len(list), gz.Close()
+}
+
+// importArchive adds the encounters of an archive to the store. Encounters
+// it already has are left alone.
+func importArchive(store Store, r io.Reader) (int, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, fmt.Errorf("reading archive: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	var list []StoredEncounter
+	raw := map[string][]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("reading archive: %w", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return 0, fmt.Errorf("reading archive: %w", err)
+		}
+		switch {
+		case hdr.Name == archiveIndex:
+			if err := json.Unmarshal(data, &list); err != nil {
+				return 0, fmt.Errorf("reading archive index: %w", err)
+			}
+		case strings.HasPrefix(hdr.Name, "raw/"):
+			id := strings.TrimSuffix(path.Base(hdr.Name), ".log")
+			raw[id] = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		}
+	}
+	if list == nil {
+		return 0, fmt.Errorf("reading archive: no %v", archiveIndex)
+	}
+	// IDs name files in the store, so an archive must not pick them
+	for _, record := range list {
+		if !validEncounterID.MatchString(record.ID) {
+			return 0, fmt.Errorf("archive has an invalid encounter id %q", record.ID)
+		}
+	}
+	err = storeBatch(store, func() error {
+		for _, record := range list {
+			lines, ok := raw[record.ID]
+			if !ok && !record.RawPruned {
+				return fmt.Errorf("archive has no raw lines for %v", record.ID)
+			}
+			if _, err := store.PutRecord(record, lines); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(list), nil
+}
+
+// dbExport writes the store to the archive named by the first argument.
+func dbExport(store Store, fs *flag.FlagSet) error {
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: db export [flags] archive.tar.gz")
+	}
+	file, err := os.Create(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	n, err := exportArchive(store, file)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d encounters to %v\n", n, fs.Arg(0))
+	return nil
+}
+
+// dbImport adds the encounters of the archives given to the store.
+func dbImport(store Store, fs *flag.FlagSet) error {
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: db import [flags] archive.tar.gz...")
+	}
+	for _, name := range fs.Args() {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		n, err := importArchive(store, file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%v: %w", name, err)
+		}
+		fmt.Printf("imported %d encounters from %v\n", n, name)
+	}
+	return nil
+}
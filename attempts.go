@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+func init() {
+	commands["attempts"] = runAttempts
+}
+
+// Attempt is one pull of a boss.
+type Attempt struct {
+	Number   int // within the progression session
+	Start    time.Time
+	Duration time.Duration
+	Damage   int     // dealt to the boss
+	Progress float64 // Damage as a fraction of the estimated boss morale
+	Killed   bool
+}
+
+// ProgressPercent is Progress in percent.
+func (a Attempt) ProgressPercent() float64 {
+	return 100 * a.Progress
+}
+
+// BossAttempts are consecutive encounters against the same boss, from the
+// first pull up to the kill or the last wipe.
+type BossAttempts struct {
+	Boss     string
+	Attempts []Attempt
+}
+
+// Best returns the kill, or the attempt that got the boss lowest.
+func (b BossAttempts) Best() Attempt {
+	best := b.Attempts[0]
+	for _, a := range b.Attempts[1:] {
+		if a.Killed && !best.Killed || a.Killed == best.Killed && a.Progress > best.Progress {
+			best = a
+		}
+	}
+	return best
+}
+
+// WipeMinutes counts wipes by the minute of the fight they happened in.
+func (b BossAttempts) WipeMinutes() map[int]int {
+	minutes := map[int]int{}
+	for _, a := range b.Attempts {
+		if !a.Killed {
+			minutes[int(a.Duration/time.Minute)]++
+		}
+	}
+	return minutes
+}
+
+// bossAttempts groups encounters into progression sessions. The boss morale
+// is estimated as the damage of the kill, or the most damage of any attempt
+// when there was no kill, so Progress is the phase reached.
+func bossAttempts(encounters []*Encounter) []BossAttempts {
+	sessions := []BossAttempts{}
+	for _, enc := range encounters {
+		boss := bossEntity(enc)
+		if boss == nil {
+			continue
+		}
+		if n := len(sessions); n == 0 || sessions[n-1].Boss != boss.Name || sessions[n-1].Best().Killed {
+			sessions = append(sessions, BossAttempts{Boss: boss.Name})
+		}
+		cur := &sessions[len(sessions)-1]
+		cur.Attempts = append(cur.Attempts, Attempt{
+			Number:   len(cur.Attempts) + 1,
+			Start:    enc.Start,
+			Duration: enc.Duration(),
+			Damage:   boss.Damage,
+			Killed:   !boss.Died.IsZero(),
+		})
+	}
+	for i := range sessions {
+		morale := 0
+		for _, a := range sessions[i].Attempts {
+			if a.Killed {
+				morale = a.Damage
+				break
+			}
+			if a.Damage > morale {
+				morale = a.Damage
+			}
+		}
+		for j := range sessions[i].Attempts {
+			sessions[i].Attempts[j].Progress = float64(sessions[i].Attempts[j].Damage) / float64(morale)
+		}
+	}
+	return sessions
+}
+
+func printBossAttempts(sessions []BossAttempts) {
+	for _, s := range sessions {
+		best := s.Best()
+		outcome := fmt.Sprintf("best attempt %d at %.0f%%", best.Number, 100*best.Progress)
+		if best.Killed {
+			outcome = fmt.Sprintf("killed on attempt %d", best.Number)
+		}
+		fmt.Printf("%v: %d attempts, %v\n", s.Boss, len(s.Attempts), outcome)
+		for _, a := range s.Attempts {
+			result := "wipe"
+			if a.Killed {
+				result = "kill"
+			}
+			fmt.Printf("  #%-3d %v %-8v %-4v reached %3.0f%% %v\n",
+				a.Number, a.Start.Format("15:04:05"), a.Duration, result, 100*a.Progress, strings.Repeat("#", int(20*a.Progress)))
+		}
+		wipes := s.WipeMinutes()
+		if len(wipes) == 0 {
+			continue
+		}
+		minutes := make([]int, 0, len(wipes))
+		for m := range wipes {
+			minutes = append(minutes, m)
+		}
+		sort.Ints(minutes)
+		fmt.Println("  wipes by minute:")
+		for _, m := range minutes {
+			fmt.Printf("    %2d-%2dm %v\n", m, m+1, strings.Repeat("#", wipes[m]))
+		}
+	}
+}
+
+// runAttempts prints the pull-to-kill chronology of every boss in a log.
+func runAttempts(args []string) error {
+	fs := flag.NewFlagSet("attempts", flag.ExitOnError)
+	setup := commonFlags(fs)
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	result, err := parseFile(inputPath(fs), ParserOptions{})
+	if err != nil {
+		return err
+	}
+	printBossAttempts(bossAttempts(segmentEncounters(result.Entries)))
+	return nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const (
+	// fights this long after midnight still belong to the previous raid night
+	nightRollover = 6 * time.Hour
+)
+
+func init() {
+	commands["attendance"] = runAttendance
+}
+
+// Attendance is how often a character showed up for a guild's raids.
+type Attendance struct {
+	Character string `json:"character"`
+	Nights    int    `json:"nights"`
+	Bosses    int    `json:"bosses"` // boss encounters fought in
+	LastNight string `json:"last_night,omitempty"`
+	// OnRoster is false for characters that raided without being on the
+	// guild's roster, like pugs.
+	OnRoster bool `json:"on_roster"`
+}
+
+// NightRate is the share of raid nights the character was present for.
+func (a Attendance) NightRate(nights int) float64 {
+	if nights == 0 {
+		return 0
+	}
+	return float64(a.Nights) / float64(nights)
+}
+
+// AttendanceReport is the attendance of every character seen in a guild's
+// encounters and everyone on its roster.
+type AttendanceReport struct {
+	Guild      string       `json:"guild,omitempty"`
+	Nights     []string     `json:"nights"`
+	Bosses     int          `json:"bosses"`
+	Characters []Attendance `json:"characters"`
+}
+
+// raidNight is the night an encounter belongs to, as month/day.
+func raidNight(start time.Time) string {
+	return start.Add(-nightRollover).Format("01/02")
+}
+
+// attendance counts the nights and boss fights each player took part in.
+func attendance(guild string, records []StoredEncounter, roster []string) AttendanceReport {
+	report := AttendanceReport{Guild: guild, Nights: []string{}, Characters: []Attendance{}}
+	nights := map[string]map[string]bool{} // character -> nights
+	bosses := map[string]int{}
+	allNights := map[string]int{}
+	for _, record := range records {
+		if record.Guild != guild {
+			continue
+		}
+		night := raidNight(record.Start)
+		allNights[night]++
+		if record.Boss != "" {
+			report.Bosses++
+		}
+		for _, s := range record.Stats {
+			if s.Kind != Player || s.Actor == selfplaceholder || s.Damage+s.Healing+s.DamageTaken == 0 {
+				continue
+			}
+			if nights[s.Actor] == nil {
+				nights[s.Actor] = map[string]bool{}
+			}
+			nights[s.Actor][night] = true
+			if record.Boss != "" {
+				bosses[s.Actor]++
+			}
+		}
+	}
+	report.Nights = sortedKeys(allNights)
+
+	onRoster := map[string]bool{}
+	for _, name := range roster {
+		onRoster[name] = true
+		if nights[name] == nil {
+			nights[name] = map[string]bool{}
+		}
+	}
+	for name, present := range nights {
+		a := Attendance{Character: name, Nights: len(present), Bosses: bosses[name], OnRoster: onRoster[name]}
+		for night := range present {
+			if night > a.LastNight {
+				a.LastNight = night
+			}
+		}
+		report.Characters = append(report.Characters, a)
+	}
+	sort.Slice(report.Characters, func(i, j int) bool {
+		a, b := report.Characters[i], report.Characters[j]
+		if a.Nights != b.Nights {
+			return a.Nights > b.Nights
+		}
+		if a.Bosses != b.Bosses {
+			return a.Bosses > b.Bosses
+		}
+		return a.Character < b.Character
+	})
+	return report
+}
+
+var attendanceHTML = template.Must(template.New("attendance").Funcs(themeFuncs).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Guild}} attendance</title>
+<style>body { background: {{theme.Background}}; color: {{theme.Foreground}}; } .pug { color: {{theme.Muted}}; }</style></head>
+<body>
+<h1>{{.Guild}} attendance</h1>
+<p>{{len .Nights}} raid nights, {{.Bosses}} boss encounters</p>
+<table>
+<tr><th>Character</th><th>Nights</th><th>Bosses</th><th>Last night</th></tr>
+{{range .Characters}}<tr{{if not .OnRoster}} class="pug"{{end}}><td>{{.Character}}</td><td>{{.Nights}}</td><td>{{.Bosses}}</td><td>{{.LastNight}}</td></tr>
+{{end}}</table>
+</body></html>
+`))
+
+func printAttendance(report AttendanceReport) {
+	fmt.Printf("%d raid nights, %d boss encounters\n", len(report.Nights), report.Bosses)
+	for _, a := range report.Characters {
+		note := ""
+		if !a.OnRoster {
+			note = "not on roster"
+		}
+		fmt.Printf("  %-16v %3d nights %4.0f%% %4d bosses  last %-5v %v\n",
+			a.Character, a.Nights, 100*a.NightRate(len(report.Nights)), a.Bosses, a.LastNight, note)
+	}
+}
+
+// getAttendance serves the attendance of the guild in the URL, as JSON or
+// with ?format=html as a page for officers.
+func (s *shareServer) getAttendance(w http.ResponseWriter, r *http.Request) {
+	records, err := s.store.ListEncounters()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	guild := r.PathValue("guild")
+	report := attendance(guild, records, s.guilds.roster(guild))
+	if r.URL.Query().Get("format") == "html" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		attendanceHTML.Execute(w, report)
+		return
+	}
+	writeJSON(w, report)
+}
+
+func (s *shareServer) getRoster(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.guilds.roster(r.PathValue("guild")))
+}
+
+// putRoster replaces the roster of the guild with a JSON list of names.
+func (s *shareServer) putRoster(w http.ResponseWriter, r *http.Request) {
+	var roster []string
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&roster); err != nil {
+		http.Error(w, fmt.Sprintf("roster must be a JSON list of names: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := s.guilds.setRoster(r.PathValue("guild"), roster); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, roster)
+}
+
+// runAttendance prints the attendance of a guild, or of the public
+// encounters without -guild, from the store.
+func runAttendance(args []string) error {
+	fs := flag.NewFlagSet("attendance", flag.ExitOnError)
+	setup := commonFlags(fs)
+	open := storeFlags(fs)
+	guild := fs.String("guild", "", "guild workspace to report on")
+	path := fs.String("workspaces", "", "guild registry file (default from config)")
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	if *path != "" {
+		config.Server.Workspaces = *path
+	}
+	store, err := open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	records, err := store.ListEncounters()
+	if err != nil {
+		return err
+	}
+	roster := config.Players
+	if *guild != "" {
+		guilds, err := openWorkspaces(config.Server.Workspaces)
+		if err != nil {
+			return err
+		}
+		roster = guilds.roster(*guild)
+	}
+	printAttendance(attendance(*guild, records, roster))
+	return nil
+}
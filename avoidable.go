@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+func init() {
+	analyses["avoidable"] = printAvoidable
+}
+
+// DamageTaken splits a player's damage taken into avoidable and unavoidable.
+type DamageTaken struct {
+	Player      string
+	Avoidable   int
+	Unavoidable int
+	BySkill     map[string]int // avoidable damage per skill
+}
+
+// skills returns the avoidable skills that hit, most damage first and ties
+// by name.
+func (d DamageTaken) skills() []string {
+	skills := make([]string, 0, len(d.BySkill))
+	for skill := range d.BySkill {
+		skills = append(skills, skill)
+	}
+	sort.Slice(skills, func(i, j int) bool {
+		if d.BySkill[skills[i]] != d.BySkill[skills[j]] {
+			return d.BySkill[skills[i]] > d.BySkill[skills[j]]
+		}
+		return skills[i] < skills[j]
+	})
+	return skills
+}
+
+// friendlyActors guesses the players of an encounter: anyone who healed or
+// buffed, or was healed or buffed.
+func friendlyActors(enc *Encounter) map[string]bool {
+	friends := map[string]bool{}
+	for _, entry := range enc.Entries {
+		if entry.etype != Heal && entry.etype != Benefit {
+			continue
+		}
+		if entry.Source != "" {
+			friends[entry.Source] = true
+		}
+		if entry.Target != "" {
+			friends[entry.Target] = true
+		}
+	}
+	return friends
+}
+
+func damageTaken(enc *Encounter, avoidable []string) []DamageTaken {
+	isAvoidable := map[string]bool{}
+	for _, skill := range avoidable {
+		isAvoidable[skill] = true
+	}
+	friends := friendlyActors(enc)
+	taken := map[string]*DamageTaken{}
+	for _, entry := range enc.Entries {
+		if entry.etype != DmgDealt || entry.Value == 0 || !friends[entry.Target] || friends[entry.Source] {
+			continue
+		}
+		d, ok := taken[entry.Target]
+		if !ok {
+			d = &DamageTaken{Player: entry.Target, BySkill: map[string]int{}}
+			taken[entry.Target] = d
+		}
+		if isAvoidable[entry.Skill] {
+			d.Avoidable += entry.Value
+			d.BySkill[entry.Skill] += entry.Value
+		} else {
+			d.Unavoidable += entry.Value
+		}
+	}
+	result := make([]DamageTaken, 0, len(taken))
+	for _, d := range taken {
+		result = append(result, *d)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Avoidable > result[j].Avoidable })
+	return result
+}
+
+func printAvoidable(enc *Encounter) {
+	fmt.Println("damage taken:")
+	if len(config.AvoidableSkills) == 0 {
+		fmt.Println("  no avoidable_skills configured, everything counts as unavoidable")
+	}
+	for _, d := range damageTaken(enc, config.AvoidableSkills) {
+		fmt.Printf("  %-12v avoidable %-10v unavoidable %v\n", d.Player, d.Avoidable, d.Unavoidable)
+		for _, skill := range d.skills() {
+			fmt.Printf("    %v: %v\n", skill, d.BySkill[skill])
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestDamageTaken(t *testing.T) {
+	hit := func(source, target, skill string, value int) *LogEntry {
+		entry := skillUseAt(0, source, skill)
+		entry.Target, entry.Value = target, value
+		return entry
+	}
+	enc := encounterOf(
+		&LogEntry{etype: Heal, Source: "Huya", Target: "Starlaf", Value: 50},
+		hit("Burkhad", "Starlaf", "Fire Pool", 300),
+		hit("Burkhad", "Starlaf", "Fire Pool", 200),
+		hit("Burkhad", "Starlaf", "Cleave", 100),
+		hit("Burkhad", "Huya", "Cleave", 400),
+		// players hitting each other and avoided hits are not damage taken
+		hit("Starlaf", "Huya", "Fire Pool", 100),
+		hit("Burkhad", "Huya", "Fire Pool", 0),
+	)
+	taken := damageTaken(enc, []string{"Fire Pool"})
+	if len(taken) != 2 || taken[0].Player != "Starlaf" {
+		t.Fatalf("got %+v, want Starlaf, the most avoidable, first", taken)
+	}
+	if d := taken[0]; d.Avoidable != 500 || d.Unavoidable != 100 || d.BySkill["Fire Pool"] != 500 {
+		t.Errorf("got %+v for Starlaf, want 500 avoidable and 100 unavoidable", d)
+	}
+	if d := taken[1]; d.Avoidable != 0 || d.Unavoidable != 400 {
+		t.Errorf("got %+v for Huya, want 400 unavoidable", d)
+	}
+}
+
+func TestDamageTakenSkills(t *testing.T) {
+	d := DamageTaken{BySkill: map[string]int{"Cleave": 100, "Fire Pool": 500, "Adds": 100, "Breath": 300}}
+	if got := d.skills(); !slices.Equal(got, []string{"Fire Pool", "Breath", "Adds", "Cleave"}) {
+		t.Errorf("got %v, want the most damage first and ties by name", got)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(`{"avoidable_skills": ["Fire Pool"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil || !slices.Equal(cfg.AvoidableSkills, []string{"Fire Pool"}) {
+		t.Errorf("got %+v, %v, want the avoidable skills", cfg, err)
+	}
+	if cfg, err := loadConfig(filepath.Join(dir, "missing.json")); err != nil || cfg.AvoidableSkills != nil {
+		t.Errorf("got %+v, %v for a missing file, want an empty config", cfg, err)
+	}
+	if err := os.WriteFile(path, []byte(`{`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(path); err == nil {
+		t.Errorf("got no error for a broken config")
+	}
+}
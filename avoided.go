@@ -0,0 +1,30 @@
+package main
+
+import "sort"
+
+// estimateAvoidedValues sets AvoidedValue on avoided damage entries. The log
+// only has what got through, so the full hit is taken to be the median of
+// the same source's unavoided, non-critical hits with the skill.
+func estimateAvoidedValues(entries []*LogEntry) {
+	key := func(e *LogEntry) [2]string { return [2]string{entityName(e.Source), e.Skill} }
+	values := map[[2]string][]int{}
+	for _, entry := range entries {
+		if entry.etype != DmgDealt || entry.Avoided != UnknownAvoid || entry.Crit || entry.Dev || entry.Value <= 0 {
+			continue
+		}
+		values[key(entry)] = append(values[key(entry)], entry.Value)
+	}
+	medians := map[[2]string]int{}
+	for k, v := range values {
+		sort.Ints(v)
+		medians[k] = v[len(v)/2]
+	}
+	for _, entry := range entries {
+		if entry.etype != DmgDealt || entry.Avoided == UnknownAvoid || entry.Avoided == Missed {
+			continue
+		}
+		if median, ok := medians[key(entry)]; ok && median > entry.Value {
+			entry.AvoidedValue = median - entry.Value
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed data/baselines.json
+var baselinesJSON []byte
+
+const (
+	// fewest parses a baseline is built from
+	baselineMinSamples = 20
+	// quantiles of a baseline, every 5 percent
+	baselineSteps = 20
+	// how long the share server reuses the baselines it built
+	baselineCacheTTL = time.Hour
+)
+
+func init() {
+	commands["baselines"] = runBaselines
+}
+
+// BaselineSet are the DPS and HPS distributions players are ranked against,
+// built from a share server's public boss kills. One ships with the program
+// and baselines update replaces it.
+type BaselineSet struct {
+	// Source is where the set was built, empty for the shipped one.
+	Source    string     `json:"source"`
+	Built     time.Time  `json:"built"`
+	Baselines []Baseline `json:"baselines"`
+	// SkillHits are the usual normal hits of class skills, see gearHints.
+	SkillHits []SkillBaseline `json:"skill_hits,omitempty"`
+}
+
+// Baseline is the distribution of a metric, "dps" or "hps", of a class on a
+// boss as its quantiles every 5 percent. Class "" is every class.
+type Baseline struct {
+	Boss      string    `json:"boss"`
+	Class     string    `json:"class,omitempty"`
+	Metric    string    `json:"metric"`
+	Samples   int       `json:"samples"`
+	Quantiles []float64 `json:"quantiles"`
+}
+
+// percentile is the share of the baseline's parses below v, 0 to 100.
+func (b Baseline) percentile(v float64) int {
+	q := b.Quantiles
+	n := len(q) - 1
+	if n < 1 || v < q[0] {
+		return 0
+	}
+	if v >= q[n] {
+		return 100
+	}
+	i := sort.Search(n, func(i int) bool { return q[i+1] > v })
+	frac := (v - q[i]) / (q[i+1] - q[i])
+	return int((float64(i) + frac) * 100 / float64(n))
+}
+
+// quantiles returns baselineSteps+1 evenly spaced quantiles of values.
+func quantiles(values []float64) []float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	q := make([]float64, baselineSteps+1)
+	for k := range q {
+		pos := float64(k) * float64(len(sorted)-1) / baselineSteps
+		lo := int(pos)
+		hi := min(lo+1, len(sorted)-1)
+		q[k] = math.Round(sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo)))
+	}
+	return q
+}
+
+// lookup finds the baseline of a class on a boss, falling back to the one
+// of every class.
+func (s *BaselineSet) lookup(boss, class, metric string) (Baseline, bool) {
+	var fallback *Baseline
+	for i, b := range s.Baselines {
+		if b.Boss != boss || b.Metric != metric {
+			continue
+		}
+		if b.Class == class {
+			return b, true
+		}
+		if b.Class == "" {
+			fallback = &s.Baselines[i]
+		}
+	}
+	if fallback == nil {
+		return Baseline{}, false
+	}
+	return *fallback, true
+}
+
+// playerMetric is what a player is ranked by: HPS for healers, whose
+// healing outweighs their damage, DPS for everyone else, with its total.
+func playerMetric(s *ActorStats) (metric string, total int) {
+	if s.Healing > s.Damage {
+		return "hps", s.Healing
+	}
+	return "dps", s.Damage
+}
+
+// buildBaselines builds the baselines of boss kills long enough to count as
+// parses, for each class and for every class, and the skill baselines.
+// Distributions of fewer than baselineMinSamples parses are left out.
+func buildBaselines(records []StoredEncounter, source string) BaselineSet {
+	type key struct{ boss, class, metric string }
+	values := map[key][]float64{}
+	for _, record := range records {
+		if record.Boss == "" || !bossKilled(record) || record.Duration < digestMinParse {
+			continue
+		}
+		for _, s := range record.Stats {
+			metric, total := playerMetric(s)
+			if s.Kind != Player || total == 0 {
+				continue
+			}
+			v := float64(total) / record.Duration.Seconds()
+			values[key{record.Boss, "", metric}] = append(values[key{record.Boss, "", metric}], v)
+			if class := record.Classes[s.Actor]; class != "" {
+				values[key{record.Boss, class, metric}] = append(values[key{record.Boss, class, metric}], v)
+			}
+		}
+	}
+	set := BaselineSet{Source: source, Built: time.Now().UTC(), Baselines: []Baseline{}}
+	for k, v := range values {
+		if len(v) >= baselineMinSamples {
+			set.Baselines = append(set.Baselines, Baseline{Boss: k.boss, Class: k.class, Metric: k.metric, Samples: len(v), Quantiles: quantiles(v)})
+		}
+	}
+	sort.Slice(set.Baselines, func(i, j int) bool {
+		a, b := set.Baselines[i], set.Baselines[j]
+		if a.Boss != b.Boss {
+			return a.Boss < b.Boss
+		}
+		if a.Class != b.Class {
+			return a.Class < b.Class
+		}
+		return a.Metric < b.Metric
+	})
+	set.SkillHits = buildSkillBaselines(records)
+	return set
+}
+
+// baselinesPath is the file of config.Baselines, else baselines.json in
+// the user's config directory.
+func baselinesPath() string {
+	if config.Baselines != "" {
+		return config.Baselines
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "SharedCombatGraphs", "baselines.json")
+}
+
+// readBaselines reads the baselines at path, or the shipped ones when there
+// is no such file.
+func readBaselines(path string) (*BaselineSet, error) {
+	data, err := os.ReadFile(path)
+	if path == "" || errors.Is(err, fs.ErrNotExist) {
+		data, err = baselinesJSON, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	set := &BaselineSet{}
+	if err := json.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("reading baselines %v: %w", path, err)
+	}
+	return set, nil
+}
+
+var (
+	baselinesOnce sync.Once
+	baselinesSet  *BaselineSet
+)
+
+// currentBaselines are the baselines reports rank against, read once. A
+// broken file is logged and ranks nothing.
+func currentBaselines() *BaselineSet {
+	baselinesOnce.Do(func() {
+		set, err := readBaselines(baselinesPath())
+		if err != nil {
+			slog.Warn("baselines unusable, run baselines update", "err", err)
+			set = &BaselineSet{}
+		}
+		baselinesSet = set
+	})
+	return baselinesSet
+}
+
+// PercentileRank is a player's result against the baseline.
+type PercentileRank struct {
+	Metric     string
+	Value      float64
+	Percentile int
+	Samples    int
+}
+
+// Color is the color log sites give the percentile.
+func (r PercentileRank) Color() string {
+	switch {
+	case r.Percentile >= 100:
+		return "#e5cc80"
+	case r.Percentile >= 99:
+		return "#e268a8"
+	case r.Percentile >= 95:
+		return "#ff8000"
+	case r.Percentile >= 75:
+		return "#a335ee"
+	case r.Percentile >= 50:
+		return "#0070ff"
+	case r.Percentile >= 25:
+		return "#1eff00"
+	}
+	return "#666666"
+}
+
+// encounterRanks ranks the players of a boss kill against the baselines,
+// keyed by actor. Wipes and trash have no ranks.
+func encounterRanks(enc *Encounter, stats []*ActorStats) map[string]*PercentileRank {
+	if !encounterKilled(enc) {
+		return nil
+	}
+	boss, set := encounterBoss(enc), currentBaselines()
+	classes := playerClasses(enc)
+	ranks := map[string]*PercentileRank{}
+	for _, s := range stats {
+		metric, total := playerMetric(s)
+		if s.Kind != Player || total == 0 {
+			continue
+		}
+		b, ok := set.lookup(boss, classes[s.Actor], metric)
+		if !ok {
+			continue
+		}
+		v := perSecond(total, enc)
+		ranks[s.Actor] = &PercentileRank{Metric: metric, Value: v, Percentile: b.percentile(v), Samples: b.Samples}
+	}
+	return ranks
+}
+
+// baselineCache keeps the share server's baselines for baselineCacheTTL,
+// as building them reads every record.
+type baselineCache struct {
+	mu  sync.Mutex
+	set *BaselineSet
+}
+
+// getBaselines serves baselines of the public boss kills, for baselines
+// update.
+func (s *shareServer) getBaselines(w http.ResponseWriter, r *http.Request) {
+	s.baselines.mu.Lock()
+	defer s.baselines.mu.Unlock()
+	if s.baselines.set == nil || time.Since(s.baselines.set.Built) > baselineCacheTTL {
+		all, err := s.store.ListEncounters()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		public := []StoredEncounter{}
+		for _, record := range all {
+			if record.Guild == "" {
+				public = append(public, record)
+			}
+		}
+		set := buildBaselines(public, r.Host)
+		s.baselines.set = &set
+	}
+	writeJSON(w, s.baselines.set)
+}
+
+// downloadBaselines fetches the baselines of the share server.
+func downloadBaselines(share ShareConfig) (*BaselineSet, error) {
+	client := &http.Client{Timeout: uploadTimeout}
+	resp, err := client.Get(strings.TrimSuffix(share.URL, "/") + "/baselines")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%v: %v", resp.Status, strings.TrimSpace(string(body)))
+	}
+	set := &BaselineSet{}
+	if err := json.NewDecoder(resp.Body).Decode(set); err != nil {
+		return nil, fmt.Errorf("reading answer: %w", err)
+	}
+	return set, nil
+}
+
+func writeBaselines(path string, set *BaselineSet) error {
+	if path == "" {
+		return fmt.Errorf("no config directory on this system, set baselines in the config")
+	}
+	data, err := json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// printBaselines lists a set, one baseline per line.
+func printBaselines(set *BaselineSet) {
+	switch {
+	case len(set.Baselines)+len(set.SkillHits) == 0 && set.Source == "":
+		fmt.Println("no baselines, run baselines update")
+		return
+	case len(set.Baselines)+len(set.SkillHits) == 0:
+		fmt.Printf("no boss of %v has %d parses yet\n", set.Source, baselineMinSamples)
+		return
+	}
+	fmt.Printf("%d baselines from %v, built %v\n", len(set.Baselines), set.Source, set.Built.Format(time.DateOnly))
+	for _, b := range set.Baselines {
+		class := b.Class
+		if class == "" {
+			class = "every class"
+		}
+		q := b.Quantiles
+		fmt.Printf("  %-24v %-14v %v  median %-8v 75%% %-8v 95%% %-8v (%d parses)\n", b.Boss, class, b.Metric,
+			shortNumber(q[baselineSteps/2]), shortNumber(q[baselineSteps*3/4]), shortNumber(q[baselineSteps*19/20]), b.Samples)
+	}
+	if len(set.SkillHits) > 0 {
+		fmt.Println("normal hits of class skills:")
+	}
+	for _, b := range set.SkillHits {
+		fmt.Printf("  %-14v %-24v median %-8v (%d players)\n", b.Class, b.Skill, shortNumber(b.Median), b.Samples)
+	}
+}
+
+// runBaselines shows, updates or builds the baselines reports rank players
+// against: update downloads them from the share server, build makes them
+// from the local store.
+func runBaselines(args []string) error {
+	if len(args) == 0 || (args[0] != "show" && args[0] != "update" && args[0] != "build") {
+		return fmt.Errorf("usage: baselines <build|show|update> [flags]")
+	}
+	fs := flag.NewFlagSet("baselines "+args[0], flag.ExitOnError)
+	setup := commonFlags(fs)
+	open := storeFlags(fs)
+	out := fs.String("o", "", "file to write (default the baselines file of the config)")
+	fs.Parse(args[1:])
+	if err := setup(); err != nil {
+		return err
+	}
+	path := baselinesPath()
+	if *out != "" {
+		path = *out
+	}
+	switch args[0] {
+	case "show":
+		set, err := readBaselines(path)
+		if err != nil {
+			return err
+		}
+		printBaselines(set)
+		return nil
+	case "update":
+		if config.Share.URL == "" {
+			return fmt.Errorf("no share server, set share.url in the config or run init")
+		}
+		set, err := downloadBaselines(config.Share)
+		if err != nil {
+			return fmt.Errorf("downloading baselines: %w", err)
+		}
+		if err := writeBaselines(path, set); err != nil {
+			return err
+		}
+		fmt.Printf("saved %d baselines to %v\n", len(set.Baselines), path)
+		return nil
+	}
+	store, err := open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	records, err := store.ListEncounters()
+	if err != nil {
+		return err
+	}
+	set := buildBaselines(records, "local store")
+	if err := writeBaselines(path, &set); err != nil {
+		return err
+	}
+	fmt.Printf("saved %d baselines of at least %d parses to %v\n", len(set.Baselines), baselineMinSamples, path)
+	return nil
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// batchStateFile remembers the finished files of a batch in its
+	// directory
+	batchStateFile = ".scg-batch.json"
+)
+
+func init() {
+	commands["batch"] = runBatch
+}
+
+// batchState is what a batch has done, so an interrupted one resumes where
+// it stopped. Done is keyed by the path relative to the batch directory.
+type batchState struct {
+	Done map[string]batchFile `json:"done"`
+}
+
+// batchFile is a finished file. A file that changed since is done again.
+type batchFile struct {
+	Size       int64     `json:"size"`
+	Modified   time.Time `json:"modified"`
+	Encounters int       `json:"encounters"`
+}
+
+func readBatchState(path string) (*batchState, error) {
+	state := &batchState{Done: map[string]batchFile{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("reading batch state %v: %w", path, err)
+	}
+	return state, nil
+}
+
+// save writes the state through a temporary file, like the file store's
+// index, so stopping a batch never loses it.
+func (s *batchState) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// batchJob is a log of the batch with its profile.
+type batchJob struct {
+	path    string
+	rel     string
+	info    fs.FileInfo
+	profile LogProfile
+	err     error
+}
+
+// batchResult is the outcome of one job.
+type batchResult struct {
+	job        batchJob
+	result     *ParseResult
+	encounters int
+	took       time.Duration
+	err        error
+}
+
+// profileGate lets logs parse in parallel while they share a profile. The
+// parser reads the patterns, layouts and locale apply selects from globals,
+// so a log with another profile waits until the running ones are done.
+type profileGate struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	key     string
+	running int
+}
+
+func newProfileGate() *profileGate {
+	g := &profileGate{}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+func (g *profileGate) enter(profile LogProfile) error {
+	key := profile.key()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for g.running > 0 && g.key != key {
+		g.cond.Wait()
+	}
+	if g.key != key {
+		if err := profile.apply(); err != nil {
+			return fmt.Errorf("selecting patterns: %w", err)
+		}
+		g.key = key
+	}
+	g.running++
+	return nil
+}
+
+// hold enters with whatever profile is applied, for parsing that has no
+// profile of its own, like stored lines, or that must not see it change,
+// like sniffing.
+func (g *profileGate) hold() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.running++
+}
+
+func (g *profileGate) leave() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.running--
+	if g.running == 0 {
+		g.cond.Broadcast()
+	}
+}
+
+// batchJobs walks dir for logs matching pattern that are not done yet,
+// grouped by profile so the gate switches patterns rarely.
+func batchJobs(dir, pattern string, state *batchState) ([]batchJob, int, error) {
+	jobs := []batchJob{}
+	skipped := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if ok, err := filepath.Match(pattern, d.Name()); err != nil || !ok {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if done, ok := state.Done[rel]; ok && done.Size == info.Size() && done.Modified.Equal(info.ModTime()) {
+			skipped++
+			return nil
+		}
+		job := batchJob{path: path, rel: rel, info: info}
+		job.profile, job.err = sniffLog(path)
+		jobs = append(jobs, job)
+		return nil
+	})
+	sort.SliceStable(jobs, func(i, j int) bool { return jobs[i].profile.key() < jobs[j].profile.key() })
+	return jobs, skipped, err
+}
+
+// runBatchJob parses a log and stores its encounters.
+func runBatchJob(store Store, gate *profileGate, job batchJob) batchResult {
+	res := batchResult{job: job, err: job.err}
+	if res.err != nil {
+		return res
+	}
+	start := time.Now()
+	if res.err = gate.enter(job.profile); res.err != nil {
+		return res
+	}
+	// storing records the pattern version, so it stays behind the gate
+	defer gate.leave()
+	res.result, res.err = parseCached(job.path, job.profile, ParserOptions{})
+	if res.err != nil {
+		return res
+	}
+	res.err = storeBatch(store, func() error {
+		for _, enc := range segmentEncounters(res.result.Entries) {
+			if _, err := store.PutEntries(job.path, enc); err != nil {
+				return err
+			}
+			res.encounters++
+		}
+		return nil
+	})
+	if res.err != nil {
+		return res
+	}
+	res.took = time.Since(start)
+	return res
+}
+
+// printBatchResult is the summary line of a file.
+func printBatchResult(res batchResult) {
+	if res.err != nil {
+		fmt.Printf("%v: failed: %v\n", res.job.rel, res.err)
+		return
+	}
+	fmt.Printf("%v: %d encounters, %d lines, %.2f%% unparsed, %v", res.job.rel, res.encounters, res.result.Lines,
+		100*res.result.ErrorRate(), res.took.Round(time.Millisecond))
+	if code, reason := parseOutcome(res.result, 1); code != exitClean {
+		fmt.Printf(" (%v)", reason)
+	}
+	fmt.Println()
+}
+
+// runBatch imports a directory tree of old logs into the store: the logs
+// parse in parallel and every finished file is remembered, so running it
+// again after a stop or with new logs only does what is left.
+func runBatch(args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	setup := commonFlags(fs)
+	open := storeFlags(fs)
+	startProfile := profileFlags(fs)
+	workers := fs.Int("j", runtime.NumCPU(), "logs to parse at once")
+	pattern := fs.String("pattern", "*.txt", "file names of the logs")
+	statePath := fs.String("state", "", "file remembering finished logs (default "+batchStateFile+" in the directory)")
+	restart := fs.Bool("restart", false, "do every log again, not only new and changed ones")
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: batch [flags] directory")
+	}
+	if _, err := filepath.Match(*pattern, ""); err != nil {
+		return fmt.Errorf("pattern %q: %w", *pattern, err)
+	}
+	dir := fs.Arg(0)
+	if *statePath == "" {
+		*statePath = filepath.Join(dir, batchStateFile)
+	}
+	state := &batchState{Done: map[string]batchFile{}}
+	if !*restart {
+		var err error
+		if state, err = readBatchState(*statePath); err != nil {
+			return err
+		}
+	}
+	store, err := open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	stopProfile, err := startProfile()
+	if err != nil {
+		return err
+	}
+	defer stopProfile()
+
+	jobs, skipped, err := batchJobs(dir, *pattern, state)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d logs to do, %d done before\n", len(jobs), skipped)
+	queue := make(chan batchJob)
+	results := make(chan batchResult)
+	gate := newProfileGate()
+	wg := sync.WaitGroup{}
+	for range max(*workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				results <- runBatchJob(store, gate, job)
+			}
+		}()
+	}
+	go func() {
+		for _, job := range jobs {
+			queue <- job
+		}
+		close(queue)
+		wg.Wait()
+		close(results)
+	}()
+
+	start := time.Now()
+	failed, encounters := 0, 0
+	for res := range results {
+		printBatchResult(res)
+		if res.err != nil {
+			failed++
+			continue
+		}
+		encounters += res.encounters
+		state.Done[res.job.rel] = batchFile{Size: res.job.info.Size(), Modified: res.job.info.ModTime(), Encounters: res.encounters}
+		if err := state.save(*statePath); err != nil {
+			return fmt.Errorf("saving batch state: %w", err)
+		}
+	}
+	fmt.Printf("stored %d encounters of %d logs in %v\n", encounters, len(jobs)-failed, time.Since(start).Round(time.Second))
+	if failed > 0 {
+		return fmt.Errorf("%d of %d logs failed, run batch again to retry them", failed, len(jobs))
+	}
+	return nil
+}
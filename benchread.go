@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+func init() {
+	commands["bench-read"] = runBenchRead
+}
+
+// writeSyntheticLog repeats the lines of a sample log into path until it
+// holds size bytes.
+func writeSyntheticLog(sample, path string, size int64) error {
+	data, err := os.ReadFile(sample)
+	if err != nil {
+		return err
+	}
+	lines := strings.SplitAfter(strings.TrimRight(string(data), "\n")+"\n", "\n")
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriterSize(file, 1<<20)
+	written := int64(0)
+	for written < size {
+		for _, line := range lines {
+			n, err := w.WriteString(line)
+			if err != nil {
+				file.Close()
+				return err
+			}
+			written += int64(n)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// runBenchRead times the read modes over a synthetic log built from a
+// sample, to check the sizes chooseReadMode switches modes at on a machine.
+// Entries are counted, not kept, so logs larger than memory can be used.
+func runBenchRead(args []string) error {
+	fs := flag.NewFlagSet("bench-read", flag.ExitOnError)
+	setup := commonFlags(fs)
+	sizeMB := fs.Int64("size", 2048, "size of the synthetic log in MB")
+	out := fs.String("o", "", "write the synthetic log here and keep it (default a temporary file)")
+	modes := fs.String("modes", "bufio,mmap,parallel", "comma separated read modes to time")
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	path := *out
+	if path == "" {
+		tmp, err := os.CreateTemp("", "bench-read-*.txt")
+		if err != nil {
+			return err
+		}
+		tmp.Close()
+		path = tmp.Name()
+		defer os.Remove(path)
+	}
+	size := *sizeMB << 20
+	fmt.Printf("writing a %d MB log to %v\n", *sizeMB, path)
+	if err := writeSyntheticLog(inputPath(fs), path, size); err != nil {
+		return err
+	}
+	profile, err := sniffLog(path)
+	if err != nil {
+		return err
+	}
+	if err := profile.apply(); err != nil {
+		return err
+	}
+	// start every mode from the page cache, not only the ones after the first
+	if file, err := os.Open(path); err == nil {
+		io.Copy(io.Discard, file)
+		file.Close()
+	}
+
+	fmt.Printf("%d CPUs, auto reads this log with %v\n", runtime.NumCPU(), chooseReadMode("auto", size, true))
+	for _, mode := range strings.Split(*modes, ",") {
+		readMode = strings.TrimSpace(mode)
+		if chooseReadMode(readMode, size, true) != readMode {
+			return fmt.Errorf("unknown read mode %q, want one of %v", readMode, readModes[1:])
+		}
+		runtime.GC()
+		before := runtime.MemStats{}
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		result, err := readAndParse(path, ParserOptions{Discard: true})
+		if err != nil {
+			return fmt.Errorf("%v: %w", readMode, err)
+		}
+		took := time.Since(start)
+		after := runtime.MemStats{}
+		runtime.ReadMemStats(&after)
+		fmt.Printf("%-9v %8.2fs %8.1f MB/s %10.0f lines/s %8d MB allocated\n", readMode, took.Seconds(),
+			float64(size>>20)/took.Seconds(), float64(result.Lines)/took.Seconds(), (after.TotalAlloc-before.TotalAlloc)>>20)
+	}
+	return nil
+}
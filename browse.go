@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	commands["browse"] = runBrowse
+}
+
+// colors of the parsed fields in the raw line view
+const (
+	browseSource = "\033[36m"
+	browseTarget = "\033[33m"
+	browseSkill  = "\033[32m"
+	browseValue  = "\033[35m"
+)
+
+// browseView is one screen of the log browser: a list of rows with a
+// cursor, which may open another view for the selected row.
+type browseView struct {
+	title string
+	rows  []string
+	// styled are the rows as drawn, rows are what the search matches
+	styled []string
+	// open returns the view behind a row, nil when rows do not drill down
+	open func(i int) *browseView
+	// raw returns the log lines behind the whole view
+	raw    func() *browseView
+	filter string
+	shown  []int // rows matching the filter
+	cursor int   // index into shown
+	top    int
+}
+
+func (v *browseView) applyFilter(filter string) {
+	v.filter = filter
+	v.shown = v.shown[:0]
+	needle := strings.ToLower(filter)
+	for i, row := range v.rows {
+		if needle == "" || strings.Contains(strings.ToLower(row), needle) {
+			v.shown = append(v.shown, i)
+		}
+	}
+	v.cursor, v.top = 0, 0
+}
+
+func (v *browseView) move(delta int) {
+	v.cursor = max(0, min(len(v.shown)-1, v.cursor+delta))
+}
+
+// draw clears the terminal and draws the rows around the cursor.
+func (v *browseView) draw(w io.Writer, height, width int, prompt string) {
+	fmt.Fprint(w, chartTheme.ansi(), "\033[H\033[2J")
+	title := v.title
+	if v.filter != "" {
+		title += fmt.Sprintf("  [/%v: %d of %d]", v.filter, len(v.shown), len(v.rows))
+	}
+	fmt.Fprintf(w, "\033[1m%v\033[22m\r\n", title)
+	visible := max(1, height-3)
+	if v.cursor < v.top {
+		v.top = v.cursor
+	}
+	if v.cursor >= v.top+visible {
+		v.top = v.cursor - visible + 1
+	}
+	for n := v.top; n < len(v.shown) && n < v.top+visible; n++ {
+		i := v.shown[n]
+		row := v.rows[i]
+		if v.styled != nil {
+			row = v.styled[i]
+		} else if len(row) > width {
+			row = row[:width]
+		}
+		if n == v.cursor {
+			fmt.Fprintf(w, "\033[7m%v\033[27m\r\n", row)
+		} else {
+			fmt.Fprintf(w, "%v\r\n", row)
+		}
+	}
+	for n := len(v.shown) - v.top; n < visible; n++ {
+		fmt.Fprint(w, "\r\n")
+	}
+	if prompt != "" {
+		fmt.Fprint(w, prompt)
+		return
+	}
+	fmt.Fprint(w, "j/k move  enter open  b back  r raw lines  / search  q quit")
+}
+
+// encounterView lists the encounters of a log.
+func encounterView(encounters []*Encounter) *browseView {
+	v := &browseView{title: "encounters"}
+	for i, enc := range encounters {
+		boss := encounterBoss(enc)
+		if boss == "" {
+			boss = "trash"
+		}
+		v.rows = append(v.rows, fmt.Sprintf("%3d  %v  %-8v %-28v %6d entries",
+			i+1, enc.Start.Format("15:04:05"), enc.Duration(), boss, len(enc.Entries)))
+	}
+	v.open = func(i int) *browseView { return actorView(encounters[i], i+1) }
+	return v
+}
+
+// actorView lists the actors of an encounter by damage.
+func actorView(enc *Encounter, n int) *browseView {
+	v := &browseView{title: fmt.Sprintf("encounter %d: %v (%v)", n, enc.Start.Format("15:04:05"), enc.Duration())}
+	stats := []*ActorStats{}
+	for _, s := range actorStats(enc) {
+		if s.Damage+s.Healing+s.DamageTaken > 0 || s.Deaths > 0 {
+			stats = append(stats, s)
+		}
+	}
+	for _, s := range stats {
+		v.rows = append(v.rows, fmt.Sprintf("%-28v %-6v dmg %-10v heal %-10v taken %-10v deaths %d",
+			s.Actor, s.Kind, s.Damage, s.Healing, s.DamageTaken, s.Deaths))
+	}
+	v.open = func(i int) *browseView { return skillView(enc, stats[i].Actor) }
+	v.raw = func() *browseView { return lineView(v.title, enc.Entries) }
+	return v
+}
+
+// skillView lists what an actor did, per event type and skill.
+func skillView(enc *Encounter, actor string) *browseView {
+	type skillKey struct {
+		etype EventType
+		skill string
+	}
+	groups := map[skillKey][]*LogEntry{}
+	involved := []*LogEntry{}
+	for _, entry := range enc.Entries {
+		if entry.SourceID == actor || entry.TargetID == actor {
+			involved = append(involved, entry)
+		}
+		if entry.SourceID != actor {
+			continue
+		}
+		key := skillKey{entry.etype, entry.Skill}
+		groups[key] = append(groups[key], entry)
+	}
+	total := func(entries []*LogEntry) int {
+		sum := 0
+		for _, entry := range entries {
+			sum += entry.Value
+		}
+		return sum
+	}
+	keys := make([]skillKey, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ti, tj := total(groups[keys[i]]), total(groups[keys[j]])
+		if ti != tj {
+			return ti > tj
+		}
+		return len(groups[keys[i]]) > len(groups[keys[j]])
+	})
+	v := &browseView{title: actor}
+	for _, key := range keys {
+		skill := key.skill
+		if skill == "" {
+			skill = "(no skill)"
+		}
+		v.rows = append(v.rows, fmt.Sprintf("%-36v %-16v %5d events %12v", skill, key.etype, len(groups[key]), total(groups[key])))
+	}
+	v.open = func(i int) *browseView {
+		return lineView(fmt.Sprintf("%v: %v", actor, keys[i].skill), groups[keys[i]])
+	}
+	v.raw = func() *browseView { return lineView(actor, involved) }
+	return v
+}
+
+// lineView shows raw log lines with their parsed fields highlighted.
+func lineView(title string, entries []*LogEntry) *browseView {
+	v := &browseView{title: title}
+	for _, entry := range entries {
+		if entry.RawMessage == "" {
+			continue
+		}
+		v.rows = append(v.rows, entry.RawMessage)
+		v.styled = append(v.styled, highlightFields(entry))
+	}
+	return v
+}
+
+// highlightFields colors the source, target, skill and value of an entry
+// where they appear in its raw line, so parser mistakes stand out.
+func highlightFields(entry *LogEntry) string {
+	line := entry.RawMessage
+	type span struct {
+		from, to int
+		color    string
+	}
+	spans := []span{}
+	taken := func(from, to int) bool {
+		for _, s := range spans {
+			if from < s.to && s.from < to {
+				return true
+			}
+		}
+		return false
+	}
+	mark := func(text, color string) {
+		if text == "" || text == selfplaceholder {
+			return
+		}
+		for offset := 0; ; {
+			i := strings.Index(line[offset:], text)
+			if i < 0 {
+				return
+			}
+			from, to := offset+i, offset+i+len(text)
+			if !taken(from, to) {
+				spans = append(spans, span{from, to, color})
+				return
+			}
+			offset = to
+		}
+	}
+	mark(entry.Skill, browseSkill)
+	mark(entry.Source, browseSource)
+	mark(entry.Target, browseTarget)
+	if entry.Value > 0 {
+		mark(groupDigits(entry.Value), browseValue)
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].from < spans[j].from })
+	var b strings.Builder
+	last := 0
+	for _, s := range spans {
+		b.WriteString(line[last:s.from])
+		b.WriteString(s.color + line[s.from:s.to] + chartTheme.ansi())
+		last = s.to
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
+
+// stty runs stty on the terminal, returning its output.
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// terminalSize asks stty for the size of the terminal, falling back to the
+// LINES and COLUMNS variables and then to 24x80.
+func terminalSize() (height, width int) {
+	height, width = 24, 80
+	if size, err := stty("size"); err == nil {
+		if rows, cols, ok := strings.Cut(size, " "); ok {
+			height, _ = strconv.Atoi(rows)
+			width, _ = strconv.Atoi(cols)
+			return height, width
+		}
+	}
+	if n, err := strconv.Atoi(os.Getenv("LINES")); err == nil {
+		height = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil {
+		width = n
+	}
+	return height, width
+}
+
+// readKey reads one key press, turning the escape sequences of the arrow
+// and page keys into the letters that do the same.
+func readKey(in *bufio.Reader) (byte, error) {
+	key, err := in.ReadByte()
+	if err != nil || key != '\033' {
+		return key, err
+	}
+	if in.Buffered() == 0 {
+		return key, nil
+	}
+	if next, _ := in.ReadByte(); next != '[' {
+		return key, nil
+	}
+	code, err := in.ReadByte()
+	switch code {
+	case 'A':
+		return 'k', err
+	case 'B':
+		return 'j', err
+	case 'C':
+		return '\r', err
+	case 'D':
+		return 'b', err
+	case '5', '6':
+		in.ReadByte() // the trailing ~
+		if code == '5' {
+			return 'u', err
+		}
+		return 'd', err
+	}
+	return key, err
+}
+
+// readSearch reads a search term up to enter, drawing it as it is typed.
+// Escape cancels the search and keeps the current filter.
+func readSearch(in *bufio.Reader, view *browseView, height, width int) (string, bool) {
+	term := []byte{}
+	for {
+		view.draw(os.Stdout, height, width, "/"+string(term))
+		key, err := in.ReadByte()
+		switch {
+		case err != nil || key == '\033':
+			return "", false
+		case key == '\r' || key == '\n':
+			return string(term), true
+		case key == 127 || key == '\b':
+			if len(term) > 0 {
+				term = term[:len(term)-1]
+			}
+		case key >= ' ':
+			term = append(term, key)
+		}
+	}
+}
+
+// runBrowse lets you page through a finished log: encounters, the actors of
+// an encounter, an actor's skills and the raw lines behind them. Keys are
+// read one at a time where stty can switch the terminal to raw input, and
+// line by line followed by enter elsewhere.
+func runBrowse(args []string) error {
+	fs := flag.NewFlagSet("browse", flag.ExitOnError)
+	setup := commonFlags(fs)
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	result, err := parseFile(inputPath(fs), ParserOptions{})
+	if err != nil {
+		return err
+	}
+	encounters := segmentEncounters(result.Entries)
+	if len(encounters) == 0 {
+		return fmt.Errorf("no encounters in %v", inputPath(fs))
+	}
+
+	raw := false
+	if saved, err := stty("-g"); err == nil {
+		if _, err := stty("-icanon", "-echo", "min", "1"); err == nil {
+			raw = true
+			defer stty(saved)
+		}
+	}
+	defer fmt.Print("\033[0m\033[H\033[2J")
+
+	in := bufio.NewReader(os.Stdin)
+	stack := []*browseView{encounterView(encounters)}
+	stack[0].applyFilter("")
+	prev := byte('\n')
+	for {
+		view := stack[len(stack)-1]
+		height, width := terminalSize()
+		page := max(1, height-3)
+		view.draw(os.Stdout, height, width, "")
+		key, err := readKey(in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// without raw input every key comes with an enter, which only opens
+		// the selected row on an otherwise empty line
+		if !raw && key == '\n' && prev != '\n' {
+			prev = key
+			continue
+		}
+		prev = key
+		switch key {
+		case 'q':
+			return nil
+		case 'j':
+			view.move(1)
+		case 'k':
+			view.move(-1)
+		case 'd', ' ':
+			view.move(page)
+		case 'u':
+			view.move(-page)
+		case 'g':
+			view.move(-len(view.shown))
+		case 'G':
+			view.move(len(view.shown))
+		case '\r', '\n', 'l':
+			if view.open != nil && len(view.shown) > 0 {
+				next := view.open(view.shown[view.cursor])
+				next.applyFilter("")
+				stack = append(stack, next)
+			}
+		case 'r':
+			if view.raw != nil {
+				next := view.raw()
+				next.applyFilter("")
+				stack = append(stack, next)
+			}
+		case 'b', 'h', 127, '\b':
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case '/':
+			if term, ok := readSearch(in, view, height, width); ok {
+				view.applyFilter(term)
+			}
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// bundleFormat marks a file as an encounter bundle
+	bundleFormat = "SharedCombatGraphs bundle"
+	// bundleVersion is bumped when fields are renamed or removed; readers
+	// ignore fields they do not know
+	bundleVersion = 1
+)
+
+func init() {
+	commands["export-bundle"] = runExportBundle
+	commands["view-bundle"] = runViewBundle
+}
+
+// Bundle is a self-contained export of a log's encounters for sharing
+// without a server: what the encounter pages show, and optionally the raw
+// lines to parse it again.
+type Bundle struct {
+	Format         string            `json:"format"`
+	Version        int               `json:"version"`
+	Created        time.Time         `json:"created"`
+	File           string            `json:"file"`
+	ParserVersion  int               `json:"parser_version"`
+	PatternVersion string            `json:"pattern_version"`
+	Encounters     []BundleEncounter `json:"encounters"`
+}
+
+// BundleEncounter is one encounter of a bundle.
+type BundleEncounter struct {
+	EncounterPage
+	Label     string   `json:"label,omitempty"`
+	Character string   `json:"character,omitempty"`
+	Lines     []string `json:"lines,omitempty"`
+}
+
+// newBundle exports encounters, with their raw lines when raw is set.
+func newBundle(file string, encounters []*Encounter, raw bool) Bundle {
+	bundle := Bundle{
+		Format: bundleFormat, Version: bundleVersion, Created: time.Now(), File: filepath.Base(file),
+		ParserVersion: parserVersion, PatternVersion: patternVersion,
+	}
+	for _, enc := range encounters {
+		be := BundleEncounter{EncounterPage: encounterPage(encounterID(enc), enc), Label: enc.Label, Character: enc.Character}
+		if raw {
+			be.Lines = rawLines(enc)
+		}
+		bundle.Encounters = append(bundle.Encounters, be)
+	}
+	return bundle
+}
+
+// readBundle loads a bundle file.
+func readBundle(path string) (Bundle, error) {
+	bundle := Bundle{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return bundle, err
+	}
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return bundle, fmt.Errorf("reading bundle %v: %w", path, err)
+	}
+	if bundle.Format != bundleFormat {
+		return bundle, fmt.Errorf("%v is not a bundle", path)
+	}
+	if bundle.Version > bundleVersion {
+		return bundle, fmt.Errorf("%v is a version %d bundle, this build reads up to version %d", path, bundle.Version, bundleVersion)
+	}
+	return bundle, nil
+}
+
+// printBundle shows the meter and deaths of every encounter of a bundle.
+func printBundle(w io.Writer, bundle Bundle) {
+	fmt.Fprintf(w, "%v, exported %v (parser %d, patterns %v)\n", bundle.File, bundle.Created.Format(time.DateTime), bundle.ParserVersion, bundle.PatternVersion)
+	for i, be := range bundle.Encounters {
+		what := "trash"
+		if be.Boss != "" {
+			what = be.Boss
+		}
+		fmt.Fprintf(w, "\nencounter %d: %v at %v (%v)", i+1, what, be.Start.Format("15:04:05"), be.Duration)
+		if be.Label != "" {
+			fmt.Fprintf(w, " %v", be.Label)
+		}
+		fmt.Fprintln(w)
+		secs := be.Duration.Seconds()
+		for _, s := range be.Actors {
+			if s.Kind != Player && !meterAllActors {
+				continue
+			}
+			fmt.Fprintf(w, "  %-24v dmg %-10v (%8.0f/s) heal %-10v (%8.0f/s) taken %-10v deaths %v\n",
+				s.Actor, s.Damage, float64(s.Damage)/max(secs, 1), s.Healing, float64(s.Healing)/max(secs, 1), s.DamageTaken, s.Deaths)
+		}
+		for _, d := range be.Deaths {
+			fmt.Fprintf(w, "  %v died at +%v\n", d.Actor, d.At)
+		}
+	}
+}
+
+// bundleViewerHTML shows bundles in a browser without a server: it is
+// served at /bundle by serve, where bundles can be dropped on it, and
+// written by view-bundle -html with the bundle inside.
+var bundleViewerHTML = template.Must(template.New("bundle").Funcs(themeFuncs).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Encounter bundle</title>
+<style>
+body { background: {{theme.Background}}; color: {{theme.Foreground}}; font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { padding: 2px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+th { border-bottom: 1px solid {{theme.Muted}}; }
+#drop { border: 2px dashed {{theme.Muted}}; padding: 1em; margin-bottom: 1em; }
+#list a { cursor: pointer; text-decoration: underline; margin-right: 1em; }
+</style></head>
+<body>
+<div id="drop">Drop a bundle exported with export-bundle here, or <input type="file" id="pick" accept=".json"></div>
+<h1 id="file"></h1>
+<p id="list"></p>
+<div id="encounter"></div>
+<script>
+const embedded = {{.}};
+const damageColor = {{theme.Damage}}, healColor = {{theme.Heal}}, mutedColor = {{theme.Muted}};
+function el(parent, name, text) {
+  const e = document.createElement(name);
+  if (text !== undefined) e.textContent = text;
+  parent.appendChild(e);
+  return e;
+}
+function table(parent, head, rows) {
+  const t = el(parent, "table"), tr = el(t, "tr");
+  head.forEach(h => el(tr, "th", h));
+  rows.forEach(r => { const row = el(t, "tr"); r.forEach(v => el(row, "td", v)); });
+}
+function dur(ns) { return Math.round(ns / 1e9) + "s"; }
+function timeline(parent, series) {
+  const W = 960, H = 160, NS = "http://www.w3.org/2000/svg";
+  const svg = document.createElementNS(NS, "svg");
+  svg.setAttribute("width", W); svg.setAttribute("height", H);
+  parent.appendChild(svg);
+  const top = Math.max(1, ...series.flatMap(s => s.values));
+  series.forEach(s => {
+    const line = document.createElementNS(NS, "polyline");
+    line.setAttribute("points", s.values.map((v, i) => (i / Math.max(1, s.values.length - 1) * W) + "," + (H - v / top * H)).join(" "));
+    line.setAttribute("fill", "none"); line.setAttribute("stroke", s.color);
+    svg.appendChild(line);
+  });
+}
+function show(enc) {
+  const div = document.getElementById("encounter");
+  div.replaceChildren();
+  const secs = Math.max(1, enc.duration / 1e9);
+  el(div, "h2", (enc.boss || "Trash") + " at " + enc.start.slice(11, 19) + " (" + dur(enc.duration) + ")" + (enc.label ? " " + enc.label : ""));
+  table(div, ["Actor", "Kind", "Damage", "DPS", "Healing", "HPS", "Taken", "Deaths"],
+    enc.actors.map(a => [a.actor, a.kind, a.damage, Math.round(a.damage / secs), a.healing, Math.round(a.healing / secs), a.damage_taken, a.deaths]));
+  timeline(div, [{values: enc.damage, color: damageColor}, {values: enc.healing, color: healColor}, {values: enc.taken, color: mutedColor}]);
+  (enc.tabs || []).forEach(tab => {
+    el(div, "h3", tab.actor);
+    const rows = s => [s.skill, s.hits, Math.round(100 * s.crits / Math.max(1, s.hits)), s.total, s.max];
+    if (tab.damage && tab.damage.length) table(div, ["Damage skill", "Hits", "Crit %", "Total", "Max"], tab.damage.map(rows));
+    if (tab.healing && tab.healing.length) table(div, ["Healing skill", "Hits", "Crit %", "Total", "Max"], tab.healing.map(rows));
+  });
+  el(div, "h3", "Deaths");
+  if (!enc.deaths || !enc.deaths.length) el(div, "p", "Nobody died.");
+  (enc.deaths || []).forEach(d => {
+    el(div, "p", d.actor + " at +" + dur(d.at));
+    table(div, ["Time", "Source", "Skill", "Damage"], (d.hits || []).map(h => ["+" + dur(h.at), h.source, h.skill, h.value]));
+  });
+}
+function load(bundle) {
+  if (bundle.format !== "SharedCombatGraphs bundle") { alert("not a bundle"); return; }
+  document.getElementById("file").textContent = bundle.file;
+  const list = document.getElementById("list");
+  list.replaceChildren();
+  bundle.encounters.forEach((enc, i) => {
+    const a = el(list, "a", (i + 1) + ". " + (enc.boss || "Trash") + " " + enc.start.slice(11, 19));
+    a.onclick = () => show(enc);
+  });
+  if (bundle.encounters.length) show(bundle.encounters[0]);
+}
+function readFile(file) { file.text().then(text => load(JSON.parse(text))); }
+const drop = document.getElementById("drop");
+drop.ondragover = ev => ev.preventDefault();
+drop.ondrop = ev => { ev.preventDefault(); readFile(ev.dataTransfer.files[0]); };
+document.getElementById("pick").onchange = ev => readFile(ev.target.files[0]);
+if (embedded) load(embedded);
+</script>
+</body></html>
+`))
+
+// getBundleViewer serves the bundle viewer for dropped bundles.
+func (s *shareServer) getBundleViewer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	if err := bundleViewerHTML.Execute(w, nil); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// runExportBundle writes a log's encounters into one bundle file.
+func runExportBundle(args []string) error {
+	fs := flag.NewFlagSet("export-bundle", flag.ExitOnError)
+	setup := commonFlags(fs)
+	out := fs.String("o", "bundle.json", "output file")
+	raw := fs.Bool("raw", false, "include the raw log lines")
+	encounter := fs.Int("encounter", 0, "only this encounter (1-based)")
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	path := inputPath(fs)
+	result, err := parseFile(path, ParserOptions{})
+	if err != nil {
+		return err
+	}
+	encounters := segmentEncounters(result.Entries)
+	if *encounter > 0 {
+		if *encounter > len(encounters) {
+			return fmt.Errorf("there are only %d encounters", len(encounters))
+		}
+		encounters = encounters[*encounter-1 : *encounter]
+	}
+	data, err := json.Marshal(newBundle(path, encounters, *raw))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %d encounters to %v\n", len(encounters), *out)
+	return nil
+}
+
+// runViewBundle shows a bundle in the terminal, or writes it into a page
+// that opens in any browser.
+func runViewBundle(args []string) error {
+	fs := flag.NewFlagSet("view-bundle", flag.ExitOnError)
+	setup := commonFlags(fs)
+	html := fs.String("html", "", "write a page showing the bundle to this file instead")
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: view-bundle [flags] bundle.json")
+	}
+	bundle, err := readBundle(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *html == "" {
+		printBundle(os.Stdout, bundle)
+		return nil
+	}
+	file, err := os.Create(*html)
+	if err != nil {
+		return err
+	}
+	if err := bundleViewerHTML.Execute(file, bundle); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
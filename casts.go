@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// hits of one mob skill this close together are one cast
+	aoeWindow = time.Second
+)
+
+func init() {
+	analyses["casts"] = printCasts
+}
+
+// Cast is one use of a mob skill: every hit of it within aoeWindow of the
+// first, so an AoE that hits seven players is one cast with seven hits.
+type Cast struct {
+	Mob   string // entity ID
+	Skill string
+	Start time.Time
+	Hits  []*LogEntry
+}
+
+// Targets are the entity IDs the cast hit, in the order it hit them.
+func (c *Cast) Targets() []string {
+	seen := map[string]bool{}
+	targets := []string{}
+	for _, hit := range c.Hits {
+		if hit.TargetID != "" && !seen[hit.TargetID] {
+			seen[hit.TargetID] = true
+			targets = append(targets, hit.TargetID)
+		}
+	}
+	return targets
+}
+
+// Damage is the total damage of the cast's hits.
+func (c *Cast) Damage() int {
+	total := 0
+	for _, hit := range c.Hits {
+		if hit.etype == DmgDealt {
+			total += hit.Value
+		}
+	}
+	return total
+}
+
+// mobCasts groups the damage and debuffs mobs dealt with a skill into casts.
+func mobCasts(enc *Encounter) []*Cast {
+	casts := []*Cast{}
+	open := map[string]*Cast{} // mob and skill -> cast still collecting hits
+	for _, entry := range enc.Entries {
+		if entry.etype != DmgDealt && entry.etype != DebuffApplied {
+			continue
+		}
+		if entry.SourceID == "" || entry.Skill == "" || enc.kindOf(entry.SourceID) != NPC {
+			continue
+		}
+		key := entry.SourceID + "\x00" + entry.Skill
+		cast, ok := open[key]
+		if !ok || entry.Timestamp.Sub(cast.Start) > aoeWindow {
+			cast = &Cast{Mob: entry.SourceID, Skill: entry.Skill, Start: entry.Timestamp}
+			open[key] = cast
+			casts = append(casts, cast)
+		}
+		cast.Hits = append(cast.Hits, entry)
+	}
+	return casts
+}
+
+// printCasts lists the mob casts that hit more than one target.
+func printCasts(enc *Encounter) {
+	fmt.Println("aoe casts:")
+	for _, cast := range mobCasts(enc) {
+		targets := cast.Targets()
+		if len(targets) < 2 {
+			continue
+		}
+		perTarget := map[string]int{}
+		for _, hit := range cast.Hits {
+			perTarget[hit.TargetID] += hit.Value
+		}
+		hits := make([]string, 0, len(targets))
+		for _, target := range targets {
+			hits = append(hits, fmt.Sprintf("%v %v", target, perTarget[target]))
+		}
+		fmt.Printf("  +%-7v %v (%v) hit %d targets for %v total: %v\n",
+			cast.Start.Sub(enc.Start), cast.Skill, cast.Mob, len(targets), cast.Damage(), strings.Join(hits, ", "))
+	}
+}
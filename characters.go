@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+)
+
+func init() {
+	commands["characters"] = runCharacters
+}
+
+// detectCharacter guesses the logging character of an encounter: the one
+// buffing itself most, since the client only logs other players' self-buffs
+// when they target you. The configured characters win over anyone else.
+// Returns "" when nobody buffed themselves.
+func detectCharacter(enc *Encounter) string {
+	counts := map[string]int{}
+	best := ""
+	for _, entry := range enc.Entries {
+		if entry.etype != Benefit || entry.Source == "" || entry.Source != entry.Target || entry.Source == selfplaceholder {
+			continue
+		}
+		counts[entry.Source]++
+		if counts[entry.Source] > counts[best] || counts[entry.Source] == counts[best] && entry.Source < best {
+			best = entry.Source
+		}
+	}
+	for _, name := range config.Characters {
+		if counts[name] > 0 && (!slices.Contains(config.Characters, best) || counts[name] > counts[best]) {
+			best = name
+		}
+	}
+	return best
+}
+
+// resolveSelf replaces the "you" placeholder with the encounter's character
+// so lines like "You have been revived." count for the right one.
+func resolveSelf(enc *Encounter) {
+	if enc.Character == "" {
+		return
+	}
+	for _, entry := range enc.Entries {
+		if entry.Source == selfplaceholder {
+			entry.Source = enc.Character
+		}
+		if entry.Target == selfplaceholder {
+			entry.Target = enc.Character
+		}
+	}
+}
+
+// canonicalName is the identity a spelling of a character maps to in the
+// config, or the name itself.
+func canonicalName(name string) string {
+	if canonical, ok := config.Identities[name]; ok {
+		return canonical
+	}
+	return name
+}
+
+// applyIdentities renames the characters of an encounter to their
+// identities, so one player counts once however their name was written.
+func applyIdentities(enc *Encounter) {
+	if len(config.Identities) == 0 {
+		return
+	}
+	enc.Character = canonicalName(enc.Character)
+	for _, entry := range enc.Entries {
+		entry.Source = canonicalName(entry.Source)
+		entry.Target = canonicalName(entry.Target)
+	}
+}
+
+// CharacterSession is a run of consecutive encounters played on one
+// character.
+type CharacterSession struct {
+	Character  string
+	Encounters []*Encounter
+}
+
+// characterSessions splits encounters where the logging character changes,
+// e.g. when someone alt-tabs between two clients writing the same file.
+func characterSessions(encounters []*Encounter) []CharacterSession {
+	sessions := []CharacterSession{}
+	for _, enc := range encounters {
+		if n := len(sessions); n == 0 || sessions[n-1].Character != enc.Character {
+			sessions = append(sessions, CharacterSession{Character: enc.Character})
+		}
+		cur := &sessions[len(sessions)-1]
+		cur.Encounters = append(cur.Encounters, enc)
+	}
+	return sessions
+}
+
+// runCharacters lists the per-character sessions of a log.
+func runCharacters(args []string) error {
+	fs := flag.NewFlagSet("characters", flag.ExitOnError)
+	setup := commonFlags(fs)
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	result, err := parseFile(inputPath(fs), ParserOptions{})
+	if err != nil {
+		return err
+	}
+	for _, s := range characterSessions(segmentEncounters(result.Entries)) {
+		name := s.Character
+		if name == "" {
+			name = "(unknown)"
+		}
+		first, last := s.Encounters[0], s.Encounters[len(s.Encounters)-1]
+		fmt.Printf("%-20v %v - %v, %d encounters\n", name, first.Start.Format("15:04:05"), last.End.Format("15:04:05"), len(s.Encounters))
+	}
+	return nil
+}
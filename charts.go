@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+)
+
+// chart renders one SVG chart of an encounter.
+type chart func(w io.Writer, enc *Encounter) error
+
+// charts are the chart types selectable with report -charts.
+var charts = map[string]chart{}
+
+func chartNames() []string {
+	names := make([]string, 0, len(charts))
+	for name := range charts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// selectCharts resolves a comma separated list of chart names.
+func selectCharts(names string) ([]string, error) {
+	selected := []string{}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := charts[name]; !ok {
+			return nil, fmt.Errorf("unknown chart %q, have: %v", name, chartNames())
+		}
+		selected = append(selected, name)
+	}
+	return selected, nil
+}
+
+// svgOpen starts an SVG document filled with the theme's background.
+func svgOpen(w io.Writer, width, height, fontSize int) {
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="%d" fill="%v">`+"\n",
+		width, height, fontSize, chartTheme.Foreground)
+	fmt.Fprintf(w, `<rect x="0" y="0" width="%d" height="%d" fill="%v"/>`+"\n", width, height, chartTheme.Background)
+}
+
+// svgText escapes s for use in SVG text content.
+func svgText(s string) string {
+	return template.HTMLEscapeString(s)
+}
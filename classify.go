@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ActorKind tells players, NPCs and pets apart.
+type ActorKind int
+
+const (
+	NPC ActorKind = iota
+	Player
+	Pet
+)
+
+func (k ActorKind) String() string {
+	switch k {
+	case Player:
+		return "player"
+	case Pet:
+		return "pet"
+	}
+	return "npc"
+}
+
+func (k ActorKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *ActorKind) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "player":
+		*k = Player
+	case "pet":
+		*k = Pet
+	case "npc":
+		*k = NPC
+	default:
+		return fmt.Errorf("unknown actor kind %q", text)
+	}
+	return nil
+}
+
+// playerNames guesses which names in an encounter are players: the logging
+// character, anyone healed, buffed or revived, and anyone using a class skill
+// from the catalog. Names the game writes with an article are never players.
+func playerNames(enc *Encounter) map[string]bool {
+	players := friendlyActors(enc)
+	players[selfplaceholder] = true
+	articled := map[string]bool{}
+	for _, entry := range enc.Entries {
+		for _, raw := range []string{entry.Source, entry.Target} {
+			if name := entityName(raw); name != raw {
+				articled[name] = true
+			}
+		}
+		switch entry.etype {
+		case Revive:
+			players[entry.Target] = true
+		case DmgDealt, Heal, Benefit:
+			if info, ok := skillCatalog[entry.Skill]; ok && info.Class != "" && entry.Source != "" {
+				players[entry.Source] = true
+			}
+		}
+	}
+	for name := range articled {
+		delete(players, name)
+	}
+	return players
+}
+
+// playerClasses guesses each player's class from the catalog skills they
+// used, the class of most of them. Players without one are left out.
+func playerClasses(enc *Encounter) map[string]string {
+	counts := map[string]map[string]int{}
+	for _, entry := range enc.Entries {
+		info, ok := skillCatalog[entry.Skill]
+		if !ok || info.Class == "" || entry.SourceID == "" || enc.kindOf(entry.SourceID) != Player {
+			continue
+		}
+		if counts[entry.SourceID] == nil {
+			counts[entry.SourceID] = map[string]int{}
+		}
+		counts[entry.SourceID][info.Class]++
+	}
+	classes := map[string]string{}
+	for id, byClass := range counts {
+		best := ""
+		for class, n := range byClass {
+			if n > byClass[best] || n == byClass[best] && class < best {
+				best = class
+			}
+		}
+		classes[id] = best
+	}
+	return classes
+}
+
+// classifyActors sets Kind on the encounter's entities. Names like "Starlaf's
+// Bear" belong to a player's pet, everything that is not a player or pet is
+// an NPC.
+func classifyActors(enc *Encounter, players map[string]bool) {
+	for _, entity := range enc.Entities {
+		switch {
+		case players[entity.Name]:
+			entity.Kind = Player
+		case isPetName(entity.Name, players):
+			entity.Kind = Pet
+		default:
+			entity.Kind = NPC
+		}
+	}
+}
+
+// isPetName reports whether a name is a possessive of a player, like
+// "Starlaf's Bear".
+func isPetName(name string, players map[string]bool) bool {
+	owner, _, found := strings.Cut(name, "'s ")
+	return found && players[owner]
+}
+
+// kindOf returns the kind of an entry's source or target entity ID.
+func (e *Encounter) kindOf(id string) ActorKind {
+	if entity, ok := e.Entities[id]; ok {
+		return entity.Kind
+	}
+	if id == selfplaceholder {
+		return Player
+	}
+	return NPC
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// encounterSummary is a line about an encounter for the in-game chat, like
+// "Boss down in 4:32 — You: 18.2k DPS, 2nd of 12". Healers get their HPS
+// among the healers instead.
+func encounterSummary(enc *Encounter) string {
+	clock := clockTime(enc.Duration().Round(time.Second))
+	line := "Trash done in " + clock
+	if name := encounterBoss(enc); name != "" {
+		if encounterKilled(enc) {
+			line = fmt.Sprintf("%v down in %v", name, clock)
+		} else {
+			line = fmt.Sprintf("%v wipe after %v", name, clock)
+		}
+	}
+	you := enc.Character
+	if you == "" {
+		you = selfplaceholder
+	}
+	stats := actorStats(enc)
+	var self *ActorStats
+	for _, s := range stats {
+		if s.Actor == you {
+			self = s
+		}
+	}
+	if self == nil || self.Damage+self.Healing == 0 {
+		return line
+	}
+	metric, total := playerMetric(self)
+	ranked := []*ActorStats{}
+	for _, s := range stats {
+		if m, t := playerMetric(s); s.Kind == Player && m == metric && t > 0 {
+			ranked = append(ranked, s)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		_, a := playerMetric(ranked[i])
+		_, b := playerMetric(ranked[j])
+		return a > b
+	})
+	rank := 1
+	for rank <= len(ranked) && ranked[rank-1] != self {
+		rank++
+	}
+	return fmt.Sprintf("%v — You: %v %v, %v of %d", line, shortNumber(perSecond(total, enc)), strings.ToUpper(metric), ordinal(rank), len(ranked))
+}
+
+// ordinal writes 1 as 1st, 2 as 2nd and so on.
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%v", n, suffix)
+}
+
+// copyToClipboard puts text on the system clipboard with the tool of the
+// platform: clip on Windows, pbcopy on macOS and wl-copy, xclip or xsel
+// elsewhere.
+func copyToClipboard(text string) error {
+	var cmd *exec.Cmd
+	input := []byte(text)
+	switch runtime.GOOS {
+	case "windows":
+		// clip reads UTF-16 when the text starts with a byte order mark
+		cmd = exec.Command("clip")
+		input = binary.LittleEndian.AppendUint16(nil, 0xFEFF)
+		for _, u := range utf16.Encode([]rune(text)) {
+			input = binary.LittleEndian.AppendUint16(input, u)
+		}
+	case "darwin":
+		cmd = exec.Command("pbcopy")
+	default:
+		tools := [][]string{{"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}}
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			tools = append([][]string{{"wl-copy"}}, tools...)
+		}
+		for _, tool := range tools {
+			if _, err := exec.LookPath(tool[0]); err == nil {
+				cmd = exec.Command(tool[0], tool[1:]...)
+				break
+			}
+		}
+		if cmd == nil {
+			return errors.New("no clipboard tool found, install wl-copy, xclip or xsel")
+		}
+	}
+	// xclip stays around serving the clipboard and would keep captured
+	// output open, so none is captured
+	cmd.Stdin = bytes.NewReader(input)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%v: %w", cmd.Args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+)
+
+// command is a subcommand run as "SharedCombatGraphs <name> [flags] [args]".
+type command func(args []string) error
+
+var commands = map[string]command{}
+
+// commonFlags registers the config, pattern, theme, segmentation and logging
+// flags every command shares and returns a function that loads them once the
+// flags are parsed. Segmentation and cache flags override the config only
+// when given.
+func commonFlags(fs *flag.FlagSet) func() error {
+	verbose := fs.Bool("v", false, "log what is going on")
+	veryVerbose := fs.Bool("vv", false, "log debug details, e.g. every unparsed line")
+	logJSON := fs.Bool("log-json", false, "write logs as JSON")
+	configPath := fs.String("config", defaultConfigPath(), "path of the JSON config file")
+	patternDir := fs.String("patterns", defaultPatternDir(), "directory of JSON pattern overrides")
+	idleGap := fs.Duration("idle-gap", encounterIdleGap, "a break in combat longer than this starts a new encounter")
+	minLength := fs.Duration("min-encounter", 0, "drop encounters shorter than this")
+	requireBoss := fs.Bool("require-boss", false, "drop encounters without a boss")
+	mergeTrash := fs.Bool("merge-trash", false, "merge trash fought right before a boss into the boss pull")
+	cacheDir := fs.String("cache", "", fmt.Sprintf("directory caching parsed logs, like %v (default from config, else off)", defaultParseCacheDir()))
+	fs.StringVar(&readMode, "read", readMode, fmt.Sprintf("how to read logs, one of %v", readModes))
+	fs.StringVar(&logCharset, "charset", logCharset, "encoding of the log: auto, utf-8, utf-16le, utf-16be or windows-1252")
+	theme := fs.String("theme", "", fmt.Sprintf("color theme, one of %v (default from config, else light)", themeNames()))
+	return func() error {
+		verbosity := 0
+		if *verbose {
+			verbosity = 1
+		}
+		if *veryVerbose {
+			verbosity = 2
+		}
+		setupLogging(verbosity, *logJSON)
+		if !slices.Contains(readModes, readMode) {
+			return fmt.Errorf("unknown read mode %q, want one of %v", readMode, readModes)
+		}
+		cfg, err := loadConfig(*configPath)
+		if err != nil {
+			return err
+		}
+		config = cfg
+		slog.Debug("loaded config", "path", *configPath)
+		segmentation = config.Segmentation
+		parseCacheDir = config.ParseCache
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "cache":
+				parseCacheDir = *cacheDir
+			case "idle-gap":
+				segmentation.IdleGapSecs = idleGap.Seconds()
+			case "min-encounter":
+				segmentation.MinLengthSecs = minLength.Seconds()
+			case "require-boss":
+				segmentation.RequireBoss = *requireBoss
+			case "merge-trash":
+				segmentation.MergeTrash = *mergeTrash
+			}
+		})
+		if *theme == "" {
+			*theme = config.Theme
+		}
+		if *theme != "" {
+			if err := selectTheme(*theme); err != nil {
+				return err
+			}
+		}
+		if err := loadPatternOverrides(*patternDir); err != nil {
+			return fmt.Errorf("loading patterns: %w", err)
+		}
+		if activeNotifiers, err = configNotifiers(config); err != nil {
+			return fmt.Errorf("config notifiers: %w", err)
+		}
+		return nil
+	}
+}
+
+// inputPath is the log file named on the command line, or the sample log.
+func inputPath(fs *flag.FlagSet) string {
+	if fs.NArg() > 0 {
+		return fs.Arg(0)
+	}
+	return "test/input.txt" // Or "Combat_20240708_2.txt"
+}
+
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
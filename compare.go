@@ -0,0 +1,201 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"sort"
+)
+
+func init() {
+	commands["compare"] = runCompare
+}
+
+// BuildSample is the DPS of one actor in each encounter fought with a build.
+type BuildSample struct {
+	Build string
+	DPS   []float64
+}
+
+func (s BuildSample) mean() float64 {
+	sum := 0.0
+	for _, v := range s.DPS {
+		sum += v
+	}
+	return sum / float64(len(s.DPS))
+}
+
+func (s BuildSample) variance() float64 {
+	m, sum := s.mean(), 0.0
+	for _, v := range s.DPS {
+		sum += (v - m) * (v - m)
+	}
+	return sum / float64(len(s.DPS)-1)
+}
+
+// BuildComparison is the result of Welch's t-test on two builds.
+type BuildComparison struct {
+	A, B BuildSample
+	Diff float64 // mean DPS of B minus A
+	T    float64
+	DF   float64
+	P    float64 // two-sided
+}
+
+// compareBuilds tests whether the mean DPS of two builds differ. Each needs at
+// least two parses.
+func compareBuilds(a, b BuildSample) (BuildComparison, error) {
+	if len(a.DPS) < 2 || len(b.DPS) < 2 {
+		return BuildComparison{}, fmt.Errorf("need at least 2 parses per build, have %d for %q and %d for %q", len(a.DPS), a.Build, len(b.DPS), b.Build)
+	}
+	va, vb := a.variance()/float64(len(a.DPS)), b.variance()/float64(len(b.DPS))
+	c := BuildComparison{A: a, B: b, Diff: b.mean() - a.mean()}
+	if va+vb == 0 {
+		c.P = 1
+		if c.Diff != 0 {
+			c.P = 0
+		}
+		return c, nil
+	}
+	c.T = c.Diff / math.Sqrt(va+vb)
+	c.DF = (va + vb) * (va + vb) / (va*va/float64(len(a.DPS)-1) + vb*vb/float64(len(b.DPS)-1))
+	c.P = studentTwoSided(c.T, c.DF)
+	return c, nil
+}
+
+// studentTwoSided is P(|T| > |t|) for Student's t distribution.
+func studentTwoSided(t, df float64) float64 {
+	return incompleteBeta(df/2, 0.5, df/(df+t*t))
+}
+
+// incompleteBeta is the regularized incomplete beta function I_x(a, b),
+// evaluated with Lentz's continued fraction.
+func incompleteBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	if x > (a+1)/(a+b+2) {
+		return 1 - incompleteBeta(b, a, 1-x)
+	}
+	const tiny, eps = 1e-300, 1e-12
+	f, c, d := 1.0, 1.0, 0.0
+	for i := 0; i <= 200; i++ {
+		m := float64(i / 2)
+		num := 1.0
+		switch {
+		case i == 0:
+		case i%2 == 0:
+			num = m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m))
+		default:
+			num = -(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1))
+		}
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		d = 1 / d
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		f *= c * d
+		if math.Abs(1-c*d) < eps {
+			break
+		}
+	}
+	return front * (f - 1) / a
+}
+
+// buildSamples collects the DPS of actor, or of the top player when empty,
+// for every encounter with a build annotation.
+func buildSamples(encounters []*Encounter, actor string) map[string]*BuildSample {
+	samples := map[string]*BuildSample{}
+	for _, enc := range encounters {
+		if enc.Build == "" {
+			continue
+		}
+		var pick *ActorStats
+		for _, s := range actorStats(enc) {
+			if s.Kind == Player && (actor == "" && (pick == nil || s.Damage > pick.Damage) || s.Actor == actor) {
+				pick = s
+			}
+		}
+		if pick == nil || pick.Damage == 0 {
+			continue
+		}
+		s, ok := samples[enc.Build]
+		if !ok {
+			s = &BuildSample{Build: enc.Build}
+			samples[enc.Build] = s
+		}
+		s.DPS = append(s.DPS, perSecond(pick.Damage, enc))
+	}
+	return samples
+}
+
+func printBuildComparison(c BuildComparison) {
+	for _, s := range []BuildSample{c.A, c.B} {
+		fmt.Printf("%-30v %3d parses, mean %8.0f/s, stddev %8.0f\n", s.Build, len(s.DPS), s.mean(), math.Sqrt(s.variance()))
+	}
+	verdict := "not significant"
+	if c.P < 0.05 {
+		verdict = "significant at 5%"
+	}
+	fmt.Printf("difference %+.0f/s (%+.1f%%), t=%.2f, df=%.1f, p=%.4f: %v\n",
+		c.Diff, 100*c.Diff/c.A.mean(), c.T, c.DF, c.P, verdict)
+}
+
+// runCompare compares the DPS of two builds over the annotated encounters of
+// one or more logs.
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	setup := commonFlags(fs)
+	buildA := fs.String("a", "", "first build (default: the first of exactly two builds found)")
+	buildB := fs.String("b", "", "second build")
+	actor := fs.String("actor", "", "actor whose DPS is compared (default: the top player of each encounter)")
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{inputPath(fs)}
+	}
+	encounters := []*Encounter{}
+	for _, path := range paths {
+		result, err := parseFile(path, ParserOptions{})
+		if err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+		encounters = append(encounters, segmentEncounters(result.Entries)...)
+	}
+	samples := buildSamples(encounters, *actor)
+	if *buildA == "" && *buildB == "" {
+		builds := make([]string, 0, len(samples))
+		for build := range samples {
+			builds = append(builds, build)
+		}
+		sort.Strings(builds)
+		if len(builds) != 2 {
+			return fmt.Errorf("found builds %q, pick two with -a and -b", builds)
+		}
+		*buildA, *buildB = builds[0], builds[1]
+	}
+	a, b := samples[*buildA], samples[*buildB]
+	if a == nil || b == nil {
+		return fmt.Errorf("no parses tagged with build %q or %q", *buildA, *buildB)
+	}
+	c, err := compareBuilds(*a, *b)
+	if err != nil {
+		return err
+	}
+	printBuildComparison(c)
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Config holds the user settings read from the config file. Flags given on
+// the command line take precedence over it.
+type Config struct {
+	// Players is the fellowship roster, reports only show these actors and
+	// collapse everyone else into "Others".
+	Players []string `json:"players,omitempty"`
+	// AvoidableSkills are boss skills that players are expected to dodge.
+	AvoidableSkills []string `json:"avoidable_skills,omitempty"`
+	// AbsorbSkills are the benefits that grant temporary Morale, the absorbs
+	// analysis credits absorbs to their casters.
+	AbsorbSkills []string `json:"absorb_skills,omitempty"`
+	// Corruptions are the dispellable effects the dispels analysis expects
+	// to see removed.
+	Corruptions []string `json:"corruptions,omitempty"`
+	// ExpectedEffects are the group buffs and debuffs every encounter should
+	// have, missing ones are called out in the summary.
+	ExpectedEffects []ExpectedEffect `json:"expected_effects,omitempty"`
+	// DebuffBonuses are percentage-damage debuffs, the meter credits their
+	// appliers with the damage they add in a contribution column.
+	DebuffBonuses []DebuffBonus `json:"debuff_bonuses,omitempty"`
+	// MeleeSkills are the skills only usable in melee range, for the melee
+	// uptime of the positioning analysis. Auto-attacks always count.
+	MeleeSkills []string `json:"melee_skills,omitempty"`
+	// PriorityTargets are the adds reported by the targets analysis.
+	PriorityTargets []string `json:"priority_targets,omitempty"`
+	// Mechanics are the rules counting failed boss mechanics per player.
+	Mechanics []MechanicRule `json:"mechanics,omitempty"`
+	// ReferenceOpener is the skill order openers are compared against.
+	ReferenceOpener []string `json:"reference_opener,omitempty"`
+	// Segmentation tunes encounter boundaries.
+	Segmentation SegmentOptions `json:"segmentation,omitempty"`
+	// Store selects where the db command keeps encounters.
+	Store StoreConfig `json:"store,omitempty"`
+	// Server protects the share server of serve mode.
+	Server ServerConfig `json:"server,omitempty"`
+	// Digest schedules the weekly digest of serve mode.
+	Digest DigestConfig `json:"digest,omitempty"`
+	// Webhooks are notified of ended encounters, deaths and new records.
+	Webhooks []WebhookConfig `json:"webhooks,omitempty"`
+	// Matrix and Telegram post the same events to a chat, with a chart of
+	// each encounter.
+	Matrix   *MatrixConfig   `json:"matrix,omitempty"`
+	Telegram *TelegramConfig `json:"telegram,omitempty"`
+	// LogDir is where the game writes combat logs, found by gui when empty.
+	LogDir string `json:"log_dir,omitempty"`
+	// Characters are the user's own characters, preferred when guessing
+	// who wrote a log.
+	Characters []string `json:"characters,omitempty"`
+	// Locale is the game client language, detected per log when empty.
+	Locale string `json:"locale,omitempty"`
+	// Share is the share server upload sends logs to.
+	Share ShareConfig `json:"share,omitempty"`
+	// Theme is the palette of reports, charts and the live meter.
+	Theme string `json:"theme,omitempty"`
+	// ClockOffsetSecs are added to the times of logs, by file name, when
+	// merge combines them, as raiders' clocks differ by seconds.
+	ClockOffsetSecs map[string]float64 `json:"clock_offset_seconds,omitempty"`
+	// Identities maps other spellings of a character, like with a surname
+	// or from another raider's log, to the name it is reported under.
+	Identities map[string]string `json:"identities,omitempty"`
+	// EnrageSecs are the enrage timers of bosses in seconds, by boss name,
+	// added to and over those of the boss catalog.
+	EnrageSecs map[string]float64 `json:"enrage_seconds,omitempty"`
+	// ParseCache is a directory caching parsed logs, off when empty.
+	ParseCache string `json:"parse_cache,omitempty"`
+	// Baselines is the file of the baselines reports rank players against,
+	// default baselines.json in the user's config directory.
+	Baselines string `json:"baselines,omitempty"`
+}
+
+// config is the loaded configuration, empty when there is no config file.
+var config Config
+
+// defaultConfigPath is config.json in the user's config directory.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "SharedCombatGraphs", "config.json")
+}
+
+// loadConfig reads a JSON config file. A missing file is not an error.
+func loadConfig(path string) (Config, error) {
+	cfg := Config{}
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("reading config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parsing config %v: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// saveConfig writes a config file, readable only by the user as it holds
+// tokens and webhook URLs.
+func saveConfig(path string, cfg Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
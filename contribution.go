@@ -0,0 +1,61 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// DebuffBonus is a percentage-damage debuff whose appliers are credited with
+// the extra damage it causes.
+type DebuffBonus struct {
+	// Skill is the skill whose hits apply the debuff.
+	Skill string `json:"skill"`
+	// Bonus is the extra damage the target takes, 0.1 for 10%.
+	Bonus float64 `json:"bonus"`
+	// Duration is how long one application lasts, in seconds.
+	Duration float64 `json:"duration"`
+}
+
+// debuffContributions credits the players keeping percentage-damage
+// debuffs up with the damage the debuffs added to other players' hits. The
+// hitter keeps the full hit in their own damage, the contribution is a
+// separate column. Stacked debuffs split the extra damage by their bonus.
+func debuffContributions(enc *Encounter, bonuses []DebuffBonus) map[string]int {
+	contribution := map[string]int{}
+	if len(bonuses) == 0 {
+		return contribution
+	}
+	type application struct {
+		by    string
+		until time.Time
+	}
+	active := map[string][]application{} // target ID -> per bonus
+	for _, entry := range enc.Entries {
+		if entry.etype != DmgDealt || entry.Value == 0 || entry.TargetID == "" || enc.kindOf(entry.TargetID) != NPC {
+			continue
+		}
+		applied := active[entry.TargetID]
+		if applied == nil {
+			applied = make([]application, len(bonuses))
+			active[entry.TargetID] = applied
+		}
+		total := 0.0
+		for i, b := range bonuses {
+			if applied[i].by != "" && applied[i].by != entry.SourceID && entry.Timestamp.Before(applied[i].until) {
+				total += b.Bonus
+			}
+		}
+		for i, b := range bonuses {
+			if applied[i].by != "" && applied[i].by != entry.SourceID && entry.Timestamp.Before(applied[i].until) {
+				contribution[applied[i].by] += int(math.Round(float64(entry.Value) * b.Bonus / (1 + total)))
+			}
+		}
+		// the applying hit itself does not benefit from the debuff
+		for i, b := range bonuses {
+			if entry.Skill == b.Skill && enc.kindOf(entry.SourceID) != NPC {
+				applied[i] = application{entry.SourceID, entry.Timestamp.Add(time.Duration(b.Duration * float64(time.Second)))}
+			}
+		}
+	}
+	return contribution
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// timestamps only have second precision, so allow uses slightly early
+	cooldownTolerance = 0.9
+	// cooldowns at least this long are called out when left idle
+	bigCooldown = 60 * time.Second
+)
+
+func init() {
+	analyses["cooldowns"] = printCooldowns
+}
+
+// CooldownUsage is how well an actor kept a skill on cooldown.
+type CooldownUsage struct {
+	Actor    string
+	Skill    string
+	Cooldown time.Duration
+	Uses     int
+	MaxUses  int
+	Idle     time.Duration // time the skill was ready but not used
+}
+
+// Utilization is the fraction of the theoretical maximum uses.
+func (c CooldownUsage) Utilization() float64 {
+	if c.MaxUses == 0 {
+		return 0
+	}
+	return float64(c.Uses) / float64(c.MaxUses)
+}
+
+// cooldownUsage reconstructs skill uses from event timestamps. Events within
+// one cooldown of the last counted use are treated as part of that use (extra
+// targets, damage over time ticks). Uses allowed early by the tolerance can
+// add up to more than fit in the encounter, so they are capped at MaxUses.
+func cooldownUsage(enc *Encounter) []CooldownUsage {
+	type key struct{ actor, skill string }
+	uses := map[key][]time.Time{}
+	for _, entry := range enc.Entries {
+		if entry.Source == "" || entry.Skill == "" {
+			continue
+		}
+		info, ok := skillCatalog[entry.Skill]
+		if !ok || info.Cooldown() == 0 {
+			continue
+		}
+		k := key{entry.Source, entry.Skill}
+		ts := uses[k]
+		minGap := time.Duration(float64(info.Cooldown()) * cooldownTolerance)
+		if len(ts) > 0 && entry.Timestamp.Sub(ts[len(ts)-1]) < minGap {
+			continue
+		}
+		uses[k] = append(ts, entry.Timestamp)
+	}
+
+	result := []CooldownUsage{}
+	for k, ts := range uses {
+		cd := skillCatalog[k.skill].Cooldown()
+		usage := CooldownUsage{
+			Actor:    k.actor,
+			Skill:    k.skill,
+			Cooldown: cd,
+			MaxUses:  int(enc.Duration()/cd) + 1,
+		}
+		usage.Uses = min(len(ts), usage.MaxUses)
+		ready := enc.Start
+		for _, t := range ts {
+			if gap := t.Sub(ready); gap > 0 {
+				usage.Idle += gap
+			}
+			ready = t.Add(cd)
+		}
+		if gap := enc.End.Sub(ready); gap > 0 {
+			usage.Idle += gap
+		}
+		result = append(result, usage)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Actor != result[j].Actor {
+			return result[i].Actor < result[j].Actor
+		}
+		return result[i].Cooldown > result[j].Cooldown
+	})
+	return result
+}
+
+func printCooldowns(enc *Encounter) {
+	fmt.Println("cooldown usage:")
+	for _, u := range cooldownUsage(enc) {
+		if !isWatched(u.Actor) {
+			continue
+		}
+		flag := ""
+		if u.Cooldown >= bigCooldown && u.Uses < u.MaxUses {
+			flag = " <- wasted"
+		}
+		fmt.Printf("  %-12v %-20v cd %-5v %3d/%-3d (%3.0f%%) idle %v%v\n",
+			u.Actor, u.Skill, u.Cooldown, u.Uses, u.MaxUses, u.Utilization()*100, u.Idle, flag)
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestCooldownUsageCapped(t *testing.T) {
+	// Rake has a 10s cooldown, rounded timestamps let it count every 9s
+	enc := encounterOf(
+		skillUseAt(0, "Starlaf", "Rake"),
+		skillUseAt(9, "Starlaf", "Rake"),
+		skillUseAt(18, "Starlaf", "Rake"),
+		skillUseAt(27, "Starlaf", "Rake"),
+		skillUseAt(36, "Starlaf", "Rake"),
+	)
+	usage := cooldownUsage(enc)
+	if len(usage) != 1 {
+		t.Fatalf("got %+v, want Starlaf's Rake", usage)
+	}
+	if u := usage[0]; u.Uses != 4 || u.MaxUses != 4 || u.Utilization() != 1 {
+		t.Errorf("got %d/%d uses, want 4/4", u.Uses, u.MaxUses)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// uptime below this share of the encounter is called out in the summary
+	minCoverage = 0.5
+)
+
+func init() {
+	analyses["coverage"] = printCoverage
+}
+
+// ExpectedEffect is a group buff or mob debuff the raid should keep up. The
+// log has no effect durations or expiries, so uptime comes from the
+// applications and the configured duration.
+type ExpectedEffect struct {
+	Name string `json:"name"`
+	// Skills apply the effect, the name itself when empty.
+	Skills []string `json:"skills,omitempty"`
+	// Debuff effects are applied to mobs by players' hits, the others are
+	// benefits applied to players.
+	Debuff bool `json:"debuff,omitempty"`
+	// Duration is how long one application lasts, in seconds.
+	Duration float64 `json:"duration"`
+}
+
+func (e ExpectedEffect) appliedBy(skill string) bool {
+	if len(e.Skills) == 0 {
+		return skill == e.Name
+	}
+	for _, s := range e.Skills {
+		if s == skill {
+			return true
+		}
+	}
+	return false
+}
+
+// Coverage is how well an expected effect was kept up in an encounter.
+type Coverage struct {
+	Effect       ExpectedEffect
+	Applications int
+	Uptime       time.Duration
+	Share        float64 // of the encounter duration
+}
+
+// effectCoverage measures the uptime of each expected effect as the union of
+// its applications, each lasting the effect's duration.
+func effectCoverage(enc *Encounter, effects []ExpectedEffect) []Coverage {
+	result := make([]Coverage, 0, len(effects))
+	for _, effect := range effects {
+		c := Coverage{Effect: effect}
+		last := time.Duration(effect.Duration * float64(time.Second))
+		var until time.Time
+		for _, entry := range enc.Entries {
+			if !effect.appliedBy(entry.Skill) {
+				continue
+			}
+			switch {
+			case effect.Debuff && entry.etype == DmgDealt && entry.Value > 0 && enc.kindOf(entry.TargetID) == NPC:
+			case !effect.Debuff && entry.etype == Benefit:
+			default:
+				continue
+			}
+			c.Applications++
+			from, to := entry.Timestamp, entry.Timestamp.Add(last)
+			if to.After(enc.End) {
+				to = enc.End
+			}
+			if c.Applications > 1 && from.Before(until) {
+				from = until
+			}
+			if to.After(from) {
+				c.Uptime += to.Sub(from)
+				until = to
+			}
+		}
+		if d := enc.Duration(); d > 0 {
+			c.Share = min(1, float64(c.Uptime)/float64(d))
+		}
+		result = append(result, c)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Share < result[j].Share })
+	return result
+}
+
+// coverageWarnings are the summary lines of expected effects that were
+// missing or had a low uptime.
+func coverageWarnings(enc *Encounter) []string {
+	warnings := []string{}
+	for _, c := range effectCoverage(enc, config.ExpectedEffects) {
+		switch {
+		case c.Applications == 0:
+			warnings = append(warnings, fmt.Sprintf("%v was never applied", c.Effect.Name))
+		case c.Share < minCoverage:
+			warnings = append(warnings, fmt.Sprintf("%v was only up %.0f%% of the fight", c.Effect.Name, 100*c.Share))
+		}
+	}
+	return warnings
+}
+
+func printCoverage(enc *Encounter) {
+	fmt.Println("buff and debuff coverage:")
+	if len(config.ExpectedEffects) == 0 {
+		fmt.Println("  no expected_effects configured")
+	}
+	for _, c := range effectCoverage(enc, config.ExpectedEffects) {
+		kind := "buff"
+		if c.Effect.Debuff {
+			kind = "debuff"
+		}
+		fmt.Printf("  %-24v %-6v %3.0f%% up (%v) from %d applications\n", c.Effect.Name, kind, 100*c.Share, c.Uptime, c.Applications)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+func init() {
+	commands["db"] = runDB
+}
+
+// dbCommands are the subcommands of "db".
+var dbCommands = map[string]func(store Store, fs *flag.FlagSet) error{
+	"put":    dbPut,
+	"list":   dbList,
+	"show":   dbShow,
+	"export": dbExport,
+	"import": dbImport,
+}
+
+func dbCommandNames() []string {
+	names := make([]string, 0, len(dbCommands))
+	for name := range dbCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// dbPut stores every encounter of the log files given.
+func dbPut(store Store, fs *flag.FlagSet) error {
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{inputPath(fs)}
+	}
+	return storeBatch(store, func() error {
+		for _, path := range paths {
+			result, err := parseFile(path, ParserOptions{})
+			if err != nil {
+				return fmt.Errorf("%v: %w", path, err)
+			}
+			for _, enc := range segmentEncounters(result.Entries) {
+				id, err := store.PutEntries(path, enc)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("stored %v (%v, %v)\n", id, enc.Start.Format("15:04:05"), enc.Duration())
+			}
+		}
+		return nil
+	})
+}
+
+func dbList(store Store, fs *flag.FlagSet) error {
+	list, err := store.ListEncounters()
+	if err != nil {
+		return err
+	}
+	for _, e := range list {
+		fmt.Printf("%v %-24v %v %-8v v%d %v\n", e.ID, e.File, e.Start.Format("15:04:05"), e.Duration, e.ParserVersion, e.Label)
+	}
+	for _, warning := range versionWarnings(list) {
+		slog.Warn(warning)
+	}
+	return nil
+}
+
+// dbShow prints the raw lines of the stored encounters named by ID.
+func dbShow(store Store, fs *flag.FlagSet) error {
+	for _, id := range fs.Args() {
+		lines, err := store.GetTimeline(id)
+		if err != nil {
+			return err
+		}
+		fmt.Println(strings.Join(lines, "\n"))
+	}
+	return nil
+}
+
+// runDB manages the encounter store: "db <subcommand> [flags] [args]".
+func runDB(args []string) error {
+	if len(args) == 0 || dbCommands[args[0]] == nil {
+		return fmt.Errorf("usage: db <%v> [flags] [args]", strings.Join(dbCommandNames(), "|"))
+	}
+	fs := flag.NewFlagSet("db "+args[0], flag.ExitOnError)
+	setup := commonFlags(fs)
+	open := storeFlags(fs)
+	fs.Parse(args[1:])
+	if err := setup(); err != nil {
+		return err
+	}
+	store, err := open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return dbCommands[args[0]](store, fs)
+}
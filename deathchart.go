@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	deathChartBucket = 5 * time.Second
+	deathChartWidth  = 800
+	deathChartHeight = 240
+	deathChartMarker = 10
+)
+
+func init() {
+	charts["deaths"] = writeDeathChartSVG
+}
+
+// DeathEvent is a player death or revive on the encounter timeline.
+type DeathEvent struct {
+	At     time.Duration // since the encounter start
+	Actor  string
+	Revive bool
+}
+
+// DeathTimeline is the data behind the deaths chart.
+type DeathTimeline struct {
+	Boss       string
+	BossDamage []int // damage taken by the boss per bucket
+	Events     []DeathEvent
+}
+
+// bossEntity guesses the boss as the NPC that took the most damage.
+func bossEntity(enc *Encounter) *Entity {
+	var boss *Entity
+	for _, e := range enc.Entities {
+		if e.Kind != NPC || e.Damage == 0 {
+			continue
+		}
+		if boss == nil || e.Damage > boss.Damage || e.Damage == boss.Damage && e.ID < boss.ID {
+			boss = e
+		}
+	}
+	return boss
+}
+
+func deathTimeline(enc *Encounter) DeathTimeline {
+	timeline := DeathTimeline{BossDamage: make([]int, int(enc.Duration()/deathChartBucket)+1)}
+	boss := bossEntity(enc)
+	if boss != nil {
+		timeline.Boss = boss.ID
+	}
+	for _, entry := range enc.Entries {
+		at := entry.Timestamp.Sub(enc.Start)
+		switch entry.etype {
+		case DmgDealt:
+			if boss != nil && entry.TargetID == boss.ID {
+				timeline.BossDamage[int(at/deathChartBucket)] += entry.Value
+			}
+		case Death, Revive:
+			if entry.TargetID == "" || enc.kindOf(entry.TargetID) != Player {
+				continue
+			}
+			timeline.Events = append(timeline.Events, DeathEvent{At: at, Actor: entry.TargetID, Revive: entry.etype == Revive})
+		}
+	}
+	return timeline
+}
+
+// writeDeathChartSVG draws the boss damage taken as bars, with player deaths
+// stacked above the time they happened and revives stacked below the axis, so
+// a wipe cascade shows up as a tower of markers.
+func writeDeathChartSVG(w io.Writer, enc *Encounter) error {
+	const top, axis, left = 20, deathChartHeight - 60, 10
+	timeline := deathTimeline(enc)
+	buckets := len(timeline.BossDamage)
+	step := float64(deathChartWidth-2*left) / float64(buckets)
+	max := 1
+	for _, dmg := range timeline.BossDamage {
+		if dmg > max {
+			max = dmg
+		}
+	}
+	svgOpen(w, deathChartWidth, deathChartHeight, 11)
+	title := "no boss"
+	if timeline.Boss != "" {
+		title = "damage to " + timeline.Boss
+	}
+	fmt.Fprintf(w, `<text x="%d" y="14">Deaths and revives, %v</text>`+"\n", left, svgText(title))
+	for b, dmg := range timeline.BossDamage {
+		h := (axis - top) * dmg / max
+		fmt.Fprintf(w, `<rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%v"><title>+%v: %v</title></rect>`+"\n",
+			left+float64(b)*step, axis-h, step, h, chartTheme.Muted, time.Duration(b)*deathChartBucket, dmg)
+	}
+	writeLagWindowsSVG(w, lagWindows(enc), left, step/deathChartBucket.Seconds(), top, deathChartHeight-top-20)
+	fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%v"/>`+"\n", left, axis, deathChartWidth-left, axis, chartTheme.Foreground)
+
+	deaths, revives := map[int]int{}, map[int]int{}
+	for _, ev := range timeline.Events {
+		b := int(ev.At / deathChartBucket)
+		x := left + (float64(b)+0.5)*step
+		if ev.Revive {
+			y := axis + deathChartMarker*(revives[b]+1)
+			revives[b]++
+			fmt.Fprintf(w, `<circle cx="%.1f" cy="%d" r="%d" fill="%v"><title>%v revived at +%v</title></circle>`+"\n", x, y, deathChartMarker/2-1, chartTheme.Revive, svgText(ev.Actor), ev.At)
+			continue
+		}
+		y := axis - deathChartMarker*(deaths[b]+1)
+		deaths[b]++
+		fmt.Fprintf(w, `<circle cx="%.1f" cy="%d" r="%d" fill="%v"><title>%v died at +%v</title></circle>`+"\n", x, y, deathChartMarker/2-1, chartTheme.Death, svgText(ev.Actor), ev.At)
+	}
+	fmt.Fprintf(w, `<text x="%d" y="%d">%v</text>`+"\n", left, deathChartHeight-4, enc.Duration())
+	_, err := fmt.Fprintln(w, "</svg>")
+	return err
+}
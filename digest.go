@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/smtp"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// how far back a digest looks
+	digestPeriod = 7 * 24 * time.Hour
+	// how many parses a digest lists
+	digestTopParses = 5
+	// shortest encounter whose DPS counts as a parse
+	digestMinParse = 30 * time.Second
+)
+
+func init() {
+	commands["digest"] = runDigest
+}
+
+// DigestConfig schedules the weekly digest of serve mode.
+type DigestConfig struct {
+	// Guild is the workspace to report on, "" for the public encounters.
+	Guild string `json:"guild,omitempty"`
+	// Weekday and Hour are when the digest is sent, Monday 09:00 local time
+	// by default.
+	Weekday string `json:"weekday,omitempty"`
+	Hour    int    `json:"hour,omitempty"`
+	// Webhook receives the digest as a JSON POST.
+	Webhook string       `json:"webhook,omitempty"`
+	Email   *EmailConfig `json:"email,omitempty"`
+}
+
+// EmailConfig is the mail server and recipients of the digest.
+type EmailConfig struct {
+	SMTP     string   `json:"smtp"` // host:port
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+}
+
+func (c DigestConfig) enabled() bool {
+	return c.Webhook != "" || c.Email != nil
+}
+
+func (c DigestConfig) weekday() (time.Weekday, error) {
+	if c.Weekday == "" {
+		return time.Monday, nil
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(c.Weekday, day.String()) {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown digest weekday %q", c.Weekday)
+}
+
+// next is when the digest after now is due.
+func (c DigestConfig) next(now time.Time) (time.Time, error) {
+	day, err := c.weekday()
+	if err != nil {
+		return time.Time{}, err
+	}
+	hour := c.Hour
+	if hour == 0 {
+		hour = 9
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	next = next.AddDate(0, 0, (int(day)-int(now.Weekday())+7)%7)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 7)
+	}
+	return next, nil
+}
+
+// Parse is one player's DPS in one encounter.
+type Parse struct {
+	Actor string  `json:"actor"`
+	DPS   float64 `json:"dps"`
+	Boss  string  `json:"boss,omitempty"`
+	File  string  `json:"file"`
+}
+
+// NotableKill is a boss that died in a stored encounter.
+type NotableKill struct {
+	Boss     string        `json:"boss"`
+	Duration time.Duration `json:"duration"`
+	File     string        `json:"file"`
+}
+
+// Digest sums up a week of stored encounters.
+type Digest struct {
+	Guild      string           `json:"guild,omitempty"`
+	From       time.Time        `json:"from"`
+	To         time.Time        `json:"to"`
+	Encounters int              `json:"encounters"`
+	TopParses  []Parse          `json:"top_parses"`
+	Kills      []NotableKill    `json:"kills"`
+	Attendance AttendanceReport `json:"attendance"`
+}
+
+// bossKilled reports whether the boss of a record died in it.
+func bossKilled(record StoredEncounter) bool {
+	for _, s := range record.Stats {
+		name, _, _ := strings.Cut(s.Actor, "#")
+		if s.Kind == NPC && name == record.Boss && s.Deaths > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// weeklyDigest builds the digest of a guild's encounters stored in the
+// digestPeriod before now.
+func weeklyDigest(guild string, records []StoredEncounter, roster []string, now time.Time) Digest {
+	digest := Digest{Guild: guild, From: now.Add(-digestPeriod), To: now, TopParses: []Parse{}, Kills: []NotableKill{}}
+	week := []StoredEncounter{}
+	for _, record := range records {
+		if record.Guild != guild || record.Stored.Before(digest.From) || !record.Stored.Before(now) {
+			continue
+		}
+		week = append(week, record)
+		if record.Duration >= digestMinParse {
+			for _, s := range record.Stats {
+				if s.Kind == Player && s.Damage > 0 {
+					digest.TopParses = append(digest.TopParses, Parse{s.Actor, float64(s.Damage) / record.Duration.Seconds(), record.Boss, record.File})
+				}
+			}
+		}
+		if record.Boss != "" && bossKilled(record) {
+			digest.Kills = append(digest.Kills, NotableKill{record.Boss, record.Duration, record.File})
+		}
+	}
+	digest.Encounters = len(week)
+	sort.Slice(digest.TopParses, func(i, j int) bool { return digest.TopParses[i].DPS > digest.TopParses[j].DPS })
+	if len(digest.TopParses) > digestTopParses {
+		digest.TopParses = digest.TopParses[:digestTopParses]
+	}
+	sort.Slice(digest.Kills, func(i, j int) bool { return digest.Kills[i].Duration < digest.Kills[j].Duration })
+	digest.Attendance = attendance(guild, week, roster)
+	return digest
+}
+
+func writeDigest(w io.Writer, d Digest) {
+	name := d.Guild
+	if name == "" {
+		name = "SharedCombatGraphs"
+	}
+	fmt.Fprintf(w, "%v weekly digest, %v to %v: %d encounters\n", name, d.From.Format("Jan 2"), d.To.Format("Jan 2"), d.Encounters)
+	if len(d.TopParses) > 0 {
+		fmt.Fprintln(w, "\ntop parses:")
+		for i, p := range d.TopParses {
+			fmt.Fprintf(w, "  %d. %-16v %8.1f dps  %v (%v)\n", i+1, p.Actor, p.DPS, p.Boss, p.File)
+		}
+	}
+	if len(d.Kills) > 0 {
+		fmt.Fprintln(w, "\nkills:")
+		for _, k := range d.Kills {
+			fmt.Fprintf(w, "  %-24v %v (%v)\n", k.Boss, k.Duration, k.File)
+		}
+	}
+	if len(d.Attendance.Characters) > 0 {
+		fmt.Fprintf(w, "\nattendance over %d raid nights:\n", len(d.Attendance.Nights))
+		for _, a := range d.Attendance.Characters {
+			fmt.Fprintf(w, "  %-16v %d nights, %d bosses\n", a.Character, a.Nights, a.Bosses)
+		}
+	}
+}
+
+// sendDigest delivers a digest to the configured webhook and mailbox.
+func sendDigest(cfg DigestConfig, d Digest) error {
+	var text bytes.Buffer
+	writeDigest(&text, d)
+	if cfg.Webhook != "" {
+		body, err := json.Marshal(map[string]any{"text": text.String(), "digest": d})
+		if err != nil {
+			return err
+		}
+		client := &http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Post(cfg.Webhook, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("posting digest: %w", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("posting digest: %v", resp.Status)
+		}
+	}
+	if cfg.Email != nil {
+		host, _, _ := strings.Cut(cfg.Email.SMTP, ":")
+		var auth smtp.Auth
+		if cfg.Email.Username != "" {
+			auth = smtp.PlainAuth("", cfg.Email.Username, cfg.Email.Password, host)
+		}
+		msg := fmt.Sprintf("From: %v\r\nTo: %v\r\nSubject: Weekly digest %v\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%v",
+			cfg.Email.From, strings.Join(cfg.Email.To, ", "), d.To.Format("2006-01-02"), strings.ReplaceAll(text.String(), "\n", "\r\n"))
+		if err := smtp.SendMail(cfg.Email.SMTP, auth, cfg.Email.From, cfg.Email.To, []byte(msg)); err != nil {
+			return fmt.Errorf("mailing digest: %w", err)
+		}
+	}
+	return nil
+}
+
+// digestNow builds and sends the digest of the store as of now.
+func digestNow(store Store, guilds *workspaces, cfg DigestConfig) error {
+	records, err := store.ListEncounters()
+	if err != nil {
+		return err
+	}
+	return sendDigest(cfg, weeklyDigest(cfg.Guild, records, guilds.roster(cfg.Guild), time.Now()))
+}
+
+// scheduleDigests sends the digest every week at the configured time until
+// done is closed.
+func scheduleDigests(store Store, guilds *workspaces, cfg DigestConfig, done <-chan struct{}) {
+	if !cfg.enabled() {
+		return
+	}
+	for {
+		next, err := cfg.next(time.Now())
+		if err != nil {
+			slog.Error("scheduling digest", "err", err)
+			return
+		}
+		slog.Info("next digest", "at", next)
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if err := digestNow(store, guilds, cfg); err != nil {
+			slog.Error("sending digest", "err", err)
+		}
+	}
+}
+
+// runDigest prints the digest of the last week, or sends it with -send.
+func runDigest(args []string) error {
+	fs := flag.NewFlagSet("digest", flag.ExitOnError)
+	setup := commonFlags(fs)
+	open := storeFlags(fs)
+	guild := fs.String("guild", "", "guild workspace to report on (default from config)")
+	path := fs.String("workspaces", "", "guild registry file (default from config)")
+	send := fs.Bool("send", false, "send the digest to the configured webhook and email instead of printing it")
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	if *guild != "" {
+		config.Digest.Guild = *guild
+	}
+	if *path != "" {
+		config.Server.Workspaces = *path
+	}
+	store, err := open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	guilds, err := openWorkspaces(config.Server.Workspaces)
+	if err != nil {
+		return err
+	}
+	if *send {
+		if !config.Digest.enabled() {
+			return fmt.Errorf("no digest webhook or email configured")
+		}
+		return digestNow(store, guilds, config.Digest)
+	}
+	records, err := store.ListEncounters()
+	if err != nil {
+		return err
+	}
+	writeDigest(os.Stdout, weeklyDigest(config.Digest.Guild, records, guilds.roster(config.Digest.Guild), time.Now()))
+	return nil
+}
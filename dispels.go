@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+func init() {
+	analyses["dispels"] = printDispels
+}
+
+// Dispel is a corruption removed from a mob.
+type Dispel struct {
+	At         time.Duration // since the encounter start
+	Corruption string
+	Target     string // entity ID
+	By         string
+	// Waited is how long the corruption could have been up: since the
+	// previous dispel of it from the same target, else since the target
+	// showed up. The log does not say when corruptions are applied, so this
+	// is an upper bound of the time to dispel.
+	Waited time.Duration
+}
+
+// DispelReport is the dispel responsiveness of an encounter.
+type DispelReport struct {
+	Dispels []Dispel
+	Wasted  int // dispels used with nothing to dispel
+	// Missed are configured corruptions never dispelled in the encounter.
+	Missed []string
+}
+
+func dispelReport(enc *Encounter, corruptions []string) DispelReport {
+	report := DispelReport{Dispels: []Dispel{}, Missed: []string{}}
+	last := map[string]time.Time{} // target and corruption -> previous dispel
+	removed := map[string]bool{}
+	for _, entry := range enc.Entries {
+		if entry.etype != CorruptionRemoved {
+			continue
+		}
+		if entry.Skill == "" {
+			report.Wasted++
+			continue
+		}
+		d := Dispel{At: entry.Timestamp.Sub(enc.Start), Corruption: entry.Skill, Target: entry.TargetID, By: entry.Source}
+		key := entry.TargetID + "\x00" + entry.Skill
+		if prev, ok := last[key]; ok {
+			d.Waited = entry.Timestamp.Sub(prev)
+		} else if target, ok := enc.Entities[entry.TargetID]; ok {
+			d.Waited = entry.Timestamp.Sub(target.FirstSeen)
+		}
+		last[key] = entry.Timestamp
+		removed[entry.Skill] = true
+		report.Dispels = append(report.Dispels, d)
+	}
+	for _, c := range corruptions {
+		if !removed[c] {
+			report.Missed = append(report.Missed, c)
+		}
+	}
+	sort.Strings(report.Missed)
+	return report
+}
+
+func printDispels(enc *Encounter) {
+	report := dispelReport(enc, config.Corruptions)
+	fmt.Println("dispels:")
+	for _, d := range report.Dispels {
+		fmt.Printf("  +%-7v %-22v from %-20v by %-12v up to %v after it could have been applied\n", d.At, d.Corruption, d.Target, d.By, d.Waited)
+	}
+	if report.Wasted > 0 {
+		fmt.Printf("  %d dispels with nothing to dispel\n", report.Wasted)
+	}
+	for _, c := range report.Missed {
+		fmt.Printf("  %v was never dispelled\n", c)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	// how much of a file is looked at to guess its encoding
+	charsetSniffBytes = 64 * 1024
+)
+
+// logCharset is the encoding of log files: "auto" or one of charsetDecoders.
+var logCharset = "auto"
+
+// cp1252High are the characters Windows-1252 puts at 0x80-0x9F, where
+// Latin-1 has control codes. The rest of the high half matches Latin-1.
+var cp1252High = [32]rune{
+	'€', '\u0081', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '\u008d', 'Ž', '\u008f',
+	'\u0090', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '\u009d', 'ž', 'Ÿ',
+}
+
+// charsetDecoders read one rune of each supported encoding.
+var charsetDecoders = map[string]func(r *bufio.Reader) (rune, error){
+	"utf-8": func(r *bufio.Reader) (rune, error) {
+		c, _, err := r.ReadRune()
+		return c, err
+	},
+	"windows-1252": func(r *bufio.Reader) (rune, error) {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b >= 0x80 && b < 0xa0 {
+			return cp1252High[b-0x80], nil
+		}
+		return rune(b), nil
+	},
+	"utf-16le": func(r *bufio.Reader) (rune, error) { return readUTF16(r, false) },
+	"utf-16be": func(r *bufio.Reader) (rune, error) { return readUTF16(r, true) },
+}
+
+func readUTF16(r *bufio.Reader, bigEndian bool) (rune, error) {
+	unit := func() (rune, error) {
+		var b [2]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, err
+		}
+		if bigEndian {
+			return rune(b[0])<<8 | rune(b[1]), nil
+		}
+		return rune(b[1])<<8 | rune(b[0]), nil
+	}
+	c, err := unit()
+	if err != nil || !utf16.IsSurrogate(c) {
+		return c, err
+	}
+	low, err := unit()
+	if err != nil {
+		return 0, err
+	}
+	return utf16.DecodeRune(c, low), nil
+}
+
+// decodingReader converts a byte stream of some charset to UTF-8.
+type decodingReader struct {
+	src    *bufio.Reader
+	decode func(r *bufio.Reader) (rune, error)
+	buf    bytes.Buffer
+	closer io.Closer
+}
+
+func (d *decodingReader) Read(p []byte) (int, error) {
+	for d.buf.Len() < len(p) {
+		c, err := d.decode(d.src)
+		if err == io.ErrUnexpectedEOF {
+			c, err = utf8.RuneError, nil
+		}
+		if err != nil {
+			if d.buf.Len() > 0 {
+				break
+			}
+			return 0, err
+		}
+		d.buf.WriteRune(c)
+	}
+	return d.buf.Read(p)
+}
+
+func (d *decodingReader) Close() error {
+	return d.closer.Close()
+}
+
+// detectCharset picks the encoding of a file from its byte order mark, or
+// from whether its start is valid UTF-8. It returns the charset and the
+// length of the BOM.
+func detectCharset(head []byte) (string, int) {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xef, 0xbb, 0xbf}):
+		return "utf-8", 3
+	case bytes.HasPrefix(head, []byte{0xff, 0xfe}):
+		return "utf-16le", 2
+	case bytes.HasPrefix(head, []byte{0xfe, 0xff}):
+		return "utf-16be", 2
+	}
+	// a multi-byte character may be cut off at the end of head
+	for i := 0; i < utf8.UTFMax && len(head) > 0 && !utf8.Valid(head); i++ {
+		head = head[:len(head)-1]
+	}
+	if !utf8.Valid(head) {
+		return "windows-1252", 0
+	}
+	return "utf-8", 0
+}
+
+// openLog opens a log file as UTF-8, converting it from logCharset or the
+// detected charset.
+func openLog(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	src := bufio.NewReaderSize(file, charsetSniffBytes)
+	head, _ := src.Peek(charsetSniffBytes)
+	charset, bom := detectCharset(head)
+	if logCharset != "auto" {
+		charset = strings.ToLower(logCharset)
+		if _, ok := charsetDecoders[charset]; !ok {
+			file.Close()
+			return nil, fmt.Errorf("unknown charset %q, want auto, utf-8, utf-16le, utf-16be or windows-1252", logCharset)
+		}
+		if detected, _ := detectCharset(head); detected != charset {
+			bom = 0
+		}
+	}
+	src.Discard(bom)
+	if charset == "utf-8" {
+		return struct {
+			io.Reader
+			io.Closer
+		}{src, file}, nil
+	}
+	return &decodingReader{src: src, decode: charsetDecoders[charset], closer: file}, nil
+}
+
+// decodeLine converts a single line read without openLog, as follow does.
+// Only single-byte charsets can be converted line by line.
+func decodeLine(line string) string {
+	if utf8.ValidString(line) && logCharset != "windows-1252" {
+		return line
+	}
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		if c := line[i]; c >= 0x80 && c < 0xa0 {
+			b.WriteRune(cp1252High[c-0x80])
+		} else {
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String()
+}
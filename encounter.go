@@ -0,0 +1,231 @@
+package main
+
+import (
+	"slices"
+	"time"
+)
+
+const (
+	// a break in combat events longer than this starts a new encounter
+	encounterIdleGap = 30 * time.Second
+	// trash ending at most this long before a boss pull is merged into it
+	mergeTrashWindow = 2 * time.Minute
+	// an NPC that stays in the fight this long is taken for a boss, trash dies
+	// faster
+	bossMinLife = time.Minute
+)
+
+// SegmentOptions tune where encounters begin and end. A skirmish wants short
+// gaps and every pack, a raid wants whole boss pulls.
+type SegmentOptions struct {
+	// IdleGapSecs replaces encounterIdleGap when set.
+	IdleGapSecs float64 `json:"idle_gap_seconds,omitempty"`
+	// MinLengthSecs drops shorter encounters.
+	MinLengthSecs float64 `json:"min_length_seconds,omitempty"`
+	// RequireBoss drops encounters without a boss.
+	RequireBoss bool `json:"require_boss,omitempty"`
+	// MergeTrash adds trash fought right before a boss pull to that pull.
+	MergeTrash bool `json:"merge_trash,omitempty"`
+	// Bosses names the bosses; without it any NPC that took damage for at
+	// least bossMinLife counts as a boss.
+	Bosses []string `json:"bosses,omitempty"`
+}
+
+// segmentation is the segmentation in use, from the config and flags.
+var segmentation SegmentOptions
+
+func (o SegmentOptions) idleGap() time.Duration {
+	if o.IdleGapSecs > 0 {
+		return time.Duration(o.IdleGapSecs * float64(time.Second))
+	}
+	return encounterIdleGap
+}
+
+func (o SegmentOptions) minLength() time.Duration {
+	return time.Duration(o.MinLengthSecs * float64(time.Second))
+}
+
+// hasBoss reports whether a boss took part in the encounter.
+func (o SegmentOptions) hasBoss(enc *Encounter) bool {
+	for _, e := range enc.Entities {
+		if o.isBoss(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBoss tells a boss from trash: one of Bosses, or without them an NPC
+// that took damage for at least bossMinLife.
+func (o SegmentOptions) isBoss(e *Entity) bool {
+	if len(o.Bosses) > 0 {
+		return slices.Contains(o.Bosses, e.Name)
+	}
+	end := e.LastSeen
+	if !e.Died.IsZero() {
+		end = e.Died
+	}
+	return e.Kind == NPC && e.Damage > 0 && end.Sub(e.FirstSeen) >= bossMinLife
+}
+
+// isCombat reports whether events of this type take part in encounters.
+func (t EventType) isCombat() bool {
+	switch t {
+	case Comment, Chat, Loot, Currency, Experience, LevelUp, Progress:
+		return false
+	}
+	return true
+}
+
+// Encounter is a contiguous block of combat entries.
+type Encounter struct {
+	Start   time.Time
+	End     time.Time
+	Entries []*LogEntry
+
+	// Label and Notes come from "### label: ..." and "### note: ..." comments,
+	// any other "### key: value" comment ends up in Meta.
+	Label string
+	Notes []string
+	Meta  map[string]string
+	// Build comes from a "### build: ..." comment and, unlike the label,
+	// carries over to the following encounters until the next one.
+	Build string
+	// Character is the logging character, from a "### character: ..."
+	// comment or detected, see detectCharacter.
+	Character string
+
+	// Entities are keyed by the IDs set on the entries.
+	Entities map[string]*Entity
+}
+
+// Duration is the time between the first and last entry of the encounter.
+func (e *Encounter) Duration() time.Duration {
+	return e.End.Sub(e.Start)
+}
+
+// addMeta records comment metadata on the encounter.
+func (e *Encounter) addMeta(key, value string) {
+	switch key {
+	case "label":
+		e.Label = value
+	case "note":
+		e.Notes = append(e.Notes, value)
+	case "build":
+		e.Build = value
+	case "character":
+		e.Character = value
+	default:
+		if e.Meta == nil {
+			e.Meta = map[string]string{}
+		}
+		e.Meta[key] = value
+	}
+}
+
+// segmentEncounters groups entries into encounters separated by idle gaps.
+// Comment metadata is attached to the encounter of the next timestamped entry,
+// or to the last encounter if the comment trails the log.
+func segmentEncounters(entries []*LogEntry) []*Encounter {
+	encounters := []*Encounter{}
+	var cur *Encounter
+	pending := []*LogEntry{}
+	build := ""
+	for _, entry := range entries {
+		if entry.etype == Comment {
+			if entry.MetaKey != "" {
+				pending = append(pending, entry)
+			}
+			continue
+		}
+		if !entry.etype.isCombat() {
+			continue
+		}
+		newEncounter := cur == nil
+		if cur != nil {
+			// a large backwards jump means a new session was appended to the log
+			gap := entry.Timestamp.Sub(cur.End)
+			newEncounter = gap > segmentation.idleGap() || gap < -reorderWindow
+		}
+		if newEncounter {
+			cur = &Encounter{Start: entry.Timestamp, End: entry.Timestamp, Build: build}
+			encounters = append(encounters, cur)
+		}
+		for _, c := range pending {
+			cur.addMeta(c.MetaKey, c.MetaValue)
+		}
+		pending = pending[:0]
+		build = cur.Build
+		cur.Entries = append(cur.Entries, entry)
+		cur.End = entry.Timestamp
+	}
+	if cur != nil {
+		for _, c := range pending {
+			cur.addMeta(c.MetaKey, c.MetaValue)
+		}
+	}
+	character := ""
+	for _, enc := range encounters {
+		identifyActors(enc, character)
+		character = enc.Character
+	}
+	if segmentation.MergeTrash {
+		encounters = mergeTrash(encounters)
+	}
+	kept := encounters[:0]
+	for _, enc := range encounters {
+		if enc.Duration() < segmentation.minLength() || segmentation.RequireBoss && !segmentation.hasBoss(enc) {
+			continue
+		}
+		kept = append(kept, enc)
+	}
+	return kept
+}
+
+// identifyActors maps the actors of an encounter onto their identities and
+// the logging character, then assigns the entities. An encounter without a
+// sign of its character is taken to be played on character.
+func identifyActors(enc *Encounter, character string) {
+	applyIdentities(enc)
+	if enc.Character == "" {
+		enc.Character = detectCharacter(enc)
+	}
+	if enc.Character == "" {
+		enc.Character = character
+	}
+	resolveSelf(enc)
+	assignEntities(enc)
+}
+
+// mergeTrash folds trash encounters into a boss pull that follows within
+// mergeTrashWindow.
+func mergeTrash(encounters []*Encounter) []*Encounter {
+	merged := []*Encounter{}
+	for i := 0; i < len(encounters); i++ {
+		enc := encounters[i]
+		j := i
+		for j+1 < len(encounters) && !segmentation.hasBoss(encounters[j]) &&
+			encounters[j+1].Start.Sub(encounters[j].End) <= mergeTrashWindow {
+			j++
+		}
+		if j == i || !segmentation.hasBoss(encounters[j]) {
+			merged = append(merged, enc)
+			continue
+		}
+		pull := &Encounter{Start: enc.Start, End: encounters[j].End, Build: encounters[j].Build, Character: encounters[j].Character}
+		for _, part := range encounters[i : j+1] {
+			pull.Entries = append(pull.Entries, part.Entries...)
+			if part.Label != "" {
+				pull.Label = part.Label
+			}
+			pull.Notes = append(pull.Notes, part.Notes...)
+			for k, v := range part.Meta {
+				pull.addMeta(k, v)
+			}
+		}
+		assignEntities(pull)
+		merged = append(merged, pull)
+		i = j
+	}
+	return merged
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSegmentation(t *testing.T) {
+	defer func(o SegmentOptions) { segmentation = o }(segmentation)
+	var entries []*LogEntry
+	// trash, a boss pull 40s later lasting 80s, then more trash after a break
+	for _, secs := range []float64{0, 2, 5} {
+		entries = append(entries, hitOn(secs, "Starlaf", "Goblin", 100))
+	}
+	for secs := 45.0; secs <= 125; secs += 10 {
+		entries = append(entries, hitOn(secs, "Starlaf", "Burkhad", 100))
+	}
+	for _, secs := range []float64{330, 333} {
+		entries = append(entries, hitOn(secs, "Starlaf", "Wolf", 100))
+	}
+	tests := []struct {
+		name   string
+		opts   SegmentOptions
+		starts []float64 // encounter starts in seconds
+	}{
+		{"default", SegmentOptions{}, []float64{0, 45, 330}},
+		{"idle gap", SegmentOptions{IdleGapSecs: 60}, []float64{0, 330}},
+		{"min length", SegmentOptions{MinLengthSecs: 10}, []float64{45}},
+		{"require boss", SegmentOptions{RequireBoss: true}, []float64{45}},
+		{"named boss", SegmentOptions{RequireBoss: true, Bosses: []string{"Wolf"}}, []float64{330}},
+		{"merge trash", SegmentOptions{MergeTrash: true}, []float64{0, 330}},
+		{"merge trash into a boss", SegmentOptions{MergeTrash: true, RequireBoss: true}, []float64{0}},
+	}
+	for _, tt := range tests {
+		segmentation = tt.opts
+		encounters := segmentEncounters(entries)
+		var starts []float64
+		for _, enc := range encounters {
+			starts = append(starts, enc.Start.Sub(testStart).Seconds())
+		}
+		if !slices.Equal(starts, tt.starts) {
+			t.Errorf("%v: got encounters at %v, want %v", tt.name, starts, tt.starts)
+		}
+	}
+}
+
+func TestIdleGapDefault(t *testing.T) {
+	if got := (SegmentOptions{}).idleGap(); got != encounterIdleGap {
+		t.Errorf("got %v, want %v", got, encounterIdleGap)
+	}
+	if got := (SegmentOptions{IdleGapSecs: 1.5}).idleGap(); got != 1500*time.Millisecond {
+		t.Errorf("got %v, want 1.5s", got)
+	}
+}
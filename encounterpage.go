@@ -0,0 +1,348 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"sort"
+	"time"
+)
+
+const (
+	// hits taken listed before each death in the deaths panel
+	deathRecapHits = 5
+)
+
+// SkillSummary is an actor's use of one skill in an encounter.
+type SkillSummary struct {
+	Skill string `json:"skill"`
+	Hits  int    `json:"hits"`
+	Crits int    `json:"crits"`
+	Total int    `json:"total"`
+	Max   int    `json:"max"`
+}
+
+// ActorTab is the per-skill breakdown of one actor on the encounter page.
+type ActorTab struct {
+	Actor   string         `json:"actor"`
+	Damage  []SkillSummary `json:"damage"`
+	Healing []SkillSummary `json:"healing"`
+}
+
+// RecapHit is a hit a player took shortly before dying.
+type RecapHit struct {
+	At     time.Duration `json:"at"`
+	Source string        `json:"source"`
+	Skill  string        `json:"skill"`
+	Value  int           `json:"value"`
+}
+
+// DeathRecap is a player death with the hits that led to it.
+type DeathRecap struct {
+	At    time.Duration `json:"at"`
+	Actor string        `json:"actor"`
+	Hits  []RecapHit    `json:"hits"`
+}
+
+// EncounterPage is the data behind the encounter page of serve mode.
+type EncounterPage struct {
+	ID       string        `json:"id,omitempty"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Boss     string        `json:"boss,omitempty"`
+	Actors   []*ActorStats `json:"actors"`
+	Tabs     []ActorTab    `json:"tabs"`
+	Deaths   []DeathRecap  `json:"deaths"`
+	// Damage, Healing and Taken are per second totals of the players and
+	// their pets, for the timeline
+	Damage  []int `json:"damage"`
+	Healing []int `json:"healing"`
+	Taken   []int `json:"taken"`
+	// Charts are inlined SVG charts and Static links the page into a
+	// published site, both for publish.
+	Charts []template.HTML `json:"-"`
+	Static bool            `json:"-"`
+}
+
+// skillSummaries totals an actor's events of one type per skill, biggest
+// first.
+func skillSummaries(enc *Encounter, actor string, etype EventType) []SkillSummary {
+	bySkill := map[string]*SkillSummary{}
+	for _, entry := range enc.Entries {
+		if entry.etype != etype || entry.SourceID != actor || entry.Skill == "" {
+			continue
+		}
+		s, ok := bySkill[entry.Skill]
+		if !ok {
+			s = &SkillSummary{Skill: entry.Skill}
+			bySkill[entry.Skill] = s
+		}
+		s.Hits++
+		if entry.Crit || entry.Dev {
+			s.Crits++
+		}
+		s.Total += entry.Value
+		s.Max = max(s.Max, entry.Value)
+	}
+	result := make([]SkillSummary, 0, len(bySkill))
+	for _, s := range bySkill {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Total > result[j].Total })
+	return result
+}
+
+// deathRecaps lists the player deaths of an encounter with the last hits
+// each player took.
+func deathRecaps(enc *Encounter) []DeathRecap {
+	recaps := []DeathRecap{}
+	taken := map[string][]RecapHit{}
+	for _, entry := range enc.Entries {
+		if entry.TargetID == "" || enc.kindOf(entry.TargetID) != Player {
+			continue
+		}
+		at := entry.Timestamp.Sub(enc.Start)
+		switch entry.etype {
+		case DmgDealt:
+			hits := append(taken[entry.TargetID], RecapHit{At: at, Source: entry.SourceID, Skill: entry.Skill, Value: entry.Value})
+			taken[entry.TargetID] = hits[max(0, len(hits)-deathRecapHits):]
+		case Death:
+			recaps = append(recaps, DeathRecap{At: at, Actor: entry.TargetID, Hits: taken[entry.TargetID]})
+			delete(taken, entry.TargetID)
+		}
+	}
+	return recaps
+}
+
+// encounterPage gathers what the encounter page shows.
+func encounterPage(id string, enc *Encounter) EncounterPage {
+	seconds := int(enc.Duration()/time.Second) + 1
+	page := EncounterPage{
+		ID: id, Start: enc.Start, Duration: enc.Duration(), Boss: encounterBoss(enc),
+		Deaths: deathRecaps(enc),
+		Damage: make([]int, seconds), Healing: make([]int, seconds), Taken: make([]int, seconds),
+	}
+	for _, s := range actorStats(enc) {
+		if s.Kind == NPC || s.Damage+s.Healing+s.DamageTaken == 0 && s.Deaths == 0 {
+			continue
+		}
+		page.Actors = append(page.Actors, s)
+		if s.Kind == Player {
+			page.Tabs = append(page.Tabs, ActorTab{
+				Actor:   s.Actor,
+				Damage:  skillSummaries(enc, s.Actor, DmgDealt),
+				Healing: skillSummaries(enc, s.Actor, Heal),
+			})
+		}
+	}
+	for _, entry := range enc.Entries {
+		second := min(seconds-1, int(entry.Timestamp.Sub(enc.Start)/time.Second))
+		switch {
+		case entry.etype == DmgDealt && enc.kindOf(entry.SourceID) != NPC && entry.SourceID != "":
+			page.Damage[second] += entry.Value
+		case entry.etype == DmgDealt && enc.kindOf(entry.TargetID) != NPC:
+			page.Taken[second] += entry.Value
+		case entry.etype == Heal:
+			page.Healing[second] += entry.Value
+		}
+	}
+	return page
+}
+
+// pageFuncs format the numbers of the encounter pages.
+var pageFuncs = template.FuncMap{
+	"perSecond": func(total int, seconds float64) string {
+		if seconds <= 0 {
+			return "0"
+		}
+		return fmt.Sprintf("%.0f", float64(total)/seconds)
+	},
+	"percentOf": func(part, whole int) string {
+		if whole == 0 {
+			return "0"
+		}
+		return fmt.Sprintf("%.0f", 100*float64(part)/float64(whole))
+	},
+}
+
+// EncountersPage is a list of stored encounters with the search that found
+// them.
+type EncountersPage struct {
+	Title      string
+	Query      map[string]string
+	Encounters []StoredEncounter
+	// Static lists the pages of a published site instead, without search.
+	Static bool
+}
+
+func encountersPage(query url.Values, list []StoredEncounter) EncountersPage {
+	page := EncountersPage{Title: "Encounters", Query: map[string]string{}, Encounters: list}
+	for key := range query {
+		page.Query[key] = query.Get(key)
+	}
+	return page
+}
+
+// encountersHTML lists stored encounters with links to their pages, below a
+// form for the search API.
+var encountersHTML = template.Must(template.New("encounters").Funcs(themeFuncs).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title>
+<style>body { background: {{theme.Background}}; color: {{theme.Foreground}}; font-family: sans-serif; } a { color: inherit; } th { text-align: left; }</style></head>
+<body>
+<h1>{{.Title}}</h1>
+{{if not .Static}}<form action="search">
+<input type="hidden" name="format" value="html">
+<label>Boss <input name="boss" value="{{.Query.boss}}"></label>
+<label>Character <input name="character" value="{{.Query.character}}"></label>
+<label>Label <input name="label" value="{{.Query.label}}"></label>
+<label>From <input type="date" name="from" value="{{.Query.from}}"></label>
+<label>To <input type="date" name="to" value="{{.Query.to}}"></label>
+<label>Min DPS <input type="number" name="min_dps" value="{{.Query.min_dps}}"></label>
+<select name="outcome"><option value="">kills and wipes</option><option value="kill"{{if eq .Query.outcome "kill"}} selected{{end}}>kills</option><option value="wipe"{{if eq .Query.outcome "wipe"}} selected{{end}}>wipes</option></select>
+<button>Search</button>
+</form>{{end}}
+<table>
+<tr><th>Start</th><th>Duration</th><th>Boss</th><th>Label</th><th>File</th></tr>
+{{$static := .Static}}{{range .Encounters}}<tr><td><a href="encounters/{{.ID}}{{if $static}}.html{{else}}?format=html{{end}}">{{.Start.Format "01/02 15:04:05"}}</a></td><td>{{.Duration}}</td><td>{{.Boss}}</td><td>{{.Label}}</td><td>{{.File}}</td></tr>
+{{end}}</table>
+</body></html>
+`))
+
+// encounterHTML is the encounter page: sortable tables, a tab per player
+// with their skills, a timeline that zooms into a range dragged across it,
+// and the deaths with the hits before them.
+var encounterHTML = template.Must(template.New("encounter").Funcs(themeFuncs).Funcs(pageFuncs).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{with .Boss}}{{.}}{{else}}Encounter{{end}} {{.Start.Format "15:04:05"}}</title>
+<style>
+body { background: {{theme.Background}}; color: {{theme.Foreground}}; font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { padding: 2px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+th { cursor: pointer; border-bottom: 1px solid {{theme.Muted}}; }
+.tabs button { background: none; color: inherit; border: 1px solid {{theme.Muted}}; margin-right: 2px; cursor: pointer; }
+.tabs button.active { border-bottom-color: {{theme.Background}}; font-weight: bold; }
+.tab { display: none; }
+.tab.active { display: block; }
+#timeline { border: 1px solid {{theme.Muted}}; user-select: none; }
+.legend span { margin-right: 1em; }
+</style></head>
+<body>
+{{if .Static}}<p><a href="../index.html">All encounters</a></p>
+{{end}}<h1>{{with .Boss}}{{.}}{{else}}Trash{{end}} at {{.Start.Format "15:04:05"}} ({{.Duration}})</h1>
+
+<h2>Damage and healing</h2>
+<table class="sortable">
+<tr><th>Actor</th><th>Kind</th><th>Damage</th><th>DPS</th><th>Boss DPS</th><th>Healing</th><th>HPS</th><th>Taken</th><th>Deaths</th></tr>
+{{$secs := .Duration.Seconds}}{{range .Actors}}<tr><td>{{.Actor}}</td><td>{{.Kind}}</td><td>{{.Damage}}</td><td>{{perSecond .Damage $secs}}</td><td>{{perSecond .BossDamage $secs}}</td><td>{{.Healing}}</td><td>{{perSecond .Healing $secs}}</td><td>{{.DamageTaken}}</td><td>{{.Deaths}}</td></tr>
+{{end}}</table>
+
+<h2>Timeline</h2>
+<p class="legend"><span style="color: {{theme.Damage}}">damage</span><span style="color: {{theme.Heal}}">healing</span><span style="color: {{theme.Muted}}">damage taken</span>drag to zoom, double click to reset</p>
+<svg id="timeline" width="960" height="220"></svg>
+
+{{if .Charts}}<h2>Charts</h2>
+{{range .Charts}}{{.}}
+{{end}}{{end}}
+<h2>Players</h2>
+<div class="tabs">{{range $i, $tab := .Tabs}}<button data-tab="tab{{$i}}"{{if eq $i 0}} class="active"{{end}}>{{$tab.Actor}}</button>{{end}}</div>
+{{range $i, $tab := .Tabs}}<div class="tab{{if eq $i 0}} active{{end}}" id="tab{{$i}}">
+{{if $tab.Damage}}<h3>Damage</h3>
+<table class="sortable">
+<tr><th>Skill</th><th>Hits</th><th>Crit %</th><th>Total</th><th>Max</th></tr>
+{{range $tab.Damage}}<tr><td>{{.Skill}}</td><td>{{.Hits}}</td><td>{{percentOf .Crits .Hits}}</td><td>{{.Total}}</td><td>{{.Max}}</td></tr>
+{{end}}</table>
+{{end}}{{if $tab.Healing}}<h3>Healing</h3>
+<table class="sortable">
+<tr><th>Skill</th><th>Hits</th><th>Crit %</th><th>Total</th><th>Max</th></tr>
+{{range $tab.Healing}}<tr><td>{{.Skill}}</td><td>{{.Hits}}</td><td>{{percentOf .Crits .Hits}}</td><td>{{.Total}}</td><td>{{.Max}}</td></tr>
+{{end}}</table>
+{{end}}</div>
+{{end}}
+
+<h2>Deaths</h2>
+{{range .Deaths}}<h3>{{.Actor}} at +{{.At}}</h3>
+{{if .Hits}}<table>
+<tr><th>Time</th><th>Source</th><th>Skill</th><th>Damage</th></tr>
+{{range .Hits}}<tr><td>+{{.At}}</td><td>{{.Source}}</td><td>{{.Skill}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+{{else}}<p>no damage taken before the death was logged</p>
+{{end}}{{else}}<p>Nobody died.</p>
+{{end}}
+
+<script>
+const series = [
+  {values: {{.Damage}}, color: {{theme.Damage}}},
+  {values: {{.Healing}}, color: {{theme.Heal}}},
+  {values: {{.Taken}}, color: {{theme.Muted}}},
+];
+const deaths = [{{range .Deaths}}{{.At.Seconds}},{{end}}];
+const svg = document.getElementById("timeline");
+const W = svg.width.baseVal.value, H = svg.height.baseVal.value, NS = "http://www.w3.org/2000/svg";
+let lo = 0, hi = series[0].values.length - 1, dragFrom = null;
+
+function el(name, attrs) {
+  const e = document.createElementNS(NS, name);
+  for (const k in attrs) e.setAttribute(k, attrs[k]);
+  svg.appendChild(e);
+  return e;
+}
+function x(sec) { return (sec - lo) / Math.max(1, hi - lo) * W; }
+function sec(px) { return lo + px / W * (hi - lo); }
+function draw() {
+  svg.replaceChildren();
+  let top = 1;
+  for (const s of series) for (let i = lo; i <= hi; i++) top = Math.max(top, s.values[i]);
+  for (const s of series) {
+    const points = [];
+    for (let i = lo; i <= hi; i++) points.push(x(i) + "," + (H - 20 - s.values[i] / top * (H - 30)));
+    el("polyline", {points: points.join(" "), fill: "none", stroke: s.color});
+  }
+  for (const d of deaths) if (d >= lo && d <= hi) el("line", {x1: x(d), x2: x(d), y1: 0, y2: H - 20, stroke: {{theme.Death}}, "stroke-dasharray": "3,3"});
+  const t = el("text", {x: 4, y: H - 4, fill: {{theme.Foreground}}, "font-size": 12});
+  t.textContent = "+" + lo + "s to +" + hi + "s, peak " + top + "/s";
+}
+svg.addEventListener("mousedown", e => { dragFrom = e.offsetX; });
+svg.addEventListener("mousemove", e => {
+  if (dragFrom === null) return;
+  draw();
+  el("rect", {x: Math.min(dragFrom, e.offsetX), y: 0, width: Math.abs(e.offsetX - dragFrom), height: H, fill: {{theme.Muted}}, "fill-opacity": 0.3});
+});
+svg.addEventListener("mouseup", e => {
+  const a = Math.round(sec(Math.min(dragFrom, e.offsetX))), b = Math.round(sec(Math.max(dragFrom, e.offsetX)));
+  dragFrom = null;
+  if (b - a >= 2) { lo = a; hi = b; }
+  draw();
+});
+svg.addEventListener("dblclick", () => { lo = 0; hi = series[0].values.length - 1; draw(); });
+draw();
+
+for (const button of document.querySelectorAll(".tabs button")) {
+  button.addEventListener("click", () => {
+    for (const b of document.querySelectorAll(".tabs button")) b.classList.toggle("active", b === button);
+    for (const tab of document.querySelectorAll(".tab")) tab.classList.toggle("active", tab.id === button.dataset.tab);
+  });
+}
+
+for (const table of document.querySelectorAll("table.sortable")) {
+  table.querySelectorAll("th").forEach((th, col) => {
+    let descending = false;
+    th.addEventListener("click", () => {
+      descending = !descending;
+      const rows = Array.from(table.rows).slice(1);
+      const value = row => {
+        const text = row.cells[col].textContent;
+        const n = parseFloat(text);
+        return isNaN(n) ? text : n;
+      };
+      rows.sort((a, b) => {
+        const va = value(a), vb = value(b);
+        const order = va < vb ? -1 : va > vb ? 1 : 0;
+        return descending ? -order : order;
+      });
+      for (const row of rows) table.tBodies[0].appendChild(row);
+    });
+  });
+}
+</script>
+</body></html>
+`))
@@ -0,0 +1,111 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+//go:embed data/bosses.json
+var bossesJSON []byte
+
+// BossInfo is the static metadata we know about a boss.
+type BossInfo struct {
+	Name       string  `json:"name"`
+	EnrageSecs float64 `json:"enrage"`
+}
+
+var bossCatalog = mustLoadBosses(bossesJSON)
+
+func mustLoadBosses(data []byte) map[string]BossInfo {
+	bosses := []BossInfo{}
+	if err := json.Unmarshal(data, &bosses); err != nil {
+		panic(fmt.Sprintf("invalid boss catalog: %v", err))
+	}
+	catalog := make(map[string]BossInfo, len(bosses))
+	for _, b := range bosses {
+		catalog[b.Name] = b
+	}
+	return catalog
+}
+
+// enrageTimer is how long after the pull a boss enrages, from the config
+// over the catalog, zero if unknown.
+func enrageTimer(boss string) time.Duration {
+	secs, ok := config.EnrageSecs[boss]
+	if !ok {
+		secs = bossCatalog[boss].EnrageSecs
+	}
+	return time.Duration(secs * float64(time.Second))
+}
+
+// EnrageStatus is a fight against a boss with an enrage timer.
+type EnrageStatus struct {
+	Boss  string
+	Timer time.Duration
+	// Elapsed runs from the boss's first line to its death, or to the end
+	// of the encounter.
+	Elapsed time.Duration
+	Killed  bool
+}
+
+// Left is the time before the enrage, negative once it is past.
+func (s *EnrageStatus) Left() time.Duration {
+	return s.Timer - s.Elapsed
+}
+
+// String is the status as reports show it.
+func (s *EnrageStatus) String() string {
+	left := s.Left().Round(time.Second)
+	switch {
+	case s.Killed && left >= 0:
+		return fmt.Sprintf("%v killed with %v of the %v enrage timer left", s.Boss, clockTime(left), clockTime(s.Timer))
+	case s.Killed:
+		return fmt.Sprintf("%v killed %v into the enrage", s.Boss, clockTime(-left))
+	case left >= 0:
+		return fmt.Sprintf("%v wipe %v short of the %v enrage timer", s.Boss, clockTime(left), clockTime(s.Timer))
+	default:
+		return fmt.Sprintf("%v wipe %v into the enrage", s.Boss, clockTime(-left))
+	}
+}
+
+// encounterEnrage finds the boss with an enrage timer that took the most
+// damage in the encounter, nil if none did.
+func encounterEnrage(enc *Encounter) *EnrageStatus {
+	var boss *Entity
+	for _, e := range enc.Entities {
+		if e.Kind != NPC || e.Damage == 0 || enrageTimer(e.Name) == 0 {
+			continue
+		}
+		if boss == nil || e.Damage > boss.Damage || e.Damage == boss.Damage && e.ID < boss.ID {
+			boss = e
+		}
+	}
+	if boss == nil {
+		return nil
+	}
+	end := enc.End
+	if !boss.Died.IsZero() {
+		end = boss.Died
+	}
+	return &EnrageStatus{Boss: boss.Name, Timer: enrageTimer(boss.Name), Elapsed: end.Sub(boss.FirstSeen), Killed: !boss.Died.IsZero()}
+}
+
+// enrageCountdown is the live meter's line about the enrage of the boss
+// being fought, "" without one.
+func enrageCountdown(enc *Encounter) string {
+	s := encounterEnrage(enc)
+	if s == nil || s.Killed {
+		return ""
+	}
+	if left := s.Left(); left > 0 {
+		return fmt.Sprintf("%v enrages in %v", s.Boss, clockTime(left.Round(time.Second)))
+	}
+	return fmt.Sprintf("%v ENRAGED %v ago", s.Boss, clockTime(-s.Left().Round(time.Second)))
+}
+
+// clockTime writes a duration as minutes and seconds, like 4:32.
+func clockTime(d time.Duration) string {
+	return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
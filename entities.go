@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+func init() {
+	analyses["entities"] = printEntities
+}
+
+// Entity is one life of an actor within an encounter. Mobs sharing a name get
+// a new entity after each death, so "Orc-warrior#2" is the second one killed.
+type Entity struct {
+	ID        string
+	Name      string
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Died      time.Time // zero if it survived the encounter
+	Damage    int       // damage taken
+	Kind      ActorKind
+}
+
+// entityName strips the article the game puts in front of mob names, so "the
+// Basking Rock-worm" and "The Basking Rock-worm" are the same actor.
+func entityName(name string) string {
+	for _, article := range []string{"the ", "The "} {
+		if strings.HasPrefix(name, article) {
+			return name[len(article):]
+		}
+	}
+	return name
+}
+
+// assignEntities fills SourceID and TargetID on the encounter's entries and
+// records the entities. Players keep one entity for the whole encounter since
+// they are revived rather than respawned.
+func assignEntities(enc *Encounter) {
+	friends := playerNames(enc)
+	enc.Entities = map[string]*Entity{}
+	alive := map[string]*Entity{}
+	dead := map[string]*Entity{} // last entity to die per name
+	lives := map[string]int{}
+
+	resolve := func(raw string, at time.Time) string {
+		if raw == "" || raw == selfplaceholder {
+			return raw
+		}
+		name := entityName(raw)
+		if e, ok := alive[name]; ok {
+			e.LastSeen = at
+			return e.ID
+		}
+		// lines logged in the same second as a death still belong to the corpse
+		if e, ok := dead[name]; ok && at.Equal(e.Died) {
+			return e.ID
+		}
+		lives[name]++
+		e := &Entity{ID: name, Name: name, FirstSeen: at, LastSeen: at}
+		if !friends[raw] && !friends[name] {
+			e.ID = fmt.Sprintf("%v#%d", name, lives[name])
+		}
+		alive[name] = e
+		enc.Entities[e.ID] = e
+		return e.ID
+	}
+
+	for _, entry := range enc.Entries {
+		entry.SourceID = resolve(entry.Source, entry.Timestamp)
+		name := entityName(entry.Target)
+		if entry.etype == Death && entry.Target != "" && !friends[entry.Target] && !friends[name] {
+			if _, ok := alive[name]; !ok {
+				// several mobs of the same name died together
+				delete(dead, name)
+			}
+			entry.TargetID = resolve(entry.Target, entry.Timestamp)
+			e := enc.Entities[entry.TargetID]
+			e.Died = entry.Timestamp
+			dead[name] = e
+			delete(alive, name)
+			continue
+		}
+		entry.TargetID = resolve(entry.Target, entry.Timestamp)
+		if entry.etype == DmgDealt && entry.TargetID != "" {
+			if e, ok := enc.Entities[entry.TargetID]; ok {
+				e.Damage += entry.Value
+			}
+		}
+	}
+	classifyActors(enc, friends)
+}
+
+func printEntities(enc *Encounter) {
+	fmt.Println("entities:")
+	entities := make([]*Entity, 0, len(enc.Entities))
+	for _, e := range enc.Entities {
+		if e.Damage > 0 {
+			entities = append(entities, e)
+		}
+	}
+	sort.Slice(entities, func(i, j int) bool { return entities[i].FirstSeen.Before(entities[j].FirstSeen) })
+	for _, e := range entities {
+		fate := "survived"
+		if !e.Died.IsZero() {
+			fate = fmt.Sprintf("died at +%v after %v", e.Died.Sub(enc.Start), e.Died.Sub(e.FirstSeen))
+		}
+		fmt.Printf("  %-28v %-6v seen +%-7v took %-10v %v\n", e.ID, e.Kind, e.FirstSeen.Sub(enc.Start), e.Damage, fate)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+func init() {
+	commands["extract"] = runExtract
+}
+
+// entryFilter selects entries by actor, time of day and encounter.
+type entryFilter struct {
+	actor     string
+	from, to  time.Duration // time of day, zero means open
+	encounter int           // 1-based, zero means all
+}
+
+// timeOfDay parses "15:04:05" (or "15:04") into an offset from midnight.
+func timeOfDay(s string) (time.Duration, error) {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time of day %q, want HH:MM:SS", s)
+}
+
+func (f entryFilter) match(entry *LogEntry) bool {
+	if f.actor != "" && entityName(entry.Source) != f.actor && entityName(entry.Target) != f.actor {
+		return false
+	}
+	t := entry.Timestamp
+	tod := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if f.from != 0 && tod < f.from {
+		return false
+	}
+	if f.to != 0 && tod > f.to {
+		return false
+	}
+	return true
+}
+
+// runExtract writes the original lines of matching entries to a new file,
+// e.g. to share a single fight or make a minimal repro log.
+func runExtract(args []string) error {
+	fs := flag.NewFlagSet("extract", flag.ExitOnError)
+	setup := commonFlags(fs)
+	out := fs.String("o", "", "output file (required)")
+	actor := fs.String("actor", "", "only entries with this source or target")
+	from := fs.String("from", "", "only entries at or after this time of day (HH:MM:SS)")
+	to := fs.String("to", "", "only entries at or before this time of day (HH:MM:SS)")
+	encounter := fs.Int("encounter", 0, "only entries of this encounter (1-based)")
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	if *out == "" {
+		return fmt.Errorf("extract needs an output file, use -o")
+	}
+
+	filter := entryFilter{actor: *actor, encounter: *encounter}
+	var err error
+	if *from != "" {
+		if filter.from, err = timeOfDay(*from); err != nil {
+			return err
+		}
+	}
+	if *to != "" {
+		if filter.to, err = timeOfDay(*to); err != nil {
+			return err
+		}
+	}
+
+	result, err := parseFile(inputPath(fs), ParserOptions{})
+	if err != nil {
+		return err
+	}
+	entries := result.Entries
+	if filter.encounter > 0 {
+		encounters := segmentEncounters(entries)
+		if filter.encounter > len(encounters) {
+			return fmt.Errorf("log has %d encounters, asked for %d", len(encounters), filter.encounter)
+		}
+		entries = encounters[filter.encounter-1].Entries
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	written := 0
+	for _, entry := range entries {
+		if entry.Timestamp.IsZero() || !filter.match(entry) {
+			continue
+		}
+		fmt.Fprintln(w, entry.RawMessage)
+		written++
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %d lines to %v\n", written, *out)
+	return nil
+}
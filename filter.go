@@ -0,0 +1,62 @@
+package main
+
+// noiseRule recognizes a family of non-combat lines.
+type noiseRule struct {
+	channel string
+	pattern string
+}
+
+var noiseRules = []noiseRule{
+	{"", "noise.channel"},
+	{"Say", "noise.say"},
+	{"Tell", "noise.tell"},
+	{"Tell", "noise.tellout"},
+	{"Emote", "noise.emote"},
+	{"System", "noise.group"},
+	{"System", "noise.leader"},
+	{"System", "noise.online"},
+	{"System", "noise.welcome"},
+}
+
+// filterNoise reports whether the line is chat or a system message rather
+// than combat. Matching lines are returned as Chat entries, the channel in
+// Skill and the message in MetaValue.
+func filterNoise(line string) (*LogEntry, bool) {
+	timestamp, msg, err := extractTimestamp(line)
+	if err != nil {
+		msg = line
+	}
+	for _, rule := range noiseRules {
+		re := pattern(rule.pattern)
+		match := re.FindStringSubmatch(msg)
+		if len(match) == 0 {
+			continue
+		}
+		entry := &LogEntry{
+			Timestamp:  timestamp,
+			etype:      Chat,
+			Skill:      rule.channel,
+			RawMessage: line,
+		}
+		for i, name := range re.SubexpNames() {
+			if match[i] == "" {
+				continue
+			}
+			switch name {
+			case "channel":
+				entry.Skill = match[i]
+			case "speaker":
+				entry.Source = match[i]
+			case "target":
+				entry.Target = match[i]
+			case "msg":
+				entry.MetaValue = match[i]
+			}
+		}
+		if entry.Source == "You" {
+			entry.Source = selfplaceholder
+		}
+		return entry, true
+	}
+	return nil, false
+}
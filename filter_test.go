@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestFilterNoise(t *testing.T) {
+	tests := []struct {
+		line    string
+		noise   bool
+		channel string
+		speaker string
+		msg     string
+	}{
+		{"[07/08 05:35:08 PM] [Fellowship] Huya: 'pull in 5'", true, "Fellowship", "Huya", "pull in 5"},
+		{"[07/08 05:35:08 PM] [Kinship] Azmaul: 'gz!'", true, "Kinship", "Azmaul", "gz!"},
+		{"[07/08 05:35:08 PM] Huya says, 'ready?'", true, "Say", "Huya", "ready?"},
+		{"[07/08 05:35:08 PM] Huya tells you, 'inv pls'", true, "Tell", "Huya", "inv pls"},
+		{"[07/08 05:35:08 PM] You tell Huya, 'sure'", true, "Tell", "", "sure"},
+		{"[07/08 05:35:08 PM] Huya waves.", true, "Emote", "Huya", ""},
+		{"[07/08 05:35:08 PM] Huya has joined your Fellowship.", true, "System", "Huya", ""},
+		{"[07/08 05:35:08 PM] Huya is now the Fellowship leader.", true, "System", "Huya", ""},
+		{"[07/08 05:35:08 PM] Huya has gone offline.", true, "System", "Huya", ""},
+		{"[07/08 05:35:08 PM] Welcome to the Lord of the Rings Online!", true, "System", "", ""},
+		{"[07/08 05:35:34 PM] Starlaf scored a critical hit with Thrash - Tier 1 on Burkhad for 40,044 Beleriand damage to Morale.", false, "", "", ""},
+		{"[07/08 05:36:48 PM] Huya defeated Burkhad.", false, "", "", ""},
+	}
+	for _, tt := range tests {
+		entry, noise := filterNoise(tt.line)
+		if noise != tt.noise {
+			t.Errorf("%q: got noise %v, want %v", tt.line, noise, tt.noise)
+			continue
+		}
+		if !noise {
+			continue
+		}
+		if entry.etype != Chat || entry.Skill != tt.channel || entry.Source != tt.speaker || entry.MetaValue != tt.msg {
+			t.Errorf("%q: got %v in %q from %q saying %q, want Chat in %q from %q saying %q", tt.line,
+				entry.etype, entry.Skill, entry.Source, entry.MetaValue, tt.channel, tt.speaker, tt.msg)
+		}
+	}
+}
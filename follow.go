@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// how often follow checks the log for new lines
+	followPoll = 250 * time.Millisecond
+)
+
+func init() {
+	commands["follow"] = runFollow
+}
+
+// tailLines sends every line appended to path, starting at the end of the
+// file unless fromStart is set. The file is reopened if it gets truncated.
+func tailLines(path string, fromStart bool, out chan<- string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { file.Close() }()
+	offset := int64(0)
+	if !fromStart {
+		if offset, err = file.Seek(0, io.SeekEnd); err != nil {
+			return err
+		}
+	}
+	reader := bufio.NewReader(file)
+	partial := ""
+	for {
+		chunk, err := reader.ReadString('\n')
+		offset += int64(len(chunk))
+		partial += chunk
+		if err == nil {
+			out <- cleanLine(decodeLine(strings.TrimRight(partial, "\r\n")))
+			partial = ""
+			continue
+		}
+		if err != io.EOF {
+			return err
+		}
+		time.Sleep(followPoll)
+		if info, statErr := os.Stat(path); statErr == nil && info.Size() < offset {
+			file.Close()
+			if file, err = os.Open(path); err != nil {
+				return err
+			}
+			reader.Reset(file)
+			offset, partial = 0, ""
+		}
+	}
+}
+
+// parseLines turns raw lines into entries, dropping chat, failures and
+// anomalies. The hooks only see one entry at a time, so anomalies are
+// checked against a window of the hits before it.
+func parseLines(lines <-chan string, out chan<- *LogEntry) {
+	defer close(out)
+	window := newAnomalyWindow()
+	for line := range lines {
+		if _, ok := filterNoise(line); ok {
+			continue
+		}
+		entry, err := parseLogLine(line)
+		if err != nil {
+			continue
+		}
+		for _, entry := range runEntryHooks([]*LogEntry{entry}, nil) {
+			if window.check(entry) == "" {
+				out <- entry
+			}
+		}
+	}
+}
+
+// runFollow watches a log file as the game writes it and shows a live meter.
+func runFollow(args []string) error {
+	fs := flag.NewFlagSet("follow", flag.ExitOnError)
+	setup := commonFlags(fs)
+	fromStart := fs.Bool("from-start", false, "read the existing contents of the log first")
+	live := liveFlags(fs)
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	path := inputPath(fs)
+	profile, err := sniffLog(path)
+	if err != nil {
+		return err
+	}
+	if err := profile.apply(); err != nil {
+		return err
+	}
+
+	lines := make(chan string)
+	entries := make(chan *LogEntry)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- tailLines(path, *fromStart, lines)
+		close(lines)
+	}()
+	go parseLines(lines, entries)
+	ctx, stop := interruptContext()
+	defer stop()
+	meter := &LiveMeter{}
+	if err := live.start(meter); err != nil {
+		return err
+	}
+	runLive(ctx, meter, entries, os.Stdout)
+	live.stop(meter, os.Stdout)
+	if ctx.Err() != nil {
+		// interrupted, the tail never ends on its own
+		return nil
+	}
+	return <-errs
+}
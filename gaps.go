@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// gaps between skill events longer than this count as not pressing buttons
+	castGapThreshold = 3 * time.Second
+)
+
+func init() {
+	analyses["gaps"] = printCastGaps
+}
+
+// CastGaps summarizes the time between an actor's consecutive skill events.
+type CastGaps struct {
+	Actor  string
+	Gaps   []time.Duration // sorted ascending
+	Idle   time.Duration   // sum of the time past the threshold in abnormal gaps
+	Flags  int             // number of gaps over the threshold
+	Events int
+}
+
+func (c CastGaps) percentile(p float64) time.Duration {
+	if len(c.Gaps) == 0 {
+		return 0
+	}
+	return c.Gaps[int(float64(len(c.Gaps)-1)*p)]
+}
+
+func castGaps(enc *Encounter) []CastGaps {
+	last := map[string]time.Time{}
+	stats := map[string]*CastGaps{}
+	for _, entry := range enc.Entries {
+		if entry.Source == "" || entry.Skill == "" {
+			continue
+		}
+		s, ok := stats[entry.Source]
+		if !ok {
+			s = &CastGaps{Actor: entry.Source}
+			stats[entry.Source] = s
+		}
+		prev, seen := last[entry.Source]
+		if seen && !entry.Timestamp.After(prev) {
+			continue
+		}
+		s.Events++
+		last[entry.Source] = entry.Timestamp
+		if !seen {
+			continue
+		}
+		gap := entry.Timestamp.Sub(prev)
+		s.Gaps = append(s.Gaps, gap)
+		if gap > castGapThreshold {
+			s.Flags++
+			s.Idle += gap - castGapThreshold
+		}
+	}
+	result := make([]CastGaps, 0, len(stats))
+	for _, s := range stats {
+		sort.Slice(s.Gaps, func(i, j int) bool { return s.Gaps[i] < s.Gaps[j] })
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Idle > result[j].Idle })
+	return result
+}
+
+func printCastGaps(enc *Encounter) {
+	fmt.Println("cast gaps:")
+	for _, g := range castGaps(enc) {
+		if len(g.Gaps) == 0 || !isWatched(g.Actor) {
+			continue
+		}
+		fmt.Printf("  %-12v median %-4v p90 %-4v max %-6v %3d gaps > %v, %v not pressing buttons\n",
+			g.Actor, g.percentile(0.5), g.percentile(0.9), g.Gaps[len(g.Gaps)-1], g.Flags, castGapThreshold, g.Idle)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCastGaps(t *testing.T) {
+	enc := encounterOf(
+		skillUseAt(0, "Starlaf", "Thrash"),
+		// more targets of a use add no gap
+		skillUseAt(0, "Starlaf", "Thrash"),
+		skillUseAt(1, "Starlaf", "Bash"),
+		skillUseAt(2, "Starlaf", "Rend"),
+		skillUseAt(10, "Starlaf", "Thrash"),
+		skillUseAt(11, "Starlaf", "Bash"),
+		skillUseAt(0, "Huya", "Cleave"),
+		skillUseAt(1, "Huya", "Cleave"),
+		skillUseAt(5, "Huya", "Cleave"),
+	)
+	gaps := castGaps(enc)
+	if len(gaps) != 2 || gaps[0].Actor != "Starlaf" {
+		t.Fatalf("got %+v, want Starlaf, the most idle, first", gaps)
+	}
+	starlaf := gaps[0]
+	if want := []time.Duration{time.Second, time.Second, time.Second, 8 * time.Second}; !slices.Equal(starlaf.Gaps, want) {
+		t.Errorf("got gaps %v, want %v", starlaf.Gaps, want)
+	}
+	if starlaf.Events != 5 || starlaf.Flags != 1 || starlaf.Idle != 5*time.Second {
+		t.Errorf("got %d events, %d flags and %v idle, want 5, 1 and 5s", starlaf.Events, starlaf.Flags, starlaf.Idle)
+	}
+	if starlaf.percentile(0.5) != time.Second || starlaf.percentile(1) != 8*time.Second {
+		t.Errorf("got median %v and max %v, want 1s and 8s", starlaf.percentile(0.5), starlaf.percentile(1))
+	}
+	huya := gaps[1]
+	if huya.Flags != 1 || huya.Idle != time.Second {
+		t.Errorf("got %d flags and %v idle for Huya, want 1 and 1s", huya.Flags, huya.Idle)
+	}
+}
+
+func TestCastGapsPercentileOfNone(t *testing.T) {
+	if got := (CastGaps{}).percentile(0.9); got != 0 {
+		t.Errorf("got %v, want 0", got)
+	}
+}
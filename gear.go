@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	// fewest normal hits of a skill whose median counts
+	gearMinHits = 5
+	// normal hits below this share of the class's median get a hint
+	gearLowRatio = 0.8
+)
+
+func init() {
+	analyses["gear"] = printGear
+}
+
+// SkillBaseline is the median normal hit of a class skill, the median of
+// the players' own medians on boss kills.
+type SkillBaseline struct {
+	Class   string  `json:"class"`
+	Skill   string  `json:"skill"`
+	Samples int     `json:"samples"`
+	Median  float64 `json:"median"`
+}
+
+// normalHitMedians is the median normal hit of every player with each class
+// skill of the catalog, keyed by actor and skill. Normal hits are neither
+// critical, devastating nor avoided in part, so they show the player's
+// stats and buffs rather than luck. Skills with fewer than gearMinHits are
+// left out.
+func normalHitMedians(enc *Encounter) map[string]map[string]int {
+	hits := map[string]map[string][]int{}
+	for _, entry := range enc.Entries {
+		if entry.etype != DmgDealt || entry.Value <= 0 || entry.Crit || entry.Dev || entry.Partial || entry.Avoided != UnknownAvoid {
+			continue
+		}
+		if info, ok := skillCatalog[entry.Skill]; !ok || info.Class == "" || enc.kindOf(entry.SourceID) != Player {
+			continue
+		}
+		if hits[entry.SourceID] == nil {
+			hits[entry.SourceID] = map[string][]int{}
+		}
+		hits[entry.SourceID][entry.Skill] = append(hits[entry.SourceID][entry.Skill], entry.Value)
+	}
+	medians := map[string]map[string]int{}
+	for actor, bySkill := range hits {
+		for skill, values := range bySkill {
+			if len(values) < gearMinHits {
+				continue
+			}
+			sort.Ints(values)
+			if medians[actor] == nil {
+				medians[actor] = map[string]int{}
+			}
+			medians[actor][skill] = values[len(values)/2]
+		}
+	}
+	return medians
+}
+
+// skillBaseline finds the baseline of a class skill.
+func (s *BaselineSet) skillBaseline(class, skill string) (SkillBaseline, bool) {
+	for _, b := range s.SkillHits {
+		if b.Class == class && b.Skill == skill {
+			return b, true
+		}
+	}
+	return SkillBaseline{}, false
+}
+
+// buildSkillBaselines builds the skill baselines of boss kills.
+func buildSkillBaselines(records []StoredEncounter) []SkillBaseline {
+	type key struct{ class, skill string }
+	values := map[key][]float64{}
+	for _, record := range records {
+		if record.Boss == "" || !bossKilled(record) {
+			continue
+		}
+		for actor, bySkill := range record.NormalHits {
+			class := record.Classes[actor]
+			if class == "" {
+				continue
+			}
+			for skill, median := range bySkill {
+				values[key{class, skill}] = append(values[key{class, skill}], float64(median))
+			}
+		}
+	}
+	baselines := []SkillBaseline{}
+	for k, v := range values {
+		if len(v) < baselineMinSamples {
+			continue
+		}
+		sort.Float64s(v)
+		baselines = append(baselines, SkillBaseline{Class: k.class, Skill: k.skill, Samples: len(v), Median: v[len(v)/2]})
+	}
+	sort.Slice(baselines, func(i, j int) bool {
+		if baselines[i].Class != baselines[j].Class {
+			return baselines[i].Class < baselines[j].Class
+		}
+		return baselines[i].Skill < baselines[j].Skill
+	})
+	return baselines
+}
+
+// GearHint compares a player's normal hits with the class's.
+type GearHint struct {
+	Actor string
+	Class string
+	// Ratio is the geometric mean over Skills of the player's median normal
+	// hit divided by the class's.
+	Ratio  float64
+	Skills int
+	// Low is set when Ratio is under gearLowRatio: something, like a
+	// missing buff or worse gear, may be holding the player back.
+	Low bool
+}
+
+// String is the hint as reports show it.
+func (h GearHint) String() string {
+	return fmt.Sprintf("%v hits for %.0f%% of the usual %v over %d skills, missing buffs or gear?", h.Actor, 100*h.Ratio, h.Class, h.Skills)
+}
+
+// gearHints compares every player with a known class against the skill
+// baselines. It is a heuristic: targets take different damage, so a low
+// ratio is a hint to look at, not a finding.
+func gearHints(enc *Encounter) []GearHint {
+	set := currentBaselines()
+	if len(set.SkillHits) == 0 {
+		return nil
+	}
+	classes := playerClasses(enc)
+	hints := []GearHint{}
+	for actor, bySkill := range normalHitMedians(enc) {
+		class := classes[actor]
+		logSum, n := 0.0, 0
+		for skill, median := range bySkill {
+			if b, ok := set.skillBaseline(class, skill); ok && b.Median > 0 {
+				logSum += math.Log(float64(median) / b.Median)
+				n++
+			}
+		}
+		if n == 0 {
+			continue
+		}
+		ratio := math.Exp(logSum / float64(n))
+		hints = append(hints, GearHint{Actor: actor, Class: class, Ratio: ratio, Skills: n, Low: ratio < gearLowRatio})
+	}
+	sort.Slice(hints, func(i, j int) bool { return hints[i].Ratio < hints[j].Ratio })
+	return hints
+}
+
+func printGear(enc *Encounter) {
+	hints := gearHints(enc)
+	fmt.Println("gear:")
+	if len(currentBaselines().SkillHits) == 0 {
+		fmt.Println("  no skill baselines, run baselines update")
+		return
+	}
+	for _, h := range hints {
+		flag := ""
+		if h.Low {
+			flag = "  <- missing buffs or gear?"
+		}
+		fmt.Printf("  %-24v %-12v %4.0f%% of the class's normal hits over %d skills%v\n", h.Actor, h.Class, 100*h.Ratio, h.Skills, flag)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+const (
+	// how often gui looks for the combat log to appear
+	logDirPoll = 2 * time.Second
+	// a log written to this recently is the one of the running game
+	recentLog = 10 * time.Minute
+	// the game's Steam app ID, for the Proton prefix on Linux
+	lotroSteamApp = "212500"
+	// where gui serves the meter page unless told otherwise
+	guiHTTPAddr = "127.0.0.1:8089"
+)
+
+func init() {
+	commands["gui"] = runGUI
+}
+
+// lotroLogDirs are the places the game keeps its logs on this system, most
+// likely first.
+func lotroLogDirs() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	const game = "The Lord of the Rings Online"
+	dirs := []string{}
+	switch runtime.GOOS {
+	case "windows":
+		if profile := os.Getenv("USERPROFILE"); profile != "" {
+			home = profile
+		}
+		dirs = append(dirs, filepath.Join(home, "Documents", game), filepath.Join(home, "OneDrive", "Documents", game))
+	case "darwin":
+		dirs = append(dirs, filepath.Join(home, "Documents", game), filepath.Join(home, "Library", "Application Support", game))
+	default:
+		user := filepath.Base(home)
+		dirs = append(dirs,
+			filepath.Join(home, "Documents", game),
+			filepath.Join(home, ".steam", "steam", "steamapps", "compatdata", lotroSteamApp, "pfx", "drive_c", "users", "steamuser", "Documents", game),
+			filepath.Join(home, ".local", "share", "Steam", "steamapps", "compatdata", lotroSteamApp, "pfx", "drive_c", "users", "steamuser", "Documents", game),
+			filepath.Join(home, ".wine", "drive_c", "users", user, "Documents", game),
+		)
+	}
+	return dirs
+}
+
+// findLogDir is the configured log directory, or the first of lotroLogDirs
+// that exists.
+func findLogDir() (string, error) {
+	if config.LogDir != "" {
+		return config.LogDir, nil
+	}
+	for _, dir := range lotroLogDirs() {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("no game log directory in %v, set log_dir in the config", lotroLogDirs())
+}
+
+// newestLog is the most recently written combat log in dir, preferring the
+// game's Combat_*.txt names over other text files.
+func newestLog(dir string) (string, time.Time) {
+	newest, newestTime := "", time.Time{}
+	for _, pattern := range []string{"Combat_*.txt", "*.txt"} {
+		paths, _ := filepath.Glob(filepath.Join(dir, pattern))
+		for _, path := range paths {
+			if info, err := os.Stat(path); err == nil && info.ModTime().After(newestTime) {
+				newest, newestTime = path, info.ModTime()
+			}
+		}
+		if newest != "" {
+			break
+		}
+	}
+	return newest, newestTime
+}
+
+// waitForLog returns the log the game is writing: the newest one if it was
+// written to lately, otherwise the first one written to from now on.
+func waitForLog(dir string) string {
+	since := time.Now().Add(-recentLog)
+	waiting := false
+	for {
+		if path, at := newestLog(dir); path != "" && at.After(since) {
+			return path
+		}
+		if !waiting {
+			fmt.Printf("waiting for a combat log in %v, start one in game with /chatlog\n", dir)
+			waiting = true
+		}
+		time.Sleep(logDirPoll)
+	}
+}
+
+// openBrowser shows a URL in the default browser.
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	case "darwin":
+		cmd = exec.Command("open", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
+
+var meterPageHTML = template.Must(template.New("meter").Funcs(themeFuncs).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Live meter</title>
+<style>
+body { background: {{theme.Background}}; color: {{theme.Foreground}}; font-family: sans-serif; }
+td { padding: 2px 8px; } td.n { text-align: right; }
+.bar { background: {{theme.Damage}}; height: 10px; }
+#status { color: {{theme.Muted}}; }
+</style></head>
+<body>
+<h1 id="title">Live meter</h1>
+<p id="status">connecting...</p>
+<table><thead><tr><th>Actor</th><th></th><th>Damage</th><th>DPS</th><th>Healing</th><th>Taken</th><th>Deaths</th></tr></thead>
+<tbody id="meter"></tbody></table>
+<script>
+let actors = {}, duration = 0, encounter = 0;
+function draw() {
+	const rows = Object.values(actors).filter(a => a.kind == "player").sort((a, b) => b.damage - a.damage);
+	const top = rows.length ? Math.max(rows[0].damage, 1) : 1, secs = Math.max(duration / 1e9, 1);
+	document.getElementById("title").textContent = "Encounter " + encounter + " (" + Math.round(secs) + "s)";
+	const body = document.getElementById("meter");
+	body.replaceChildren(...rows.map(a => {
+		const tr = document.createElement("tr");
+		const cells = [a.actor, "", a.damage, Math.round(a.damage / secs), a.healing, a.damage_taken, a.deaths];
+		cells.forEach((v, i) => {
+			const td = document.createElement("td");
+			if (i == 1) {
+				const bar = document.createElement("div");
+				bar.className = "bar";
+				bar.style.width = Math.round(200 * a.damage / top) + "px";
+				td.appendChild(bar);
+			} else {
+				td.textContent = v;
+				if (i > 1) td.className = "n";
+			}
+			tr.appendChild(td);
+		});
+		return tr;
+	}));
+}
+function connect() {
+	const ws = new WebSocket((location.protocol == "https:" ? "wss://" : "ws://") + location.host + "/overlay");
+	const status = document.getElementById("status");
+	ws.onopen = () => { ws.send(JSON.stringify({type: "hello", v: 1, capabilities: ["state", "diff"]})); status.textContent = "waiting for combat..."; };
+	ws.onmessage = ev => {
+		const msg = JSON.parse(ev.data);
+		if (msg.type == "state") { actors = {}; encounter = msg.encounter; }
+		if (msg.type != "state" && msg.type != "diff") return;
+		duration = msg.duration || 0;
+		(msg.actors || []).forEach(a => actors[a.actor] = a);
+		status.textContent = "";
+		draw();
+	};
+	ws.onclose = () => { status.textContent = "disconnected, retrying..."; setTimeout(connect, 2000); };
+}
+connect();
+</script>
+</body></html>
+`))
+
+// meterPageHandler serves the live meter page, which follows the meter over
+// the overlay protocol.
+func meterPageHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	if err := meterPageHTML.Execute(w, nil); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// runGUI follows the game's current combat log without any flags and opens
+// the live meter in the browser. On Windows it is also what a double-click
+// on the binary runs.
+func runGUI(args []string) error {
+	err := gui(args)
+	if err != nil && runtime.GOOS == "windows" {
+		// keep the console window open long enough to read the error
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprint(os.Stderr, "press Enter to close")
+		bufio.NewReader(os.Stdin).ReadString('\n')
+	}
+	return err
+}
+
+func gui(args []string) error {
+	fs := flag.NewFlagSet("gui", flag.ExitOnError)
+	setup := commonFlags(fs)
+	live := liveFlags(fs)
+	noBrowser := fs.Bool("no-browser", false, "do not open the meter in the browser")
+	fs.Set("http", guiHTTPAddr) // the meter page needs the live API
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	path := fs.Arg(0)
+	if path == "" {
+		dir, err := findLogDir()
+		if err != nil {
+			return err
+		}
+		path = waitForLog(dir)
+	}
+	fmt.Printf("following %v\n", path)
+	profile, err := sniffLog(path)
+	if err != nil {
+		return err
+	}
+	if err := profile.apply(); err != nil {
+		return err
+	}
+
+	lines := make(chan string)
+	entries := make(chan *LogEntry)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- tailLines(path, false, lines)
+		close(lines)
+	}()
+	go parseLines(lines, entries)
+	ctx, stop := interruptContext()
+	defer stop()
+	meter := &LiveMeter{}
+	if err := live.start(meter); err != nil {
+		return err
+	}
+	host, port, err := net.SplitHostPort(live.httpAddr)
+	if err != nil {
+		return fmt.Errorf("-http: %w", err)
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	url := "http://" + net.JoinHostPort(host, port) + "/"
+	meter.SetStatus("meter at " + url)
+	if !*noBrowser {
+		if err := openBrowser(url); err != nil {
+			meter.SetStatus(fmt.Sprintf("open %v in a browser (%v)", url, err))
+		}
+	}
+	runLive(ctx, meter, entries, os.Stdout)
+	live.stop(meter, os.Stdout)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return <-errs
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+const (
+	heatmapBucket    = 10 * time.Second
+	heatmapMaxSkills = 15
+	heatmapCell      = 14
+	heatmapLabel     = 180
+)
+
+func init() {
+	charts["heatmap"] = writeHeatmapSVG
+}
+
+// Heatmap is the damage of one actor per skill per time bucket.
+type Heatmap struct {
+	Actor   string
+	Skills  []string // sorted by total damage
+	Buckets int
+	Cells   map[string][]int // skill -> damage per bucket
+	Max     int
+}
+
+// skillHeatmaps builds a heatmap for every watched player of the encounter.
+func skillHeatmaps(enc *Encounter) []*Heatmap {
+	buckets := int(enc.Duration()/heatmapBucket) + 1
+	maps := map[string]*Heatmap{}
+	totals := map[string]map[string]int{}
+	for _, entry := range enc.Entries {
+		if entry.etype != DmgDealt || entry.Value == 0 || entry.Skill == "" {
+			continue
+		}
+		if enc.kindOf(entry.SourceID) != Player || !isWatched(entry.SourceID) {
+			continue
+		}
+		h, ok := maps[entry.SourceID]
+		if !ok {
+			h = &Heatmap{Actor: entry.SourceID, Buckets: buckets, Cells: map[string][]int{}}
+			maps[entry.SourceID] = h
+			totals[entry.SourceID] = map[string]int{}
+		}
+		if h.Cells[entry.Skill] == nil {
+			h.Cells[entry.Skill] = make([]int, buckets)
+		}
+		b := int(entry.Timestamp.Sub(enc.Start) / heatmapBucket)
+		h.Cells[entry.Skill][b] += entry.Value
+		if h.Cells[entry.Skill][b] > h.Max {
+			h.Max = h.Cells[entry.Skill][b]
+		}
+		totals[entry.SourceID][entry.Skill] += entry.Value
+	}
+	result := make([]*Heatmap, 0, len(maps))
+	for actor, h := range maps {
+		for skill := range h.Cells {
+			h.Skills = append(h.Skills, skill)
+		}
+		t := totals[actor]
+		sort.Slice(h.Skills, func(i, j int) bool { return t[h.Skills[i]] > t[h.Skills[j]] })
+		if len(h.Skills) > heatmapMaxSkills {
+			h.Skills = h.Skills[:heatmapMaxSkills]
+		}
+		result = append(result, h)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Actor < result[j].Actor })
+	return result
+}
+
+// writeHeatmapSVG draws one skill-by-time grid per actor, darker cells did
+// more damage.
+func writeHeatmapSVG(w io.Writer, enc *Encounter) error {
+	maps := skillHeatmaps(enc)
+	width, height := heatmapLabel+heatmapCell*(int(enc.Duration()/heatmapBucket)+1)+10, 10
+	for _, h := range maps {
+		height += heatmapCell * (len(h.Skills) + 3)
+	}
+	svgOpen(w, width, height, 11)
+	writeLagWindowsSVG(w, lagWindows(enc), heatmapLabel, heatmapCell/heatmapBucket.Seconds(), 10, height-20)
+	y := 10
+	for _, h := range maps {
+		y += heatmapCell
+		fmt.Fprintf(w, `<text x="4" y="%d" font-weight="bold">%v (cells are %v)</text>`+"\n", y, svgText(h.Actor), heatmapBucket)
+		y += heatmapCell / 2
+		for _, skill := range h.Skills {
+			fmt.Fprintf(w, `<text x="4" y="%d">%v</text>`+"\n", y+heatmapCell-3, svgText(skill))
+			for b, dmg := range h.Cells[skill] {
+				if dmg == 0 {
+					continue
+				}
+				fmt.Fprintf(w, `<rect x="%d" y="%d" width="%d" height="%d" fill="%v" fill-opacity="%.2f"><title>%v at +%v: %v</title></rect>`+"\n",
+					heatmapLabel+b*heatmapCell, y, heatmapCell-1, heatmapCell-1, chartTheme.Damage,
+					0.15+0.85*float64(dmg)/float64(h.Max), svgText(skill), time.Duration(b)*heatmapBucket, dmg)
+			}
+			y += heatmapCell
+		}
+		y += heatmapCell
+	}
+	_, err := fmt.Fprintln(w, "</svg>")
+	return err
+}
@@ -0,0 +1,48 @@
+package main
+
+// EntryHook is a post-processing step run on parsed entries before anything
+// consumes them. It may change entries, drop them or reorder them, and
+// records what it did on the ParseResult.
+type EntryHook struct {
+	Name string
+	Run  func(entries []*LogEntry, result *ParseResult) []*LogEntry
+}
+
+// entryHooks are run in order by runEntryHooks. Steps that depend on others,
+// like the anomaly check on ordered entries, come after them.
+var entryHooks = []EntryHook{
+	{"normalize", func(entries []*LogEntry, result *ParseResult) []*LogEntry {
+		entries, result.Normalized = normalizeEntries(entries)
+		return entries
+	}},
+	{"anomalies", func(entries []*LogEntry, result *ParseResult) []*LogEntry {
+		entries, result.Anomalies = dropAnomalies(entries)
+		return entries
+	}},
+	{"value-types", func(entries []*LogEntry, result *ParseResult) []*LogEntry {
+		enrichValueTypes(entries)
+		return entries
+	}},
+	{"avoided", func(entries []*LogEntry, result *ParseResult) []*LogEntry {
+		estimateAvoidedValues(entries)
+		return entries
+	}},
+}
+
+// addEntryHook appends a step to the end of the chain, after the built-in
+// ones.
+func addEntryHook(name string, run func(entries []*LogEntry, result *ParseResult) []*LogEntry) {
+	entryHooks = append(entryHooks, EntryHook{Name: name, Run: run})
+}
+
+// runEntryHooks passes entries through the hook chain. result may be nil
+// when nobody wants the stats, like when rebuilding a stored encounter.
+func runEntryHooks(entries []*LogEntry, result *ParseResult) []*LogEntry {
+	if result == nil {
+		result = &ParseResult{}
+	}
+	for _, hook := range entryHooks {
+		entries = hook.Run(entries, result)
+	}
+	return entries
+}
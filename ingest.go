@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	dbCommands["ingest"] = dbIngest
+}
+
+// ExternalEntry is the schema of entries parsed by other tools. JSON files
+// hold an array of these objects, CSV files a header row naming the same
+// fields followed by one entry per row:
+//
+//	time        RFC 3339 or the log's "01/02 03:04:05 PM", required
+//	type        DmgDealt, DmgTaken, Heal, PowerRestored, Benefit or Death
+//	source      who acted, required
+//	target      who it was done to, required
+//	skill       skill name, "" for melee attacks and deaths
+//	value       damage or healing, plain digits
+//	damage_type like "Common" or "Beleriand", damage only
+//	crit        true for critical hits and heals
+//
+// Entries are stored as the log lines the game would have written, so they
+// parse, reindex and chart like any other log.
+type ExternalEntry struct {
+	Time       string `json:"time"`
+	Type       string `json:"type"`
+	Source     string `json:"source"`
+	Target     string `json:"target"`
+	Skill      string `json:"skill,omitempty"`
+	Value      int    `json:"value,omitempty"`
+	DamageType string `json:"damage_type,omitempty"`
+	Crit       bool   `json:"crit,omitempty"`
+}
+
+// readExternalJSON reads a JSON array of entries.
+func readExternalJSON(r io.Reader) ([]ExternalEntry, error) {
+	var entries []ExternalEntry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("reading JSON entries: %w", err)
+	}
+	return entries, nil
+}
+
+// readExternalCSV reads entries from a CSV file with a header row.
+func readExternalCSV(r io.Reader) ([]ExternalEntry, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV entries: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("CSV file has no header row")
+	}
+	columns := map[string]int{}
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"time", "type", "source", "target"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV header has no %q column", name)
+		}
+	}
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	entries := make([]ExternalEntry, 0, len(rows)-1)
+	for n, row := range rows[1:] {
+		e := ExternalEntry{
+			Time:       field(row, "time"),
+			Type:       field(row, "type"),
+			Source:     field(row, "source"),
+			Target:     field(row, "target"),
+			Skill:      field(row, "skill"),
+			DamageType: field(row, "damage_type"),
+		}
+		if v := field(row, "value"); v != "" {
+			if e.Value, err = strconv.Atoi(v); err != nil {
+				return nil, fmt.Errorf("row %d: value %q is not a number", n+2, v)
+			}
+		}
+		if c := field(row, "crit"); c != "" {
+			if e.Crit, err = strconv.ParseBool(c); err != nil {
+				return nil, fmt.Errorf("row %d: crit %q is not true or false", n+2, c)
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// groupDigits writes a value the way the English client does, 1,234,567.
+func groupDigits(v int) string {
+	s := strconv.Itoa(v)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// logLine renders an entry as the combat log line the game writes for it.
+func (e ExternalEntry) logLine() (string, error) {
+	at, err := time.Parse(time.RFC3339, e.Time)
+	if err != nil {
+		if at, err = time.Parse("01/02 03:04:05 PM", e.Time); err != nil {
+			return "", fmt.Errorf("time %q is neither RFC 3339 nor 01/02 03:04:05 PM", e.Time)
+		}
+	}
+	if e.Source == "" || e.Target == "" {
+		return "", errors.New("source and target are required")
+	}
+	if e.Value < 0 {
+		return "", fmt.Errorf("negative value %d", e.Value)
+	}
+	critical := ""
+	if e.Crit {
+		critical = "critical "
+	}
+	skill := e.Skill
+	var msg string
+	switch e.Type {
+	case "DmgDealt", "DmgTaken":
+		if skill == "" {
+			skill = "a melee attack"
+		}
+		damageType := e.DamageType
+		if damageType == "" {
+			damageType = "Common"
+		}
+		msg = fmt.Sprintf("%v scored a %vhit with %v on %v for %v %v damage to Morale.", e.Source, critical, skill, e.Target, groupDigits(e.Value), damageType)
+	case "Heal", "PowerRestored":
+		pool := "Morale"
+		if e.Type == "PowerRestored" {
+			pool = "Power"
+		}
+		with := ""
+		if skill != "" {
+			with = " with " + skill
+		}
+		msg = fmt.Sprintf("%v applied a %vheal%v to %v restoring %v points to %v.", e.Source, critical, with, e.Target, groupDigits(e.Value), pool)
+	case "Benefit":
+		if skill == "" {
+			return "", errors.New("benefit without a skill")
+		}
+		msg = fmt.Sprintf("%v applied a benefit with %v on %v.", e.Source, skill, e.Target)
+	case "Death":
+		msg = fmt.Sprintf("%v defeated %v.", e.Source, e.Target)
+	default:
+		return "", fmt.Errorf("unsupported type %q", e.Type)
+	}
+	return fmt.Sprintf("[%v] %v", at.Format("01/02 03:04:05 PM"), msg), nil
+}
+
+// externalLog writes external entries to a temporary log file and returns
+// its path. Entries must be in time order, as in a log.
+func externalLog(entries []ExternalEntry) (string, error) {
+	tmp, err := os.CreateTemp("", "ingest-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+	for i, e := range entries {
+		line, err := e.logLine()
+		if err != nil {
+			os.Remove(tmp.Name())
+			return "", fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		fmt.Fprintln(tmp, line)
+	}
+	return tmp.Name(), nil
+}
+
+// dbIngest stores entries exported by other parsers from the .json and .csv
+// files given, in the ExternalEntry schema.
+func dbIngest(store Store, fs *flag.FlagSet) error {
+	if fs.NArg() == 0 {
+		return errors.New("usage: db ingest <file.json|file.csv>...")
+	}
+	return storeBatch(store, func() error {
+		return ingestFiles(store, fs.Args())
+	})
+}
+
+// ingestFiles stores the encounters of external files.
+func ingestFiles(store Store, paths []string) error {
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		var entries []ExternalEntry
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json":
+			entries, err = readExternalJSON(file)
+		case ".csv":
+			entries, err = readExternalCSV(file)
+		default:
+			err = errors.New("unknown format, use a .json or .csv file")
+		}
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+		logPath, err := externalLog(entries)
+		if err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+		result, err := parseFile(logPath, ParserOptions{})
+		os.Remove(logPath)
+		if err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+		for _, enc := range segmentEncounters(result.Entries) {
+			id, err := store.PutEntries(path, enc)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("stored %v (%v, %v)\n", id, enc.Start.Format("15:04:05"), enc.Duration())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+func init() {
+	commands["init"] = runInit
+}
+
+// wizard asks questions on a terminal.
+type wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask shows a question with its default and returns the answer, or the
+// default for an empty one.
+func (wz *wizard) ask(question, def string) string {
+	if def != "" {
+		fmt.Fprintf(wz.out, "%v [%v]: ", question, def)
+	} else {
+		fmt.Fprintf(wz.out, "%v: ", question)
+	}
+	answer, _ := wz.in.ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return def
+	}
+	if answer == "-" {
+		return ""
+	}
+	return answer
+}
+
+// askList asks for a comma separated list.
+func (wz *wizard) askList(question string, def []string) []string {
+	answer := wz.ask(question, strings.Join(def, ", "))
+	list := []string{}
+	for _, item := range strings.Split(answer, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// patternLocales are the client languages there are patterns for.
+func patternLocales() []string {
+	locales := []string{defaultLocale}
+	for _, set := range patternSets {
+		if set.Locale != "" && !slices.Contains(locales, set.Locale) {
+			locales = append(locales, set.Locale)
+		}
+	}
+	return locales
+}
+
+// logCharacters are the characters of a log's encounters, most played first.
+func logCharacters(path string) []string {
+	result, err := parseFile(path, ParserOptions{})
+	if err != nil {
+		return nil
+	}
+	counts := map[string]int{}
+	for _, s := range characterSessions(segmentEncounters(result.Entries)) {
+		if s.Character != "" {
+			counts[s.Character] += len(s.Encounters)
+		}
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int { return counts[b] - counts[a] })
+	return names
+}
+
+// runInit walks a new user through the settings that matter and writes the
+// config file. Answers default to what is already configured or detected;
+// "-" clears one.
+func runInit(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	setup := commonFlags(fs)
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	path := fs.Lookup("config").Value.String()
+	if path == "" {
+		return fmt.Errorf("no config directory on this system, use -config")
+	}
+	cfg := config
+	wz := &wizard{in: bufio.NewReader(os.Stdin), out: os.Stdout}
+	fmt.Printf("Setting up %v, Enter keeps the value in brackets, - clears it.\n\n", path)
+
+	dir := cfg.LogDir
+	if dir == "" {
+		dir, _ = findLogDir()
+	}
+	for {
+		dir = wz.ask("Folder the game writes combat logs to", dir)
+		if info, err := os.Stat(dir); dir == "" || err == nil && info.IsDir() {
+			break
+		}
+		fmt.Printf("%v is no folder.\n", dir)
+	}
+	cfg.LogDir = dir
+	// the detected folder is found again by gui, only keep a chosen one
+	if found, _ := findLogDir(); found == dir && config.LogDir == "" {
+		cfg.LogDir = ""
+	}
+
+	characters, locale := cfg.Characters, cfg.Locale
+	if log, _ := newestLog(dir); dir != "" && log != "" {
+		fmt.Printf("Looking at %v...\n", filepath.Base(log))
+		if len(characters) == 0 {
+			characters = logCharacters(log)
+		}
+		if profile, err := sniffLog(log); err == nil && locale == "" {
+			locale = profile.Locale
+		}
+	}
+	cfg.Characters = wz.askList("Your characters, comma separated", characters)
+	for {
+		locale = wz.ask(fmt.Sprintf("Game client language, one of %v", patternLocales()), locale)
+		if locale == "" || slices.Contains(patternLocales(), locale) {
+			break
+		}
+		fmt.Printf("There are no patterns for %q.\n", locale)
+	}
+	cfg.Locale = locale
+	if cfg.Locale == defaultLocale {
+		cfg.Locale = ""
+	}
+
+	fmt.Println("\nA share server stores your logs for the guild, leave it empty to skip.")
+	cfg.Share.URL = wz.ask("Share server URL", cfg.Share.URL)
+	if cfg.Share.URL != "" {
+		cfg.Share.Guild = wz.ask("Guild on the server", cfg.Share.Guild)
+		if cfg.Share.Guild != "" {
+			cfg.Share.Token = wz.ask("Guild token with the upload role", cfg.Share.Token)
+		}
+	} else {
+		cfg.Share = ShareConfig{}
+	}
+
+	fmt.Println("\nA Discord webhook posts every encounter to a channel, leave it empty to skip.")
+	discord := -1
+	for i, hook := range cfg.Webhooks {
+		if hook.Name == "discord" {
+			discord = i
+		}
+	}
+	current := ""
+	if discord >= 0 {
+		current = cfg.Webhooks[discord].URL
+	}
+	switch url := wz.ask("Discord webhook URL", current); {
+	case url == "" && discord >= 0:
+		cfg.Webhooks = slices.Delete(cfg.Webhooks, discord, discord+1)
+	case url != "" && discord >= 0:
+		cfg.Webhooks[discord].URL = url
+	case url != "":
+		cfg.Webhooks = append(cfg.Webhooks, WebhookConfig{Name: "discord", URL: url, Events: []string{eventEncounter, eventRecord}})
+	}
+
+	if err := saveConfig(path, cfg); err != nil {
+		return err
+	}
+	fmt.Printf("\nWrote %v. Run gui to start the live meter.\n", path)
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+const (
+	// seconds without a single event that count as a gap
+	lagGap = 4
+	// a second after a gap with this many times the median rate is the
+	// client catching up on what it did not log during the gap
+	lagBurstFactor = 4
+	// median events per second above which any gap is suspicious, busy
+	// fights always have something going on
+	lagBusyRate = 3
+)
+
+func init() {
+	analyses["lag"] = printLag
+}
+
+// LagWindow is a stretch of an encounter where the log probably misses or
+// delays events, so DPS dips in it are not real.
+type LagWindow struct {
+	From, To time.Duration // since the encounter start
+	Burst    int           // events logged at once right after, 0 if none
+	Reason   string
+}
+
+// eventRate counts the encounter's combat events per second.
+func eventRate(enc *Encounter) []int {
+	rate := make([]int, int(enc.Duration()/time.Second)+1)
+	for _, entry := range enc.Entries {
+		if !entry.etype.isCombat() {
+			continue
+		}
+		if s := int(entry.Timestamp.Sub(enc.Start) / time.Second); s >= 0 && s < len(rate) {
+			rate[s]++
+		}
+	}
+	return rate
+}
+
+// medianRate is the median of the seconds that had events.
+func medianRate(rate []int) int {
+	busy := []int{}
+	for _, n := range rate {
+		if n > 0 {
+			busy = append(busy, n)
+		}
+	}
+	if len(busy) == 0 {
+		return 0
+	}
+	sort.Ints(busy)
+	return busy[len(busy)/2]
+}
+
+// explainedQuiet marks the seconds in which a gap is expected: after
+// someone died or released, as in wipes, and while the logging character is
+// dead. Succumbing to wounds is logged as a Revive but means running back,
+// only being healed or fighting again ends a death.
+func explainedQuiet(enc *Encounter, seconds int) []bool {
+	quiet := make([]bool, seconds)
+	mark := func(from, to int) {
+		for s := max(from, 0); s < min(to, seconds); s++ {
+			quiet[s] = true
+		}
+	}
+	deadSince := -1
+	for _, entry := range enc.Entries {
+		s := int(entry.Timestamp.Sub(enc.Start) / time.Second)
+		self := entry.Target == enc.Character || entry.Target == selfplaceholder
+		switch {
+		case entry.etype == Death || entry.etype == Revive:
+			mark(s, s+lagGap)
+			if self && entry.etype == Death && deadSince < 0 {
+				deadSince = s
+			}
+		case self && entry.etype.isCombat() && deadSince >= 0:
+			mark(deadSince, s)
+			deadSince = -1
+		}
+	}
+	if deadSince >= 0 {
+		mark(deadSince, seconds)
+	}
+	return quiet
+}
+
+// lagWindows finds gaps in the event stream that look like client lag (the
+// gap is followed by a burst of events) or a logging stall (a gap in an
+// otherwise busy fight). Gaps after deaths are left alone, nothing happens
+// in a wipe.
+func lagWindows(enc *Encounter) []LagWindow {
+	rate := eventRate(enc)
+	median := medianRate(rate)
+	quiet := explainedQuiet(enc, len(rate))
+	windows := []LagWindow{}
+	for s := 0; s < len(rate); {
+		if rate[s] > 0 {
+			s++
+			continue
+		}
+		from := s
+		for s < len(rate) && rate[s] == 0 {
+			s++
+		}
+		gap := s - from
+		if gap < lagGap || s == len(rate) || quiet[from] {
+			continue
+		}
+		w := LagWindow{From: time.Duration(from) * time.Second, To: time.Duration(s) * time.Second}
+		switch {
+		case rate[s] >= lagBurstFactor*median:
+			w.Burst = rate[s]
+			w.Reason = fmt.Sprintf("client lag, %d events logged at once after %ds without any", rate[s], gap)
+		case median >= lagBusyRate:
+			w.Reason = fmt.Sprintf("possible logging stall, %ds without events in a fight averaging %d per second", gap, median)
+		default:
+			continue
+		}
+		windows = append(windows, w)
+	}
+	return windows
+}
+
+// writeLagWindowsSVG shades the lag windows of a chart whose time axis starts
+// at x and has scale pixels per second.
+func writeLagWindowsSVG(w io.Writer, windows []LagWindow, x, scale float64, top, height int) {
+	for _, lag := range windows {
+		fmt.Fprintf(w, `<rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%v" fill-opacity="0.3"><title>+%v to +%v: %v</title></rect>`+"\n",
+			x+lag.From.Seconds()*scale, top, (lag.To-lag.From).Seconds()*scale, height, chartTheme.Muted, lag.From, lag.To, svgText(lag.Reason))
+	}
+}
+
+func printLag(enc *Encounter) {
+	rate := eventRate(enc)
+	peak := 0
+	for _, n := range rate {
+		peak = max(peak, n)
+	}
+	fmt.Printf("event rate: median %d/s, peak %d/s\n", medianRate(rate), peak)
+	for _, w := range lagWindows(enc) {
+		fmt.Printf("  +%-6v to +%-6v %v\n", w.From, w.To, w.Reason)
+	}
+}
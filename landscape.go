@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// landscape fights are short and come in quick succession
+	landscapeIdleGap = 10 * time.Second
+)
+
+func init() {
+	commands["landscape"] = runLandscape
+}
+
+// Kill is one mob fought by the players. Training dummies never die, they
+// are reported with the time they were hit for.
+type Kill struct {
+	Mob       string
+	FirstHit  time.Time
+	LastHit   time.Time
+	Died      time.Time     // zero if it survived
+	Damage    int           // dealt by players
+	SinceLast time.Duration // since the previous target of the fight went down
+}
+
+// TimeToKill is the time from the first player hit to the death, or to the
+// last hit for mobs that survived.
+func (k Kill) TimeToKill() time.Duration {
+	if k.Died.IsZero() {
+		return k.LastHit.Sub(k.FirstHit)
+	}
+	return k.Died.Sub(k.FirstHit)
+}
+
+// DPS is the player damage per second over the time to kill.
+func (k Kill) DPS() float64 {
+	secs := k.TimeToKill().Seconds()
+	if secs < 1 {
+		secs = 1
+	}
+	return float64(k.Damage) / secs
+}
+
+// landscapeKills segments encounters by target: every NPC the players damaged
+// is one segment, in the order they died or were last hit.
+func landscapeKills(encounters []*Encounter) []Kill {
+	kills := []Kill{}
+	for _, enc := range encounters {
+		byID := map[string]*Kill{}
+		for _, entry := range enc.Entries {
+			if entry.etype != DmgDealt || entry.Value == 0 || enc.kindOf(entry.TargetID) != NPC {
+				continue
+			}
+			if kind := enc.kindOf(entry.SourceID); kind != Player && kind != Pet {
+				continue
+			}
+			k, ok := byID[entry.TargetID]
+			if !ok {
+				k = &Kill{Mob: entry.TargetID, FirstHit: entry.Timestamp}
+				byID[entry.TargetID] = k
+			}
+			k.LastHit = entry.Timestamp
+			k.Damage += entry.Value
+		}
+		segment := []Kill{}
+		for id, k := range byID {
+			k.Died = enc.Entities[id].Died
+			segment = append(segment, *k)
+		}
+		end := func(k Kill) time.Time { return k.FirstHit.Add(k.TimeToKill()) }
+		sort.Slice(segment, func(i, j int) bool { return end(segment[i]).Before(end(segment[j])) })
+		for i := 1; i < len(segment); i++ {
+			segment[i].SinceLast = end(segment[i]).Sub(end(segment[i-1]))
+		}
+		kills = append(kills, segment...)
+	}
+	return kills
+}
+
+// onTarget is the time at least one target was being fought, overlapping
+// targets count once.
+func onTarget(kills []Kill) time.Duration {
+	sorted := append([]Kill(nil), kills...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].FirstHit.Before(sorted[j].FirstHit) })
+	busy := time.Duration(0)
+	if len(sorted) == 0 {
+		return busy
+	}
+	// log timestamps have no year, so they are before the zero time
+	until := sorted[0].FirstHit
+	for _, k := range sorted {
+		start, end := k.FirstHit, k.FirstHit.Add(k.TimeToKill())
+		if start.Before(until) {
+			start = until
+		}
+		if end.After(start) {
+			busy += end.Sub(start)
+			until = end
+		}
+	}
+	return busy
+}
+
+func printLandscapeKills(kills []Kill) {
+	total := 0
+	for _, k := range kills {
+		fate := "killed"
+		if k.Died.IsZero() {
+			fate = "survived"
+		}
+		fmt.Printf("%v %-28v %-8v %-9v %10v dmg %8.0f/s  +%v\n",
+			k.FirstHit.Format("15:04:05"), k.Mob, fate, k.TimeToKill(), k.Damage, k.DPS(), k.SinceLast)
+		total += k.Damage
+	}
+	busy := onTarget(kills)
+	if busy < time.Second {
+		busy = time.Second
+	}
+	fmt.Printf("%d targets, %v dmg, sustained %.0f/s over %v on target\n", len(kills), total, float64(total)/busy.Seconds(), busy)
+}
+
+// runLandscape reports each mob killed as its own segment, for solo play and
+// build testing on training dummies.
+func runLandscape(args []string) error {
+	fs := flag.NewFlagSet("landscape", flag.ExitOnError)
+	setup := commonFlags(fs)
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	if segmentation.IdleGapSecs == 0 {
+		segmentation.IdleGapSecs = landscapeIdleGap.Seconds()
+	}
+	result, err := parseFile(inputPath(fs), ParserOptions{})
+	if err != nil {
+		return err
+	}
+	printLandscapeKills(landscapeKills(segmentEncounters(result.Entries)))
+	return nil
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// how often the live meter redraws
+	liveRefresh = time.Second
+	// rows of the -top table
+	liveTopN = 10
+)
+
+// LiveMeter is the live pipeline: it takes entries as they happen, keeps the
+// current encounter and renders a meter of it.
+type LiveMeter struct {
+	mu      sync.Mutex
+	entries []*LogEntry // of the current encounter
+	count   int         // encounters seen so far
+	status  string      // shown under the meter
+	compact bool        // draw the top 10 table of -top instead
+	// clipboard copies a summary of every ended encounter, see
+	// encounterSummary
+	clipboard bool
+}
+
+// SetStatus sets a one-line message shown under the meter.
+func (m *LiveMeter) SetStatus(status string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.status = status
+}
+
+// endsEncounter reports whether an entry starts a new encounter after the
+// current one.
+func (m *LiveMeter) endsEncounter(entry *LogEntry) bool {
+	if !entry.etype.isCombat() {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := len(m.entries)
+	return n > 0 && entry.Timestamp.Sub(m.entries[n-1].Timestamp) > segmentation.idleGap()
+}
+
+// Add feeds one entry, starting a new encounter after an idle gap.
+func (m *LiveMeter) Add(entry *LogEntry) {
+	if !entry.etype.isCombat() {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if n := len(m.entries); n == 0 || entry.Timestamp.Sub(m.entries[n-1].Timestamp) > segmentation.idleGap() {
+		m.entries = nil
+		m.count++
+	}
+	m.entries = append(m.entries, entry)
+}
+
+// encounterEnded tells the notifiers about an ended encounter and copies
+// its summary with -clipboard.
+func (m *LiveMeter) encounterEnded(enc *Encounter) {
+	notify(encounterEvent("", "", enc))
+	if !m.clipboard {
+		return
+	}
+	summary := encounterSummary(enc)
+	if err := copyToClipboard(summary); err != nil {
+		m.SetStatus(fmt.Sprintf("copying the summary: %v", err))
+		return
+	}
+	m.SetStatus("copied: " + summary)
+}
+
+// Snapshot returns the current encounter, nil before the first entry.
+// Segmenting assigns entity IDs on the entries, so every snapshot gets its
+// own copies for the render loop and the HTTP handlers to take them at once.
+func (m *LiveMeter) Snapshot() *Encounter {
+	m.mu.Lock()
+	entries := make([]*LogEntry, len(m.entries))
+	for i, entry := range m.entries {
+		copied := *entry
+		entries[i] = &copied
+	}
+	m.mu.Unlock()
+	if len(entries) == 0 {
+		return nil
+	}
+	encounters := segmentEncounters(entries)
+	return encounters[len(encounters)-1]
+}
+
+// snapshotOf takes a snapshot along with its copy of an entry just added,
+// which has the entity IDs the entry itself never gets. The copy is nil
+// when the entry is not in the current encounter.
+func (m *LiveMeter) snapshotOf(entry *LogEntry) (*Encounter, *LogEntry) {
+	enc := m.Snapshot()
+	if enc == nil {
+		return nil, nil
+	}
+	for i := len(enc.Entries) - 1; i >= 0; i-- {
+		c := enc.Entries[i]
+		if c.etype == entry.etype && c.Timestamp.Equal(entry.Timestamp) && c.Target == entry.Target && c.RawMessage == entry.RawMessage {
+			return enc, c
+		}
+	}
+	return enc, nil
+}
+
+// Render clears the terminal and draws the meter of the current encounter.
+func (m *LiveMeter) Render(w io.Writer) {
+	enc := m.Snapshot()
+	fmt.Fprint(w, chartTheme.ansi(), "\033[H\033[2J")
+	m.mu.Lock()
+	count, status := m.count, m.status
+	m.mu.Unlock()
+	if enc == nil {
+		fmt.Fprintln(w, "waiting for combat...")
+		if status != "" {
+			fmt.Fprintln(w, status)
+		}
+		fmt.Fprint(w, "\033[0m")
+		return
+	}
+	fmt.Fprintf(w, "encounter %d: %v\n", count, enc.Duration())
+	if countdown := enrageCountdown(enc); countdown != "" {
+		fmt.Fprintln(w, countdown)
+	}
+	if m.compact {
+		printTopTo(w, enc)
+	} else {
+		printMeterTo(w, enc)
+	}
+	if status != "" {
+		fmt.Fprintln(w, status)
+	}
+	fmt.Fprint(w, "\033[0m")
+}
+
+// liveOptions are the flags shared by the live commands.
+type liveOptions struct {
+	httpAddr    string
+	snapshotDir string
+	top         bool
+	clipboard   bool
+	share       string
+	server      *http.Server
+	clients     *wsClients
+	unpublish   context.CancelFunc
+}
+
+func liveFlags(fs *flag.FlagSet) *liveOptions {
+	opts := &liveOptions{}
+	fs.StringVar(&opts.httpAddr, "http", "", "serve the live API on this address, e.g. :8089")
+	fs.StringVar(&opts.snapshotDir, "snapshots", ".", "directory snapshots are saved to")
+	fs.BoolVar(&opts.top, "top", false, fmt.Sprintf("show a compact table of the top %d damage and healing instead of the meter", liveTopN))
+	fs.BoolVar(&opts.clipboard, "clipboard", false, "copy a one-line summary of every ended encounter to the clipboard, for the in-game chat")
+	fs.StringVar(&opts.share, "share", "", "publish the meter as a live session of this name on the share server, for spectate")
+	return opts
+}
+
+// start runs the snapshot keybindings and, if configured, the HTTP API and
+// publishing to the share server.
+func (opts *liveOptions) start(meter *LiveMeter) error {
+	meter.compact, meter.clipboard = opts.top, opts.clipboard
+	if opts.share != "" {
+		if config.Share.URL == "" {
+			return fmt.Errorf("no share server to publish to, set share.url in the config or run init")
+		}
+		if !validSessionName.MatchString(opts.share) {
+			return fmt.Errorf("session name %q: use letters, digits, '.', '-' and '_'", opts.share)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		opts.unpublish = cancel
+		go publishLiveMeter(ctx, meter, config.Share, opts.share)
+	}
+	go watchSnapshotKeys(meter, opts.snapshotDir, meter.SetStatus)
+	if opts.httpAddr == "" {
+		return nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/snapshot", snapshotHandler(meter, opts.snapshotDir))
+	mux.Handle("/top", topHandler(meter))
+	opts.clients = newWSClients()
+	mux.Handle("/overlay", overlayHandler(meter, opts.clients))
+	mux.HandleFunc("/{$}", meterPageHandler)
+	opts.server = &http.Server{Addr: opts.httpAddr, Handler: mux}
+	go func() {
+		if err := opts.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			meter.SetStatus(fmt.Sprintf("http server stopped: %v", err))
+		}
+	}()
+	return nil
+}
+
+// stop shuts the HTTP API down, letting open requests finish, ends the
+// published session, saves a last snapshot of the current encounter and
+// prints a summary.
+func (opts *liveOptions) stop(meter *LiveMeter, w io.Writer) {
+	if opts.unpublish != nil {
+		opts.unpublish()
+	}
+	if opts.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := opts.server.Shutdown(ctx); err != nil {
+			slog.Error("stopping http server", "err", err)
+		}
+		if err := opts.clients.shutdown(ctx); err != nil {
+			slog.Error("closing overlays", "err", err)
+		}
+	}
+	meter.mu.Lock()
+	count := meter.count
+	meter.mu.Unlock()
+	fmt.Fprintf(w, "saw %d encounters\n", count)
+	if count == 0 {
+		return
+	}
+	path, err := saveSnapshot(meter, opts.snapshotDir, "json")
+	if err != nil {
+		slog.Error("saving last snapshot", "err", err)
+		return
+	}
+	fmt.Fprintf(w, "saved the last encounter to %v\n", path)
+}
+
+// runLive feeds entries from source into the meter, redrawing it every
+// second until source is closed or ctx is canceled. Notifiers hear about
+// player deaths and ended encounters.
+func runLive(ctx context.Context, meter *LiveMeter, source <-chan *LogEntry, w io.Writer) {
+	ticker := time.NewTicker(liveRefresh)
+	defer ticker.Stop()
+	defer flushNotifications()
+	tally, gapsChecked := newChannelTally(), false
+	for {
+		select {
+		case <-ctx.Done():
+			meter.Render(w)
+			return
+		case entry, ok := <-source:
+			if !ok {
+				if enc := meter.Snapshot(); enc != nil {
+					meter.encounterEnded(enc)
+				}
+				meter.Render(w)
+				return
+			}
+			if meter.endsEncounter(entry) {
+				meter.encounterEnded(meter.Snapshot())
+				// a whole fight is needed to tell a quiet start from a
+				// missing filter
+				if gaps := tally.loggingGaps(); !gapsChecked && gaps != nil {
+					gapsChecked = true
+					if len(gaps) > 0 {
+						meter.SetStatus(loggingGapsStatus(gaps))
+					}
+				}
+			}
+			meter.Add(entry)
+			tally.add(entry)
+			if entry.etype == Death && len(activeNotifiers) > 0 {
+				if enc, died := meter.snapshotOf(entry); died != nil && enc.kindOf(died.TargetID) == Player {
+					notify(deathEvent(enc, died))
+				}
+			}
+		case <-ticker.C:
+			meter.Render(w)
+		}
+	}
+}
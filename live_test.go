@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"io"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// recordingNotifier keeps the events it is sent.
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []WebhookEvent
+}
+
+func (n *recordingNotifier) name() string                  { return "recording" }
+func (n *recordingNotifier) wants(event WebhookEvent) bool { return true }
+
+func (n *recordingNotifier) send(event WebhookEvent) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, event)
+	return nil
+}
+
+func TestLiveDeathNotifications(t *testing.T) {
+	recorder := &recordingNotifier{}
+	defer func(notifiers []notifier) { activeNotifiers = notifiers }(activeNotifiers)
+	activeNotifiers = []notifier{recorder}
+
+	source := make(chan *LogEntry)
+	done := make(chan struct{})
+	go func() {
+		runLive(context.Background(), &LiveMeter{}, source, io.Discard)
+		close(done)
+	}()
+	for _, line := range []string{
+		"[07/08 05:35:08 PM] Starlaf applied a benefit with Man-form on Starlaf.",
+		"[07/08 05:35:10 PM] Burkhad scored a hit with Cleave on Starlaf for 2,508 Beleriand damage to Morale.",
+		"[07/08 05:35:11 PM] Burkhad defeated Starlaf.",
+		// NPC deaths are not told
+		"[07/08 05:35:12 PM] Huya defeated Burkhad.",
+	} {
+		entry, err := parseLogLine(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		source <- entry
+	}
+	close(source)
+	<-done
+
+	deaths := []string{}
+	for _, event := range recorder.events {
+		if event.Event == eventDeath {
+			deaths = append(deaths, event.Text)
+		}
+	}
+	if want := []string{"Starlaf died at +3s"}; !slices.Equal(deaths, want) {
+		t.Errorf("got deaths %q, want %q", deaths, want)
+	}
+}
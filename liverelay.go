@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// how long a publishing meter waits before dialing the share server
+	// again after losing it
+	publishRetry = 5 * time.Second
+	// how long a session outlives its publisher's connection, for it to
+	// come back without its spectators noticing
+	liveGrace = 30 * time.Second
+)
+
+// live session names, used in URLs
+var validSessionName = regexp.MustCompile(`^[\w.-]{1,64}$`)
+
+// LiveSessionInfo describes a live session published to the share server.
+type LiveSessionInfo struct {
+	Name       string    `json:"name"`
+	Started    time.Time `json:"started"`
+	Encounter  int       `json:"encounter"`
+	Spectators int       `json:"spectators"`
+}
+
+// liveSession is a live meter published to the share server. It keeps the
+// current encounter from the publisher's states and diffs and is the
+// overlaySource of its spectators.
+type liveSession struct {
+	name    string
+	started time.Time
+	done    chan struct{}
+	// publishing is set while a meter is connected, guarded by the
+	// relay's mutex
+	publishing bool
+
+	mu         sync.Mutex
+	hello      OverlayMessage
+	frame      overlayFrame
+	ok         bool // a state was received
+	spectators int
+}
+
+func (l *liveSession) overlayHello() OverlayMessage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	hello := l.hello
+	hello.Type, hello.V, hello.MinV, hello.Capabilities = overlayHello, overlayVersion, overlayMinVersion, overlayCapabilities
+	return hello
+}
+
+func (l *liveSession) overlayFrame() (overlayFrame, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.frame, l.ok
+}
+
+func (l *liveSession) overlayDone() <-chan struct{} {
+	return l.done
+}
+
+func (l *liveSession) info() LiveSessionInfo {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return LiveSessionInfo{Name: l.name, Started: l.started, Encounter: l.frame.Encounter, Spectators: l.spectators}
+}
+
+// liveRelay passes live sessions from the meters publishing them to their
+// spectators. Sessions are keyed by guild and name; public ones have no
+// guild.
+type liveRelay struct {
+	mu       sync.Mutex
+	sessions map[string]*liveSession
+}
+
+func newLiveRelay() *liveRelay {
+	return &liveRelay{sessions: map[string]*liveSession{}}
+}
+
+func liveSessionKey(guild, name string) string {
+	return guild + "/" + name
+}
+
+// open registers a session, or takes one back within liveGrace of its
+// publisher leaving. It returns false if another meter publishes it.
+func (r *liveRelay) open(guild, name string) (*liveSession, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := liveSessionKey(guild, name)
+	session, ok := r.sessions[key]
+	if ok && session.publishing {
+		return nil, false
+	}
+	if !ok {
+		session = &liveSession{name: name, started: time.Now(), done: make(chan struct{})}
+		r.sessions[key] = session
+	}
+	session.publishing = true
+	return session, true
+}
+
+// leave marks a session's publisher gone. The session ends, telling its
+// spectators, unless it is published again within liveGrace.
+func (r *liveRelay) leave(guild string, session *liveSession) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session.publishing = false
+	time.AfterFunc(liveGrace, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		key := liveSessionKey(guild, session.name)
+		if session.publishing || r.sessions[key] != session {
+			return
+		}
+		delete(r.sessions, key)
+		close(session.done)
+	})
+}
+
+func (r *liveRelay) get(guild, name string) *liveSession {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sessions[liveSessionKey(guild, name)]
+}
+
+// list returns the sessions of a guild by name.
+func (r *liveRelay) list(guild string) []LiveSessionInfo {
+	r.mu.Lock()
+	sessions := []*liveSession{}
+	for key, session := range r.sessions {
+		if strings.HasPrefix(key, guild+"/") {
+			sessions = append(sessions, session)
+		}
+	}
+	r.mu.Unlock()
+	list := []LiveSessionInfo{}
+	for _, session := range sessions {
+		list = append(list, session.info())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// listLiveSessions lists the live sessions of the guild in the URL, or the
+// public ones outside of guild routes.
+func (s *shareServer) listLiveSessions(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.live.list(r.PathValue("guild")))
+}
+
+// publishLive takes the overlay protocol from a live meter and keeps its
+// session open while the meter stays connected, see liveRelay.leave.
+func (s *shareServer) publishLive(w http.ResponseWriter, r *http.Request) {
+	guild, name := r.PathValue("guild"), r.PathValue("session")
+	if !validSessionName.MatchString(name) {
+		http.Error(w, "invalid session name", http.StatusBadRequest)
+		return
+	}
+	session, ok := s.live.open(guild, name)
+	if !ok {
+		http.Error(w, "a session of that name is live", http.StatusConflict)
+		return
+	}
+	defer s.live.leave(guild, session)
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	defer ws.conn.Close()
+	defer s.clients.track()()
+	// the read below only returns once the connection closes
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-s.clients.closing:
+			ws.close(1001, "server shutting down")
+		case <-finished:
+		}
+	}()
+	slog.Info("live session started", "guild", guild, "session", name, "remote", r.RemoteAddr)
+	for {
+		_, data, err := ws.readMessage()
+		if err != nil {
+			slog.Info("live session ended", "guild", guild, "session", name, "err", err)
+			return
+		}
+		var msg OverlayMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			ws.writeJSON(OverlayMessage{Type: overlayError, V: overlayVersion, Error: fmt.Sprintf("invalid message: %v", err)})
+			continue
+		}
+		session.mu.Lock()
+		if msg.Type == overlayHello {
+			session.hello = msg
+		} else if session.frame.apply(msg) {
+			session.ok = true
+		}
+		session.mu.Unlock()
+	}
+}
+
+// spectateLive speaks the overlay protocol to a spectator of a session.
+func (s *shareServer) spectateLive(w http.ResponseWriter, r *http.Request) {
+	session := s.live.get(r.PathValue("guild"), r.PathValue("session"))
+	if session == nil {
+		http.Error(w, "no such live session", http.StatusNotFound)
+		return
+	}
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	defer ws.conn.Close()
+	defer s.clients.track()()
+	session.mu.Lock()
+	session.spectators++
+	session.mu.Unlock()
+	defer func() {
+		session.mu.Lock()
+		session.spectators--
+		session.mu.Unlock()
+	}()
+	o := &overlaySession{ws: ws, source: session, diffs: true, closing: s.clients.closing}
+	if err := o.run(); err != nil {
+		slog.Debug("spectator disconnected", "remote", r.RemoteAddr, "err", err)
+	}
+}
+
+// liveURL is where a live session is published and spectated.
+func (c ShareConfig) liveURL(session string) string {
+	base := strings.TrimSuffix(c.URL, "/")
+	if c.Guild != "" {
+		base += "/guilds/" + url.PathEscape(c.Guild)
+	}
+	return base + "/live/" + url.PathEscape(session)
+}
+
+// shareHeader authorizes requests with the token of the config.
+func (c ShareConfig) shareHeader() http.Header {
+	header := http.Header{}
+	if c.Token != "" {
+		header.Set("Authorization", "Bearer "+c.Token)
+	}
+	return header
+}
+
+// publishLiveMeter publishes the meter as a live session on the share
+// server until ctx is done, dialing again whenever the connection drops.
+func publishLiveMeter(ctx context.Context, meter *LiveMeter, share ShareConfig, name string) {
+	for {
+		ws, err := dialWebSocket(share.liveURL(name)+"/publish", share.shareHeader())
+		if err == nil {
+			meter.SetStatus(fmt.Sprintf("live as %q on %v", name, share.URL))
+			stop := context.AfterFunc(ctx, func() { ws.close(1000, "session ended") })
+			err = (&overlaySession{ws: ws, source: meter, diffs: true}).run()
+			stop()
+			ws.conn.Close()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		meter.SetStatus(fmt.Sprintf("sharing: %v, retrying in %v", err, publishRetry))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(publishRetry):
+		}
+	}
+}
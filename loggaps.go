@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// combat entries a log needs before a missing kind of line is more
+	// likely the chat filter than a quiet fight
+	loggingGapMinCombat = 200
+)
+
+// LoggingGap is a kind of combat line missing from a log, usually because
+// its chat filter is off in game.
+type LoggingGap struct {
+	Channel string `json:"channel"`
+	Fix     string `json:"fix"`
+}
+
+// loggedChannels are the kinds of combat lines a complete log has, with the
+// filter that turns each on.
+var loggedChannels = []struct {
+	channel string
+	filter  string
+}{
+	{"damage dealt", "outgoing damage"},
+	{"damage taken", "incoming damage"},
+	{"healing", "outgoing and incoming healing"},
+	{"benefit", "benefits"},
+}
+
+// channelTally counts entries by loggedChannels as they are parsed. Hits
+// are split into dealt and taken by their target: only the logging
+// character's incoming hits are logged, and it is among the healed and
+// buffed.
+type channelTally struct {
+	combat   int
+	byType   map[EventType]int
+	hitsOn   map[string]int
+	friendly map[string]bool
+}
+
+func newChannelTally() *channelTally {
+	return &channelTally{byType: map[EventType]int{}, hitsOn: map[string]int{}, friendly: map[string]bool{selfplaceholder: true}}
+}
+
+func (t *channelTally) add(entry *LogEntry) {
+	if !entry.etype.isCombat() {
+		return
+	}
+	t.combat++
+	t.byType[entry.etype]++
+	switch entry.etype {
+	case DmgDealt:
+		t.hitsOn[entry.Target]++
+	case Heal, Benefit:
+		t.friendly[entry.Target] = true
+	}
+}
+
+func (t *channelTally) count(channel string) int {
+	taken := 0
+	for target, n := range t.hitsOn {
+		if t.friendly[target] {
+			taken += n
+		}
+	}
+	switch channel {
+	case "damage dealt":
+		return t.byType[DmgDealt] - taken
+	case "damage taken":
+		return taken
+	case "healing":
+		return t.byType[Heal]
+	case "benefit":
+		return t.byType[Benefit]
+	}
+	return 0
+}
+
+// loggingGaps are the expected kinds of combat lines absent from the
+// tallied entries. It returns nil while there is too little combat to tell
+// and an empty slice for a complete log.
+func (t *channelTally) loggingGaps() []LoggingGap {
+	if t.combat < loggingGapMinCombat {
+		return nil
+	}
+	gaps := []LoggingGap{}
+	for _, c := range loggedChannels {
+		if t.count(c.channel) == 0 {
+			gaps = append(gaps, LoggingGap{
+				Channel: c.channel,
+				Fix: fmt.Sprintf("in game, right-click the chat tab you log, open its filters and tick the combat %v messages, "+
+					"then restart logging with /chatlog", c.filter),
+			})
+		}
+	}
+	return gaps
+}
+
+// printLoggingGaps draws the gaps as a box that is hard to scroll past, as
+// their stats silently come out empty otherwise.
+func printLoggingGaps(w io.Writer, gaps []LoggingGap) {
+	if len(gaps) == 0 {
+		return
+	}
+	rule := strings.Repeat("!", 72)
+	fmt.Fprintln(w, rule)
+	fmt.Fprintln(w, "!! This log is missing combat lines, some stats will be empty:")
+	for _, g := range gaps {
+		fmt.Fprintf(w, "!!  - no %v lines\n", g.Channel)
+		fmt.Fprintf(w, "!!    %v\n", g.Fix)
+	}
+	fmt.Fprintln(w, rule)
+}
+
+// loggingGapsStatus sums the gaps up in one line for the live meter.
+func loggingGapsStatus(gaps []LoggingGap) string {
+	channels := []string{}
+	for _, g := range gaps {
+		channels = append(channels, g.Channel)
+	}
+	return fmt.Sprintf("!! no %v lines: tick their combat messages in the filters of the logged chat tab, then /chatlog again",
+		strings.Join(channels, ", "))
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+)
+
+// setupLogging configures the diagnostics logger on stderr. Warnings and
+// errors are always shown, -v adds info and -vv debug messages.
+func setupLogging(verbosity int, json bool) {
+	level := slog.LevelWarn
+	switch {
+	case verbosity >= 2:
+		level = slog.LevelDebug
+	case verbosity == 1:
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+	if json {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(handler))
+}
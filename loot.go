@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	copperPerSilver = 100
+	copperPerGold   = 1000 * copperPerSilver
+)
+
+func pLoot(line string) (*LogEntry, error) {
+	if !pattern("loot.detect").MatchString(line) {
+		return nil, &ParseNotMatchError{}
+	}
+	timestamp, msg, err := extractTimestamp(line)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing timestamp: %w", err)
+	}
+	entry := &LogEntry{RawMessage: line}
+	entry.Timestamp = timestamp
+
+	re := pattern("loot")
+	match := re.FindStringSubmatch(msg)
+	if len(match) == 0 {
+		return nil, fmt.Errorf("Failed to parse as loot: <%s>", msg)
+	}
+	entry.Source = match[re.SubexpIndex("looter")]
+	if entry.Source == "You" {
+		entry.Source = selfplaceholder
+	}
+	entry.Skill = match[re.SubexpIndex("item")]
+	entry.Value = 1
+	if count := match[re.SubexpIndex("count")]; count != "" {
+		val, err := parseValue(count)
+		if err != nil {
+			return nil, fmt.Errorf("value not convertable to int: %v <%v>", err, msg)
+		}
+		entry.Value = val
+	}
+	return entry, nil
+}
+
+func pCurrency(line string) (*LogEntry, error) {
+	if !pattern("currency.detect").MatchString(line) {
+		return nil, &ParseNotMatchError{}
+	}
+	if pattern("currency.exclude").MatchString(line) {
+		return nil, &ParseNotMatchError{}
+	}
+	timestamp, msg, err := extractTimestamp(line)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing timestamp: %w", err)
+	}
+	entry := &LogEntry{RawMessage: line}
+	entry.Timestamp = timestamp
+	entry.Source = selfplaceholder
+
+	coins := pattern("currency.coins")
+	if matches := coins.FindAllStringSubmatch(msg, -1); len(matches) > 0 {
+		total := 0
+		for _, m := range matches {
+			val, err := parseValue(m[coins.SubexpIndex("value")])
+			if err != nil {
+				return nil, fmt.Errorf("value not convertable to int: %v <%v>", err, msg)
+			}
+			switch strings.ToLower(m[coins.SubexpIndex("metal")]) {
+			case "gold":
+				total += val * copperPerGold
+			case "silver":
+				total += val * copperPerSilver
+			default:
+				total += val
+			}
+		}
+		entry.Skill = "Coin"
+		entry.Value = total
+		entry.ValueType = "copper"
+		return entry, nil
+	}
+
+	re := pattern("currency")
+	match := re.FindStringSubmatch(msg)
+	if len(match) == 0 {
+		return nil, fmt.Errorf("Failed to parse as currency: <%s>", msg)
+	}
+	val, err := parseValue(match[re.SubexpIndex("value")])
+	if err != nil {
+		return nil, fmt.Errorf("value not convertable to int: %v <%v>", err, msg)
+	}
+	entry.Skill = match[re.SubexpIndex("currency")]
+	entry.Value = val
+	return entry, nil
+}
+
+// formatCoin renders a copper amount as gold/silver/copper.
+func formatCoin(copper int) string {
+	return fmt.Sprintf("%dg %ds %dc", copper/copperPerGold, copper%copperPerGold/copperPerSilver, copper%copperPerSilver)
+}
+
+// LootSummary totals the loot and currency gained over a session.
+type LootSummary struct {
+	Items    map[string]map[string]int // looter -> item -> count
+	Currency map[string]int            // currency -> amount, coin in copper
+}
+
+func summarizeLoot(entries []*LogEntry) LootSummary {
+	summary := LootSummary{Items: map[string]map[string]int{}, Currency: map[string]int{}}
+	for _, entry := range entries {
+		switch entry.etype {
+		case Loot:
+			if summary.Items[entry.Source] == nil {
+				summary.Items[entry.Source] = map[string]int{}
+			}
+			summary.Items[entry.Source][entry.Skill] += entry.Value
+		case Currency:
+			summary.Currency[entry.Skill] += entry.Value
+		}
+	}
+	return summary
+}
+
+func printLootSummary(summary LootSummary) {
+	if len(summary.Items) == 0 && len(summary.Currency) == 0 {
+		return
+	}
+	fmt.Println("loot:")
+	looters := make([]string, 0, len(summary.Items))
+	for looter := range summary.Items {
+		looters = append(looters, looter)
+	}
+	sort.Strings(looters)
+	for _, looter := range looters {
+		items := make([]string, 0, len(summary.Items[looter]))
+		for item := range summary.Items[looter] {
+			items = append(items, item)
+		}
+		sort.Strings(items)
+		for _, item := range items {
+			fmt.Printf("  %v: %vx %v\n", looter, summary.Items[looter][item], item)
+		}
+	}
+	currencies := make([]string, 0, len(summary.Currency))
+	for currency := range summary.Currency {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	for _, currency := range currencies {
+		if currency == "Coin" {
+			fmt.Printf("  %v: %v\n", currency, formatCoin(summary.Currency[currency]))
+			continue
+		}
+		fmt.Printf("  %v: %v\n", currency, summary.Currency[currency])
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import "testing"
+
+func TestParseLoot(t *testing.T) {
+	tests := []struct {
+		line      string
+		etype     EventType
+		source    string
+		skill     string
+		value     int
+		valueType string
+	}{
+		{"[07/08 06:05:00 PM] You acquired [Ancient Script].", Loot, selfplaceholder, "Ancient Script", 1, ""},
+		{"[07/08 06:05:00 PM] You've acquired 3 [Ancient Script].", Loot, selfplaceholder, "Ancient Script", 3, ""},
+		{"[07/08 06:05:00 PM] Huya has acquired 1,200 [Shards].", Loot, "Huya", "Shards", 1200, ""},
+		{"[07/08 06:05:00 PM] You've earned 45 copper.", Currency, selfplaceholder, "Coin", 45, "copper"},
+		{"[07/08 06:05:00 PM] You've earned 23 silver 45 copper.", Currency, selfplaceholder, "Coin", 2345, "copper"},
+		{"[07/08 06:05:00 PM] You've earned 1 gold 23 silver 45 copper.", Currency, selfplaceholder, "Coin", 102345, "copper"},
+		{"[07/08 06:05:00 PM] You've looted 2 Gold.", Currency, selfplaceholder, "Coin", 200000, "copper"},
+		{"[07/08 06:05:00 PM] You have received 1,500 Marks.", Currency, selfplaceholder, "Marks", 1500, ""},
+	}
+	for _, tt := range tests {
+		entry, err := parseLogLine(tt.line)
+		if err != nil {
+			t.Errorf("%q: %v", tt.line, err)
+			continue
+		}
+		if entry.etype != tt.etype || entry.Source != tt.source || entry.Skill != tt.skill || entry.Value != tt.value || entry.ValueType != tt.valueType {
+			t.Errorf("%q: got %v %q %q %d %q, want %v %q %q %d %q", tt.line,
+				entry.etype, entry.Source, entry.Skill, entry.Value, entry.ValueType,
+				tt.etype, tt.source, tt.skill, tt.value, tt.valueType)
+		}
+	}
+}
+
+func TestExperienceIsNotCurrency(t *testing.T) {
+	entry, err := parseLogLine("[07/08 06:05:00 PM] You've earned 1,234 XP.")
+	if err == nil && entry.etype == Currency {
+		t.Errorf("experience parsed as currency %q", entry.Skill)
+	}
+}
+
+func TestFormatCoin(t *testing.T) {
+	tests := []struct {
+		copper int
+		want   string
+	}{
+		{0, "0g 0s 0c"},
+		{45, "0g 0s 45c"},
+		{2345, "0g 23s 45c"},
+		{102345, "1g 23s 45c"},
+		{12 * copperPerGold, "12g 0s 0c"},
+	}
+	for _, tt := range tests {
+		if got := formatCoin(tt.copper); got != tt.want {
+			t.Errorf("formatCoin(%d) = %q, want %q", tt.copper, got, tt.want)
+		}
+	}
+}
+
+func TestSummarizeLoot(t *testing.T) {
+	entries := []*LogEntry{
+		{etype: Loot, Source: "Huya", Skill: "Shards", Value: 2},
+		{etype: Loot, Source: "Huya", Skill: "Shards", Value: 3},
+		{etype: Currency, Skill: "Coin", Value: 2345},
+		{etype: Currency, Skill: "Coin", Value: copperPerGold},
+		{etype: DmgDealt, Source: "Huya", Skill: "Shards", Value: 40044},
+	}
+	summary := summarizeLoot(entries)
+	if got := summary.Items["Huya"]["Shards"]; got != 5 {
+		t.Errorf("got %d Shards, want 5", got)
+	}
+	if got := formatCoin(summary.Currency["Coin"]); got != "1g 23s 45c" {
+		t.Errorf("got %v of coin, want 1g 23s 45c", got)
+	}
+}
@@ -1,12 +1,12 @@
 package main
 
 import (
-	"bufio"
 	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
 	"os"
-	"regexp"
-	"strconv"
+	"runtime"
 	"strings"
 	"time"
 )
@@ -33,8 +33,50 @@ const (
 	CcBroken
 	Benefit
 	Comment
+	Chat
+	Loot
+	Currency
+	Experience
+	LevelUp
+	Progress
 )
 
+var eventTypeNames = map[EventType]string{
+	Unknown:             "Unknown",
+	DmgTaken:            "DmgTaken",
+	DmgDealt:            "DmgDealt",
+	Heal:                "Heal",
+	PowerRestored:       "PowerRestored",
+	DebuffApplied:       "DebuffApplied",
+	BuffApplied:         "BuffApplied",
+	Interrupt:           "Interrupt",
+	CorruptionRemoved:   "CorruptionRemoved",
+	Death:               "Death",
+	Revive:              "Revive",
+	CombatStart:         "CombatStart",
+	CombatEnd:           "CombatEnd",
+	MobInterrupt:        "MobInterrupt",
+	TempMoraleLost:      "TempMoraleLost",
+	TempMoraleNotWasted: "TempMoraleNotWasted",
+	CcBroken:            "CcBroken",
+	Benefit:             "Benefit",
+	Comment:             "Comment",
+	Chat:                "Chat",
+	Loot:                "Loot",
+	Currency:            "Currency",
+	Experience:          "Experience",
+	LevelUp:             "LevelUp",
+	Progress:            "Progress",
+}
+
+func (t EventType) String() string {
+	if name, ok := eventTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Avoid is how a hit was avoided, UnknownAvoid for hits that were not.
 type Avoid int
 
 const (
@@ -48,6 +90,25 @@ const (
 	Missed
 )
 
+// hitAvoids maps the avoidance qualifier of a "scored a ... hit" line to Avoid.
+var hitAvoids = map[string]Avoid{
+	"blocked": Blocked,
+	"parried": Parried,
+	"evaded":  Evaded,
+}
+
+// attemptAvoids maps how a "tried to use ... but he ... the attempt" line
+// says the target avoided it to Avoid.
+var attemptAvoids = map[string]Avoid{
+	"blocked":        Blocked,
+	"parried":        Parried,
+	"evaded":         Evaded,
+	"resisted":       Resisted,
+	"deflected":      Deflected,
+	"was immune to":  Immune,
+	"were immune to": Immune,
+}
+
 const (
 	// whenever parser finds "you", to be replaced later by logic that knows the player's name
 	selfplaceholder = "SELF_REPLACE"
@@ -73,11 +134,28 @@ type LogEntry struct {
 	Skill     string
 	Value     int
 	ValueType string
+	School    string // mitigation school of a damage type, see enrichValueType
 	Crit      bool
 	Dev       bool
 	Avoided   Avoid
+	// Partial is set for "partially blocked" and the like: the hit landed
+	// with reduced damage instead of being avoided outright.
+	Partial bool
+	// AvoidedValue estimates the damage the avoidance took away, see
+	// estimateAvoidedValues. Zero when there is nothing to estimate from.
+	AvoidedValue int
 	// FinalTarget string
 	RawMessage string // The original log line (for debugging)
+
+	// MetaKey and MetaValue are set on Comment entries following the
+	// "### key: value" convention, e.g. "### label: Attempt 3".
+	MetaKey   string
+	MetaValue string
+
+	// SourceID and TargetID identify the entity within its encounter, see
+	// assignEntities.
+	SourceID string
+	TargetID string
 }
 
 // parseLogLine parses a single line from the log file.
@@ -93,11 +171,16 @@ func parseLogLine(line string) (*LogEntry, error) {
 		Revive:            {pRevive, pSuccumb}, // no idea why succumb to wounds == revive
 		CorruptionRemoved: {pCorrRemove},
 		CcBroken:          {pCCBroken},
+		Loot:              {pLoot},
+		Currency:          {pCurrency},
+		Experience:        {pExperience},
+		LevelUp:           {pLevelUp},
+		Progress:          {pProgress},
 	}
 	var entry *LogEntry
-	for _, ps := range options {
+	for et, ps := range options {
 		for _, p := range ps {
-			e, err := p(line)
+			e, err := runParser(p, line)
 			if err != nil {
 				var nomatch *ParseNotMatchError
 				if errors.As(err, &nomatch) {
@@ -107,19 +190,102 @@ func parseLogLine(line string) (*LogEntry, error) {
 			}
 			// was success
 			entry = e
+			entry.etype = et
 		}
 	}
 	if entry == nil {
-		return nil, fmt.Errorf("No parsers matched: <%v>", line)
+		return nil, &UnmatchedLineError{Line: line}
 	}
 	entry.RawMessage = line
 
 	return entry, nil
 }
 
+// timestampLayouts are tried in order, sniffLog moves the one a log uses first.
+var timestampLayouts = []string{"01/02 03:04:05 PM", "01/02 15:04:05"}
+
+// fastTimestamps is set by selectPatterns while the timestamp pattern is the
+// embedded one, whose prefixes scanTimestamp reads without it.
+var fastTimestamps bool
+
 // extractTimestamp extracts the timestamp from the log line.
 func extractTimestamp(line string) (time.Time, string, error) {
-	re := regexp.MustCompile(`^\[?(\d{2}\/\d{2}\s+\d{2}:\d{2}:\d{2}\s*(?:AM|PM)?)\]? `)
+	if fastTimestamps {
+		if t, n, ok := scanTimestamp(line); ok {
+			return t, line[n:], nil
+		}
+	}
+	return matchTimestamp(line)
+}
+
+// twoDigits reads a number of two digits.
+func twoDigits(s string) (int, bool) {
+	if s[0] < '0' || s[0] > '9' || s[1] < '0' || s[1] > '9' {
+		return 0, false
+	}
+	return int(s[0]-'0')*10 + int(s[1]-'0'), true
+}
+
+// scanTimestamp reads the prefixes of real logs, "[01/02 03:04:05 PM] " and
+// "[01/02 15:04:05] ", at their fixed offsets, as extracting timestamps is
+// the hottest path of parsing. It returns the time as time.Parse would and
+// the length of the prefix, or false for anything else, which is left to
+// the timestamp pattern.
+func scanTimestamp(line string) (time.Time, int, bool) {
+	i := 0
+	if strings.HasPrefix(line, "[") {
+		i = 1
+	}
+	if len(line) < i+14 {
+		return time.Time{}, 0, false
+	}
+	s := line[i : i+14]
+	if s[2] != '/' || s[5] != ' ' || s[8] != ':' || s[11] != ':' {
+		return time.Time{}, 0, false
+	}
+	month, ok1 := twoDigits(s[0:2])
+	day, ok2 := twoDigits(s[3:5])
+	hour, ok3 := twoDigits(s[6:8])
+	minute, ok4 := twoDigits(s[9:11])
+	second, ok5 := twoDigits(s[12:14])
+	if !(ok1 && ok2 && ok3 && ok4 && ok5) {
+		return time.Time{}, 0, false
+	}
+	i += 14
+	rest := line[i:]
+	if strings.HasPrefix(rest, " AM") || strings.HasPrefix(rest, " PM") {
+		// like the 03 of a layout, 00 to 12
+		if hour > 12 {
+			return time.Time{}, 0, false
+		}
+		switch {
+		case rest[1] == 'P' && hour < 12:
+			hour += 12
+		case rest[1] == 'A' && hour == 12:
+			hour = 0
+		}
+		i += 3
+	} else if hour > 23 || len(rest) > 1 && rest[0] == ' ' && strings.ContainsRune("\t\n\f\r ]", rune(rest[1])) {
+		// the pattern would take the space into the timestamp
+		return time.Time{}, 0, false
+	}
+	if i < len(line) && line[i] == ']' {
+		i++
+	}
+	if i >= len(line) || line[i] != ' ' {
+		return time.Time{}, 0, false
+	}
+	// time.Parse checks days against year 0, a leap year
+	if month < 1 || month > 12 || day < 1 || day > time.Date(0, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day() ||
+		minute > 59 || second > 59 {
+		return time.Time{}, 0, false
+	}
+	return time.Date(0, time.Month(month), day, hour, minute, second, 0, time.UTC), i + 1, true
+}
+
+// matchTimestamp extracts the timestamp with the timestamp pattern.
+func matchTimestamp(line string) (time.Time, string, error) {
+	re := pattern("timestamp")
 	match := re.FindStringSubmatch(line)
 	if len(match) < 2 {
 		return time.Time{}, "", fmt.Errorf("timestamp not found")
@@ -128,8 +294,7 @@ func extractTimestamp(line string) (time.Time, string, error) {
 	remaining := line[len(match[0]):]
 
 	timestampStr := match[1]
-	formats := []string{"01/02 03:04:05 PM", "01/02 03:04:05"}
-	for _, format := range formats {
+	for _, format := range timestampLayouts {
 		t, err := time.Parse(format, timestampStr)
 		if err == nil {
 			return t, remaining, nil
@@ -139,54 +304,144 @@ func extractTimestamp(line string) (time.Time, string, error) {
 }
 
 func main() {
-	filePath := "test/input.txt" // Or "Combat_20240708_2.txt"
+	if len(os.Args) == 1 && runtime.GOOS == "windows" {
+		// started by a double-click
+		os.Args = append(os.Args, "gui")
+	}
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				slog.Error(err.Error(), "command", os.Args[1])
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	keepChat := flag.Bool("chat", false, "keep chat and system lines as Chat entries instead of skipping them")
+	analyze := flag.String("analyze", "", fmt.Sprintf("comma separated analyses to run per encounter: %v", analysisNames()))
+	openerRef := flag.String("opener-ref", "", "comma separated reference skill order for the opener analysis")
+	priority := flag.String("priority", "", "comma separated priority adds for the targets analysis")
+	strict := flag.Bool("strict", false, "fail on the first unparsed or partially parsed line")
+	sample := flag.Int("sample", 10, "number of failing lines to show in lenient mode")
+	qualityPath := flag.String("quality", "", "write a JSON parse quality report to this path")
+	maxErrorRate := flag.Float64("max-error-rate", -1, fmt.Sprintf("exit with %d when more than this share of lines (0 to 1) fails to parse, "+
+		"%d when any fails or the log has warnings; off when negative", exitTooManyErrors, exitWarnings))
+	flag.BoolVar(&meterAllActors, "all-actors", false, "include NPCs and pets in the meter")
+	players := flag.String("players", "", "comma separated roster to focus reports on")
+	setup := commonFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %v [command] [flags] [logfile]\ncommands: %v\n", os.Args[0], commandNames())
+		flag.PrintDefaults()
+	}
+	flag.Parse()
 
-	file, err := os.Open(filePath)
+	if err := setup(); err != nil {
+		slog.Error("loading config", "err", err)
+		os.Exit(1)
+	}
+	if *players != "" {
+		config.Players = strings.Split(*players, ",")
+	}
+	if *priority != "" {
+		config.PriorityTargets = strings.Split(*priority, ",")
+	}
+	if *openerRef != "" {
+		config.ReferenceOpener = strings.Split(*openerRef, ",")
+	}
+
+	filePath := inputPath(flag.CommandLine)
+
+	result, err := parseFile(filePath, ParserOptions{Strict: *strict, KeepChat: *keepChat, SampleErrors: *sample})
 	if err != nil {
-		fmt.Println("Error opening file:", err)
-		return
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	errorlines := []string{}
-	lines := 0
-	for scanner.Scan() {
-		lines++
-		line := scanner.Text()
-		_, err := parseLogLine(line)
-		if err != nil {
-			fmt.Println("Error parsing line:", err)
-			errorlines = append(errorlines, line)
-			continue
+		slog.Error("parsing file", "path", filePath, "err", err)
+		os.Exit(1)
+	}
+	entries := result.Entries
+
+	for _, warning := range result.Profile.Warnings {
+		slog.Warn(warning, "path", filePath)
+	}
+	printLoggingGaps(os.Stdout, result.LoggingGaps)
+	fmt.Printf("total lines: %v\n", result.Lines)
+	fmt.Printf("total errors: %v (%v unparsed, %v partial)\n", result.Errors(), result.Unparsed, result.Partial)
+	for channel, count := range result.Noise {
+		fmt.Printf("skipped %v lines: %v\n", channel, count)
+	}
+	for _, line := range result.Samples {
+		fmt.Println(line)
+	}
+	printNormalizeStats(result.Normalized)
+	for _, a := range result.Anomalies {
+		fmt.Printf("anomaly: %v: %v\n", a.Reason, a.Line)
+	}
+	if *qualityPath != "" {
+		if err := writeQualityReport(*qualityPath, qualityReport(filePath, result)); err != nil {
+			slog.Error("writing quality report", "path", *qualityPath, "err", err)
 		}
-		// fmt.Printf("Event Data: %+v\n", entry)
 	}
 
-	if scanner.Err() != nil {
-		fmt.Println("Error reading file:", scanner.Err())
+	printLootSummary(summarizeLoot(entries))
+	printProgressionSummary(summarizeProgression(entries))
+
+	encounters := segmentEncounters(entries)
+	multiple := len(characterSessions(encounters)) > 1
+	for i, enc := range encounters {
+		fmt.Printf("encounter %d: %v (%v, %v entries)", i+1, enc.Start.Format("15:04:05"), enc.Duration(), len(enc.Entries))
+		if enc.Label != "" {
+			fmt.Printf(" [%v]", enc.Label)
+		}
+		fmt.Println()
+		if multiple {
+			fmt.Printf("  character: %v\n", enc.Character)
+		}
+		if enc.Build != "" {
+			fmt.Printf("  build: %v\n", enc.Build)
+		}
+		for _, note := range enc.Notes {
+			fmt.Printf("  note: %v\n", note)
+		}
+		for _, warning := range coverageWarnings(enc) {
+			fmt.Printf("  coverage: %v\n", warning)
+		}
+	}
+
+	if err := runAnalyses(*analyze, encounters); err != nil {
+		slog.Error("running analyses", "err", err)
 	}
-	fmt.Printf("total lines: %v\n", lines)
-	fmt.Printf("total errors: %v\n", len(errorlines))
-	for i, el := range errorlines {
-		if i >= 10 {
-			break
+	if *maxErrorRate >= 0 {
+		code, reason := parseOutcome(result, *maxErrorRate)
+		if code != exitClean {
+			slog.Warn("parse quality", "exit", code, "reason", reason)
 		}
-		fmt.Println(el)
+		os.Exit(code)
 	}
 }
 
 func pComment(line string) (*LogEntry, error) {
 	if strings.HasPrefix(line, "###") {
-		return &LogEntry{
+		entry := &LogEntry{
 			etype: Comment,
-		}, nil
+		}
+		entry.MetaKey, entry.MetaValue = parseCommentMeta(line)
+		return entry, nil
 	}
 	return nil, &ParseNotMatchError{}
 }
 
+// parseCommentMeta splits a "### key: value" comment into a lowercased key and
+// its value. Comments without a colon return empty strings.
+func parseCommentMeta(line string) (string, string) {
+	body := strings.TrimSpace(strings.Trim(line, "#"))
+	key, value, found := strings.Cut(body, ":")
+	if !found {
+		return "", ""
+	}
+	return strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+}
+
 func pBenefit(line string) (*LogEntry, error) {
-	if match, err := regexp.Match("applied a .*benefit", []byte(line)); err != nil || !match {
+	if !pattern("benefit.detect").MatchString(line) {
 		return nil, &ParseNotMatchError{}
 	}
 
@@ -197,7 +452,7 @@ func pBenefit(line string) (*LogEntry, error) {
 	entry := &LogEntry{RawMessage: line}
 	entry.Timestamp = timestamp
 
-	re := regexp.MustCompile(`(?P<source>\w+) applied a (?P<crit>critical )?benefit with (?P<benefitname>.*) on (?P<target>.*).`)
+	re := pattern("benefit")
 	match := re.FindStringSubmatch(msg)
 	if len(match) == 0 {
 		return nil, fmt.Errorf("Failed to parse as Benefit: <%s>", msg)
@@ -210,7 +465,7 @@ func pBenefit(line string) (*LogEntry, error) {
 }
 
 func pHeal(line string) (*LogEntry, error) {
-	if match, err := regexp.Match("applied a .*heal", []byte(line)); err != nil || !match {
+	if !pattern("heal.detect").MatchString(line) {
 		return nil, &ParseNotMatchError{}
 	}
 
@@ -221,12 +476,12 @@ func pHeal(line string) (*LogEntry, error) {
 	entry := &LogEntry{RawMessage: line}
 	entry.Timestamp = timestamp
 
-	selfheal := regexp.MustCompile(`(?P<skill>\w+) applied a (?<crit>critical )?heal to (?P<target>.*) restoring (?P<value>[\d,]+) points to (?P<type>.*).`)
+	selfheal := pattern("heal.self")
 	match := selfheal.FindStringSubmatch(msg)
 	if len(match) != 0 {
 		entry.Skill = match[selfheal.SubexpIndex("skill")]
 		entry.Target = match[selfheal.SubexpIndex("target")]
-		val, err := strconv.Atoi(strings.Replace(match[selfheal.SubexpIndex("value")], ",", "", -1))
+		val, err := parseValue(match[selfheal.SubexpIndex("value")])
 		if err != nil {
 			return nil, fmt.Errorf("value not convertable to int: %v", err)
 		}
@@ -235,7 +490,7 @@ func pHeal(line string) (*LogEntry, error) {
 		entry.Crit = match[selfheal.SubexpIndex("crit")] != ""
 		return entry, nil
 	}
-	incHeal := regexp.MustCompile(`(?P<otherplayer>\w+) applied a (?<crit>critical )?heal with (?P<skill>.*?) to (?P<target>.*) restoring (?P<value>[\d,]+) points to (?P<type>.*).`)
+	incHeal := pattern("heal.other")
 	match = incHeal.FindStringSubmatch(msg)
 	if len(match) == 0 {
 		return nil, fmt.Errorf("Failed to parse as heal: <%v>", line)
@@ -243,7 +498,7 @@ func pHeal(line string) (*LogEntry, error) {
 	entry.Skill = match[incHeal.SubexpIndex("skill")]
 	entry.Target = match[incHeal.SubexpIndex("target")]
 	entry.Source = match[incHeal.SubexpIndex("otherplayer")]
-	val, err := strconv.Atoi(strings.Replace(match[incHeal.SubexpIndex("value")], ",", "", -1))
+	val, err := parseValue(match[incHeal.SubexpIndex("value")])
 	if err != nil {
 		return nil, fmt.Errorf("value not convertable to int: %v <%v>", err, msg)
 	}
@@ -254,7 +509,7 @@ func pHeal(line string) (*LogEntry, error) {
 }
 
 func pDmg(line string) (*LogEntry, error) {
-	if match, err := regexp.Match("scored a .*hit.*for.*damage", []byte(line)); err != nil || !match {
+	if !pattern("dmg.detect").MatchString(line) {
 		return nil, &ParseNotMatchError{}
 	}
 	timestamp, msg, err := extractTimestamp(line)
@@ -264,7 +519,7 @@ func pDmg(line string) (*LogEntry, error) {
 	entry := &LogEntry{RawMessage: line}
 	entry.Timestamp = timestamp
 
-	dmg := regexp.MustCompile(`(?P<source>[^ ]+) scored a (partially )?(?<avoided>blocked|parried|evaded)?(?<crit>critical|devastating)? ?hit with (?P<skill>.*?) on (?P<target>.*) for (?P<value>[\d,]+) (?P<type>.*?) ?damage to Morale.`)
+	dmg := pattern("dmg")
 	match := dmg.FindStringSubmatch(msg)
 	if len(match) == 0 {
 		return nil, fmt.Errorf("Failed to parse as dmg dealt: <%v>", line)
@@ -272,31 +527,36 @@ func pDmg(line string) (*LogEntry, error) {
 	entry.Skill = match[dmg.SubexpIndex("skill")]
 	entry.Target = match[dmg.SubexpIndex("target")]
 	entry.Source = match[dmg.SubexpIndex("source")]
-	val, err := strconv.Atoi(strings.Replace(match[dmg.SubexpIndex("value")], ",", "", -1))
+	val, err := parseValue(match[dmg.SubexpIndex("value")])
 	if err != nil {
 		return nil, fmt.Errorf("value not convertable to int: %v <%v>", err, msg)
 	}
 	entry.Value = val
 	entry.ValueType = match[dmg.SubexpIndex("type")]
-	entry.Crit = match[dmg.SubexpIndex("crit")] == "critical"
-	entry.Dev = match[dmg.SubexpIndex("crit")] == "devastating"
+	setHitQualifier(entry, dmg.SubexpNames(), match)
+	return entry, nil
+}
 
-	switch match[dmg.SubexpIndex("crit")] {
-	case "blocked":
-		entry.Avoided = Blocked
-	case "parried":
-		entry.Avoided = Parried
-	case "evaded":
-		entry.Avoided = Evaded
+// setHitQualifier fills the crit and avoidance fields from the qualifiers of
+// a "scored a ... hit" match.
+func setHitQualifier(entry *LogEntry, names []string, match []string) {
+	group := map[string]string{}
+	for i, name := range names {
+		if name != "" {
+			group[name] = match[i]
+		}
 	}
-	return entry, nil
+	entry.Crit = group["crit"] == "critical"
+	entry.Dev = group["crit"] == "devastating"
+	entry.Avoided = hitAvoids[group["avoided"]]
+	entry.Partial = group["partial"] != "" && entry.Avoided != UnknownAvoid
 }
 
 func pDmgNoValue(line string) (*LogEntry, error) {
-	if match, err := regexp.Match("scored a .*hit", []byte(line)); err != nil || !match {
+	if !pattern("dmgnovalue.detect").MatchString(line) {
 		return nil, &ParseNotMatchError{}
 	}
-	if match, err := regexp.Match("scored a .*hit.*for.*damage", []byte(line)); err != nil || match {
+	if pattern("dmg.detect").MatchString(line) {
 		return nil, &ParseNotMatchError{}
 	}
 
@@ -307,7 +567,7 @@ func pDmgNoValue(line string) (*LogEntry, error) {
 	entry := &LogEntry{RawMessage: line}
 	entry.Timestamp = timestamp
 
-	dmg := regexp.MustCompile(`(?P<player>\w+) scored a (partially )?(?<avoided>blocked|parried|evaded)?(?<crit>critical|devastating)? ?hit with (?P<skill>.*?) on (?P<target>[^ ]+).$`)
+	dmg := pattern("dmgnovalue")
 	match := dmg.FindStringSubmatch(msg)
 	if len(match) == 0 {
 		return nil, fmt.Errorf("Failed to parse as dmg no value: <%v>", line)
@@ -316,22 +576,12 @@ func pDmgNoValue(line string) (*LogEntry, error) {
 	entry.Target = match[dmg.SubexpIndex("target")]
 	entry.Source = match[dmg.SubexpIndex("player")]
 	entry.Value = 0
-	entry.Crit = match[dmg.SubexpIndex("crit")] == "critical"
-	entry.Dev = match[dmg.SubexpIndex("crit")] == "devastating"
-
-	switch match[dmg.SubexpIndex("crit")] {
-	case "blocked":
-		entry.Avoided = Blocked
-	case "parried":
-		entry.Avoided = Parried
-	case "evaded":
-		entry.Avoided = Evaded
-	}
+	setHitQualifier(entry, dmg.SubexpNames(), match)
 	return entry, nil
 }
 
 func pAvoid(line string) (*LogEntry, error) {
-	if match, err := regexp.Match("tried to use.*", []byte(line)); err != nil || !match {
+	if !pattern("avoid.detect").MatchString(line) {
 		return nil, &ParseNotMatchError{}
 	}
 	timestamp, msg, err := extractTimestamp(line)
@@ -341,7 +591,7 @@ func pAvoid(line string) (*LogEntry, error) {
 	entry := &LogEntry{RawMessage: line}
 	entry.Timestamp = timestamp
 
-	miss := regexp.MustCompile(`(?P<player>\w+) tried to use (?P<skill>.*?) on (?P<target>.*) but (?P<reason>.*) the attempt.`)
+	miss := pattern("avoid")
 	match := miss.FindStringSubmatch(msg)
 	if len(match) == 0 {
 		return nil, fmt.Errorf("Failed to parse as avoid: <%v>", line)
@@ -349,23 +599,12 @@ func pAvoid(line string) (*LogEntry, error) {
 	entry.Skill = match[miss.SubexpIndex("skill")]
 	entry.Target = match[miss.SubexpIndex("target")]
 	entry.Source = match[miss.SubexpIndex("player")]
-	switch match[miss.SubexpIndex("reason")] {
-	case "blocked":
-		entry.Avoided = Blocked
-	case "parried":
-		entry.Avoided = Parried
-	case "evaded":
-		entry.Avoided = Evaded
-	case "resisted":
-		entry.Avoided = Resisted
-	default:
-		entry.Avoided = UnknownAvoid
-	}
+	entry.Avoided = attemptAvoids[match[miss.SubexpIndex("reason")]]
 	return entry, nil
 }
 
 func pMiss(line string) (*LogEntry, error) {
-	if match, err := regexp.Match("missed trying to use.*", []byte(line)); err != nil || !match {
+	if !pattern("miss.detect").MatchString(line) {
 		return nil, &ParseNotMatchError{}
 	}
 	timestamp, msg, err := extractTimestamp(line)
@@ -375,7 +614,7 @@ func pMiss(line string) (*LogEntry, error) {
 	entry := &LogEntry{RawMessage: line}
 	entry.Timestamp = timestamp
 
-	miss := regexp.MustCompile(`(?P<player>\w+) missed trying to use (?P<skill>.*?) on (?P<target>.*).`)
+	miss := pattern("miss")
 	match := miss.FindStringSubmatch(msg)
 	if len(match) == 0 {
 		return nil, fmt.Errorf("Failed to parse as avoid: <%v>", line)
@@ -388,7 +627,7 @@ func pMiss(line string) (*LogEntry, error) {
 }
 
 func pTempMoraleLost(line string) (*LogEntry, error) {
-	if match, err := regexp.Match("You have lost .* of temporary Morale!", []byte(line)); err != nil || !match {
+	if !pattern("tempmorale.detect").MatchString(line) {
 		return nil, &ParseNotMatchError{}
 	}
 	timestamp, msg, err := extractTimestamp(line)
@@ -398,12 +637,12 @@ func pTempMoraleLost(line string) (*LogEntry, error) {
 	entry := &LogEntry{RawMessage: line}
 	entry.Timestamp = timestamp
 
-	re := regexp.MustCompile(`You have lost (?P<value>[\d,]+) points of temporary Morale!`)
+	re := pattern("tempmorale")
 	match := re.FindStringSubmatch(msg)
 	if len(match) == 0 {
 		return nil, fmt.Errorf("Failed to parse as temp morale lost: <%s>", msg)
 	}
-	val, err := strconv.Atoi(strings.Replace(match[re.SubexpIndex("value")], ",", "", -1))
+	val, err := parseValue(match[re.SubexpIndex("value")])
 	if err != nil {
 		return nil, fmt.Errorf("value not convertable to int: %v <%v>", err, msg)
 	}
@@ -413,7 +652,7 @@ func pTempMoraleLost(line string) (*LogEntry, error) {
 }
 
 func pDefeat(line string) (*LogEntry, error) {
-	if match, err := regexp.Match(".* defeated .*$", []byte(line)); err != nil || !match {
+	if !pattern("defeat.detect").MatchString(line) {
 		return nil, &ParseNotMatchError{}
 	}
 	timestamp, msg, err := extractTimestamp(line)
@@ -422,7 +661,7 @@ func pDefeat(line string) (*LogEntry, error) {
 	}
 	entry := &LogEntry{RawMessage: line}
 	entry.Timestamp = timestamp
-	re := regexp.MustCompile(`(?P<victor>.*) defeated (?P<dead>.*)\.`)
+	re := pattern("defeat")
 	match := re.FindStringSubmatch(msg)
 	if len(match) == 0 {
 		return nil, fmt.Errorf("Failed to parse as defeat: <%s>", msg)
@@ -433,7 +672,7 @@ func pDefeat(line string) (*LogEntry, error) {
 }
 
 func pIncapacitate(line string) (*LogEntry, error) {
-	if match, err := regexp.Match(`( incapacitated you|You have been incapacitated by misadventure)\.$`, []byte(line)); err != nil || !match {
+	if !pattern("incapacitate.detect").MatchString(line) {
 		return nil, &ParseNotMatchError{}
 	}
 	timestamp, msg, err := extractTimestamp(line)
@@ -447,18 +686,19 @@ func pIncapacitate(line string) (*LogEntry, error) {
 		entry.Target = selfplaceholder
 		return entry, nil
 	}
-	re := regexp.MustCompile(`(?P<source>.*) incapacitated you\.`)
+	re := pattern("incapacitate")
 	match := re.FindStringSubmatch(msg)
 	if len(match) == 0 {
 		return nil, fmt.Errorf("Failed to parse as incapacitation: <%s>", msg)
 	}
 	entry.Source = match[re.SubexpIndex("source")]
+	entry.Target = selfplaceholder
 	return entry, nil
 
 }
 
 func pRevive(line string) (*LogEntry, error) {
-	if match, err := regexp.Match(".* been revived.$", []byte(line)); err != nil || !match {
+	if !pattern("revive.detect").MatchString(line) {
 		return nil, &ParseNotMatchError{}
 	}
 	timestamp, msg, err := extractTimestamp(line)
@@ -473,7 +713,7 @@ func pRevive(line string) (*LogEntry, error) {
 		return entry, nil
 	}
 
-	re := regexp.MustCompile(`(?P<target>.*) has been revived\.`)
+	re := pattern("revive")
 	match := re.FindStringSubmatch(msg)
 	if len(match) == 0 {
 		return nil, fmt.Errorf("Failed to parse as revive: <%s>", msg)
@@ -483,7 +723,7 @@ func pRevive(line string) (*LogEntry, error) {
 }
 
 func pSuccumb(line string) (*LogEntry, error) {
-	if match, err := regexp.Match("succumb.*wounds", []byte(line)); err != nil || !match {
+	if !pattern("succumb.detect").MatchString(line) {
 		return nil, &ParseNotMatchError{}
 	}
 	timestamp, msg, err := extractTimestamp(line)
@@ -498,7 +738,7 @@ func pSuccumb(line string) (*LogEntry, error) {
 		return entry, nil
 	}
 
-	re := regexp.MustCompile(`(?P<target>.*) has succumbed to .* wounds\.`)
+	re := pattern("succumb")
 	match := re.FindStringSubmatch(msg)
 	if len(match) == 0 {
 		return nil, fmt.Errorf("Failed to parse as succumbing to wounds: <%s>", msg)
@@ -508,7 +748,7 @@ func pSuccumb(line string) (*LogEntry, error) {
 }
 
 func pCorrRemove(line string) (*LogEntry, error) {
-	if match, err := regexp.Match("(have dispelled.*from.*|Nothing to dispel.)", []byte(line)); err != nil || !match {
+	if !pattern("corrremove.detect").MatchString(line) {
 		return nil, &ParseNotMatchError{}
 	}
 	timestamp, msg, err := extractTimestamp(line)
@@ -523,18 +763,19 @@ func pCorrRemove(line string) (*LogEntry, error) {
 		return entry, nil
 	}
 
-	re := regexp.MustCompile(`You have dispelled (?P<corruption>.*) from (?P<target>.*)\.$`)
+	re := pattern("corrremove")
 	match := re.FindStringSubmatch(msg)
 	if len(match) == 0 {
 		return nil, fmt.Errorf("Failed to parse as corr removal: <%s>", msg)
 	}
+	entry.Source = selfplaceholder
 	entry.Target = match[re.SubexpIndex("target")]
 	entry.Skill = match[re.SubexpIndex("corruption")]
 	return entry, nil
 }
 
 func pCCBroken(line string) (*LogEntry, error) {
-	if match, err := regexp.Match(` released .* from being immobilized!`, []byte(line)); err != nil || !match {
+	if !pattern("ccbroken.detect").MatchString(line) {
 		return nil, &ParseNotMatchError{}
 	}
 	timestamp, msg, err := extractTimestamp(line)
@@ -544,7 +785,7 @@ func pCCBroken(line string) (*LogEntry, error) {
 	entry := &LogEntry{RawMessage: line}
 	entry.Timestamp = timestamp
 
-	re := regexp.MustCompile(`(?P<source>.*) (have|has) released (?P<target>.*) from being immobilized!$`)
+	re := pattern("ccbroken")
 	match := re.FindStringSubmatch(msg)
 	if len(match) == 0 {
 		return nil, fmt.Errorf("Failed to parse as cc break: <%s>", msg)
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseHitAvoidance(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		value   int
+		avoided Avoid
+		partial bool
+		crit    bool
+	}{
+		{
+			name:  "hit",
+			line:  "[07/08 05:36:00 PM] Starlaf scored a hit with Lightning Strike on Burkhad for 12,345 Lightning damage to Morale.",
+			value: 12345,
+		},
+		{
+			name:  "critical hit",
+			line:  "[07/08 05:36:00 PM] Starlaf scored a critical hit with Lightning Strike on Burkhad for 24,690 Lightning damage to Morale.",
+			value: 24690,
+			crit:  true,
+		},
+		{
+			name:    "blocked",
+			line:    "[07/08 05:36:00 PM] Burkhad scored a blocked hit with Cleave on Starlaf.",
+			avoided: Blocked,
+		},
+		{
+			name:    "parried",
+			line:    "[07/08 05:36:00 PM] Burkhad scored a parried hit with Cleave on Starlaf.",
+			avoided: Parried,
+		},
+		{
+			name:    "evaded",
+			line:    "[07/08 05:36:00 PM] Burkhad scored a evaded hit with Cleave on Starlaf.",
+			avoided: Evaded,
+		},
+		{
+			name:    "partially blocked",
+			line:    "[07/08 05:36:00 PM] Ishakhar scored a partially blocked hit with Routing Cry on Starlaf for 63,776 Shadow damage to Morale.",
+			value:   63776,
+			avoided: Blocked,
+			partial: true,
+		},
+		{
+			name:    "partially parried",
+			line:    "[07/08 05:36:36 PM] Ishakhar scored a partially parried hit with Routing Cry on Starlaf for 74,718 Shadow damage to Morale.",
+			value:   74718,
+			avoided: Parried,
+			partial: true,
+		},
+		{
+			name:    "partially evaded",
+			line:    "[07/08 05:38:42 PM] Pherida scored a partially evaded hit with Marking Shot on Starlaf for 78,893 Common damage to Morale.",
+			value:   78893,
+			avoided: Evaded,
+			partial: true,
+		},
+		{
+			name:    "attempt blocked",
+			line:    "[07/08 05:35:41 PM] Starlaf tried to use Cleave on Burkhad but he blocked the attempt.",
+			avoided: Blocked,
+		},
+		{
+			name:    "attempt parried",
+			line:    "[07/08 05:35:41 PM] Starlaf tried to use Cleave on Burkhad but he parried the attempt.",
+			avoided: Parried,
+		},
+		{
+			name:    "attempt evaded",
+			line:    "[07/08 05:35:41 PM] Starlaf tried to use Cleave on Burkhad but he evaded the attempt.",
+			avoided: Evaded,
+		},
+		{
+			name:    "attempt resisted",
+			line:    "[07/08 05:35:41 PM] Starlaf tried to use Cleave on Burkhad but she resisted the attempt.",
+			avoided: Resisted,
+		},
+		{
+			name:    "immune",
+			line:    "[07/08 05:35:41 PM] Starlaf tried to use Knockback on Burkhad but he was immune to the attempt.",
+			avoided: Immune,
+		},
+		{
+			name:    "immune, she",
+			line:    "[07/08 05:35:41 PM] Starlaf tried to use Knockback on Pherida but she was immune to the attempt.",
+			avoided: Immune,
+		},
+		{
+			name:    "missed",
+			line:    "[07/08 05:38:54 PM] Starlaf missed trying to use a ranged attack on Nuralai.",
+			avoided: Missed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := parseLogLine(tt.line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry.etype != DmgDealt {
+				t.Errorf("got %v, want %v", entry.etype, DmgDealt)
+			}
+			if entry.Value != tt.value {
+				t.Errorf("got value %d, want %d", entry.Value, tt.value)
+			}
+			if entry.Avoided != tt.avoided {
+				t.Errorf("got avoided %v, want %v", entry.Avoided, tt.avoided)
+			}
+			if entry.Partial != tt.partial {
+				t.Errorf("got partial %v, want %v", entry.Partial, tt.partial)
+			}
+			if entry.Crit != tt.crit {
+				t.Errorf("got crit %v, want %v", entry.Crit, tt.crit)
+			}
+		})
+	}
+}
+
+func TestScanTimestamp(t *testing.T) {
+	tests := []struct {
+		line string
+		want time.Time
+		rest string
+		ok   bool
+	}{
+		{"[07/08 05:35:41 PM] Starlaf", time.Date(0, 7, 8, 17, 35, 41, 0, time.UTC), "Starlaf", true},
+		{"[07/08 12:00:00 AM] x", time.Date(0, 7, 8, 0, 0, 0, 0, time.UTC), "x", true},
+		{"[07/08 12:00:00 PM] x", time.Date(0, 7, 8, 12, 0, 0, 0, time.UTC), "x", true},
+		{"[07/08 17:35:41] Starlaf", time.Date(0, 7, 8, 17, 35, 41, 0, time.UTC), "Starlaf", true},
+		{"07/08 17:35:41 Starlaf", time.Date(0, 7, 8, 17, 35, 41, 0, time.UTC), "Starlaf", true},
+		{"[02/29 00:00:00] x", time.Date(0, 2, 29, 0, 0, 0, 0, time.UTC), "x", true},
+		{"[02/30 00:00:00] x", time.Time{}, "", false},
+		{"[13/08 17:35:41] x", time.Time{}, "", false},
+		{"[07/08 24:00:00] x", time.Time{}, "", false},
+		{"[07/08 13:00:00 PM] x", time.Time{}, "", false},
+		{"[07/08 17:35:41]x", time.Time{}, "", false},
+		// whitespace the pattern allows is left to it
+		{"[07/08  05:35:41 PM] x", time.Time{}, "", false},
+		{"[07/08\t05:35:41 PM] x", time.Time{}, "", false},
+		{"[07/08 05:35:41PM] x", time.Time{}, "", false},
+		{"[07/08 17:35:41  ] x", time.Time{}, "", false},
+		{"[07/08 17:35:41 \t] x", time.Time{}, "", false},
+		{"[07/08 17:35", time.Time{}, "", false},
+		{"", time.Time{}, "", false},
+	}
+	for _, tt := range tests {
+		got, n, ok := scanTimestamp(tt.line)
+		if ok != tt.ok {
+			t.Errorf("%q: got ok %v, want %v", tt.line, ok, tt.ok)
+			continue
+		}
+		if ok && (!got.Equal(tt.want) || tt.line[n:] != tt.rest) {
+			t.Errorf("%q: got %v and %q, want %v and %q", tt.line, got, tt.line[n:], tt.want, tt.rest)
+		}
+		// the pattern must read what the scanner reads
+		if want, rest, err := matchTimestamp(tt.line); ok && (err != nil || !want.Equal(got) || rest != tt.line[n:]) {
+			t.Errorf("%q: the pattern reads %v and %q, %v", tt.line, want, rest, err)
+		}
+	}
+}
+
+// FuzzScanTimestamp checks that whatever scanTimestamp reads, the timestamp
+// pattern reads the same way with either layout tried first.
+func FuzzScanTimestamp(f *testing.F) {
+	for _, seed := range []string{
+		"[07/08 05:35:41 PM] Starlaf scored a hit.",
+		"[07/08 17:35:41] Starlaf scored a hit.",
+		"07/08 12:00:00 AM x",
+		"[07/08  05:35:41 PM] x",
+		"[07/08 05:35:41\tPM] x",
+		"[07/08 17:35:41 ] x",
+		"[02/29 23:59:59] x",
+	} {
+		f.Add(seed)
+	}
+	defer func(layouts []string) { timestampLayouts = layouts }(timestampLayouts)
+	f.Fuzz(func(t *testing.T, line string) {
+		got, n, ok := scanTimestamp(line)
+		if !ok {
+			return
+		}
+		for _, layouts := range [][]string{
+			{"01/02 03:04:05 PM", "01/02 15:04:05"},
+			{"01/02 15:04:05", "01/02 03:04:05 PM"},
+		} {
+			timestampLayouts = layouts
+			want, rest, err := matchTimestamp(line)
+			if err != nil {
+				t.Fatalf("%q: scanned %v but the pattern fails: %v", line, got, err)
+			}
+			if !got.Equal(want) || line[n:] != rest {
+				t.Fatalf("%q: scanned %v and %q, the pattern %v and %q", line, got, line[n:], want, rest)
+			}
+		}
+	})
+}
+
+func TestParseNonASCIINames(t *testing.T) {
+	tests := []struct {
+		line   string
+		source string
+		target string
+	}{
+		{"[07/08 05:36:21 PM] Êphaltud tried to use Cleave on Starlaf but he evaded the attempt.", "Êphaltud", "Starlaf"},
+		{"[07/08 05:36:21 PM] Nûralai missed trying to use a ranged attack on Starlaf.", "Nûralai", "Starlaf"},
+		{"[07/08 05:36:21 PM] Phêrida scored a hit with Marking Shot on Starlaf.", "Phêrida", "Starlaf"},
+		{"[07/08 05:36:21 PM] Ishakhâr applied a benefit with Routing Cry on Ishakhâr.", "Ishakhâr", "Ishakhâr"},
+		{"[07/08 05:36:21 PM] Eärwen applied a heal with Beacon of Hope to Starlaf restoring 11,240 points to Morale.", "Eärwen", "Starlaf"},
+		// decomposed, an e followed by a combining circumflex
+		{"[07/08 05:36:21 PM] E\u0302phaltud missed trying to use a ranged attack on Starlaf.", "E\u0302phaltud", "Starlaf"},
+		{"[07/08 05:36:21 PM] Ar-Pharazôn missed trying to use a ranged attack on Starlaf.", "Ar-Pharazôn", "Starlaf"},
+	}
+	for _, tt := range tests {
+		entry, err := parseLogLine(tt.line)
+		if err != nil {
+			t.Errorf("%v", err)
+			continue
+		}
+		if entry.Source != tt.source || entry.Target != tt.target {
+			t.Errorf("%q: got %q on %q, want %q on %q", tt.line, entry.Source, entry.Target, tt.source, tt.target)
+		}
+	}
+}
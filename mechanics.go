@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+func init() {
+	analyses["mechanics"] = printMechanics
+}
+
+// MechanicRule makes taking damage from a boss skill a failed mechanic.
+type MechanicRule struct {
+	// Boss is the boss whose encounters the rule applies to, all
+	// encounters when empty.
+	Boss string `json:"boss,omitempty"`
+	// Name is the mechanic as the scoreboard shows it, Skill when empty.
+	Name  string `json:"name,omitempty"`
+	Skill string `json:"skill"`
+	// Window is how long, in seconds, further hits of the skill on the
+	// same player count as the same failure, for damage that ticks.
+	Window float64 `json:"window,omitempty"`
+}
+
+func (r MechanicRule) label() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.Skill
+}
+
+// MechanicBoard counts the failed mechanics of each player in one attempt.
+type MechanicBoard struct {
+	Mechanics []string // in the order of the rules
+	Players   []MechanicPlayer
+}
+
+// MechanicPlayer is one row of a MechanicBoard.
+type MechanicPlayer struct {
+	Actor    string
+	Failures []int // per mechanic
+	Total    int
+}
+
+// mechanicRules are the rules that apply to an encounter: those of every
+// boss that took part, as a pull can have several.
+func mechanicRules(enc *Encounter, rules []MechanicRule) []MechanicRule {
+	present := map[string]bool{}
+	for _, e := range enc.Entities {
+		present[e.Name] = true
+	}
+	applies := []MechanicRule{}
+	for _, r := range rules {
+		if r.Boss == "" || present[r.Boss] {
+			applies = append(applies, r)
+		}
+	}
+	return applies
+}
+
+// mechanicFailures counts the players' failed mechanics in an encounter,
+// worst first. It is nil when no rule applies.
+func mechanicFailures(enc *Encounter, rules []MechanicRule) *MechanicBoard {
+	rules = mechanicRules(enc, rules)
+	if len(rules) == 0 {
+		return nil
+	}
+	board := &MechanicBoard{}
+	bySkill := map[string][]int{}
+	for i, r := range rules {
+		board.Mechanics = append(board.Mechanics, r.label())
+		bySkill[r.Skill] = append(bySkill[r.Skill], i)
+	}
+	players := map[string]*MechanicPlayer{}
+	// the last failure of each rule per player, for Window
+	last := map[string][]time.Time{}
+	for _, entry := range enc.Entries {
+		if entry.etype != DmgDealt || entry.Value == 0 || entry.TargetID == "" || enc.kindOf(entry.TargetID) != Player {
+			continue
+		}
+		for _, i := range bySkill[entry.Skill] {
+			p := players[entry.TargetID]
+			if p == nil {
+				p = &MechanicPlayer{Actor: entry.TargetID, Failures: make([]int, len(rules))}
+				players[entry.TargetID] = p
+				last[entry.TargetID] = make([]time.Time, len(rules))
+			}
+			window := time.Duration(rules[i].Window * float64(time.Second))
+			if prev := last[entry.TargetID][i]; !prev.IsZero() && entry.Timestamp.Sub(prev) <= window {
+				continue
+			}
+			last[entry.TargetID][i] = entry.Timestamp
+			p.Failures[i]++
+			p.Total++
+		}
+	}
+	for _, p := range players {
+		board.Players = append(board.Players, *p)
+	}
+	sortMechanicPlayers(board.Players)
+	return board
+}
+
+// mergeMechanicBoards adds up the boards of several attempts, mechanics of
+// the same name together.
+func mergeMechanicBoards(boards []*MechanicBoard) *MechanicBoard {
+	merged := &MechanicBoard{}
+	column := map[string]int{}
+	players := map[string]*MechanicPlayer{}
+	for _, board := range boards {
+		for _, name := range board.Mechanics {
+			if _, ok := column[name]; !ok {
+				column[name] = len(merged.Mechanics)
+				merged.Mechanics = append(merged.Mechanics, name)
+			}
+		}
+	}
+	if len(merged.Mechanics) == 0 {
+		return nil
+	}
+	for _, board := range boards {
+		for _, row := range board.Players {
+			p := players[row.Actor]
+			if p == nil {
+				p = &MechanicPlayer{Actor: row.Actor, Failures: make([]int, len(merged.Mechanics))}
+				players[row.Actor] = p
+			}
+			for i, n := range row.Failures {
+				p.Failures[column[board.Mechanics[i]]] += n
+			}
+			p.Total += row.Total
+		}
+	}
+	for _, p := range players {
+		merged.Players = append(merged.Players, *p)
+	}
+	sortMechanicPlayers(merged.Players)
+	return merged
+}
+
+// sortMechanicPlayers puts the players with the most failures first.
+func sortMechanicPlayers(players []MechanicPlayer) {
+	sort.Slice(players, func(i, j int) bool {
+		if players[i].Total != players[j].Total {
+			return players[i].Total > players[j].Total
+		}
+		return players[i].Actor < players[j].Actor
+	})
+}
+
+func printMechanics(enc *Encounter) {
+	fmt.Println("mechanics:")
+	board := mechanicFailures(enc, config.Mechanics)
+	if board == nil {
+		fmt.Println("  no mechanics configured for this encounter")
+		return
+	}
+	if len(board.Players) == 0 {
+		fmt.Println("  no failures")
+	}
+	for _, p := range board.Players {
+		fmt.Printf("  %-24v %3d failures:", p.Actor, p.Total)
+		for i, n := range p.Failures {
+			if n > 0 {
+				fmt.Printf(" %v %d;", board.Mechanics[i], n)
+			}
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// defeats further apart than this in two logs are not the same one
+	maxClockSkew = 5 * time.Minute
+)
+
+func init() {
+	commands["merge"] = runMerge
+}
+
+// mergeLine is a combat line of one of the merged logs.
+type mergeLine struct {
+	At     time.Time
+	Msg    string // the line after its timestamp
+	Defeat bool
+	// Self is set for lines about "you", the log's uploader.
+	Self bool
+}
+
+// mergeLog is a log to merge, on its own clock until shifted by Offset.
+type mergeLog struct {
+	Path   string
+	Header []string // the ### lines the log starts with
+	Lines  []mergeLine
+	Offset time.Duration
+	// How the offset was found, for the summary.
+	How string
+	// Uploader is the character who wrote the log, Players everyone
+	// it saw playing.
+	Uploader string
+	Players  map[string]bool
+}
+
+// readMergeLog parses a log into its combat lines.
+func readMergeLog(path string) (*mergeLog, error) {
+	result, err := parseFile(path, ParserOptions{})
+	if err != nil {
+		return nil, err
+	}
+	log := &mergeLog{Path: path}
+	for _, entry := range result.Entries {
+		// the patterns of this log are applied until the next one is parsed
+		at, msg, err := extractTimestamp(entry.RawMessage)
+		if err != nil {
+			continue
+		}
+		self := entry.Source == selfplaceholder || entry.Target == selfplaceholder
+		log.Lines = append(log.Lines, mergeLine{At: at, Msg: msg, Defeat: entry.etype == Death, Self: self})
+	}
+	log.Players = map[string]bool{}
+	played := map[string]int{}
+	for _, enc := range segmentEncounters(result.Entries) {
+		for name := range playerNames(enc) {
+			log.Players[name] = true
+		}
+		if enc.Character != "" {
+			played[enc.Character]++
+			if played[enc.Character] > played[log.Uploader] {
+				log.Uploader = enc.Character
+			}
+		}
+	}
+	delete(log.Players, selfplaceholder)
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := newLineScanner(file)
+	for scanner.Scan() && strings.HasPrefix(scanner.Text(), "###") {
+		log.Header = append(log.Header, scanner.Text())
+	}
+	return log, nil
+}
+
+// estimateClockOffset finds what to add to other's times to match ref's,
+// from the defeats both logged: the median difference of the same defeat
+// message within maxClockSkew. ok is false without shared defeats.
+func estimateClockOffset(ref, other *mergeLog) (offset time.Duration, shared int, ok bool) {
+	refDefeats := map[string][]time.Time{}
+	for _, line := range ref.Lines {
+		if line.Defeat {
+			refDefeats[line.Msg] = append(refDefeats[line.Msg], line.At.Add(ref.Offset))
+		}
+	}
+	diffs := []time.Duration{}
+	for _, line := range other.Lines {
+		if !line.Defeat {
+			continue
+		}
+		for _, at := range refDefeats[line.Msg] {
+			if d := at.Sub(line.At); d.Abs() <= maxClockSkew {
+				diffs = append(diffs, d)
+			}
+		}
+	}
+	if len(diffs) == 0 {
+		return 0, 0, false
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i] < diffs[j] })
+	return diffs[len(diffs)/2], len(diffs), true
+}
+
+// selfLines are the lines about "you" the game also writes about others,
+// with the form it uses for them.
+var selfLines = map[string]string{
+	"You have been revived.":      "%v has been revived.",
+	"You succumb to your wounds.": "%v has succumbed to their wounds.",
+}
+
+// thirdPerson rewrites a line about "you" as the line about the uploader
+// another raider's log would have. ok is false for lines only ever written
+// about yourself.
+func thirdPerson(msg, uploader string) (string, bool) {
+	form, ok := selfLines[msg]
+	if !ok || uploader == "" {
+		return "", false
+	}
+	return fmt.Sprintf(form, uploader), true
+}
+
+// mergeLines combines the shifted lines of logs in time order. A line both
+// logs saw is kept once: of lines with the same time and message, as many
+// are kept as the log with the most of them has.
+//
+// The merged log reads as written by the uploader of the first log. Lines
+// about "you" of the other logs are rewritten to name their uploader where
+// the game has such a line, and dropped otherwise; dropped counts them.
+func mergeLines(logs []*mergeLog) (merged []mergeLine, dropped int) {
+	type key struct {
+		at  time.Time
+		msg string
+	}
+	kept := map[key]int{}
+	for i, log := range logs {
+		seen := map[key]int{}
+		for _, line := range log.Lines {
+			if line.Self && i > 0 {
+				msg, ok := thirdPerson(line.Msg, log.Uploader)
+				if !ok {
+					dropped++
+					continue
+				}
+				line.Msg, line.Self = msg, false
+			}
+			line.At = line.At.Add(log.Offset)
+			k := key{line.At, line.Msg}
+			seen[k]++
+			if seen[k] > kept[k] {
+				kept[k]++
+				merged = append(merged, line)
+			}
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].At.Before(merged[j].At) })
+	return merged, dropped
+}
+
+// isSpellingOf reports whether long looks like name written another way,
+// with a surname or a server suffix.
+func isSpellingOf(long, name string) bool {
+	if len(long) <= len(name) || !strings.HasPrefix(long, name) {
+		return false
+	}
+	return strings.ContainsRune(" -@", rune(long[len(name)]))
+}
+
+// resolveIdentities asks about the characters of the merged logs that may
+// be one player written two ways, and about logs whose uploader was not
+// found, adding the answers to config.Identities. It returns whether
+// anything was added. Without wz it only warns.
+func resolveIdentities(wz *wizard, logs []*mergeLog) bool {
+	players := map[string]bool{}
+	for _, log := range logs {
+		for name := range log.Players {
+			players[canonicalName(name)] = true
+		}
+	}
+	names := make([]string, 0, len(players))
+	for name := range players {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	changed := false
+	for _, long := range names {
+		for _, name := range names {
+			if !isSpellingOf(long, name) || canonicalName(long) != long {
+				continue
+			}
+			if wz == nil {
+				slog.Warn(fmt.Sprintf("%q may be %q, map it under identities in the config if so", long, name))
+				continue
+			}
+			if answer := wz.ask(fmt.Sprintf("Is %q the same character as %q? y/n", long, name), "n"); strings.HasPrefix(answer, "y") {
+				if config.Identities == nil {
+					config.Identities = map[string]string{}
+				}
+				config.Identities[long] = name
+				changed = true
+			}
+		}
+	}
+	// lines about "you" of the first log are kept as they are
+	for _, log := range logs[1:] {
+		if log.Uploader != "" {
+			continue
+		}
+		if wz == nil {
+			slog.Warn("no uploader found, its lines about \"you\" are dropped", "log", log.Path)
+			continue
+		}
+		log.Uploader = canonicalName(wz.ask(fmt.Sprintf("Which character wrote %v?", log.Path), ""))
+	}
+	return changed
+}
+
+// writeMergedLog writes merged lines as a log in the game's format.
+func writeMergedLog(path string, header []string, lines []mergeLine) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	for _, line := range header {
+		fmt.Fprintln(w, line)
+	}
+	for _, line := range lines {
+		fmt.Fprintf(w, "[%v] %v\n", line.At.Format("01/02 03:04:05 PM"), line.Msg)
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// runMerge combines the logs several raiders wrote of the same raid into
+// one log, which every other command reads like any log. Each log's clock
+// is corrected by an offset: given with -offset, saved in the config, or
+// estimated against the first log from the defeats both logged. Characters
+// that may be one player are asked about and mapped in the config, see
+// resolveIdentities.
+func runMerge(args []string) error {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	setup := commonFlags(fs)
+	out := fs.String("o", "merged.txt", "merged log to write")
+	offsets := map[string]time.Duration{}
+	fs.Func("offset", "clock offset of a log as file=duration, e.g. Combat_b.txt=-3s; repeatable", func(s string) error {
+		name, value, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("want file=duration")
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		offsets[filepath.Base(name)] = d
+		return nil
+	})
+	estimate := fs.Bool("estimate", false, "estimate offsets even for logs with one in the config")
+	save := fs.Bool("save", false, "save the offsets used to the config file")
+	noAsk := fs.Bool("no-ask", false, "only warn about characters that may be one player, do not ask")
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return fmt.Errorf("usage: merge [flags] logfile logfile...")
+	}
+
+	logs := []*mergeLog{}
+	locale := ""
+	for i, path := range fs.Args() {
+		log, err := readMergeLog(path)
+		if err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+		if i == 0 {
+			locale = valueLocale
+		} else if valueLocale != locale {
+			return fmt.Errorf("%v is from a %v client, %v from a %v one, they cannot be merged", path, valueLocale, fs.Arg(0), locale)
+		}
+		name := filepath.Base(path)
+		secs, saved := config.ClockOffsetSecs[name]
+		switch d, given := offsets[name]; {
+		case given:
+			log.Offset, log.How = d, "given"
+		case saved && !*estimate:
+			log.Offset, log.How = time.Duration(secs*float64(time.Second)), "from the config"
+		case i == 0:
+			log.How = "reference"
+		default:
+			if offset, shared, ok := estimateClockOffset(logs[0], log); ok {
+				log.Offset, log.How = offset, fmt.Sprintf("estimated from %d shared defeats", shared)
+			} else {
+				log.How = "no shared defeats to estimate from"
+			}
+		}
+		logs = append(logs, log)
+	}
+
+	var wz *wizard
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 && !*noAsk {
+		wz = &wizard{in: bufio.NewReader(os.Stdin), out: os.Stdout}
+	}
+	identified := resolveIdentities(wz, logs)
+	for _, log := range logs {
+		log.Uploader = canonicalName(log.Uploader)
+	}
+
+	merged, dropped := mergeLines(logs)
+	if err := writeMergedLog(*out, logs[0].Header, merged); err != nil {
+		return err
+	}
+	// the log date selects patterns, keep the one of the logs
+	if info, err := os.Stat(logs[0].Path); err == nil {
+		os.Chtimes(*out, info.ModTime(), info.ModTime())
+	}
+	total := 0
+	for _, log := range logs {
+		total += len(log.Lines)
+		fmt.Printf("%v by %v: %d lines, offset %+v (%v)\n", log.Path, log.Uploader, len(log.Lines), log.Offset, log.How)
+	}
+	fmt.Printf("wrote %d lines to %v, %d seen by more than one log", len(merged), *out, total-dropped-len(merged))
+	if dropped > 0 {
+		fmt.Printf(", %d about other uploaders only the first log's uploader has", dropped)
+	}
+	fmt.Println()
+
+	if *save || identified {
+		path := fs.Lookup("config").Value.String()
+		if path == "" {
+			return fmt.Errorf("no config directory on this system, use -config")
+		}
+		if *save {
+			if config.ClockOffsetSecs == nil {
+				config.ClockOffsetSecs = map[string]float64{}
+			}
+			for _, log := range logs {
+				config.ClockOffsetSecs[filepath.Base(log.Path)] = log.Offset.Seconds()
+			}
+		}
+		if err := saveConfig(path, config); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+		fmt.Printf("saved the offsets and identities to %v\n", path)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// meterAllActors includes NPCs and pets in the meter, set with -all-actors.
+var meterAllActors bool
+
+func init() {
+	analyses["meter"] = printMeter
+}
+
+// ActorStats are the totals of one actor over an encounter.
+type ActorStats struct {
+	Actor       string    `json:"actor"`
+	Kind        ActorKind `json:"kind"`
+	Damage      int       `json:"damage"`
+	Overkill    int       `json:"overkill"` // estimated, part of Damage
+	Healing     int       `json:"healing"`
+	DamageTaken int       `json:"damage_taken"`
+	Deaths      int       `json:"deaths"`
+	// BossDamage is the part of Damage dealt to bosses, see isBoss; the
+	// rest went into trash and adds.
+	BossDamage int `json:"boss_damage"`
+	// Contribution is the damage the actor's debuffs added to other
+	// players' hits, see debuffContributions.
+	Contribution int `json:"contribution,omitempty"`
+}
+
+// Effective is the damage that did not go into overkill.
+func (s *ActorStats) Effective() int {
+	return s.Damage - s.Overkill
+}
+
+// actorStats totals damage, healing and deaths per entity.
+func actorStats(enc *Encounter) []*ActorStats {
+	stats := map[string]*ActorStats{}
+	get := func(id string) *ActorStats {
+		s, ok := stats[id]
+		if !ok {
+			s = &ActorStats{Actor: id, Kind: enc.kindOf(id)}
+			stats[id] = s
+		}
+		return s
+	}
+	bosses := map[string]bool{}
+	for id, e := range enc.Entities {
+		bosses[id] = segmentation.isBoss(e)
+	}
+	for _, entry := range enc.Entries {
+		switch entry.etype {
+		case DmgDealt:
+			if entry.SourceID != "" {
+				get(entry.SourceID).Damage += entry.Value
+				if bosses[entry.TargetID] {
+					get(entry.SourceID).BossDamage += entry.Value
+				}
+			}
+			if entry.TargetID != "" {
+				get(entry.TargetID).DamageTaken += entry.Value
+			}
+		case Heal:
+			if entry.SourceID != "" {
+				get(entry.SourceID).Healing += entry.Value
+			}
+		case Death:
+			if entry.TargetID != "" {
+				get(entry.TargetID).Deaths++
+			}
+		}
+	}
+	for id, over := range overkill(enc) {
+		get(id).Overkill += over
+	}
+	for id, extra := range debuffContributions(enc, config.DebuffBonuses) {
+		get(id).Contribution += extra
+	}
+	result := make([]*ActorStats, 0, len(stats))
+	for _, s := range stats {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Damage > result[j].Damage })
+	return result
+}
+
+// perSecond divides a total by the encounter duration.
+func perSecond(total int, enc *Encounter) float64 {
+	secs := enc.Duration().Seconds()
+	if secs < 1 {
+		secs = 1
+	}
+	return float64(total) / secs
+}
+
+// othersActor collects everyone outside the configured roster.
+const othersActor = "Others"
+
+// isWatched reports whether an actor is on the configured roster. Without a
+// roster everyone is watched.
+func isWatched(actor string) bool {
+	if len(config.Players) == 0 {
+		return true
+	}
+	for _, p := range config.Players {
+		if p == actor {
+			return true
+		}
+	}
+	return false
+}
+
+// watchedStats collapses actors outside the roster into one "Others" row.
+func watchedStats(stats []*ActorStats) []*ActorStats {
+	if len(config.Players) == 0 {
+		return stats
+	}
+	result := []*ActorStats{}
+	others := &ActorStats{Actor: othersActor, Kind: Player}
+	for _, s := range stats {
+		if isWatched(s.Actor) {
+			result = append(result, s)
+			continue
+		}
+		others.Damage += s.Damage
+		others.BossDamage += s.BossDamage
+		others.Overkill += s.Overkill
+		others.Healing += s.Healing
+		others.DamageTaken += s.DamageTaken
+		others.Deaths += s.Deaths
+		others.Contribution += s.Contribution
+	}
+	return append(result, others)
+}
+
+func printMeter(enc *Encounter) {
+	printMeterTo(os.Stdout, enc)
+}
+
+func printMeterTo(w io.Writer, enc *Encounter) {
+	fmt.Fprintln(w, "meter:")
+	stats := actorStats(enc)
+	if !meterAllActors {
+		players := []*ActorStats{}
+		for _, s := range stats {
+			if s.Kind == Player {
+				players = append(players, s)
+			}
+		}
+		stats = players
+	}
+	printStatsTo(w, watchedStats(stats), enc)
+}
+
+// printStatsTo draws meter rows of stats, per second over the encounter.
+func printStatsTo(w io.Writer, stats []*ActorStats, enc *Encounter) {
+	for _, s := range stats {
+		fmt.Fprintf(w, "  %-24v %-6v dmg %-10v (%8.0f/s) boss %-10v (%8.0f/s) effective %-10v overkill %-8v heal %-10v (%8.0f/s) taken %-10v deaths %v",
+			s.Actor, s.Kind, s.Damage, perSecond(s.Damage, enc), s.BossDamage, perSecond(s.BossDamage, enc), s.Effective(), s.Overkill,
+			s.Healing, perSecond(s.Healing, enc), s.DamageTaken, s.Deaths)
+		if len(config.DebuffBonuses) > 0 {
+			fmt.Fprintf(w, " contribution %v (%.0f/s)", s.Contribution, perSecond(s.Contribution, enc))
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// serverMetrics are the self-metrics of serve mode, exposed on /metrics in
+// the Prometheus text format.
+type serverMetrics struct {
+	started     time.Time
+	ready       atomic.Bool
+	lines       atomic.Int64
+	parseErrors atomic.Int64
+	uploads     atomic.Int64
+	inFlight    atomic.Int64 // uploads being parsed or stored
+	stored      atomic.Int64
+}
+
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{started: time.Now()}
+}
+
+// recordUpload counts the lines of a parsed upload.
+func (m *serverMetrics) recordUpload(result *ParseResult, stored int) {
+	m.uploads.Add(1)
+	m.lines.Add(int64(result.Lines))
+	m.parseErrors.Add(int64(result.Errors()))
+	m.stored.Add(int64(stored))
+}
+
+func (m *serverMetrics) healthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// readyz fails until the store is open and again while shutting down, so a
+// proxy stops sending uploads before they would be cut off.
+func (m *serverMetrics) readyz(w http.ResponseWriter, r *http.Request) {
+	if !m.ready.Load() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ready")
+}
+
+func (m *serverMetrics) metrics(w http.ResponseWriter, r *http.Request) {
+	uptime := time.Since(m.started).Seconds()
+	lines, errs := m.lines.Load(), m.parseErrors.Load()
+	errorRate := 0.0
+	if lines > 0 {
+		errorRate = float64(errs) / float64(lines)
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	metric := func(name, kind, help string, value any) {
+		fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n%v %v\n", name, help, name, kind, name, value)
+	}
+	metric("scg_uptime_seconds", "gauge", "Seconds since the server started.", uptime)
+	metric("scg_lines_total", "counter", "Log lines parsed from uploads.", lines)
+	metric("scg_lines_per_second", "gauge", "Lines parsed per second since start.", float64(lines)/uptime)
+	metric("scg_parse_errors_total", "counter", "Uploaded lines that did not parse.", errs)
+	metric("scg_parse_error_rate", "gauge", "Share of uploaded lines that did not parse.", errorRate)
+	metric("scg_uploads_total", "counter", "Uploads received.", m.uploads.Load())
+	metric("scg_uploads_in_flight", "gauge", "Uploads being parsed or stored.", m.inFlight.Load())
+	metric("scg_encounters_stored_total", "counter", "Encounters stored from uploads.", m.stored.Load())
+}
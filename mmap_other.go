@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// mapFile reads a whole file where there is no mmap.
+func mapFile(path string) ([]byte, func() error, error) {
+	data, err := os.ReadFile(path)
+	return data, func() error { return nil }, err
+}
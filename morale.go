@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+const (
+	moraleChartWidth = 800
+	moraleChartRow   = 50
+	moraleLabel      = 120
+	// a dip to this share of the estimated Morale without dying is a near-death
+	nearDeathShare = 0.8
+)
+
+func init() {
+	analyses["morale"] = printMorale
+	charts["morale"] = writeMoraleChartSVG
+}
+
+// MoralePoint is a player's missing Morale after an event.
+type MoralePoint struct {
+	At      time.Duration // since the encounter start
+	Deficit int
+}
+
+// MoraleCurve approximates a player's Morale over an encounter. The log has
+// no absolute Morale, so the curve tracks how much is missing: damage taken
+// adds to it, heals received and damage soaked by temporary Morale take from
+// it, and it cannot go below full.
+type MoraleCurve struct {
+	Actor  string
+	Points []MoralePoint
+	Deaths []time.Duration
+	// MaxMorale is estimated from the deficit at the deepest death, 0 when
+	// the player did not die.
+	MaxMorale int
+	Peak      int // largest deficit
+}
+
+// NearDeaths are the times the player dropped to within nearDeathShare of
+// the estimated Morale and lived.
+func (c MoraleCurve) NearDeaths() []MoralePoint {
+	if c.MaxMorale == 0 {
+		return nil
+	}
+	near := []MoralePoint{}
+	dipping := false
+	for _, p := range c.Points {
+		low := float64(p.Deficit) >= nearDeathShare*float64(c.MaxMorale)
+		if low && !dipping && !c.diedAt(p.At) {
+			near = append(near, p)
+		}
+		dipping = low
+	}
+	return near
+}
+
+// diedAt reports whether a death follows within a few seconds of at.
+func (c MoraleCurve) diedAt(at time.Duration) bool {
+	for _, d := range c.Deaths {
+		if d >= at && d-at <= 5*time.Second {
+			return true
+		}
+	}
+	return false
+}
+
+// moraleCurves reconstructs the Morale curves of the encounter's players.
+func moraleCurves(enc *Encounter) []*MoraleCurve {
+	curves := map[string]*MoraleCurve{}
+	get := func(id string) *MoraleCurve {
+		c, ok := curves[id]
+		if !ok {
+			c = &MoraleCurve{Actor: id}
+			curves[id] = c
+		}
+		return c
+	}
+	deficit := map[string]int{}
+	for _, entry := range enc.Entries {
+		at := entry.Timestamp.Sub(enc.Start)
+		id := entry.TargetID
+		if entry.etype == TempMoraleLost {
+			// only the logging character sees its temporary Morale
+			id = enc.Character
+		}
+		if id == "" || enc.kindOf(id) != Player {
+			continue
+		}
+		switch entry.etype {
+		case DmgDealt:
+			deficit[id] += entry.Value
+		case TempMoraleLost:
+			deficit[id] = max(0, deficit[id]-entry.Value)
+		case Heal:
+			deficit[id] = max(0, deficit[id]-entry.Value)
+		case Death:
+			c := get(id)
+			c.Deaths = append(c.Deaths, at)
+			c.MaxMorale = max(c.MaxMorale, deficit[id])
+			deficit[id] = 0
+		default:
+			continue
+		}
+		c := get(id)
+		c.Points = append(c.Points, MoralePoint{at, deficit[id]})
+		c.Peak = max(c.Peak, deficit[id])
+	}
+	list := make([]*MoraleCurve, 0, len(curves))
+	for _, c := range curves {
+		// other players' damage taken is not in the log, a curve of only
+		// deaths says nothing
+		if c.Peak > 0 && isWatched(c.Actor) {
+			list = append(list, c)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Actor < list[j].Actor })
+	return list
+}
+
+func writeMoraleChartSVG(w io.Writer, enc *Encounter) error {
+	curves := moraleCurves(enc)
+	height := 24 + moraleChartRow*len(curves)
+	svgOpen(w, moraleChartWidth, height, 11)
+	fmt.Fprintf(w, `<text x="4" y="14">Estimated Morale, full at the top of each row</text>`+"\n")
+	secs := max(enc.Duration().Seconds(), 1)
+	scale := float64(moraleChartWidth-moraleLabel-10) / secs
+	for i, c := range curves {
+		top := 24 + i*moraleChartRow
+		bottom := top + moraleChartRow - 8
+		full := c.MaxMorale
+		note := "est %v"
+		if full == 0 {
+			full, note = c.Peak, "dip %v"
+		}
+		full = max(full, 1)
+		fmt.Fprintf(w, `<text x="4" y="%d">%v</text>`+"\n", top+14, svgText(c.Actor))
+		fmt.Fprintf(w, `<text x="4" y="%d" fill="%v">%v</text>`+"\n", top+28, chartTheme.Muted, fmt.Sprintf(note, full))
+		fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%v"/>`+"\n", moraleLabel, bottom, moraleChartWidth-10, bottom, chartTheme.Muted)
+		y := func(deficit int) float64 {
+			return float64(top) + float64(bottom-top)*float64(min(deficit, full))/float64(full)
+		}
+		x0, y0 := float64(moraleLabel), y(0)
+		for _, p := range c.Points {
+			x := float64(moraleLabel) + p.At.Seconds()*scale
+			fmt.Fprintf(w, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%v"/>`+"\n", x0, y0, x, y(p.Deficit), chartTheme.Heal)
+			x0, y0 = x, y(p.Deficit)
+		}
+		for _, p := range c.NearDeaths() {
+			fmt.Fprintf(w, `<circle cx="%.1f" cy="%.1f" r="3" fill="%v"><title>%v near death at +%v</title></circle>`+"\n",
+				float64(moraleLabel)+p.At.Seconds()*scale, y(p.Deficit), chartTheme.Revive, svgText(c.Actor), p.At)
+		}
+		for _, d := range c.Deaths {
+			fmt.Fprintf(w, `<circle cx="%.1f" cy="%d" r="4" fill="%v"><title>%v died at +%v</title></circle>`+"\n",
+				float64(moraleLabel)+d.Seconds()*scale, bottom, chartTheme.Death, svgText(c.Actor), d)
+		}
+	}
+	_, err := fmt.Fprintln(w, "</svg>")
+	return err
+}
+
+func printMorale(enc *Encounter) {
+	fmt.Println("morale:")
+	for _, c := range moraleCurves(enc) {
+		estimate := "unknown"
+		if c.MaxMorale > 0 {
+			estimate = fmt.Sprintf("~%v", c.MaxMorale)
+		}
+		fmt.Printf("  %-14v morale %-9v deepest dip %-9v %d deaths", c.Actor, estimate, c.Peak, len(c.Deaths))
+		for _, p := range c.NearDeaths() {
+			fmt.Printf(", near death at +%v", p.At)
+		}
+		fmt.Println()
+	}
+}
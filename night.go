@@ -0,0 +1,136 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// players listed among the death leaders of a raid night
+	nightDeathLeaders = 5
+)
+
+// nightGapBuckets are the upper bounds the breaks between pulls are counted
+// under, the last one open.
+var nightGapBuckets = []time.Duration{time.Minute, 2 * time.Minute, 5 * time.Minute, 10 * time.Minute}
+
+// RaidNight is the overview of every encounter of a session.
+type RaidNight struct {
+	Session SessionSummary
+	// Bosses are the progression of each boss, see bossAttempts.
+	Bosses    []BossAttempts
+	Killed    int
+	Wipes     int
+	BossTime  time.Duration // in boss pulls
+	TrashTime time.Duration
+	Players   []*NightPlayer // by damage
+	Deaths    []*NightPlayer // the death leaders
+	// Mechanics are the failed mechanics over all attempts, nil without
+	// rules.
+	Mechanics  *MechanicBoard
+	Gaps       []NightGapBucket
+	MedianGap  time.Duration
+	LongestGap time.Duration
+}
+
+// NightPlayer is a player's totals over the night.
+type NightPlayer struct {
+	Actor      string
+	Damage     int
+	BossDamage int
+	Healing    int
+	Deaths     int
+	Encounters int
+}
+
+// NightGapBucket counts the breaks between pulls up to a length, the last
+// bucket, with Max zero, the longer ones.
+type NightGapBucket struct {
+	Max     time.Duration
+	Count   int
+	Percent int // of all breaks
+}
+
+// summarizeRaidNight aggregates the encounters of a session.
+func summarizeRaidNight(encounters []*Encounter) RaidNight {
+	bosses := []*Encounter{}
+	for _, enc := range encounters {
+		if encounterBoss(enc) != "" {
+			bosses = append(bosses, enc)
+		}
+	}
+	night := RaidNight{Session: summarizeSession(encounters), Bosses: bossAttempts(bosses)}
+	for _, b := range night.Bosses {
+		for _, a := range b.Attempts {
+			if a.Killed {
+				night.Killed++
+			} else {
+				night.Wipes++
+			}
+		}
+	}
+	players := map[string]*NightPlayer{}
+	boards := []*MechanicBoard{}
+	for _, enc := range encounters {
+		if board := mechanicFailures(enc, config.Mechanics); board != nil {
+			boards = append(boards, board)
+		}
+		if encounterBoss(enc) != "" {
+			night.BossTime += enc.Duration()
+		} else {
+			night.TrashTime += enc.Duration()
+		}
+		for _, s := range actorStats(enc) {
+			if s.Kind != Player {
+				continue
+			}
+			p := players[s.Actor]
+			if p == nil {
+				p = &NightPlayer{Actor: s.Actor}
+				players[s.Actor] = p
+			}
+			p.Damage += s.Damage
+			p.BossDamage += s.BossDamage
+			p.Healing += s.Healing
+			p.Deaths += s.Deaths
+			p.Encounters++
+		}
+	}
+	night.Mechanics = mergeMechanicBoards(boards)
+	for _, p := range players {
+		night.Players = append(night.Players, p)
+	}
+	sort.Slice(night.Players, func(i, j int) bool {
+		if night.Players[i].Damage != night.Players[j].Damage {
+			return night.Players[i].Damage > night.Players[j].Damage
+		}
+		return night.Players[i].Actor < night.Players[j].Actor
+	})
+	for _, p := range night.Players {
+		if p.Deaths > 0 {
+			night.Deaths = append(night.Deaths, p)
+		}
+	}
+	sort.SliceStable(night.Deaths, func(i, j int) bool { return night.Deaths[i].Deaths > night.Deaths[j].Deaths })
+	night.Deaths = night.Deaths[:min(len(night.Deaths), nightDeathLeaders)]
+
+	for _, upTo := range nightGapBuckets {
+		night.Gaps = append(night.Gaps, NightGapBucket{Max: upTo})
+	}
+	night.Gaps = append(night.Gaps, NightGapBucket{})
+	gaps := []time.Duration{}
+	for i := 1; i < len(encounters); i++ {
+		gap := encounters[i].Start.Sub(encounters[i-1].End)
+		gaps = append(gaps, gap)
+		b := sort.Search(len(nightGapBuckets), func(b int) bool { return gap <= nightGapBuckets[b] })
+		night.Gaps[b].Count++
+	}
+	for i := range night.Gaps {
+		night.Gaps[i].Percent = 100 * night.Gaps[i].Count / max(len(gaps), 1)
+	}
+	if len(gaps) > 0 {
+		sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+		night.MedianGap, night.LongestGap = gaps[len(gaps)/2], gaps[len(gaps)-1]
+	}
+	return night
+}
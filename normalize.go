@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// entries arriving up to this much earlier than the latest timestamp are
+	// moved back into order, larger jumps are left alone and reported
+	reorderWindow = 5 * time.Second
+	// the fewest lines repeated back to back that count as written twice.
+	// One line repeated is real, like two equal damage ticks in a second.
+	duplicateRun = 3
+	// the longest run of lines looked for repeated back to back
+	duplicateMaxRun = 32
+)
+
+// NormalizeStats describes what normalizeEntries changed.
+type NormalizeStats struct {
+	Duplicates int
+	Reordered  int
+	// BackwardJumps are lines whose timestamp went back further than the
+	// reorder window, e.g. a new session appended to the same file.
+	BackwardJumps []string
+}
+
+// sameLine reports whether two entries come from the same line, time and
+// all.
+func sameLine(a, b *LogEntry) bool {
+	return a.RawMessage != "" && a.RawMessage == b.RawMessage && a.Timestamp.Equal(b.Timestamp)
+}
+
+// repeatedRun is the length of the run of lines at i that repeats the lines
+// right before it, as the client does when it hiccups, 0 if there is none.
+// Runs are at least duplicateRun lines, not all the same one.
+func repeatedRun(entries []*LogEntry, i int) int {
+	for n := min(duplicateMaxRun, i, len(entries)-i); n >= duplicateRun; n-- {
+		repeated, varied := true, false
+		for k := 0; k < n && repeated; k++ {
+			repeated = sameLine(entries[i-n+k], entries[i+k])
+			varied = varied || entries[i+k].RawMessage != entries[i].RawMessage
+		}
+		if repeated && varied {
+			return n
+		}
+	}
+	return 0
+}
+
+// overlap is the length of the run of lines at i, after the timestamps went
+// back, that were already logged before the jump, as when sessions written
+// with overlapping lines are concatenated. 0 if there is none.
+func overlap(entries []*LogEntry, i int) int {
+	for k := i - 1; k >= 0; k-- {
+		if !sameLine(entries[k], entries[i]) {
+			continue
+		}
+		n := 0
+		for k+n < i && i+n < len(entries) && sameLine(entries[k+n], entries[i+n]) {
+			n++
+		}
+		// the whole tail before the jump repeats, or a run long enough
+		if k+n == i || n >= duplicateRun {
+			return n
+		}
+	}
+	return 0
+}
+
+// normalizeEntries drops runs of lines the client logged twice and moves
+// entries with slightly out of order timestamps back into place. Only runs
+// of lines are taken for duplicates, single lines repeat in real fights.
+func normalizeEntries(entries []*LogEntry) ([]*LogEntry, NormalizeStats) {
+	stats := NormalizeStats{}
+	result := make([]*LogEntry, 0, len(entries))
+	var latest time.Time
+	seen := false
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if n := repeatedRun(entries, i); n > 0 {
+			stats.Duplicates += n
+			i += n - 1
+			continue
+		}
+		if entry.Timestamp.IsZero() {
+			result = append(result, entry)
+			continue
+		}
+		if seen && entry.Timestamp.Before(latest) {
+			if latest.Sub(entry.Timestamp) > reorderWindow {
+				if n := overlap(entries, i); n > 0 {
+					stats.Duplicates += n
+					i += n - 1
+					continue
+				}
+				stats.BackwardJumps = append(stats.BackwardJumps, entry.RawMessage)
+				latest = entry.Timestamp
+				result = append(result, entry)
+				continue
+			}
+			stats.Reordered++
+			pos := len(result)
+			for pos > 0 && !result[pos-1].Timestamp.IsZero() && result[pos-1].Timestamp.After(entry.Timestamp) {
+				pos--
+			}
+			result = append(result, nil)
+			copy(result[pos+1:], result[pos:])
+			result[pos] = entry
+			continue
+		}
+		latest = entry.Timestamp
+		seen = true
+		result = append(result, entry)
+	}
+	return result, stats
+}
+
+func printNormalizeStats(stats NormalizeStats) {
+	if stats.Duplicates > 0 {
+		fmt.Printf("dropped duplicate lines: %v\n", stats.Duplicates)
+	}
+	if stats.Reordered > 0 {
+		fmt.Printf("reordered lines: %v\n", stats.Reordered)
+	}
+	for _, line := range stats.BackwardJumps {
+		fmt.Printf("timestamp went backwards: %v\n", line)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// normalizeLines runs normalizeEntries over lines written as "seconds
+// message" and returns what is left in the same form.
+func normalizeLines(lines []string) ([]string, NormalizeStats) {
+	base := time.Date(2024, 7, 8, 17, 0, 0, 0, time.UTC)
+	entries := []*LogEntry{}
+	for _, line := range lines {
+		var secs int
+		var msg string
+		fmt.Sscanf(line, "%d %s", &secs, &msg)
+		entries = append(entries, &LogEntry{Timestamp: base.Add(time.Duration(secs) * time.Second), RawMessage: line})
+	}
+	normalized, stats := normalizeEntries(entries)
+	kept := []string{}
+	for _, entry := range normalized {
+		kept = append(kept, entry.RawMessage)
+	}
+	return kept, stats
+}
+
+func TestNormalizeDuplicates(t *testing.T) {
+	tests := []struct {
+		name       string
+		lines      []string
+		want       []string
+		duplicates int
+	}{
+		{
+			name:  "equal ticks in a second are kept",
+			lines: []string{"1 tick", "1 tick", "2 hit"},
+			want:  []string{"1 tick", "1 tick", "2 hit"},
+		},
+		{
+			name:  "many equal ticks are kept",
+			lines: []string{"1 tick", "1 tick", "1 tick", "1 tick", "1 tick", "1 tick"},
+			want:  []string{"1 tick", "1 tick", "1 tick", "1 tick", "1 tick", "1 tick"},
+		},
+		{
+			name:  "two mobs alternating are kept",
+			lines: []string{"1 a", "1 b", "1 a", "1 b"},
+			want:  []string{"1 a", "1 b", "1 a", "1 b"},
+		},
+		{
+			name:       "a run written twice is dropped",
+			lines:      []string{"1 a", "1 b", "2 c", "1 a", "1 b", "2 c", "3 d"},
+			want:       []string{"1 a", "1 b", "2 c", "3 d"},
+			duplicates: 3,
+		},
+		{
+			name:       "overlap of concatenated sessions is dropped",
+			lines:      []string{"10 a", "11 b", "20 c", "21 d", "11 b", "20 c", "21 d", "30 e"},
+			want:       []string{"10 a", "11 b", "20 c", "21 d", "30 e"},
+			duplicates: 3,
+		},
+		{
+			name:  "a new session without overlap is kept",
+			lines: []string{"20 a", "21 b", "1 c", "2 d"},
+			want:  []string{"20 a", "21 b", "1 c", "2 d"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, stats := normalizeLines(tt.lines)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if stats.Duplicates != tt.duplicates {
+				t.Errorf("got %d duplicates, want %d", stats.Duplicates, tt.duplicates)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// the chart attached to encounter events unless configured otherwise
+	defaultNotifyChart = "heatmap"
+	// scale of the attached chart images, for phone screens
+	notifyChartScale = 2
+	// Telegram cuts photo captions at this many characters
+	telegramCaptionLimit = 1024
+)
+
+// MatrixConfig posts events to a Matrix room as the user of an access token.
+type MatrixConfig struct {
+	// Homeserver is the base URL, e.g. https://matrix.org.
+	Homeserver string `json:"homeserver"`
+	Token      string `json:"token"`
+	// Room is a room ID like !abc:matrix.org, which the user has joined.
+	Room   string   `json:"room"`
+	Events []string `json:"events,omitempty"`
+	Guild  string   `json:"guild,omitempty"`
+	// Chart is attached to encounter events, heatmap by default, none for
+	// text only.
+	Chart string `json:"chart,omitempty"`
+}
+
+// TelegramConfig posts events to a Telegram chat as a bot.
+type TelegramConfig struct {
+	Token  string `json:"token"`
+	ChatID string `json:"chat_id"`
+	// APIURL is the Bot API server, https://api.telegram.org by default.
+	APIURL string   `json:"api_url,omitempty"`
+	Events []string `json:"events,omitempty"`
+	Guild  string   `json:"guild,omitempty"`
+	// Chart is attached to encounter events, heatmap by default, none for
+	// text only.
+	Chart string `json:"chart,omitempty"`
+}
+
+// configNotifiers sets up the webhooks and chat notifiers of the config.
+func configNotifiers(config Config) ([]notifier, error) {
+	hooks, err := compileWebhooks(config.Webhooks)
+	if err != nil {
+		return nil, err
+	}
+	notifiers := []notifier{}
+	for _, hook := range hooks {
+		notifiers = append(notifiers, hook)
+	}
+	if m := config.Matrix; m != nil {
+		if m.Homeserver == "" || m.Token == "" || m.Room == "" {
+			return nil, fmt.Errorf("matrix needs homeserver, token and room")
+		}
+		if err := checkEvents("matrix", m.Events); err != nil {
+			return nil, err
+		}
+		if err := checkNotifyChart("matrix", m.Chart); err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, &matrixNotifier{MatrixConfig: *m})
+	}
+	if t := config.Telegram; t != nil {
+		if t.Token == "" || t.ChatID == "" {
+			return nil, fmt.Errorf("telegram needs token and chat_id")
+		}
+		if err := checkEvents("telegram", t.Events); err != nil {
+			return nil, err
+		}
+		if err := checkNotifyChart("telegram", t.Chart); err != nil {
+			return nil, err
+		}
+		n := &telegramNotifier{TelegramConfig: *t}
+		if n.APIURL == "" {
+			n.APIURL = "https://api.telegram.org"
+		}
+		notifiers = append(notifiers, n)
+	}
+	return notifiers, nil
+}
+
+func checkNotifyChart(name, chart string) error {
+	if _, ok := charts[chart]; chart != "" && chart != "none" && !ok {
+		return fmt.Errorf("%v: unknown chart %q, have: %v", name, chart, chartNames())
+	}
+	return nil
+}
+
+// notifyChart renders the configured chart of an encounter event as PNG,
+// or returns nil when the event has no chart.
+func notifyChart(name string, event WebhookEvent) ([]byte, error) {
+	if name == "" {
+		name = defaultNotifyChart
+	}
+	if event.enc == nil || name == "none" {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	background := svgAttrMap{"fill": chartTheme.Background}.color("fill", 1)
+	if err := writeChartPNG(&buf, charts[name], event.enc, notifyChartScale, background); err != nil {
+		return nil, fmt.Errorf("chart %v: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// notifyRequest does an API request of a chat notifier and decodes the JSON
+// answer into out, when given.
+func notifyRequest(req *http.Request, out any) error {
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%v: %v", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// matrixNotifier sends m.text messages, and an m.image of the chart after
+// the text of encounter events.
+type matrixNotifier struct {
+	MatrixConfig
+}
+
+// matrixTxn numbers the messages sent, for Matrix's idempotent sends.
+var matrixTxn atomic.Int64
+
+func (m *matrixNotifier) name() string {
+	return "matrix"
+}
+
+func (m *matrixNotifier) wants(event WebhookEvent) bool {
+	return wantsEvent(m.Guild, m.Events, event)
+}
+
+func (m *matrixNotifier) send(event WebhookEvent) error {
+	if err := m.message(map[string]any{"msgtype": "m.text", "body": event.Text}); err != nil {
+		return err
+	}
+	png, err := notifyChart(m.Chart, event)
+	if err != nil || png == nil {
+		return err
+	}
+	name := fmt.Sprintf("%v-%v.png", event.Event, event.Start.Format("150405"))
+	uri, err := m.upload(name, png)
+	if err != nil {
+		return err
+	}
+	return m.message(map[string]any{
+		"msgtype": "m.image", "body": name, "url": uri,
+		"info": map[string]any{"mimetype": "image/png", "size": len(png)},
+	})
+}
+
+// upload stores a file in the homeserver's media repository and returns its
+// mxc:// URI.
+func (m *matrixNotifier) upload(name string, data []byte) (string, error) {
+	u := strings.TrimSuffix(m.Homeserver, "/") + "/_matrix/media/v3/upload?filename=" + url.QueryEscape(name)
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.Token)
+	req.Header.Set("Content-Type", "image/png")
+	var answer struct {
+		ContentURI string `json:"content_uri"`
+	}
+	if err := notifyRequest(req, &answer); err != nil {
+		return "", fmt.Errorf("uploading %v: %w", name, err)
+	}
+	return answer.ContentURI, nil
+}
+
+// message sends a room message event.
+func (m *matrixNotifier) message(content map[string]any) error {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	txn := fmt.Sprintf("scg-%d-%d", time.Now().UnixNano(), matrixTxn.Add(1))
+	u := fmt.Sprintf("%v/_matrix/client/v3/rooms/%v/send/m.room.message/%v",
+		strings.TrimSuffix(m.Homeserver, "/"), url.PathEscape(m.Room), txn)
+	req, err := http.NewRequest(http.MethodPut, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.Token)
+	req.Header.Set("Content-Type", "application/json")
+	return notifyRequest(req, nil)
+}
+
+// telegramNotifier sends messages through the Bot API, encounter events as
+// a photo of the chart with the text as its caption.
+type telegramNotifier struct {
+	TelegramConfig
+}
+
+func (t *telegramNotifier) name() string {
+	return "telegram"
+}
+
+func (t *telegramNotifier) wants(event WebhookEvent) bool {
+	return wantsEvent(t.Guild, t.Events, event)
+}
+
+func (t *telegramNotifier) send(event WebhookEvent) error {
+	png, err := notifyChart(t.Chart, event)
+	if err != nil {
+		return err
+	}
+	if png == nil || len([]rune(event.Text)) > telegramCaptionLimit {
+		data, err := json.Marshal(map[string]string{"chat_id": t.ChatID, "text": event.Text})
+		if err != nil {
+			return err
+		}
+		if err := t.call("sendMessage", "application/json", bytes.NewReader(data)); err != nil || png == nil {
+			return err
+		}
+		return t.photo(png, "")
+	}
+	return t.photo(png, event.Text)
+}
+
+// photo sends a PNG image with an optional caption.
+func (t *telegramNotifier) photo(png []byte, caption string) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("chat_id", t.ChatID)
+	if caption != "" {
+		form.WriteField("caption", caption)
+	}
+	part, err := form.CreateFormFile("photo", "chart.png")
+	if err != nil {
+		return err
+	}
+	part.Write(png)
+	if err := form.Close(); err != nil {
+		return err
+	}
+	return t.call("sendPhoto", form.FormDataContentType(), &body)
+}
+
+// call posts to a Bot API method. Errors are reported in the answer's
+// description.
+func (t *telegramNotifier) call(method, contentType string, body io.Reader) error {
+	u := strings.TrimSuffix(t.APIURL, "/") + "/bot" + t.Token + "/" + method
+	req, err := http.NewRequest(http.MethodPost, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	var answer struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := notifyRequest(req, &answer); err != nil {
+		// keep the bot token out of the logs
+		return fmt.Errorf("telegram %v: %v", method, strings.ReplaceAll(err.Error(), t.Token, "<token>"))
+	}
+	if !answer.OK {
+		return fmt.Errorf("telegram %v: %v", method, answer.Description)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// valueSeparators are the digit group separators each client locale writes
+// in numbers, e.g. "1,234,567" in English and "1.234.567" or "1 234 567" in
+// German and French clients.
+var valueSeparators = map[string]string{
+	"en": ",",
+	"de": ". \u00a0\u202f",
+	"fr": " \u00a0\u202f.",
+}
+
+// valueLocale is the locale numbers are parsed in, set from the sniffed log.
+var valueLocale = defaultLocale
+
+// parseValue parses a number as the client writes it. Anything left over
+// after removing the locale's separators is an error rather than a value off
+// by a factor of 1000.
+func parseValue(s string) (int, error) {
+	separators, ok := valueSeparators[valueLocale]
+	if !ok {
+		separators = valueSeparators[defaultLocale]
+	}
+	digits := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(separators, r) {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(s))
+	val, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %v number %q", valueLocale, s)
+	}
+	return val, nil
+}
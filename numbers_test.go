@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// withValueLocale sets valueLocale for the rest of a test.
+func withValueLocale(t *testing.T, locale string) {
+	previous := valueLocale
+	valueLocale = locale
+	t.Cleanup(func() { valueLocale = previous })
+}
+
+func TestParseLocaleValues(t *testing.T) {
+	tests := []struct {
+		locale string
+		value  string
+		want   int
+	}{
+		{"en", "7", 7},
+		{"en", "1,234", 1234},
+		{"en", "1,234,567", 1234567},
+		{"de", "1.234", 1234},
+		{"de", "1.234.567", 1234567},
+		{"de", "1 234 567", 1234567},
+		{"fr", "1 234 567", 1234567},
+		{"fr", "1\u00a0234\u00a0567", 1234567},
+		{"fr", "1\u202f234\u202f567", 1234567},
+	}
+	lines := []struct {
+		name   string
+		format string
+	}{
+		{"damage", "[07/08 05:35:34 PM] Starlaf scored a hit with Thrash on Burkhad for %v Beleriand damage to Morale."},
+		{"heal", "[07/08 05:35:23 PM] Azmaul applied a heal with Beacon of Hope to Starlaf restoring %v points to Morale."},
+		{"temporary morale", "[07/08 05:35:44 PM] You have lost %v points of temporary Morale!"},
+		{"experience", "[07/08 05:35:44 PM] You've earned %v XP."},
+	}
+	for _, tt := range tests {
+		withValueLocale(t, tt.locale)
+		if got, err := parseValue(tt.value); err != nil || got != tt.want {
+			t.Errorf("%v %q: got %d, %v, want %d", tt.locale, tt.value, got, err, tt.want)
+		}
+		for _, line := range lines {
+			entry, err := parseLogLine(fmt.Sprintf(line.format, tt.value))
+			if err != nil {
+				t.Errorf("%v %v %q: %v", tt.locale, line.name, tt.value, err)
+				continue
+			}
+			if got := entry.Value; got != tt.want {
+				t.Errorf("%v %v %q: got %d, want %d", tt.locale, line.name, tt.value, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestParseValueRejectsOtherLocales(t *testing.T) {
+	withValueLocale(t, "en")
+	for _, value := range []string{"1.234", "1 234"} {
+		if got, err := parseValue(value); err == nil {
+			t.Errorf("en %q: got %d, want an error", value, got)
+		}
+	}
+}
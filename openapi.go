@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// apiSchemaTypes are the types published as JSON Schemas under /schemas.
+var apiSchemaTypes = map[string]reflect.Type{
+	"LogEntry":         reflect.TypeFor[LogEntry](),
+	"Encounter":        reflect.TypeFor[Encounter](),
+	"ActorStats":       reflect.TypeFor[ActorStats](),
+	"StoredEncounter":  reflect.TypeFor[StoredEncounter](),
+	"AttendanceReport": reflect.TypeFor[AttendanceReport](),
+	"OverlayMessage":   reflect.TypeFor[OverlayMessage](),
+	"LiveSessionInfo":  reflect.TypeFor[LiveSessionInfo](),
+	"BaselineSet":      reflect.TypeFor[BaselineSet](),
+	"TargetDamage":     reflect.TypeFor[TargetDamage](),
+}
+
+// apiOperation is one route of the share server in the OpenAPI document.
+type apiOperation struct {
+	Method, Path string
+	Summary      string
+	Role         string // guild role the route needs, "" for public routes
+	Response     reflect.Type
+	Query        []string
+}
+
+// apiOperations lists the JSON routes of routes(). Keep them in sync.
+var apiOperations = []apiOperation{
+	{"GET", "/encounters", "List the public encounters", "", reflect.TypeFor[[]StoredEncounter](), []string{"format"}},
+	{"GET", "/encounters/{id}", "Raw log lines of a public encounter", "", nil, []string{"format"}},
+	{"GET", "/encounters/{id}/targets", "Damage taken over time by the targets of a public encounter", "", reflect.TypeFor[[]TargetDamage](), []string{"target"}},
+	{"GET", "/search", "Search the public encounters", "", reflect.TypeFor[[]StoredEncounter](), []string{"boss", "character", "label", "outcome", "from", "to", "min_dps", "format"}},
+	{"POST", "/upload", "Store the encounters of a log file", "", reflect.TypeFor[[]string](), []string{"file"}},
+	{"GET", "/live", "List the public live sessions", "", reflect.TypeFor[[]LiveSessionInfo](), nil},
+	{"GET", "/live/{session}", "Spectate a public live session, a WebSocket of OverlayMessage", "", nil, nil},
+	{"GET", "/live/{session}/publish", "Publish a live session, a WebSocket of OverlayMessage", "", nil, nil},
+	{"GET", "/baselines", "DPS and HPS baselines of the public boss kills", "", reflect.TypeFor[BaselineSet](), nil},
+	{"GET", "/guilds/{guild}/encounters", "List the guild's encounters", roleMember, reflect.TypeFor[[]StoredEncounter](), []string{"format"}},
+	{"GET", "/guilds/{guild}/encounters/{id}", "Raw log lines of a guild encounter", roleMember, nil, []string{"format"}},
+	{"GET", "/guilds/{guild}/encounters/{id}/targets", "Damage taken over time by the targets of a guild encounter", roleMember, reflect.TypeFor[[]TargetDamage](), []string{"target"}},
+	{"GET", "/guilds/{guild}/search", "Search the guild's encounters", roleMember, reflect.TypeFor[[]StoredEncounter](), []string{"boss", "character", "label", "outcome", "from", "to", "min_dps", "format"}},
+	{"POST", "/guilds/{guild}/upload", "Store the encounters of a log file in the guild", roleUpload, reflect.TypeFor[[]string](), []string{"file"}},
+	{"GET", "/guilds/{guild}/attendance", "Raid attendance of the guild", roleMember, reflect.TypeFor[AttendanceReport](), []string{"format"}},
+	{"GET", "/guilds/{guild}/live", "List the guild's live sessions", roleMember, reflect.TypeFor[[]LiveSessionInfo](), nil},
+	{"GET", "/guilds/{guild}/live/{session}", "Spectate a live session of the guild, a WebSocket of OverlayMessage", roleMember, nil, nil},
+	{"GET", "/guilds/{guild}/live/{session}/publish", "Publish a live session in the guild, a WebSocket of OverlayMessage", roleUpload, nil, nil},
+	{"GET", "/guilds/{guild}/roster", "The guild's roster", roleMember, reflect.TypeFor[[]string](), nil},
+	{"PUT", "/guilds/{guild}/roster", "Replace the guild's roster", roleAdmin, reflect.TypeFor[[]string](), nil},
+	{"GET", "/guilds/{guild}/tokens", "List the guild's tokens without their secrets", roleAdmin, reflect.TypeFor[[]map[string]string](), nil},
+	{"POST", "/guilds/{guild}/tokens", "Issue a token, shown only in this response", roleAdmin, reflect.TypeFor[map[string]string](), []string{"role", "label"}},
+	{"DELETE", "/guilds/{guild}/tokens/{token}", "Revoke a token", roleAdmin, nil, nil},
+}
+
+// schemaBuilder turns Go types into JSON Schemas the way encoding/json
+// renders them. Named structs become references to definitions under
+// prefix, so recursive types terminate.
+type schemaBuilder struct {
+	prefix string
+	defs   map[string]any
+}
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	durationType      = reflect.TypeFor[time.Duration]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == durationType:
+		return map[string]any{"type": "integer", "description": "nanoseconds"}
+	case t == reflect.TypeFor[ActorKind]():
+		return map[string]any{"type": "string", "enum": []string{NPC.String(), Player.String(), Pet.String()}}
+	case t.Implements(textMarshalerType):
+		return map[string]any{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.schema(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		if _, ok := b.defs[t.Name()]; !ok {
+			b.defs[t.Name()] = nil // placeholder against recursion
+			b.defs[t.Name()] = b.object(t)
+		}
+		return map[string]any{"$ref": b.prefix + t.Name()}
+	}
+	return map[string]any{}
+}
+
+// object describes a struct's exported fields under their JSON names.
+// Embedded structs are flattened like encoding/json does.
+func (b *schemaBuilder) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	var add func(t reflect.Type)
+	add = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+				add(field.Type)
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = b.schema(field.Type)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+	}
+	add(t)
+	object := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		object["required"] = required
+	}
+	return object
+}
+
+// jsonSchema is a standalone JSON Schema of a type, with the structs it
+// refers to under $defs.
+func jsonSchema(t reflect.Type) map[string]any {
+	b := &schemaBuilder{prefix: "#/$defs/", defs: map[string]any{}}
+	root := b.schema(t)
+	schema := map[string]any{"$schema": "https://json-schema.org/draft/2020-12/schema", "$defs": b.defs}
+	for k, v := range root {
+		schema[k] = v
+	}
+	return schema
+}
+
+// openAPIDocument describes the JSON API of the share server.
+func openAPIDocument() map[string]any {
+	b := &schemaBuilder{prefix: "#/components/schemas/", defs: map[string]any{}}
+	for _, t := range apiSchemaTypes {
+		b.schema(t)
+	}
+	paths := map[string]map[string]any{}
+	for _, op := range apiOperations {
+		parameters := []any{}
+		for _, segment := range strings.Split(op.Path, "/") {
+			if strings.HasPrefix(segment, "{") {
+				parameters = append(parameters, map[string]any{
+					"name": strings.Trim(segment, "{}"), "in": "path", "required": true, "schema": map[string]any{"type": "string"},
+				})
+			}
+		}
+		for _, name := range op.Query {
+			parameters = append(parameters, map[string]any{"name": name, "in": "query", "schema": map[string]any{"type": "string"}})
+		}
+		content := map[string]any{"text/plain": map[string]any{"schema": map[string]any{"type": "string"}}}
+		if op.Response != nil {
+			content = map[string]any{"application/json": map[string]any{"schema": b.schema(op.Response)}}
+		}
+		operation := map[string]any{
+			"summary":    op.Summary,
+			"parameters": parameters,
+			"responses":  map[string]any{"200": map[string]any{"description": "OK", "content": content}},
+		}
+		if op.Role != "" {
+			operation["security"] = []any{map[string]any{"guildToken": []string{}}}
+			operation["description"] = "Needs a guild token with the " + op.Role + " role."
+		}
+		if paths[op.Path] == nil {
+			paths[op.Path] = map[string]any{}
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = operation
+	}
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info":    map[string]any{"title": "SharedCombatGraphs share server", "version": patternVersion},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas":         b.defs,
+			"securitySchemes": map[string]any{"guildToken": map[string]any{"type": "http", "scheme": "bearer"}},
+		},
+	}
+}
+
+func (s *shareServer) getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, openAPIDocument())
+}
+
+// getSchema serves the JSON Schema of one of apiSchemaTypes, e.g.
+// /schemas/StoredEncounter.json.
+func (s *shareServer) getSchema(w http.ResponseWriter, r *http.Request) {
+	t, ok := apiSchemaTypes[strings.TrimSuffix(r.PathValue("name"), ".json")]
+	if !ok {
+		http.Error(w, "unknown schema", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, jsonSchema(t))
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	openerWindow = 30 * time.Second
+)
+
+func init() {
+	analyses["opener"] = printOpeners
+}
+
+// SkillUse is one use of a skill, offset from the start of the encounter.
+type SkillUse struct {
+	Offset time.Duration
+	Skill  string
+}
+
+// actorOpeners returns each actor's skill uses during the first 30 seconds.
+// Repeated events of the same skill in the same second (extra targets) are
+// collapsed into one use.
+func actorOpeners(enc *Encounter) map[string][]SkillUse {
+	openers := map[string][]SkillUse{}
+	for _, entry := range enc.Entries {
+		if entry.Source == "" || entry.Skill == "" {
+			continue
+		}
+		offset := entry.Timestamp.Sub(enc.Start)
+		if offset > openerWindow {
+			break
+		}
+		seq := openers[entry.Source]
+		if n := len(seq); n > 0 && seq[n-1].Skill == entry.Skill && seq[n-1].Offset == offset {
+			continue
+		}
+		openers[entry.Source] = append(seq, SkillUse{Offset: offset, Skill: entry.Skill})
+	}
+	return openers
+}
+
+// formatOpener renders a sequence as "Skill > Skill (+3s) > Skill", noting
+// gaps of more than a second between consecutive uses.
+func formatOpener(seq []SkillUse) string {
+	parts := make([]string, 0, len(seq))
+	var last time.Duration
+	for i, use := range seq {
+		part := use.Skill
+		if gap := use.Offset - last; i > 0 && gap > time.Second {
+			part = fmt.Sprintf("(+%v) %v", gap, use.Skill)
+		}
+		parts = append(parts, part)
+		last = use.Offset
+	}
+	return strings.Join(parts, " > ")
+}
+
+// openerDivergence returns the index of the first use that differs from the
+// reference, or -1 if the opener follows the whole reference.
+func openerDivergence(seq []SkillUse, ref []string) int {
+	for i, skill := range ref {
+		if i >= len(seq) || seq[i].Skill != skill {
+			return i
+		}
+	}
+	return -1
+}
+
+func printOpeners(enc *Encounter) {
+	fmt.Println("openers:")
+	openers := actorOpeners(enc)
+	actors := make([]string, 0, len(openers))
+	for actor := range openers {
+		actors = append(actors, actor)
+	}
+	sort.Strings(actors)
+	for _, actor := range actors {
+		if !isWatched(actor) {
+			continue
+		}
+		fmt.Printf("  %v: %v\n", actor, formatOpener(openers[actor]))
+		if len(config.ReferenceOpener) == 0 {
+			continue
+		}
+		if i := openerDivergence(openers[actor], config.ReferenceOpener); i >= 0 {
+			fmt.Printf("    differs from reference at use %d, expected %v\n", i+1, config.ReferenceOpener[i])
+		}
+	}
+}
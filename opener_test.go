@@ -0,0 +1,70 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// testStart is when the encounters built by the tests start.
+var testStart = time.Date(0, 7, 8, 17, 35, 8, 0, time.UTC)
+
+// skillUseAt is a hit by source with skill secs seconds into an encounter.
+func skillUseAt(secs float64, source, skill string) *LogEntry {
+	return &LogEntry{
+		Timestamp: testStart.Add(time.Duration(secs * float64(time.Second))),
+		etype:     DmgDealt,
+		Source:    source,
+		Target:    "Burkhad",
+		Skill:     skill,
+		Value:     100,
+	}
+}
+
+// encounterOf is an encounter of the entries, starting at testStart.
+func encounterOf(entries ...*LogEntry) *Encounter {
+	return &Encounter{Start: testStart, End: entries[len(entries)-1].Timestamp, Entries: entries}
+}
+
+func TestActorOpeners(t *testing.T) {
+	enc := encounterOf(
+		skillUseAt(0, "Starlaf", "Thrash"),
+		// a second target of the same use
+		skillUseAt(0, "Starlaf", "Thrash"),
+		skillUseAt(0, "Huya", "Cleave"),
+		skillUseAt(1, "Starlaf", "Bash"),
+		skillUseAt(5, "Starlaf", "Thrash"),
+		skillUseAt(30, "Huya", "Cleave"),
+		skillUseAt(31, "Starlaf", "Rend"),
+	)
+	openers := actorOpeners(enc)
+	want := []SkillUse{{0, "Thrash"}, {time.Second, "Bash"}, {5 * time.Second, "Thrash"}}
+	if !slices.Equal(openers["Starlaf"], want) {
+		t.Errorf("got %v, want %v", openers["Starlaf"], want)
+	}
+	if got := len(openers["Huya"]); got != 2 {
+		t.Errorf("got %d uses by Huya, want the 2 in the first 30s", got)
+	}
+	if got, want := formatOpener(openers["Starlaf"]), "Thrash > Bash > (+4s) Thrash"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestOpenerDivergence(t *testing.T) {
+	seq := []SkillUse{{0, "Thrash"}, {time.Second, "Bash"}, {2 * time.Second, "Rend"}}
+	tests := []struct {
+		ref  []string
+		want int
+	}{
+		{[]string{"Thrash", "Bash", "Rend"}, -1},
+		{[]string{"Thrash", "Bash"}, -1},
+		{[]string{"Thrash", "Rend"}, 1},
+		{[]string{"Bash"}, 0},
+		{[]string{"Thrash", "Bash", "Rend", "Cleave"}, 3},
+	}
+	for _, tt := range tests {
+		if got := openerDivergence(seq, tt.ref); got != tt.want {
+			t.Errorf("%q: got %d, want %d", tt.ref, got, tt.want)
+		}
+	}
+}
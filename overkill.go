@@ -0,0 +1,59 @@
+package main
+
+// The log never says how much morale a mob had left, so overkill is estimated:
+// the least damage any mob of the same name needed to die is taken as its
+// morale, and whatever a kill took beyond that was wasted on the final hit.
+
+// killingBlows returns the last damage entry on each entity that died.
+func killingBlows(enc *Encounter) map[string]*LogEntry {
+	last := map[string]*LogEntry{}
+	blows := map[string]*LogEntry{}
+	for _, entry := range enc.Entries {
+		switch entry.etype {
+		case DmgDealt:
+			if entry.TargetID != "" && entry.Value > 0 {
+				last[entry.TargetID] = entry
+			}
+		case Death:
+			if hit, ok := last[entry.TargetID]; ok {
+				blows[entry.TargetID] = hit
+			}
+		}
+	}
+	return blows
+}
+
+// estimatedMorale is the least damage a mob of each name took before dying.
+func estimatedMorale(enc *Encounter) map[string]int {
+	morale := map[string]int{}
+	for _, e := range enc.Entities {
+		if e.Died.IsZero() || e.Damage == 0 || e.Kind != NPC {
+			continue
+		}
+		if m, ok := morale[e.Name]; !ok || e.Damage < m {
+			morale[e.Name] = e.Damage
+		}
+	}
+	return morale
+}
+
+// overkill totals the estimated overkill of each actor's killing blows. It is
+// never more than the killing blow itself.
+func overkill(enc *Encounter) map[string]int {
+	morale := estimatedMorale(enc)
+	result := map[string]int{}
+	for id, hit := range killingBlows(enc) {
+		e, ok := enc.Entities[id]
+		if !ok || e.Kind != NPC || hit.SourceID == "" {
+			continue
+		}
+		over := e.Damage - morale[e.Name]
+		if over > hit.Value {
+			over = hit.Value
+		}
+		if over > 0 {
+			result[hit.SourceID] += over
+		}
+	}
+	return result
+}
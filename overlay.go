@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// The overlay protocol lets third-party overlay apps follow the live meter.
+// It runs on /overlay of the live API (live, follow and replay with -http)
+// as a WebSocket of JSON text messages, each an OverlayMessage with a type
+// and the protocol version v.
+//
+// Server to client:
+//
+//	hello  v, min_v, server, parser_version, pattern_version, capabilities;
+//	       sent first
+//	state  v, seq, encounter, start, duration, actors; the whole meter, sent
+//	       when an encounter starts and when asked with resync
+//	diff   v, seq, encounter, duration, actors; only the actors whose stats
+//	       changed since the last state or diff, sent every second
+//	error  v, error; a client mistake, fatal if followed by a close
+//	end    v; the followed session ended, sent by the share server before
+//	       it closes a spectator's connection, see liveRelay
+//
+// Client to server:
+//
+//	hello   v, capabilities; optional, drops to full states every second
+//	        when "diff" is not among the capabilities
+//	resync  asks for a state, e.g. after an overlay reload
+//
+// seq counts states and diffs on a connection, encounter counts encounters
+// since the meter started. Within a version fields are only ever added, so
+// clients must ignore fields and message types they do not know. Renaming
+// or removing a field bumps the version; min_v is the oldest version the
+// server still speaks and a client hello below it gets an error and a close.
+// The JSON Schema of the messages is at /schemas/OverlayMessage.json of
+// serve mode.
+//
+// A live meter publishing to the share server speaks the server side of the
+// protocol over a connection it dialed, and the share server speaks it to
+// spectators.
+
+const (
+	overlayVersion    = 1
+	overlayMinVersion = 1
+)
+
+// overlay message types
+const (
+	overlayHello  = "hello"
+	overlayState  = "state"
+	overlayDiff   = "diff"
+	overlayError  = "error"
+	overlayResync = "resync"
+	overlayEnd    = "end"
+)
+
+// overlayCapabilities are what this server offers in its hello.
+var overlayCapabilities = []string{overlayState, overlayDiff, overlayResync}
+
+// OverlayMessage is every message of the overlay protocol, fields unused by
+// a type left out.
+type OverlayMessage struct {
+	Type string `json:"type"`
+	V    int    `json:"v"`
+
+	MinV           int      `json:"min_v,omitempty"`
+	Server         string   `json:"server,omitempty"`
+	ParserVersion  int      `json:"parser_version,omitempty"`
+	PatternVersion string   `json:"pattern_version,omitempty"`
+	Capabilities   []string `json:"capabilities,omitempty"`
+
+	Seq       int           `json:"seq,omitempty"`
+	Encounter int           `json:"encounter,omitempty"`
+	Start     *time.Time    `json:"start,omitempty"`
+	Duration  time.Duration `json:"duration,omitempty"`
+	Actors    []*ActorStats `json:"actors,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// overlayFrame is the current encounter of what an overlay follows.
+type overlayFrame struct {
+	Encounter int
+	Start     time.Time
+	Duration  time.Duration
+	Actors    []*ActorStats
+}
+
+// overlaySource is what an overlay follows: the local meter or a session
+// published to the share server.
+type overlaySource interface {
+	// overlayHello is the hello sent first.
+	overlayHello() OverlayMessage
+	// overlayFrame returns the current encounter, ok is false before the
+	// first.
+	overlayFrame() (frame overlayFrame, ok bool)
+	// overlayDone is closed when there is nothing more to follow, nil for
+	// sources that last.
+	overlayDone() <-chan struct{}
+}
+
+func (m *LiveMeter) overlayHello() OverlayMessage {
+	return OverlayMessage{
+		Type: overlayHello, V: overlayVersion, MinV: overlayMinVersion, Server: "SharedCombatGraphs",
+		ParserVersion: parserVersion, PatternVersion: patternVersion, Capabilities: overlayCapabilities,
+	}
+}
+
+func (m *LiveMeter) overlayFrame() (overlayFrame, bool) {
+	enc := m.Snapshot()
+	if enc == nil {
+		return overlayFrame{}, false
+	}
+	m.mu.Lock()
+	count := m.count
+	m.mu.Unlock()
+	return overlayFrame{Encounter: count, Start: enc.Start, Duration: enc.Duration(), Actors: watchedStats(actorStats(enc))}, true
+}
+
+func (m *LiveMeter) overlayDone() <-chan struct{} {
+	return nil
+}
+
+// overlaySession is one connected overlay.
+type overlaySession struct {
+	ws        *wsConn
+	source    overlaySource
+	diffs     bool
+	seq       int
+	encounter int                   // of the last state
+	sent      map[string]ActorStats // as of the last state or diff
+	// closing is closed when the server shuts down, see wsClients
+	closing <-chan struct{}
+}
+
+func overlayHandler(meter *LiveMeter, clients *wsClients) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgradeWebSocket(w, r)
+		if err != nil {
+			return
+		}
+		defer clients.track()()
+		s := &overlaySession{ws: ws, source: meter, diffs: true, closing: clients.closing}
+		if err := s.run(); err != nil {
+			slog.Debug("overlay disconnected", "remote", r.RemoteAddr, "err", err)
+		}
+		ws.conn.Close()
+	}
+}
+
+// run talks to the overlay until it leaves.
+func (s *overlaySession) run() error {
+	incoming := make(chan OverlayMessage)
+	readErr := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(incoming)
+		for {
+			_, data, err := s.ws.readMessage()
+			if err != nil {
+				readErr <- err
+				return
+			}
+			var msg OverlayMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				msg = OverlayMessage{Error: fmt.Sprintf("invalid message: %v", err)}
+			}
+			select {
+			case incoming <- msg:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := s.ws.writeJSON(s.source.overlayHello())
+	if err == nil {
+		err = s.update()
+	}
+	ticker := time.NewTicker(liveRefresh)
+	defer ticker.Stop()
+	for err == nil {
+		select {
+		case msg, ok := <-incoming:
+			if !ok {
+				return <-readErr
+			}
+			err = s.handle(msg)
+		case <-ticker.C:
+			err = s.update()
+		case <-s.source.overlayDone():
+			s.ws.writeJSON(OverlayMessage{Type: overlayEnd, V: overlayVersion})
+			s.ws.close(1000, "session ended")
+			return nil
+		case <-s.closing:
+			s.ws.writeJSON(OverlayMessage{Type: overlayEnd, V: overlayVersion})
+			s.ws.close(1001, "server shutting down")
+			return nil
+		}
+	}
+	return err
+}
+
+// handle answers a client message.
+func (s *overlaySession) handle(msg OverlayMessage) error {
+	switch {
+	case msg.Error != "":
+		return s.ws.writeJSON(OverlayMessage{Type: overlayError, V: overlayVersion, Error: msg.Error})
+	case msg.Type == overlayHello:
+		if msg.V < overlayMinVersion {
+			reason := fmt.Sprintf("protocol version %d is no longer supported, the oldest is %d", msg.V, overlayMinVersion)
+			s.ws.writeJSON(OverlayMessage{Type: overlayError, V: overlayVersion, Error: reason})
+			s.ws.close(1002, "unsupported version")
+			return fmt.Errorf("%v", reason)
+		}
+		s.diffs = slices.Contains(msg.Capabilities, overlayDiff)
+		return nil
+	case msg.Type == overlayResync:
+		s.encounter = 0
+		return s.update()
+	}
+	return s.ws.writeJSON(OverlayMessage{Type: overlayError, V: overlayVersion, Error: fmt.Sprintf("unknown message type %q", msg.Type)})
+}
+
+// update sends a state on a new encounter, or without diffs, and otherwise
+// a diff when anything changed.
+func (s *overlaySession) update() error {
+	frame, ok := s.source.overlayFrame()
+	if !ok {
+		return nil
+	}
+	stats := frame.Actors
+	msg := OverlayMessage{V: overlayVersion, Encounter: frame.Encounter, Duration: frame.Duration}
+	if frame.Encounter != s.encounter || !s.diffs {
+		start := frame.Start
+		msg.Type, msg.Start, msg.Actors = overlayState, &start, stats
+		s.encounter, s.sent = frame.Encounter, map[string]ActorStats{}
+	} else {
+		msg.Type = overlayDiff
+		for _, a := range stats {
+			if s.sent[a.Actor] != *a {
+				msg.Actors = append(msg.Actors, a)
+			}
+		}
+		if len(msg.Actors) == 0 {
+			return nil
+		}
+	}
+	for _, a := range stats {
+		s.sent[a.Actor] = *a
+	}
+	s.seq++
+	msg.Seq = s.seq
+	return s.ws.writeJSON(msg)
+}
+
+// apply updates a frame with a state or diff, the way a client of the
+// protocol keeps the meter, and reports whether msg was one. The actors
+// are copied on write, so frames returned earlier stay as they were.
+func (f *overlayFrame) apply(msg OverlayMessage) bool {
+	switch msg.Type {
+	case overlayState:
+		f.Encounter, f.Duration, f.Actors = msg.Encounter, msg.Duration, slices.Clone(msg.Actors)
+		if msg.Start != nil {
+			f.Start = *msg.Start
+		}
+		return true
+	case overlayDiff:
+		actors := slices.Clone(f.Actors)
+		for _, changed := range msg.Actors {
+			i := slices.IndexFunc(actors, func(a *ActorStats) bool { return a.Actor == changed.Actor })
+			if i < 0 {
+				actors = append(actors, changed)
+			} else {
+				actors[i] = changed
+			}
+		}
+		f.Duration, f.Actors = msg.Duration, actors
+		return true
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOverlayClosedOnShutdown(t *testing.T) {
+	clients := newWSClients()
+	server := httptest.NewServer(overlayHandler(&LiveMeter{}, clients))
+	defer server.Close()
+	ws, err := dialWebSocket(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.conn.Close()
+	ws.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, data, err := ws.readMessage(); err != nil || !strings.Contains(string(data), `"hello"`) {
+		t.Fatalf("first message %s, %v, want the hello", data, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := clients.shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	_, data, err := ws.readMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg OverlayMessage
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Type != overlayEnd {
+		t.Fatalf("got %s, want an end message", data)
+	}
+	_, op, payload, err := ws.readFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op != wsClose || len(payload) < 2 || binary.BigEndian.Uint16(payload) != 1001 {
+		t.Errorf("got opcode %#x payload %q, want a close with code 1001", op, payload)
+	}
+}
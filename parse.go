@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// parserVersion is bumped whenever a change to parsing or aggregation changes
+// the numbers derived from the same log, so stored and exported results can
+// tell they are not comparable.
+const parserVersion = 2
+
+// ParserOptions control how a log file is turned into entries.
+type ParserOptions struct {
+	// Strict makes any unparsed or partially parsed line, and any entry that
+	// breaks the invariants of validateEntry, fail the whole file. Useful when
+	// developing parsers against a corpus.
+	Strict bool
+	// KeepChat keeps chat and system lines as Chat entries.
+	KeepChat bool
+	// SampleErrors is how many failing lines to keep for display in lenient mode.
+	SampleErrors int
+	// Discard counts the lines without keeping any entries, to measure
+	// parsing logs larger than memory.
+	Discard bool
+}
+
+// ParseResult is everything parseFile learned about a log file.
+type ParseResult struct {
+	Entries    []*LogEntry
+	Lines      int
+	Unparsed   int // lines no parser matched
+	Partial    int // lines a parser matched but could not fully parse
+	Samples    []string
+	Shapes     map[string]*ShapeCount // failing lines clustered by lineShape
+	Noise      map[string]int         // skipped chat lines per channel
+	Normalized NormalizeStats
+	Anomalies  []Anomaly // implausible entries left out of Entries
+	Profile    LogProfile
+	// LoggingGaps are kinds of combat lines the game was not set to log.
+	LoggingGaps []LoggingGap
+}
+
+// Errors is the number of lines that did not produce an entry.
+func (r *ParseResult) Errors() int {
+	return r.Unparsed + r.Partial
+}
+
+func newParseResult() *ParseResult {
+	return &ParseResult{Noise: map[string]int{}, Shapes: map[string]*ShapeCount{}}
+}
+
+// addLine parses the next line of a log into the result. An error fails a
+// strict parse, the caller adds the line number.
+func (r *ParseResult) addLine(line string, opts ParserOptions) error {
+	r.Lines++
+	if chat, ok := filterNoise(line); ok {
+		r.Noise[chat.Skill]++
+		if opts.KeepChat && !opts.Discard {
+			r.Entries = append(r.Entries, chat)
+		}
+		return nil
+	}
+	entry, err := parseLogLine(line)
+	if err != nil {
+		if opts.Strict {
+			return err
+		}
+		slog.Debug("unparsed line", "line", r.Lines, "err", err)
+		var unmatched *UnmatchedLineError
+		if errors.As(err, &unmatched) {
+			r.Unparsed++
+		} else {
+			r.Partial++
+		}
+		if len(r.Samples) < opts.SampleErrors {
+			r.Samples = append(r.Samples, line)
+		}
+		shape := lineShape(line)
+		if _, ok := r.Shapes[shape]; !ok {
+			r.Shapes[shape] = &ShapeCount{Shape: shape, Example: line}
+		}
+		r.Shapes[shape].Count++
+		return nil
+	}
+	if opts.Strict {
+		if err := validateEntry(entry); err != nil {
+			return err
+		}
+	}
+	if !opts.Discard {
+		r.Entries = append(r.Entries, entry)
+	}
+	return nil
+}
+
+// merge appends the result of the lines following r's.
+func (r *ParseResult) merge(next *ParseResult, opts ParserOptions) {
+	r.Lines += next.Lines
+	r.Unparsed += next.Unparsed
+	r.Partial += next.Partial
+	r.Entries = append(r.Entries, next.Entries...)
+	for _, sample := range next.Samples {
+		if len(r.Samples) < opts.SampleErrors {
+			r.Samples = append(r.Samples, sample)
+		}
+	}
+	for shape, count := range next.Shapes {
+		if have, ok := r.Shapes[shape]; ok {
+			have.Count += count.Count
+		} else {
+			r.Shapes[shape] = count
+		}
+	}
+	for channel, n := range next.Noise {
+		r.Noise[channel] += n
+	}
+}
+
+// UnmatchedLineError is returned by parseLogLine when no parser claims a line.
+type UnmatchedLineError struct {
+	Line string
+}
+
+func (u *UnmatchedLineError) Error() string {
+	return fmt.Sprintf("No parsers matched: <%v>", u.Line)
+}
+
+const (
+	// longest line the scanner accepts, real lines are a few hundred bytes
+	maxLineBytes = 1 << 20
+)
+
+// lineScanner splits a log into lines, dropping carriage returns and byte
+// order marks and keeping track of the byte offset for error messages.
+type lineScanner struct {
+	*bufio.Scanner
+	line   int
+	offset int64 // of the start of the current line
+	next   int64
+}
+
+func newLineScanner(r io.Reader) *lineScanner {
+	s := &lineScanner{Scanner: bufio.NewScanner(r)}
+	s.Buffer(make([]byte, 64*1024), maxLineBytes)
+	s.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		s.next += int64(advance)
+		return advance, token, err
+	})
+	return s
+}
+
+func (s *lineScanner) Scan() bool {
+	s.offset = s.next
+	if !s.Scanner.Scan() {
+		return false
+	}
+	s.line++
+	return true
+}
+
+// Text returns the current line without stray CRs and BOMs.
+func (s *lineScanner) Text() string {
+	return cleanLine(s.Scanner.Text())
+}
+
+// Err explains where reading stopped.
+func (s *lineScanner) Err() error {
+	err := s.Scanner.Err()
+	if errors.Is(err, bufio.ErrTooLong) {
+		return fmt.Errorf("line %d at byte %d is longer than %d bytes: %w", s.line+1, s.offset, maxLineBytes, err)
+	}
+	return err
+}
+
+// cleanLine strips the CRs and byte order marks Windows tools and
+// concatenated logs leave in lines.
+func cleanLine(line string) string {
+	line = strings.TrimPrefix(line, "\ufeff")
+	return strings.ReplaceAll(line, "\r", "")
+}
+
+// parseFile reads and parses a whole log file.
+func parseFile(path string, opts ParserOptions) (*ParseResult, error) {
+	profile, err := sniffLog(path)
+	if err != nil {
+		return nil, fmt.Errorf("sniffing file: %w", err)
+	}
+	if err := profile.apply(); err != nil {
+		return nil, fmt.Errorf("selecting patterns: %w", err)
+	}
+	return parseCached(path, profile, opts)
+}
+
+// parseProfiled parses a log file with the patterns of its profile, which
+// must be applied already.
+func parseProfiled(path string, profile LogProfile, opts ParserOptions) (*ParseResult, error) {
+	result, err := readAndParse(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	result.Profile = profile
+	slog.Info("parsed file", "path", path, "lines", result.Lines, "entries", len(result.Entries), "errors", result.Errors(),
+		"locale", profile.Locale, "patterns", patternVersion)
+	result.Entries = runEntryHooks(result.Entries, result)
+	tally := newChannelTally()
+	for _, entry := range result.Entries {
+		tally.add(entry)
+	}
+	result.LoggingGaps = tally.loggingGaps()
+	for _, gap := range result.LoggingGaps {
+		slog.Warn(fmt.Sprintf("no %v lines in the log", gap.Channel), "path", path, "fix", gap.Fix)
+	}
+	return result, nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// cached parses are removed this long after they were written
+	parseCacheMaxAge = 30 * 24 * time.Hour
+)
+
+// parseCacheDir keeps parse results keyed by parseCacheKey, so reports over
+// unchanged logs skip parsing. The cache keeps whole logs, so it is off
+// unless set with -cache or parse_cache in the config.
+var parseCacheDir string
+
+// defaultParseCacheDir is the suggested cache in the user's cache directory.
+func defaultParseCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "SharedCombatGraphs", "parse")
+}
+
+// cachedParse is a parse result as cached. Gob skips the unexported event
+// types of the entries, they are kept beside them.
+type cachedParse struct {
+	Result *ParseResult
+	Types  []EventType
+}
+
+// parseCacheKey hashes the contents of a log with everything its parse
+// depends on: the parser, the patterns and layouts of its profile, the
+// charset, the options and the entry hooks.
+func parseCacheKey(path string, profile LogProfile, opts ParserOptions) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "\x00parser %d\x00%v\x00charset %v\x00%+v\x00", parserVersion, profile.key(), logCharset, opts)
+	names := make([]string, 0, len(patterns))
+	for name := range patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(h, "%v=%v\x00", name, patterns[name])
+	}
+	for _, hook := range entryHooks {
+		fmt.Fprintf(h, "hook %v\x00", hook.Name)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readParseCache returns the cached result of a key, or nil.
+func readParseCache(key string) *ParseResult {
+	path := filepath.Join(parseCacheDir, key+".gob")
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	cached := cachedParse{}
+	if err := gob.NewDecoder(file).Decode(&cached); err != nil || len(cached.Types) != len(cached.Result.Entries) {
+		slog.Warn("ignoring broken parse cache entry", "path", path, "err", err)
+		return nil
+	}
+	for i, entry := range cached.Result.Entries {
+		entry.etype = cached.Types[i]
+	}
+	return cached.Result
+}
+
+// writeParseCache stores a result under its key through a temporary file,
+// so parallel parses of the same log never read half of one.
+func writeParseCache(key string, result *ParseResult) error {
+	if err := os.MkdirAll(parseCacheDir, 0o755); err != nil {
+		return err
+	}
+	cached := cachedParse{Result: result, Types: make([]EventType, len(result.Entries))}
+	for i, entry := range result.Entries {
+		cached.Types[i] = entry.etype
+	}
+	tmp, err := os.CreateTemp(parseCacheDir, "parse-*.tmp")
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(tmp).Encode(cached); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(parseCacheDir, key+".gob"))
+}
+
+// pruneParseCache removes the entries written more than maxAge ago and
+// returns how many.
+func pruneParseCache(maxAge time.Duration) int {
+	if parseCacheDir == "" {
+		return 0
+	}
+	files, _ := filepath.Glob(filepath.Join(parseCacheDir, "*.gob"))
+	removed := 0
+	for _, path := range files {
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > maxAge {
+			if os.Remove(path) == nil {
+				removed++
+			}
+		}
+	}
+	return removed
+}
+
+// parseCached is parseProfiled through the parse cache. A cache that cannot
+// be read or written only costs the time of parsing.
+func parseCached(path string, profile LogProfile, opts ParserOptions) (*ParseResult, error) {
+	if parseCacheDir == "" {
+		return parseProfiled(path, profile, opts)
+	}
+	key, err := parseCacheKey(path, profile, opts)
+	if err != nil {
+		return nil, fmt.Errorf("opening file: %w", err)
+	}
+	if result := readParseCache(key); result != nil {
+		slog.Info("loaded cached parse", "path", path, "lines", result.Lines, "entries", len(result.Entries))
+		return result, nil
+	}
+	result, err := parseProfiled(path, profile, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeParseCache(key, result); err != nil {
+		slog.Warn("caching parse", "path", path, "err", err)
+	}
+	pruneParseCache(parseCacheMaxAge)
+	return result, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseCache(t *testing.T) {
+	defer func(dir string) { parseCacheDir = dir }(parseCacheDir)
+	parseCacheDir = t.TempDir()
+	parsed, err := parseFile("test/input.txt", ParserOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(parseCacheDir, "*.gob"))
+	if len(files) != 1 {
+		t.Fatalf("got cache files %v, want one", files)
+	}
+	cached, err := parseFile("test/input.txt", ParserOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached.Lines != parsed.Lines || len(cached.Entries) != len(parsed.Entries) {
+		t.Fatalf("got %d lines and %d entries from the cache, want %d and %d",
+			cached.Lines, len(cached.Entries), parsed.Lines, len(parsed.Entries))
+	}
+	for i, entry := range cached.Entries {
+		if want := parsed.Entries[i]; entry.etype != want.etype || entry.RawMessage != want.RawMessage || !entry.Timestamp.Equal(want.Timestamp) {
+			t.Fatalf("entry %d: got %v %q from the cache, want %v %q", i, entry.etype, entry.RawMessage, want.etype, want.RawMessage)
+		}
+	}
+
+	// other options are another entry
+	if _, err := parseFile("test/input.txt", ParserOptions{KeepChat: true}); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(parseCacheDir, "*.gob")); len(files) != 2 {
+		t.Errorf("got cache files %v, want one per options", files)
+	}
+}
+
+func TestPruneParseCache(t *testing.T) {
+	defer func(dir string) { parseCacheDir = dir }(parseCacheDir)
+	parseCacheDir = t.TempDir()
+	old, fresh := filepath.Join(parseCacheDir, "old.gob"), filepath.Join(parseCacheDir, "fresh.gob")
+	for _, path := range []string{old, fresh} {
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	written := time.Now().Add(-parseCacheMaxAge - time.Hour)
+	if err := os.Chtimes(old, written, written); err != nil {
+		t.Fatal(err)
+	}
+	if n := pruneParseCache(parseCacheMaxAge); n != 1 {
+		t.Errorf("removed %d entries, want 1", n)
+	}
+	if _, err := os.Stat(old); err == nil {
+		t.Errorf("an entry older than %v was kept", parseCacheMaxAge)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("a fresh entry was removed: %v", err)
+	}
+}
+
+func TestReadParseCacheIgnoresBrokenEntries(t *testing.T) {
+	defer func(dir string) { parseCacheDir = dir }(parseCacheDir)
+	parseCacheDir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(parseCacheDir, "broken.gob"), []byte("not gob"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if result := readParseCache("broken"); result != nil {
+		t.Errorf("got %+v from a broken entry, want nil", result)
+	}
+}
+
+func TestRetentionPrunesParseCache(t *testing.T) {
+	defer func(dir string) { parseCacheDir = dir }(parseCacheDir)
+	parseCacheDir = t.TempDir()
+	old := filepath.Join(parseCacheDir, "old.gob")
+	if err := os.WriteFile(old, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	written := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(old, written, written); err != nil {
+		t.Fatal(err)
+	}
+	store, err := openFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if _, err := pruneStore(store, 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(old); err == nil {
+		t.Errorf("a cached parse older than the retention was kept")
+	}
+}
+
+func TestParseCacheOff(t *testing.T) {
+	defer func(dir string) { parseCacheDir = dir }(parseCacheDir)
+	parseCacheDir = ""
+	// with the cache off, pruning must not look in the working directory
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("old.gob", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if n := pruneParseCache(0); n != 0 {
+		t.Errorf("removed %d entries with the cache off", n)
+	}
+	if _, err := os.Stat("old.gob"); err != nil {
+		t.Errorf("pruned the working directory: %v", err)
+	}
+}
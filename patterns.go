@@ -0,0 +1,189 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const (
+	// dates in pattern set ranges
+	patternDateFormat = "2006-01-02"
+)
+
+//go:embed data/patterns.json
+var patternsJSON []byte
+
+// PatternSet is a versioned collection of named message patterns. The
+// embedded set can be extended or overridden by JSON files in a pattern
+// directory, so new message formats can ship without a rebuild.
+type PatternSet struct {
+	Version string     `json:"version"`
+	Game    *GameRange `json:"game,omitempty"`
+	// Locale restricts the set to logs from a game client language.
+	Locale   string            `json:"locale,omitempty"`
+	Patterns map[string]string `json:"patterns"`
+}
+
+// GameRange limits a pattern set to the game updates whose combat messages
+// it matches. Zero values leave that side of the range open. From and Until
+// are the release dates of the range, used when a log has no version header.
+type GameRange struct {
+	MinUpdate int    `json:"min_update,omitempty"`
+	MaxUpdate int    `json:"max_update,omitempty"`
+	From      string `json:"from,omitempty"`
+	Until     string `json:"until,omitempty"`
+}
+
+// contains reports whether the range covers the game update, or the log date
+// when the update is unknown.
+func (g *GameRange) contains(update int, date time.Time) bool {
+	if update > 0 {
+		return (g.MinUpdate == 0 || update >= g.MinUpdate) && (g.MaxUpdate == 0 || update <= g.MaxUpdate)
+	}
+	if date.IsZero() || (g.From == "" && g.Until == "") {
+		return false
+	}
+	if from, err := time.Parse(patternDateFormat, g.From); err == nil && date.Before(from) {
+		return false
+	}
+	if until, err := time.Parse(patternDateFormat, g.Until); err == nil && !date.Before(until) {
+		return false
+	}
+	return true
+}
+
+var (
+	// patternSets holds the embedded set followed by any overrides
+	patternSets    []PatternSet
+	patternVersion string
+	patterns       map[string]*regexp.Regexp
+)
+
+func init() {
+	set, err := decodePatternSet(patternsJSON)
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded patterns: %v", err))
+	}
+	patternSets = []PatternSet{set}
+	if err := selectPatterns(0, time.Time{}, defaultLocale); err != nil {
+		panic(fmt.Sprintf("invalid embedded patterns: %v", err))
+	}
+}
+
+// pattern returns the compiled pattern with the given name.
+func pattern(name string) *regexp.Regexp {
+	re, ok := patterns[name]
+	if !ok {
+		panic(fmt.Sprintf("unknown pattern %q", name))
+	}
+	return re
+}
+
+func decodePatternSet(data []byte) (PatternSet, error) {
+	set := PatternSet{}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return set, err
+	}
+	return set, nil
+}
+
+func compilePatterns(raw map[string]string) (map[string]*regexp.Regexp, error) {
+	compiled := make(map[string]*regexp.Regexp, len(raw))
+	for name, expr := range raw {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %v: %w", name, err)
+		}
+		compiled[name] = re
+	}
+	return compiled, nil
+}
+
+// selectPatterns activates the pattern sets for a game update (or log date
+// if the update is unknown) and client locale. Sets without a range always
+// apply, sets whose range matches are layered on top of them. Sets for
+// another locale are skipped.
+func selectPatterns(update int, date time.Time, locale string) error {
+	selected := map[string]*regexp.Regexp{}
+	version := ""
+	apply := func(set PatternSet) error {
+		compiled, err := compilePatterns(set.Patterns)
+		if err != nil {
+			return err
+		}
+		for name, re := range compiled {
+			selected[name] = re
+		}
+		if set.Version != "" {
+			version = set.Version
+		}
+		return nil
+	}
+	for _, set := range patternSets {
+		if set.Locale != "" && set.Locale != locale {
+			continue
+		}
+		if set.Game == nil {
+			if err := apply(set); err != nil {
+				return err
+			}
+		}
+	}
+	for _, set := range patternSets {
+		if set.Locale != "" && set.Locale != locale {
+			continue
+		}
+		if set.Game != nil && set.Game.contains(update, date) {
+			if err := apply(set); err != nil {
+				return err
+			}
+		}
+	}
+	patterns = selected
+	patternVersion = version
+	fastTimestamps = selected["timestamp"] != nil && selected["timestamp"].String() == patternSets[0].Patterns["timestamp"]
+	return nil
+}
+
+// defaultPatternDir is the patterns directory next to the config file.
+func defaultPatternDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "SharedCombatGraphs", "patterns")
+}
+
+// loadPatternOverrides adds every *.json pattern set in dir, in name order,
+// on top of the embedded patterns. A missing directory is ignored.
+func loadPatternOverrides(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		set, err := decodePatternSet(data)
+		if err != nil {
+			return fmt.Errorf("%v: %w", file, err)
+		}
+		if _, err := compilePatterns(set.Patterns); err != nil {
+			return fmt.Errorf("%v: %w", file, err)
+		}
+		patternSets = append(patternSets, set)
+	}
+	return selectPatterns(0, time.Time{}, defaultLocale)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// rasterizeSVG draws the subset of SVG our charts produce (rect, circle, line
+// and text) into an image, scale times the SVG size. Text uses a small built-in
+// bitmap font since there is no font rendering in the standard library.
+func rasterizeSVG(svg []byte, scale float64, background color.Color) (*image.RGBA, error) {
+	dec := xml.NewDecoder(bytes.NewReader(svg))
+	var img *image.RGBA
+	var text *svgTextRun
+	var textColor color.Color = color.Black
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading svg: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			attr := svgAttrs(t)
+			if t.Name.Local == "svg" {
+				w, h := attr.num("width")*scale, attr.num("height")*scale
+				img = image.NewRGBA(image.Rect(0, 0, int(w), int(h)))
+				draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+				if attr["fill"] != "" {
+					textColor = attr.color("fill", 1)
+				}
+				continue
+			}
+			if img == nil {
+				return nil, fmt.Errorf("reading svg: %v outside of <svg>", t.Name.Local)
+			}
+			switch t.Name.Local {
+			case "rect":
+				fill := attr.color("fill", attr.num("fill-opacity"))
+				r := image.Rect(int(attr.num("x")*scale), int(attr.num("y")*scale),
+					int((attr.num("x")+attr.num("width"))*scale), int((attr.num("y")+attr.num("height"))*scale))
+				draw.Draw(img, r, image.NewUniform(fill), image.Point{}, draw.Over)
+			case "circle":
+				fillCircle(img, attr.num("cx")*scale, attr.num("cy")*scale, attr.num("r")*scale, attr.color("fill", 1))
+			case "line":
+				drawLine(img, attr.num("x1")*scale, attr.num("y1")*scale, attr.num("x2")*scale, attr.num("y2")*scale,
+					math.Max(1, scale), attr.color("stroke", 1))
+			case "text":
+				text = &svgTextRun{x: attr.num("x") * scale, y: attr.num("y") * scale, color: textColor}
+				if attr["fill"] != "" {
+					text.color = attr.color("fill", 1)
+				}
+			}
+		case xml.CharData:
+			if text != nil {
+				text.s += string(t)
+			}
+		case xml.EndElement:
+			if t.Name.Local == "text" && text != nil {
+				drawBitmapText(img, text.x, text.y, math.Max(1, math.Round(scale*2)), text.color, text.s)
+				text = nil
+			}
+		}
+	}
+	if img == nil {
+		return nil, fmt.Errorf("reading svg: no <svg> element")
+	}
+	return img, nil
+}
+
+// writeChartPNG renders a chart and writes it as a PNG image.
+func writeChartPNG(w io.Writer, c chart, enc *Encounter, scale float64, background color.Color) error {
+	var buf bytes.Buffer
+	if err := c(&buf, enc); err != nil {
+		return err
+	}
+	img, err := rasterizeSVG(buf.Bytes(), scale, background)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+type svgTextRun struct {
+	x, y  float64
+	color color.Color
+	s     string
+}
+
+type svgAttrMap map[string]string
+
+func svgAttrs(el xml.StartElement) svgAttrMap {
+	attrs := svgAttrMap{}
+	for _, a := range el.Attr {
+		attrs[a.Name.Local] = a.Value
+	}
+	return attrs
+}
+
+func (a svgAttrMap) num(name string) float64 {
+	v, err := strconv.ParseFloat(a[name], 64)
+	if err != nil {
+		if name == "fill-opacity" {
+			return 1
+		}
+		return 0
+	}
+	return v
+}
+
+// color parses "#rrggbb" and a few names; unknown colors are black.
+func (a svgAttrMap) color(name string, opacity float64) color.Color {
+	c := color.NRGBA{A: uint8(255 * opacity)}
+	switch v := a[name]; {
+	case v == "none":
+		c.A = 0
+	case v == "white":
+		c.R, c.G, c.B = 255, 255, 255
+	case len(v) == 7 && v[0] == '#':
+		rgb, err := strconv.ParseUint(v[1:], 16, 32)
+		if err == nil {
+			c.R, c.G, c.B = uint8(rgb>>16), uint8(rgb>>8), uint8(rgb)
+		}
+	}
+	return c
+}
+
+func fillCircle(img *image.RGBA, cx, cy, r float64, c color.Color) {
+	src := image.NewUniform(c)
+	for y := int(cy - r); y <= int(cy+r); y++ {
+		dy := float64(y) + 0.5 - cy
+		half := math.Sqrt(math.Max(0, r*r-dy*dy))
+		draw.Draw(img, image.Rect(int(cx-half), y, int(cx+half+0.5), y+1), src, image.Point{}, draw.Over)
+	}
+}
+
+func drawLine(img *image.RGBA, x1, y1, x2, y2, width float64, c color.Color) {
+	src := image.NewUniform(c)
+	steps := int(math.Max(math.Abs(x2-x1), math.Abs(y2-y1))) + 1
+	for i := 0; i <= steps; i++ {
+		x := x1 + (x2-x1)*float64(i)/float64(steps)
+		y := y1 + (y2-y1)*float64(i)/float64(steps)
+		draw.Draw(img, image.Rect(int(x), int(y), int(x+width), int(y+width)), src, image.Point{}, draw.Src)
+	}
+}
+
+// drawBitmapText draws s with its baseline at y, px pixels per font dot.
+func drawBitmapText(img *image.RGBA, x, y, px float64, c color.Color, s string) {
+	src := image.NewUniform(c)
+	top := y - 5*px
+	for _, r := range strings.TrimSpace(s) {
+		glyph, ok := bitmapFont[unicode.ToUpper(r)]
+		if !ok {
+			glyph = bitmapFont['?']
+		}
+		for row, bits := range glyph {
+			for col, bit := range bits {
+				if bit != '#' {
+					continue
+				}
+				gx, gy := x+float64(col)*px, top+float64(row)*px
+				draw.Draw(img, image.Rect(int(gx), int(gy), int(gx+px), int(gy+px)), src, image.Point{}, draw.Src)
+			}
+		}
+		x += 4 * px
+	}
+}
+
+// bitmapFont is a 3x5 font, lower case letters are drawn as upper case.
+var bitmapFont = map[rune][5]string{
+	' ':  {"...", "...", "...", "...", "..."},
+	'A':  {".#.", "#.#", "###", "#.#", "#.#"},
+	'B':  {"##.", "#.#", "##.", "#.#", "##."},
+	'C':  {".##", "#..", "#..", "#..", ".##"},
+	'D':  {"##.", "#.#", "#.#", "#.#", "##."},
+	'E':  {"###", "#..", "##.", "#..", "###"},
+	'F':  {"###", "#..", "##.", "#..", "#.."},
+	'G':  {".##", "#..", "#.#", "#.#", ".##"},
+	'H':  {"#.#", "#.#", "###", "#.#", "#.#"},
+	'I':  {"###", ".#.", ".#.", ".#.", "###"},
+	'J':  {"..#", "..#", "..#", "#.#", ".#."},
+	'K':  {"#.#", "#.#", "##.", "#.#", "#.#"},
+	'L':  {"#..", "#..", "#..", "#..", "###"},
+	'M':  {"#.#", "###", "###", "#.#", "#.#"},
+	'N':  {"##.", "#.#", "#.#", "#.#", "#.#"},
+	'O':  {".#.", "#.#", "#.#", "#.#", ".#."},
+	'P':  {"##.", "#.#", "##.", "#..", "#.."},
+	'Q':  {".#.", "#.#", "#.#", "##.", ".##"},
+	'R':  {"##.", "#.#", "##.", "#.#", "#.#"},
+	'S':  {".##", "#..", ".#.", "..#", "##."},
+	'T':  {"###", ".#.", ".#.", ".#.", ".#."},
+	'U':  {"#.#", "#.#", "#.#", "#.#", "###"},
+	'V':  {"#.#", "#.#", "#.#", "#.#", ".#."},
+	'W':  {"#.#", "#.#", "###", "###", "#.#"},
+	'X':  {"#.#", "#.#", ".#.", "#.#", "#.#"},
+	'Y':  {"#.#", "#.#", ".#.", ".#.", ".#."},
+	'Z':  {"###", "..#", ".#.", "#..", "###"},
+	'0':  {"###", "#.#", "#.#", "#.#", "###"},
+	'1':  {".#.", "##.", ".#.", ".#.", "###"},
+	'2':  {"##.", "..#", ".#.", "#..", "###"},
+	'3':  {"##.", "..#", ".#.", "..#", "##."},
+	'4':  {"#.#", "#.#", "###", "..#", "..#"},
+	'5':  {"###", "#..", "##.", "..#", "##."},
+	'6':  {".##", "#..", "###", "#.#", "###"},
+	'7':  {"###", "..#", ".#.", ".#.", ".#."},
+	'8':  {"###", "#.#", "###", "#.#", "###"},
+	'9':  {"###", "#.#", "###", "..#", "##."},
+	'.':  {"...", "...", "...", "...", ".#."},
+	',':  {"...", "...", "...", ".#.", "#.."},
+	':':  {"...", ".#.", "...", ".#.", "..."},
+	'-':  {"...", "...", "###", "...", "..."},
+	'+':  {"...", ".#.", "###", ".#.", "..."},
+	'#':  {"#.#", "###", "#.#", "###", "#.#"},
+	'(':  {".#.", "#..", "#..", "#..", ".#."},
+	')':  {".#.", "..#", "..#", "..#", ".#."},
+	'/':  {"..#", "..#", ".#.", "#..", "#.."},
+	'%':  {"#.#", "..#", ".#.", "#..", "#.#"},
+	'\'': {".#.", ".#.", "...", "...", "..."},
+	'?':  {"##.", "..#", ".#.", "...", ".#."},
+}
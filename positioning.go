@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// a melee skill event counts as being in melee range this long
+	meleeWindow = 2 * time.Second
+	// players hit by one cast that make it a clump
+	aoeClumpMin = 3
+	// auto-attacks are always melee
+	meleeAttack = "a melee attack"
+)
+
+func init() {
+	analyses["positioning"] = printPositioning
+}
+
+// Positioning is a rough proxy of where a player stood.
+type Positioning struct {
+	Player string
+	// MeleeUptime is the share of the encounter the player used melee
+	// skills, only set for players who used any.
+	MeleeUptime float64
+	// Clumps is how often the player was one of aoeClumpMin or more
+	// players hit by one mob skill within aoeWindow, i.e. stacked up.
+	Clumps int
+}
+
+// meleeUptime is the share of the encounter each player spent using melee
+// skills, counting meleeWindow per event.
+func meleeUptime(enc *Encounter, meleeSkills []string) map[string]float64 {
+	melee := map[string]bool{meleeAttack: true}
+	for _, s := range meleeSkills {
+		melee[s] = true
+	}
+	up := map[string]time.Duration{}
+	until := map[string]time.Time{}
+	for _, entry := range enc.Entries {
+		if entry.etype != DmgDealt || !melee[entry.Skill] || enc.kindOf(entry.SourceID) != Player {
+			continue
+		}
+		from, to := entry.Timestamp, entry.Timestamp.Add(meleeWindow)
+		if last, ok := until[entry.SourceID]; ok && from.Before(last) {
+			from = last
+		}
+		if to.After(enc.End) {
+			to = enc.End
+		}
+		if to.After(from) {
+			up[entry.SourceID] += to.Sub(from)
+			until[entry.SourceID] = to
+		}
+	}
+	share := map[string]float64{}
+	for player, d := range up {
+		share[player] = min(1, float64(d)/float64(max(enc.Duration(), time.Second)))
+	}
+	return share
+}
+
+// aoeClumps counts per player the mob casts that hit aoeClumpMin or more
+// players at once.
+func aoeClumps(enc *Encounter) map[string]int {
+	clumps := map[string]int{}
+	for _, cast := range mobCasts(enc) {
+		players := []string{}
+		for _, target := range cast.Targets() {
+			if enc.kindOf(target) == Player {
+				players = append(players, target)
+			}
+		}
+		if len(players) < aoeClumpMin {
+			continue
+		}
+		for _, player := range players {
+			clumps[player]++
+		}
+	}
+	return clumps
+}
+
+func positioning(enc *Encounter) []Positioning {
+	uptime := meleeUptime(enc, config.MeleeSkills)
+	clumps := aoeClumps(enc)
+	players := map[string]bool{}
+	for p := range uptime {
+		players[p] = true
+	}
+	for p := range clumps {
+		players[p] = true
+	}
+	result := []Positioning{}
+	for p := range players {
+		if isWatched(p) {
+			result = append(result, Positioning{Player: p, MeleeUptime: uptime[p], Clumps: clumps[p]})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Player < result[j].Player })
+	return result
+}
+
+func printPositioning(enc *Encounter) {
+	fmt.Println("positioning:")
+	for _, p := range positioning(enc) {
+		fmt.Printf("  %-14v melee uptime %3.0f%%  caught in %d aoe clumps\n", p.Player, 100*p.MeleeUptime, p.Clumps)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strings"
+	"sync"
+)
+
+// parserLabels labels the time spent in each parser function while a CPU
+// profile is taken, so the profile shows which patterns are slow.
+var parserLabels bool
+
+// parserNames caches the function names of parsers by entry point.
+var parserNames sync.Map
+
+// parserName is the name of a parser function, like "pDmg".
+func parserName(p eventParser) string {
+	pc := reflect.ValueOf(p).Pointer()
+	if name, ok := parserNames.Load(pc); ok {
+		return name.(string)
+	}
+	name := "unknown"
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		name = fn.Name()[strings.LastIndex(fn.Name(), ".")+1:]
+	}
+	parserNames.Store(pc, name)
+	return name
+}
+
+// runParser calls a parser, under a "parser" pprof label while profiling.
+func runParser(p eventParser, line string) (entry *LogEntry, err error) {
+	if !parserLabels {
+		return p(line)
+	}
+	pprof.Do(context.Background(), pprof.Labels("parser", parserName(p)), func(context.Context) {
+		entry, err = p(line)
+	})
+	return entry, err
+}
+
+// profileFlags registers -cpuprofile, -memprofile and -trace. The returned
+// function starts what was asked for and returns the function that stops
+// it and writes the files, to be deferred.
+func profileFlags(fs *flag.FlagSet) func() (func(), error) {
+	cpuPath := fs.String("cpuprofile", "", "write a CPU profile to this file, for reports of slowness")
+	memPath := fs.String("memprofile", "", "write an allocation profile to this file when done")
+	tracePath := fs.String("trace", "", "write an execution trace to this file")
+	return func() (func(), error) {
+		stops := []func(){}
+		stop := func() {
+			for i := len(stops) - 1; i >= 0; i-- {
+				stops[i]()
+			}
+		}
+		if *cpuPath != "" {
+			file, err := os.Create(*cpuPath)
+			if err != nil {
+				return nil, err
+			}
+			if err := pprof.StartCPUProfile(file); err != nil {
+				file.Close()
+				return nil, fmt.Errorf("starting CPU profile: %w", err)
+			}
+			stops = append(stops, func() {
+				pprof.StopCPUProfile()
+				file.Close()
+			})
+		}
+		if *tracePath != "" {
+			file, err := os.Create(*tracePath)
+			if err != nil {
+				stop()
+				return nil, err
+			}
+			if err := trace.Start(file); err != nil {
+				file.Close()
+				stop()
+				return nil, fmt.Errorf("starting trace: %w", err)
+			}
+			stops = append(stops, func() {
+				trace.Stop()
+				file.Close()
+			})
+		}
+		if *memPath != "" {
+			path := *memPath
+			stops = append(stops, func() {
+				file, err := os.Create(path)
+				if err != nil {
+					slog.Error("writing allocation profile", "err", err)
+					return
+				}
+				defer file.Close()
+				if err := pprof.Lookup("allocs").WriteTo(file, 0); err != nil {
+					slog.Error("writing allocation profile", "err", err)
+				}
+			})
+		}
+		parserLabels = *cpuPath != ""
+		return stop, nil
+	}
+}
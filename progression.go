@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func pExperience(line string) (*LogEntry, error) {
+	if !pattern("experience.detect").MatchString(line) {
+		return nil, &ParseNotMatchError{}
+	}
+	timestamp, msg, err := extractTimestamp(line)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing timestamp: %w", err)
+	}
+	entry := &LogEntry{RawMessage: line}
+	entry.Timestamp = timestamp
+	entry.Source = selfplaceholder
+
+	re := pattern("experience")
+	match := re.FindStringSubmatch(msg)
+	if len(match) == 0 {
+		return nil, fmt.Errorf("Failed to parse as experience: <%s>", msg)
+	}
+	val, err := parseValue(match[re.SubexpIndex("value")])
+	if err != nil {
+		return nil, fmt.Errorf("value not convertable to int: %v <%v>", err, msg)
+	}
+	entry.Value = val
+	entry.ValueType = "XP"
+	return entry, nil
+}
+
+func pLevelUp(line string) (*LogEntry, error) {
+	if !pattern("levelup.detect").MatchString(line) {
+		return nil, &ParseNotMatchError{}
+	}
+	timestamp, msg, err := extractTimestamp(line)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing timestamp: %w", err)
+	}
+	entry := &LogEntry{RawMessage: line}
+	entry.Timestamp = timestamp
+
+	re := pattern("levelup")
+	match := re.FindStringSubmatch(msg)
+	if len(match) == 0 {
+		return nil, fmt.Errorf("Failed to parse as level up: <%s>", msg)
+	}
+	entry.Source = match[re.SubexpIndex("who")]
+	if entry.Source == "You" || entry.Source == "Your" {
+		entry.Source = selfplaceholder
+	}
+	entry.Value, _ = strconv.Atoi(match[re.SubexpIndex("level")])
+	return entry, nil
+}
+
+// pProgress covers virtue ranks, trait points and similar character progress.
+func pProgress(line string) (*LogEntry, error) {
+	if !pattern("progress.detect").MatchString(line) {
+		return nil, &ParseNotMatchError{}
+	}
+	timestamp, msg, err := extractTimestamp(line)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing timestamp: %w", err)
+	}
+	entry := &LogEntry{RawMessage: line}
+	entry.Timestamp = timestamp
+	entry.Source = selfplaceholder
+
+	if strings.Contains(msg, "earned a trait point") {
+		entry.Skill = "Trait Point"
+		entry.Value = 1
+		return entry, nil
+	}
+	re := pattern("progress")
+	match := re.FindStringSubmatch(msg)
+	if len(match) == 0 {
+		return nil, fmt.Errorf("Failed to parse as progress: <%s>", msg)
+	}
+	entry.Skill = match[re.SubexpIndex("name")]
+	entry.Value, _ = strconv.Atoi(match[re.SubexpIndex("rank")])
+	return entry, nil
+}
+
+// ProgressionSummary totals the experience and levels gained over a session.
+type ProgressionSummary struct {
+	XP       int
+	Span     time.Duration
+	Levels   int
+	Progress []string
+}
+
+// XPPerHour is the experience rate over the whole session span.
+func (p ProgressionSummary) XPPerHour() float64 {
+	if p.Span <= 0 {
+		return 0
+	}
+	return float64(p.XP) / p.Span.Hours()
+}
+
+func summarizeProgression(entries []*LogEntry) ProgressionSummary {
+	summary := ProgressionSummary{}
+	var first, last time.Time
+	for _, entry := range entries {
+		if !entry.Timestamp.IsZero() {
+			if first.IsZero() {
+				first = entry.Timestamp
+			}
+			last = entry.Timestamp
+		}
+		switch entry.etype {
+		case Experience:
+			summary.XP += entry.Value
+		case LevelUp:
+			if entry.Source == selfplaceholder {
+				summary.Levels++
+			}
+		case Progress:
+			summary.Progress = append(summary.Progress, fmt.Sprintf("%v %v", entry.Skill, entry.Value))
+		}
+	}
+	summary.Span = last.Sub(first)
+	return summary
+}
+
+func printProgressionSummary(summary ProgressionSummary) {
+	if summary.XP == 0 && summary.Levels == 0 && len(summary.Progress) == 0 {
+		return
+	}
+	fmt.Println("progression:")
+	fmt.Printf("  xp: %v (%.0f/hour)\n", summary.XP, summary.XPPerHour())
+	fmt.Printf("  levels gained: %v\n", summary.Levels)
+	for _, p := range summary.Progress {
+		fmt.Printf("  %v\n", p)
+	}
+}
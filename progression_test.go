@@ -0,0 +1,62 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseProgression(t *testing.T) {
+	tests := []struct {
+		line   string
+		etype  EventType
+		source string
+		skill  string
+		value  int
+	}{
+		{"[07/08 06:05:00 PM] You've earned 1,234 XP.", Experience, selfplaceholder, "", 1234},
+		{"[07/08 06:05:00 PM] You have gained 250 experience points.", Experience, selfplaceholder, "", 250},
+		{"[07/08 06:05:00 PM] Your level has changed to 141.", LevelUp, selfplaceholder, "", 141},
+		{"[07/08 06:05:00 PM] Huya has reached level 140.", LevelUp, "Huya", "", 140},
+		{"[07/08 06:05:00 PM] Your virtue Valour has increased to rank 12.", Progress, selfplaceholder, "Valour", 12},
+		{"[07/08 06:05:00 PM] Your Fidelity virtue has increased to rank 3.", Progress, selfplaceholder, "Fidelity", 3},
+		{"[07/08 06:05:00 PM] You have earned a trait point.", Progress, selfplaceholder, "Trait Point", 1},
+	}
+	for _, tt := range tests {
+		entry, err := parseLogLine(tt.line)
+		if err != nil {
+			t.Errorf("%q: %v", tt.line, err)
+			continue
+		}
+		if entry.etype != tt.etype || entry.Source != tt.source || entry.Skill != tt.skill || entry.Value != tt.value {
+			t.Errorf("%q: got %v %q %q %d, want %v %q %q %d", tt.line,
+				entry.etype, entry.Source, entry.Skill, entry.Value, tt.etype, tt.source, tt.skill, tt.value)
+		}
+	}
+}
+
+func TestSummarizeProgression(t *testing.T) {
+	var entries []*LogEntry
+	for _, line := range []string{
+		"[07/08 05:00:00 PM] You've earned 1,000 XP.",
+		"[07/08 05:30:00 PM] Huya has reached level 140.",
+		"[07/08 05:30:00 PM] Your level has changed to 141.",
+		"[07/08 05:45:00 PM] Your virtue Valour has increased to rank 12.",
+		"[07/08 06:00:00 PM] You've earned 2,000 XP.",
+	} {
+		entry, err := parseLogLine(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	summary := summarizeProgression(entries)
+	if summary.XP != 3000 || summary.Levels != 1 {
+		t.Errorf("got %d XP and %d levels, want 3000 and 1", summary.XP, summary.Levels)
+	}
+	if got := summary.XPPerHour(); got != 3000 {
+		t.Errorf("got %v XP per hour, want 3000", got)
+	}
+	if !slices.Equal(summary.Progress, []string{"Valour 12"}) {
+		t.Errorf("got progress %q, want Valour 12", summary.Progress)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+const (
+	// how often serve mode enforces the retention policy
+	retentionInterval = time.Hour
+)
+
+func init() {
+	commands["prune"] = runPrune
+}
+
+// pruneStore applies the retention policy once. Cached parses hold the
+// same lines, so those older than the retention go too.
+func pruneStore(store Store, retention time.Duration) (int, error) {
+	if retention <= 0 {
+		return 0, nil
+	}
+	pruneParseCache(retention)
+	return store.PruneRaw(time.Now().Add(-retention))
+}
+
+// enforceRetention prunes the store every retentionInterval until done is
+// closed.
+func enforceRetention(store Store, retention time.Duration, done <-chan struct{}, report func(int, error)) {
+	if retention <= 0 {
+		return
+	}
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+	for {
+		report(pruneStore(store, retention))
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runPrune drops raw lines older than the retention period, keeping the
+// aggregates of every encounter.
+func runPrune(args []string) error {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	setup := commonFlags(fs)
+	open := storeFlags(fs)
+	days := fs.Int("days", 0, "keep raw lines this many days (default from config)")
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	if *days > 0 {
+		config.Store.RetentionDays = *days
+	}
+	if config.Store.RetentionDays <= 0 {
+		return fmt.Errorf("no retention configured, use -days or store.retention_days")
+	}
+	store, err := open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	n, err := pruneStore(store, config.Store.retention())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("pruned raw lines of %d encounters older than %d days\n", n, config.Store.RetentionDays)
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func init() {
+	commands["publish"] = runPublish
+}
+
+// writePage renders a template into a file of the site.
+func writePage(path string, page *template.Template, data any) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := page.Execute(file, data); err != nil {
+		file.Close()
+		return fmt.Errorf("writing %v: %w", path, err)
+	}
+	return file.Close()
+}
+
+// runPublish renders the encounters of one or more logs as a static site:
+// an index page and an encounter page with charts for each, linked
+// relatively so the directory works from any static host or from disk.
+func runPublish(args []string) error {
+	fs := flag.NewFlagSet("publish", flag.ExitOnError)
+	setup := commonFlags(fs)
+	out := fs.String("o", "site", "output directory")
+	title := fs.String("title", "", "title of the index page (default from the date of the logs)")
+	chartList := fs.String("charts", strings.Join(chartNames(), ","), "comma separated charts to draw on the encounter pages")
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	names, err := selectCharts(*chartList)
+	if err != nil {
+		return err
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{inputPath(fs)}
+	}
+
+	list := []StoredEncounter{}
+	seen := map[string]bool{}
+	for _, path := range paths {
+		result, err := parseFile(path, ParserOptions{})
+		if err != nil {
+			return err
+		}
+		for _, enc := range segmentEncounters(result.Entries) {
+			record := storedEncounter(path, enc)
+			// the same pull logged by two raiders
+			if seen[record.ID] {
+				continue
+			}
+			seen[record.ID] = true
+			page := encounterPage(record.ID, enc)
+			page.Static = true
+			for _, name := range names {
+				svg, err := renderChart(name, enc)
+				if err != nil {
+					return fmt.Errorf("chart %v of %v: %w", name, record.ID, err)
+				}
+				page.Charts = append(page.Charts, svg)
+			}
+			if err := writePage(filepath.Join(*out, "encounters", record.ID+".html"), encounterHTML, page); err != nil {
+				return err
+			}
+			list = append(list, record)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Start.Before(list[j].Start) })
+
+	index := EncountersPage{Title: *title, Encounters: list, Static: true}
+	if index.Title == "" {
+		index.Title = "Encounters"
+		if len(list) > 0 {
+			index.Title = "Raid night of " + list[0].Start.Format("01/02")
+		}
+	}
+	if err := writePage(filepath.Join(*out, "index.html"), encountersHTML, index); err != nil {
+		return err
+	}
+	fmt.Printf("published %d encounters to %v\n", len(list), *out)
+	return nil
+}
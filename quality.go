@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+)
+
+const (
+	// number of unknown line shapes listed in the quality report
+	qualityShapes = 20
+)
+
+// Exit codes of -max-error-rate for scripts gating on parse quality. 1 is
+// any other failure and 2 a usage error, as for every command.
+const (
+	exitClean         = 0
+	exitWarnings      = 3
+	exitTooManyErrors = 4
+)
+
+// QualityReport is the machine readable summary of how well a file parsed,
+// meant to be attached to issues about missing parsers.
+type QualityReport struct {
+	File           string         `json:"file"`
+	ParserVersion  int            `json:"parser_version"`
+	PatternVersion string         `json:"pattern_version"`
+	GameVersion    int            `json:"game_version,omitempty"`
+	Locale         string         `json:"locale"`
+	Warnings       []string       `json:"warnings,omitempty"`
+	LoggingGaps    []LoggingGap   `json:"logging_gaps,omitempty"`
+	Lines          int            `json:"lines"`
+	ErrorRate      float64        `json:"error_rate"`
+	Parsed         map[string]int `json:"parsed"`
+	Unparsed       int            `json:"unparsed"`
+	Partial        int            `json:"partial"`
+	Chat           map[string]int `json:"chat,omitempty"`
+	UnknownShapes  []ShapeCount   `json:"unknown_shapes,omitempty"`
+	Timestamps     TimestampStats `json:"timestamps"`
+	Anomalies      []Anomaly      `json:"anomalies,omitempty"`
+}
+
+// ShapeCount is a cluster of unparsed lines sharing the same shape.
+type ShapeCount struct {
+	Shape   string `json:"shape"`
+	Count   int    `json:"count"`
+	Example string `json:"example"`
+}
+
+// TimestampStats are the anomalies found while normalizing.
+type TimestampStats struct {
+	Duplicates    int      `json:"duplicates"`
+	Reordered     int      `json:"reordered"`
+	BackwardJumps []string `json:"backward_jumps,omitempty"`
+}
+
+var (
+	shapeTimestamp = regexp.MustCompile(`^\[?\d{2}\/\d{2}\s+\d{2}:\d{2}:\d{2}\s*(?:AM|PM)?\]? `)
+	shapeNumber    = regexp.MustCompile(`[\d,]*\d`)
+	shapeName      = regexp.MustCompile(`\b\p{Lu}[\p{L}'-]*(?: \p{Lu}[\p{L}'-]*)*`)
+)
+
+// lineShape reduces a line to its message template by dropping the timestamp
+// and replacing numbers and capitalized names.
+func lineShape(line string) string {
+	shape := shapeTimestamp.ReplaceAllString(line, "")
+	shape = shapeNumber.ReplaceAllString(shape, "#")
+	return shapeName.ReplaceAllString(shape, "Name")
+}
+
+func qualityReport(path string, result *ParseResult) QualityReport {
+	report := QualityReport{
+		File:           path,
+		ParserVersion:  parserVersion,
+		PatternVersion: patternVersion,
+		GameVersion:    result.Profile.GameVersion,
+		Locale:         result.Profile.Locale,
+		Warnings:       result.Profile.Warnings,
+		LoggingGaps:    result.LoggingGaps,
+		Lines:          result.Lines,
+		ErrorRate:      result.ErrorRate(),
+		Parsed:         map[string]int{},
+		Unparsed:       result.Unparsed,
+		Partial:        result.Partial,
+		Chat:           result.Noise,
+		Timestamps: TimestampStats{
+			Duplicates:    result.Normalized.Duplicates,
+			Reordered:     result.Normalized.Reordered,
+			BackwardJumps: result.Normalized.BackwardJumps,
+		},
+	}
+	for _, entry := range result.Entries {
+		report.Parsed[entry.etype.String()]++
+	}
+	report.Anomalies = append(report.Anomalies, result.Anomalies...)
+	report.Anomalies = append(report.Anomalies, encounterAnomalies(segmentEncounters(result.Entries))...)
+	for _, shape := range result.Shapes {
+		report.UnknownShapes = append(report.UnknownShapes, *shape)
+	}
+	sort.Slice(report.UnknownShapes, func(i, j int) bool {
+		return report.UnknownShapes[i].Count > report.UnknownShapes[j].Count
+	})
+	if len(report.UnknownShapes) > qualityShapes {
+		report.UnknownShapes = report.UnknownShapes[:qualityShapes]
+	}
+	return report
+}
+
+func writeQualityReport(path string, report QualityReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ErrorRate is the share of the lines other than chat that produced no
+// entry.
+func (r *ParseResult) ErrorRate() float64 {
+	lines := r.Lines
+	for _, n := range r.Noise {
+		lines -= n
+	}
+	if lines <= 0 {
+		return 0
+	}
+	return float64(r.Errors()) / float64(lines)
+}
+
+// parseOutcome grades a parse for scripts: too many errors above maxRate,
+// warnings for any failed line, logging gap or sniffing warning, and clean
+// otherwise. reason explains anything but clean.
+func parseOutcome(result *ParseResult, maxRate float64) (code int, reason string) {
+	rate := result.ErrorRate()
+	switch {
+	case rate > maxRate:
+		return exitTooManyErrors, fmt.Sprintf("%.2f%% of the lines did not parse, more than %.2f%%", 100*rate, 100*maxRate)
+	case result.Errors() > 0:
+		return exitWarnings, fmt.Sprintf("%d lines did not parse", result.Errors())
+	case len(result.LoggingGaps) > 0:
+		return exitWarnings, fmt.Sprintf("no %v lines", result.LoggingGaps[0].Channel)
+	case len(result.Profile.Warnings) > 0:
+		return exitWarnings, result.Profile.Warnings[0]
+	}
+	return exitClean, ""
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServerConfig protects a public share server.
+type ServerConfig struct {
+	// RateLimit is requests per second per client, zero disables it.
+	RateLimit float64 `json:"rate_limit,omitempty"`
+	// Burst is how many requests a client may make at once, default 10.
+	Burst int `json:"burst,omitempty"`
+	// MaxUploadBytes caps the size of uploaded logs, default 64 MiB.
+	MaxUploadBytes int64 `json:"max_upload_bytes,omitempty"`
+	// TrustProxy takes the client address from X-Forwarded-For, only enable
+	// it behind a reverse proxy that sets the header.
+	TrustProxy bool `json:"trust_proxy,omitempty"`
+	// Workspaces is the guild registry file, next to the config by default.
+	Workspaces string `json:"workspaces,omitempty"`
+}
+
+const (
+	defaultBurst          = 10
+	defaultMaxUploadBytes = 64 << 20
+)
+
+func (c ServerConfig) burst() int {
+	if c.Burst > 0 {
+		return c.Burst
+	}
+	return defaultBurst
+}
+
+func (c ServerConfig) maxUploadBytes() int64 {
+	if c.MaxUploadBytes > 0 {
+		return c.MaxUploadBytes
+	}
+	return defaultMaxUploadBytes
+}
+
+// rateLimiter is a token bucket per client address.
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: float64(burst), buckets: map[string]*tokenBucket{}}
+}
+
+// allow takes a token from the client's bucket. When it is empty it returns
+// false and how long until the next token.
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[client]
+	if !ok {
+		// forget clients whose buckets are full again
+		if len(l.buckets) > 10000 {
+			for key, old := range l.buckets {
+				if now.Sub(old.last).Seconds()*l.rate >= l.burst {
+					delete(l.buckets, key)
+				}
+			}
+		}
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// clientAddr is the address requests are limited by.
+func clientAddr(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limitRequests answers 429 to clients over the rate limit.
+func limitRequests(cfg ServerConfig, next http.Handler) http.Handler {
+	if cfg.RateLimit <= 0 {
+		return next
+	}
+	limiter := newRateLimiter(cfg.RateLimit, cfg.burst())
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := limiter.allow(clientAddr(r, cfg.TrustProxy), time.Now())
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
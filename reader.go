@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+const (
+	// logs from this size on are parsed in chunks on every CPU. Parsing,
+	// not reading, takes the time, see bench-read.
+	parallelMinBytes = 4 << 20
+	// smallest chunk worth a goroutine
+	minChunkBytes = 1 << 20
+)
+
+// readModes are the ways of reading a log, set with -read.
+var readModes = []string{"auto", "bufio", "mmap", "parallel"}
+
+// readMode is how logs are read: bufio reads the file through a buffer,
+// mmap maps it into memory and parallel maps it and parses chunks of it on
+// every CPU. auto picks one by size, see chooseReadMode.
+var readMode = "auto"
+
+// chooseReadMode resolves auto for a log of size bytes. Mapping and chunks
+// need the log as UTF-8, others are converted while reading. Mapping alone
+// is no faster than bufio, auto leaves it out.
+func chooseReadMode(mode string, size int64, utf8 bool) string {
+	if !utf8 {
+		return "bufio"
+	}
+	if mode != "auto" {
+		return mode
+	}
+	if size >= parallelMinBytes && runtime.NumCPU() > 1 {
+		return "parallel"
+	}
+	return "bufio"
+}
+
+// logIsUTF8 reports whether a log can be parsed as is, and the length of its
+// byte order mark.
+func logIsUTF8(path string) (bool, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, 0, err
+	}
+	defer file.Close()
+	head := make([]byte, charsetSniffBytes)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, 0, err
+	}
+	charset, bom := detectCharset(head[:n])
+	if logCharset != "auto" {
+		charset = strings.ToLower(logCharset)
+	}
+	return charset == "utf-8", bom, nil
+}
+
+// readAndParse parses every line of a log in the readMode that suits it.
+func readAndParse(path string, opts ParserOptions) (*ParseResult, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening file: %w", err)
+	}
+	utf8, bom, err := logIsUTF8(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening file: %w", err)
+	}
+	mode := chooseReadMode(readMode, info.Size(), utf8)
+	if mode == "bufio" {
+		file, err := openLog(path)
+		if err != nil {
+			return nil, fmt.Errorf("opening file: %w", err)
+		}
+		defer file.Close()
+		return parseSequential(file, opts)
+	}
+	data, unmap, err := mapFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening file: %w", err)
+	}
+	defer unmap()
+	data = data[min(bom, len(data)):]
+	if mode == "mmap" {
+		return parseSequential(bytes.NewReader(data), opts)
+	}
+	return parseChunks(data, runtime.NumCPU(), opts)
+}
+
+// parseSequential parses the lines of r one after another.
+func parseSequential(r io.Reader, opts ParserOptions) (*ParseResult, error) {
+	result := newParseResult()
+	scanner := newLineScanner(r)
+	for scanner.Scan() {
+		if err := result.addLine(scanner.Text(), opts); err != nil {
+			return nil, fmt.Errorf("line %d: %w", result.Lines, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+	return result, nil
+}
+
+// splitChunks cuts data into about n parts at line ends.
+func splitChunks(data []byte, n int) [][]byte {
+	size := max(len(data)/max(n, 1), minChunkBytes)
+	chunks := [][]byte{}
+	for len(data) > 0 {
+		end := min(size, len(data))
+		if i := bytes.IndexByte(data[end:], '\n'); i >= 0 {
+			end += i + 1
+		} else {
+			end = len(data)
+		}
+		chunks = append(chunks, data[:end])
+		data = data[end:]
+	}
+	return chunks
+}
+
+// parseChunk parses the lines of a chunk the way lineScanner splits them.
+// On an error it returns how many lines of the chunk were read.
+func parseChunk(chunk []byte, opts ParserOptions) (*ParseResult, error) {
+	result := newParseResult()
+	for len(chunk) > 0 {
+		line := chunk
+		if i := bytes.IndexByte(chunk, '\n'); i >= 0 {
+			line, chunk = chunk[:i], chunk[i+1:]
+		} else {
+			chunk = nil
+		}
+		if len(line) > maxLineBytes {
+			result.Lines++
+			return result, fmt.Errorf("longer than %d bytes", maxLineBytes)
+		}
+		if err := result.addLine(cleanLine(string(line)), opts); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// parseChunks parses data in chunks on workers goroutines and merges the
+// results in order. The parser only reads globals, so chunks of one log
+// parse safely side by side.
+func parseChunks(data []byte, workers int, opts ParserOptions) (*ParseResult, error) {
+	chunks := splitChunks(data, workers)
+	results := make([]*ParseResult, len(chunks))
+	errs := make([]error, len(chunks))
+	wg := sync.WaitGroup{}
+	limit := make(chan struct{}, workers)
+	for i, chunk := range chunks {
+		wg.Add(1)
+		limit <- struct{}{}
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = parseChunk(chunk, opts)
+			<-limit
+		}()
+	}
+	wg.Wait()
+	result := newParseResult()
+	for i, part := range results {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("line %d: %w", result.Lines+part.Lines, errs[i])
+		}
+		result.merge(part, opts)
+	}
+	return result, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// benchLogBytes is the size of the log the read benchmarks parse, past
+// parallelMinBytes so auto would pick parallel for it. bench-read times
+// larger logs.
+const benchLogBytes = parallelMinBytes + 1<<20
+
+// syntheticLog writes a log of size bytes from test/input.txt and applies
+// its profile.
+func syntheticLog(tb testing.TB, size int64) string {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), "combat.txt")
+	if err := writeSyntheticLog("test/input.txt", path, size); err != nil {
+		tb.Fatal(err)
+	}
+	profile, err := sniffLog(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err := profile.apply(); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+// withReadMode sets readMode for the rest of a test or benchmark.
+func withReadMode(tb testing.TB, mode string) {
+	previous := readMode
+	readMode = mode
+	tb.Cleanup(func() { readMode = previous })
+}
+
+func benchmarkRead(b *testing.B, mode string) {
+	path := syntheticLog(b, benchLogBytes)
+	withReadMode(b, mode)
+	b.SetBytes(benchLogBytes)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if _, err := readAndParse(path, ParserOptions{Discard: true}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadBufio(b *testing.B)    { benchmarkRead(b, "bufio") }
+func BenchmarkReadMmap(b *testing.B)     { benchmarkRead(b, "mmap") }
+func BenchmarkReadParallel(b *testing.B) { benchmarkRead(b, "parallel") }
+
+func TestReadModesAgree(t *testing.T) {
+	path := syntheticLog(t, 2*minChunkBytes)
+	var want *ParseResult
+	for _, mode := range readModes[1:] {
+		withReadMode(t, mode)
+		result, err := readAndParse(path, ParserOptions{Discard: true})
+		if err != nil {
+			t.Fatalf("%v: %v", mode, err)
+		}
+		if want == nil {
+			want = result
+			continue
+		}
+		if result.Lines != want.Lines || result.Unparsed != want.Unparsed || result.Partial != want.Partial {
+			t.Errorf("%v read %d lines, %d unparsed and %d partial, bufio %d, %d and %d", mode,
+				result.Lines, result.Unparsed, result.Partial, want.Lines, want.Unparsed, want.Partial)
+		}
+	}
+}
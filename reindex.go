@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+func init() {
+	commands["reindex"] = runReindex
+}
+
+// rebuildEncounter rebuilds a stored encounter from its raw lines with the
+// profile it was parsed with, applied through the gate. Records without a
+// profile parse with the applied one.
+func rebuildEncounter(gate *profileGate, record StoredEncounter, lines []string) (*Encounter, error) {
+	if record.Profile != nil {
+		if err := gate.enter(*record.Profile); err != nil {
+			return nil, err
+		}
+	} else {
+		gate.hold()
+	}
+	defer gate.leave()
+	return encounterFromLines(lines, record.Character)
+}
+
+// encounterFromLines rebuilds an encounter from its raw lines with the
+// patterns applied. The lines are one encounter already, so they are not
+// segmented again, but their actors are identified like segmentEncounters
+// does, with the character the encounter was played on.
+func encounterFromLines(lines []string, character string) (*Encounter, error) {
+	entries := []*LogEntry{}
+	for _, line := range lines {
+		entry, err := parseLogLine(line)
+		if err != nil || !entry.etype.isCombat() {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	entries = runEntryHooks(entries, nil)
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no combat entries in %d lines", len(lines))
+	}
+	enc := &Encounter{Start: entries[0].Timestamp, End: entries[len(entries)-1].Timestamp, Entries: entries, Character: character}
+	identifyActors(enc, "")
+	return enc, nil
+}
+
+// reindexStore recomputes the stats of every encounter that still has its
+// raw lines. Pruned encounters keep the stats they have.
+func reindexStore(store Store) (updated, skipped int, err error) {
+	list, err := store.ListEncounters()
+	if err != nil {
+		return 0, 0, err
+	}
+	gate := newProfileGate()
+	err = storeBatch(store, func() error {
+		for _, record := range list {
+			if record.RawPruned {
+				skipped++
+				continue
+			}
+			lines, err := store.GetTimeline(record.ID)
+			if err != nil {
+				return err
+			}
+			enc, err := rebuildEncounter(gate, record, lines)
+			if err != nil {
+				return fmt.Errorf("%v: %w", record.ID, err)
+			}
+			record.Stats = actorStats(enc)
+			record.Classes = playerClasses(enc)
+			record.NormalHits = normalHitMedians(enc)
+			record.Boss = encounterBoss(enc)
+			if record.Hash == "" {
+				// records stored without a profile may not rebuild exactly, so
+				// only fill in hashes that are missing
+				record.Hash = contentHash(record.Guild, enc)
+			}
+			record.Duration = enc.Duration()
+			record.ParserVersion = parserVersion
+			record.PatternVersion = patternVersion
+			if err := store.UpdateRecord(record); err != nil {
+				return err
+			}
+			updated++
+		}
+		return nil
+	})
+	return updated, skipped, err
+}
+
+// runReindex recomputes derived stats from the stored raw lines, for after
+// an upgrade changed how they are computed.
+func runReindex(args []string) error {
+	fs := flag.NewFlagSet("reindex", flag.ExitOnError)
+	setup := commonFlags(fs)
+	open := storeFlags(fs)
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	store, err := open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	updated, skipped, err := reindexStore(store)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("reindexed %d encounters, %d without raw lines kept their stats\n", updated, skipped)
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReindexStore(t *testing.T) {
+	store, err := openFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	lines := []string{
+		"[07/08 05:35:33 PM] Starlaf scored a hit with Trample on Burkhad for 22,754 Beleriand damage to Morale.",
+		"[07/08 05:35:35 PM] Huya applied a heal to Starlaf restoring 2,508 points to Morale.",
+		"[07/08 05:35:37 PM] Starlaf scored a hit with Trample on Burkhad for 31,601 Beleriand damage to Morale.",
+	}
+	// stats from an older parser, and an encounter pruned since
+	stale := StoredEncounter{ID: "0708-173533-012345678", Stored: time.Now(), Stats: []*ActorStats{{Actor: "Starlaf", Damage: 1}}}
+	pruned := StoredEncounter{ID: "0708-180000-012345678", Stored: time.Now(), Stats: stale.Stats, RawPruned: true}
+	for _, record := range []StoredEncounter{stale, pruned} {
+		if _, err := store.PutRecord(record, lines); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	updated, skipped, err := reindexStore(store)
+	if err != nil || updated != 1 || skipped != 1 {
+		t.Fatalf("got %d updated, %d skipped, %v, want 1 and 1", updated, skipped, err)
+	}
+	list, err := store.ListEncounters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	damage := map[string]int{}
+	for _, record := range list {
+		for _, s := range record.Stats {
+			damage[record.ID+" "+s.Actor] = s.Damage
+		}
+		if record.ID == stale.ID && record.Duration != 4*time.Second {
+			t.Errorf("got duration %v, want 4s", record.Duration)
+		}
+	}
+	if got := damage[stale.ID+" Starlaf"]; got != 54355 {
+		t.Errorf("got %d damage by Starlaf after reindexing, want 54355", got)
+	}
+	if got := damage[pruned.ID+" Starlaf"]; got != 1 {
+		t.Errorf("got %d damage by Starlaf in a pruned encounter, want the 1 it had", got)
+	}
+}
+
+func TestReindexKeepsStats(t *testing.T) {
+	defer func(cfg Config, dir string) { config, parseCacheDir = cfg, dir }(config, parseCacheDir)
+	parseCacheDir = ""
+	t.Cleanup(func() { LogProfile{Locale: defaultLocale}.apply() })
+	tests := []struct {
+		name       string
+		identities map[string]string
+		profile    LogProfile
+		lines      []string
+	}{
+		{"identities", map[string]string{"Starlaf": "Star"}, LogProfile{Locale: defaultLocale}, []string{
+			"[07/08 05:35:08 PM] Starlaf applied a benefit with Man-form on Starlaf.",
+			"[07/08 05:35:10 PM] Starlaf scored a hit with Trample on Burkhad for 22,754 Beleriand damage to Morale.",
+			"[07/08 05:35:11 PM] Burkhad incapacitated you.",
+		}},
+		{"german numbers and clock", nil, LogProfile{Locale: "de", Clock24: true}, []string{
+			"[07/08 17:35:08] Starlaf applied a benefit with Man-form on Starlaf.",
+			"[07/08 17:35:10] Starlaf scored a hit with Trample on Burkhad for 22.754 Beleriand damage to Morale.",
+			"[07/08 17:35:11] Huya applied a heal to Starlaf restoring 2.508 points to Morale.",
+		}},
+	}
+	for _, tt := range tests {
+		config.Identities = tt.identities
+		path := filepath.Join(t.TempDir(), "combat.txt")
+		if err := os.WriteFile(path, []byte(strings.Join(tt.lines, "\n")+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := tt.profile.apply(); err != nil {
+			t.Fatal(err)
+		}
+		result, err := parseProfiled(path, tt.profile, ParserOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		store, err := openFileStore(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, enc := range segmentEncounters(result.Entries) {
+			if _, err := store.PutEntries(path, enc); err != nil {
+				t.Fatal(err)
+			}
+		}
+		before, _ := store.ListEncounters()
+
+		// reindexing runs with whatever profile is applied
+		if err := (LogProfile{Locale: defaultLocale}).apply(); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := reindexStore(store); err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+		after, _ := store.ListEncounters()
+		if len(before) != 1 || len(after) != 1 || !reflect.DeepEqual(before[0].Stats, after[0].Stats) {
+			t.Errorf("%v: reindexing changed the stats from %v to %v", tt.name, statsOf(before), statsOf(after))
+		}
+	}
+}
+
+// statsOf formats the stats of records for test failures.
+func statsOf(records []StoredEncounter) []string {
+	stats := []string{}
+	for _, record := range records {
+		for _, s := range record.Stats {
+			stats = append(stats, fmt.Sprintf("%+v", *s))
+		}
+	}
+	return stats
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	commands["replay"] = runReplay
+}
+
+// parseSpeed reads a replay speed like "2x", "0.5x" or "4".
+func parseSpeed(s string) (float64, error) {
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(s), "x"), 64)
+	if err != nil || speed <= 0 {
+		return 0, fmt.Errorf("invalid speed %q, want e.g. 2x", s)
+	}
+	return speed, nil
+}
+
+// replayEntries sends entries keeping their original spacing divided by speed.
+func replayEntries(entries []*LogEntry, speed float64, out chan<- *LogEntry) {
+	defer close(out)
+	var last time.Time
+	for _, entry := range entries {
+		if entry.Timestamp.IsZero() {
+			continue
+		}
+		if !last.IsZero() {
+			if gap := entry.Timestamp.Sub(last); gap > 0 {
+				time.Sleep(time.Duration(float64(gap) / speed))
+			}
+		}
+		last = entry.Timestamp
+		out <- entry
+	}
+}
+
+// runReplay plays a recorded log through the live meter in real time, for
+// demos and rewatching fights without the game running.
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	setup := commonFlags(fs)
+	speedFlag := fs.String("speed", "1x", "playback speed, e.g. 2x")
+	live := liveFlags(fs)
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	speed, err := parseSpeed(*speedFlag)
+	if err != nil {
+		return err
+	}
+	result, err := parseFile(inputPath(fs), ParserOptions{})
+	if err != nil {
+		return err
+	}
+	source := make(chan *LogEntry)
+	go replayEntries(result.Entries, speed, source)
+	ctx, stop := interruptContext()
+	defer stop()
+	meter := &LiveMeter{}
+	if err := live.start(meter); err != nil {
+		return err
+	}
+	runLive(ctx, meter, source, os.Stdout)
+	live.stop(meter, os.Stdout)
+	return nil
+}
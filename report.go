@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html/template"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	commands["report"] = runReport
+}
+
+// ReportEncounter is one encounter of the HTML report with its rendered charts.
+type ReportEncounter struct {
+	EncounterSnapshot
+	Number int
+	Charts []template.HTML
+	// Ranks are the players' percentiles against the baselines, keyed by
+	// actor, on boss kills with one.
+	Ranks map[string]*PercentileRank
+	// Enrage is how the boss kill or wipe went against the enrage timer.
+	Enrage *EnrageStatus
+	// Mechanics are the players' failed mechanics, nil without rules for
+	// the encounter.
+	Mechanics *MechanicBoard
+	// GearHints are the players whose normal hits are low for their class.
+	GearHints []GearHint
+}
+
+var reportHTML = template.Must(template.New("report").Funcs(themeFuncs).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.File}}</title>
+<style>body { background: {{theme.Background}}; color: {{theme.Foreground}}; }</style></head>
+<body>
+<h1>{{.File}}</h1>
+{{with .Night}}<h2>Raid night</h2>
+<p>{{.Session.Encounters}} encounters over {{.Session.Span}}: {{.Killed}} bosses killed, {{.Wipes}} wipes.
+{{.BossTime}} in boss pulls, {{.TrashTime}} on trash, {{.Session.Idle}} between fights.</p>
+{{if .Bosses}}<table>
+<tr><th>Boss</th><th>Pulls</th><th>Outcome</th></tr>
+{{range .Bosses}}<tr><td>{{.Boss}}</td><td>{{len .Attempts}}</td><td>{{with .Best}}{{if .Killed}}killed on pull {{.Number}} in {{.Duration}}{{else}}best pull {{.Number}} at {{printf "%.0f" .ProgressPercent}}%{{end}}{{end}}</td></tr>
+{{end}}</table>
+{{end}}<h3>Players</h3>
+<table>
+<tr><th>Player</th><th>Damage</th><th>Boss damage</th><th>Healing</th><th>Deaths</th><th>Encounters</th></tr>
+{{range .Players}}<tr><td>{{.Actor}}</td><td>{{.Damage}}</td><td>{{.BossDamage}}</td><td>{{.Healing}}</td><td>{{.Deaths}}</td><td>{{.Encounters}}</td></tr>
+{{end}}</table>
+{{with .Mechanics}}<h3>Mechanics</h3>
+{{template "mechanics" .}}{{end}}{{if .Deaths}}<h3>Death leaders</h3>
+<ol>{{range .Deaths}}<li>{{.Actor}}: {{.Deaths}}</li>{{end}}</ol>
+{{end}}<h3>Breaks between pulls</h3>
+<p>Median {{.MedianGap}}, longest {{.LongestGap}}.</p>
+<table>
+{{range .Gaps}}<tr><td>{{if .Max}}up to {{.Max}}{{else}}longer{{end}}</td><td>{{.Count}}</td><td><div style="background: {{theme.Muted}}; height: 10px; width: {{.Percent}}px"></div></td></tr>
+{{end}}</table>
+{{end}}{{range .Encounters}}<h2>Encounter {{.Number}} at {{.Start.Format "15:04:05"}} ({{.Duration}}){{if .Label}} {{.Label}}{{end}}</h2>
+{{if .Build}}<p>Build: {{.Build}}</p>
+{{end}}{{$ranks := .Ranks}}<table>
+<tr><th>Actor</th><th>Kind</th><th>Damage</th><th>Boss damage</th><th>Healing</th><th>Taken</th><th>Deaths</th>{{if $ranks}}<th>Percentile</th>{{end}}</tr>
+{{range .Actors}}<tr><td>{{.Actor}}</td><td>{{.Kind}}</td><td>{{.Damage}}</td><td>{{.BossDamage}}</td><td>{{.Healing}}</td><td>{{.DamageTaken}}</td><td>{{.Deaths}}</td>{{if $ranks}}<td>{{with index $ranks .Actor}}<span style="color: {{.Color}}" title="{{printf "%.0f" .Value}} {{.Metric}} against {{.Samples}} parses">{{.Percentile}}</span>{{end}}</td>{{end}}</tr>
+{{end}}</table>
+{{with .Enrage}}<p>{{.}}</p>
+{{end}}{{with .Mechanics}}<h3>Failed mechanics</h3>
+{{template "mechanics" .}}{{end}}{{range .GearHints}}<p style="color: {{theme.Muted}}">Hint: {{.}}</p>
+{{end}}{{range .Charts}}{{.}}
+{{end}}{{end}}</body></html>
+{{define "mechanics"}}{{if .Players}}<table>
+<tr><th>Player</th>{{range .Mechanics}}<th>{{.}}</th>{{end}}<th>Total</th></tr>
+{{range .Players}}<tr><td>{{.Actor}}</td>{{range .Failures}}<td>{{.}}</td>{{end}}<td>{{.Total}}</td></tr>
+{{end}}</table>
+{{else}}<p>No failed mechanics.</p>
+{{end}}{{end}}`))
+
+// renderChart renders a chart into a string for inlining into HTML.
+func renderChart(name string, enc *Encounter) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := charts[name](&buf, enc); err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
+
+// writeReportHTML writes every encounter's meter and charts into one page,
+// after the raid night overview with night.
+func writeReportHTML(w io.Writer, file string, encounters []*Encounter, names []string, night bool) error {
+	page := struct {
+		File       string
+		Night      *RaidNight
+		Encounters []ReportEncounter
+	}{File: filepath.Base(file)}
+	if night {
+		overview := summarizeRaidNight(encounters)
+		page.Night = &overview
+	}
+	for i, enc := range encounters {
+		re := ReportEncounter{EncounterSnapshot: snapshotOf(enc), Number: i + 1}
+		re.Ranks = encounterRanks(enc, re.Actors)
+		re.Enrage = encounterEnrage(enc)
+		re.Mechanics = mechanicFailures(enc, config.Mechanics)
+		for _, hint := range gearHints(enc) {
+			if hint.Low {
+				re.GearHints = append(re.GearHints, hint)
+			}
+		}
+		for _, name := range names {
+			svg, err := renderChart(name, enc)
+			if err != nil {
+				return fmt.Errorf("chart %v of encounter %d: %w", name, i+1, err)
+			}
+			re.Charts = append(re.Charts, svg)
+		}
+		page.Encounters = append(page.Encounters, re)
+	}
+	return reportHTML.Execute(w, page)
+}
+
+// writeReportImages writes one SVG or PNG file per encounter and chart into
+// dir.
+func writeReportImages(dir, format string, encounters []*Encounter, names []string, scale float64, background color.Color) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for i, enc := range encounters {
+		for _, name := range names {
+			path := filepath.Join(dir, fmt.Sprintf("encounter-%02d-%v.%v", i+1, name, format))
+			file, err := os.Create(path)
+			if err != nil {
+				return err
+			}
+			if format == "png" {
+				err = writeChartPNG(file, charts[name], enc, scale, background)
+			} else {
+				err = charts[name](file, enc)
+			}
+			if cerr := file.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return fmt.Errorf("writing %v: %w", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// runReport renders the encounters of a log as an HTML page with charts, or
+// as a directory of SVG or PNG files.
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	setup := commonFlags(fs)
+	out := fs.String("o", "report.html", "output file for html, output directory for svg and png")
+	format := fs.String("format", "html", "html, svg or png")
+	scale := fs.Float64("scale", 1, "size of png images relative to the svg charts")
+	background := fs.String("background", "", "background color of png images (default from the theme)")
+	chartList := fs.String("charts", strings.Join(chartNames(), ","), "comma separated charts to draw")
+	night := fs.Bool("night", false, "start the html report with a raid night overview of all encounters")
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	names, err := selectCharts(*chartList)
+	if err != nil {
+		return err
+	}
+	path := inputPath(fs)
+	result, err := parseFile(path, ParserOptions{})
+	if err != nil {
+		return err
+	}
+	encounters := segmentEncounters(result.Entries)
+
+	switch *format {
+	case "html":
+		file, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return writeReportHTML(file, path, encounters, names, *night)
+	case "svg", "png":
+		if *background == "" {
+			*background = chartTheme.Background
+		}
+		bg := svgAttrMap{"fill": *background}.color("fill", 1)
+		return writeReportImages(*out, *format, encounters, names, *scale, bg)
+	}
+	return fmt.Errorf("unknown report format %q, want html, svg or png", *format)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+func init() {
+	analyses["schools"] = printSchools
+}
+
+// valueTypeNames maps the lower-cased spellings of a damage or heal type to
+// its canonical name. Older clients and other tools write some of them
+// differently.
+var valueTypeNames = map[string]string{
+	"common":             "Common",
+	"physical":           "Common",
+	"fire":               "Fire",
+	"frost":              "Frost",
+	"cold":               "Frost",
+	"lightning":          "Lightning",
+	"acid":               "Acid",
+	"shadow":             "Shadow",
+	"light":              "Light",
+	"beleriand":          "Beleriand",
+	"westernesse":        "Westernesse",
+	"ancient dwarf":      "Ancient Dwarf-make",
+	"ancient dwarf-make": "Ancient Dwarf-make",
+	"dwarf-make":         "Ancient Dwarf-make",
+	"fell-wrought":       "Fell-wrought",
+	"fell wrought":       "Fell-wrought",
+	"orc-craft":          "Orc-craft",
+	"orc craft":          "Orc-craft",
+	"morale":             "Morale",
+	"power":              "Power",
+}
+
+// valueTypeSchools groups the damage types by the mitigation that applies to
+// them. The legendary weapon types are kept apart.
+var valueTypeSchools = map[string]string{
+	"Common":             "physical",
+	"Fell-wrought":       "physical",
+	"Orc-craft":          "physical",
+	"Fire":               "tactical",
+	"Frost":              "tactical",
+	"Lightning":          "tactical",
+	"Acid":               "tactical",
+	"Shadow":             "tactical",
+	"Light":              "tactical",
+	"Beleriand":          "legendary",
+	"Westernesse":        "legendary",
+	"Ancient Dwarf-make": "legendary",
+}
+
+// valueTypeNote is the breakdown some clients put in front of the type, like
+// "(289,198 from 70 Wrath) Beleriand".
+var valueTypeNote = regexp.MustCompile(`^\([^)]*\)\s*`)
+
+// canonicalValueType strips the notes, whitespace and punctuation the
+// patterns leave around a captured type and maps synonyms to one spelling.
+// Unknown types are kept as they are, only cleaned up.
+func canonicalValueType(raw string) string {
+	clean := valueTypeNote.ReplaceAllString(strings.TrimSpace(raw), "")
+	clean = strings.Join(strings.Fields(strings.Trim(clean, " .")), " ")
+	if name, ok := valueTypeNames[strings.ToLower(clean)]; ok {
+		return name
+	}
+	return clean
+}
+
+// enrichValueType normalizes an entry's ValueType and sets its School.
+func enrichValueType(entry *LogEntry) {
+	if entry.ValueType == "" {
+		return
+	}
+	entry.ValueType = canonicalValueType(entry.ValueType)
+	if entry.etype == DmgDealt {
+		entry.School = valueTypeSchools[entry.ValueType]
+	}
+}
+
+// enrichValueTypes runs enrichValueType over parsed entries.
+func enrichValueTypes(entries []*LogEntry) {
+	for _, entry := range entries {
+		enrichValueType(entry)
+	}
+}
+
+// printSchools breaks the damage down by school and type, separately for
+// damage dealt by players and damage taken by them.
+func printSchools(enc *Encounter) {
+	for _, side := range []struct {
+		title string
+		match func(e *LogEntry) bool
+	}{
+		{"damage dealt by type:", func(e *LogEntry) bool { return enc.kindOf(e.SourceID) != NPC }},
+		{"damage taken by type:", func(e *LogEntry) bool { return enc.kindOf(e.TargetID) != NPC }},
+	} {
+		byType := map[string]int{}
+		total := 0
+		for _, entry := range enc.Entries {
+			if entry.etype != DmgDealt || entry.Value == 0 || !side.match(entry) {
+				continue
+			}
+			byType[entry.ValueType] += entry.Value
+			total += entry.Value
+		}
+		if total == 0 {
+			continue
+		}
+		types := make([]string, 0, len(byType))
+		for t := range byType {
+			types = append(types, t)
+		}
+		sort.Slice(types, func(i, j int) bool { return byType[types[i]] > byType[types[j]] })
+		fmt.Println(side.title)
+		for _, t := range types {
+			school := valueTypeSchools[t]
+			if school == "" {
+				school = "unknown"
+			}
+			name := t
+			if name == "" {
+				name = "(untyped)"
+			}
+			fmt.Printf("  %-20v %-10v %10v %5.1f%%\n", name, school, byType[t], 100*float64(byType[t])/float64(total))
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// date format of search ranges
+	searchDateFormat = "2006-01-02"
+)
+
+func init() {
+	dbCommands["search"] = dbSearch
+}
+
+// EncounterQuery selects stored encounters. Zero fields match everything.
+type EncounterQuery struct {
+	Boss      string // part of the boss name
+	Character string // a player who took part
+	Label     string // part of the "### label:" comment
+	Outcome   string // "kill" or "wipe", only boss encounters have one
+	// From and To limit the day the encounter started, To included. Logs
+	// have no year, so only month and day count and a range may wrap
+	// around the new year.
+	From, To time.Time
+	// MinDPS is the least damage per second of Character, or of the best
+	// player when no character is given.
+	MinDPS float64
+}
+
+// parseEncounterQuery reads a query from URL parameters: boss, character,
+// label, outcome, from, to and min_dps.
+func parseEncounterQuery(values url.Values) (EncounterQuery, error) {
+	q := EncounterQuery{
+		Boss:      values.Get("boss"),
+		Character: values.Get("character"),
+		Label:     values.Get("label"),
+		Outcome:   values.Get("outcome"),
+	}
+	if q.Outcome != "" && q.Outcome != "kill" && q.Outcome != "wipe" {
+		return q, fmt.Errorf("outcome must be kill or wipe, not %q", q.Outcome)
+	}
+	for _, bound := range []struct {
+		name string
+		to   *time.Time
+	}{{"from", &q.From}, {"to", &q.To}} {
+		if v := values.Get(bound.name); v != "" {
+			day, err := time.Parse(searchDateFormat, v)
+			if err != nil {
+				return q, fmt.Errorf("%v must be a date like 2024-07-08: %w", bound.name, err)
+			}
+			*bound.to = day
+		}
+	}
+	if v := values.Get("min_dps"); v != "" {
+		dps, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return q, fmt.Errorf("min_dps must be a number: %w", err)
+		}
+		q.MinDPS = dps
+	}
+	return q, nil
+}
+
+// dayOfYear turns a time into a comparable month and day.
+func dayOfYear(t time.Time) int {
+	return int(t.Month())*100 + t.Day()
+}
+
+// inDays reports whether the record started within the query's days.
+func (q EncounterQuery) inDays(record StoredEncounter) bool {
+	day := dayOfYear(record.Start)
+	switch {
+	case q.From.IsZero() && q.To.IsZero():
+		return true
+	case q.To.IsZero():
+		return day >= dayOfYear(q.From)
+	case q.From.IsZero():
+		return day <= dayOfYear(q.To)
+	case dayOfYear(q.From) <= dayOfYear(q.To):
+		return day >= dayOfYear(q.From) && day <= dayOfYear(q.To)
+	}
+	return day >= dayOfYear(q.From) || day <= dayOfYear(q.To)
+}
+
+// matches reports whether a stored encounter fits the query.
+func (q EncounterQuery) matches(record StoredEncounter) bool {
+	if q.Boss != "" && !strings.Contains(strings.ToLower(record.Boss), strings.ToLower(q.Boss)) {
+		return false
+	}
+	if q.Label != "" && !strings.Contains(strings.ToLower(record.Label), strings.ToLower(q.Label)) {
+		return false
+	}
+	switch q.Outcome {
+	case "kill":
+		if record.Boss == "" || !bossKilled(record) {
+			return false
+		}
+	case "wipe":
+		if record.Boss == "" || bossKilled(record) {
+			return false
+		}
+	}
+	if !q.inDays(record) {
+		return false
+	}
+	if q.Character == "" && q.MinDPS == 0 {
+		return true
+	}
+	seconds := record.Duration.Seconds()
+	for _, s := range record.Stats {
+		if s.Kind != Player || q.Character != "" && !strings.EqualFold(s.Actor, q.Character) {
+			continue
+		}
+		if seconds > 0 && float64(s.Damage)/seconds >= q.MinDPS || q.MinDPS == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// searchEncounters returns the records matching the query, in store order.
+func searchEncounters(records []StoredEncounter, q EncounterQuery) []StoredEncounter {
+	found := []StoredEncounter{}
+	for _, record := range records {
+		if q.matches(record) {
+			found = append(found, record)
+		}
+	}
+	return found
+}
+
+// search serves the encounters of the guild in the URL that match the query
+// parameters, as JSON or with ?format=html as a page with a search form.
+func (s *shareServer) search(w http.ResponseWriter, r *http.Request) {
+	q, err := parseEncounterQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	all, err := s.store.ListEncounters()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	list := []StoredEncounter{}
+	for _, record := range all {
+		if record.Guild == r.PathValue("guild") {
+			list = append(list, record)
+		}
+	}
+	list = searchEncounters(list, q)
+	if r.URL.Query().Get("format") == "html" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		encountersHTML.Execute(w, encountersPage(r.URL.Query(), list))
+		return
+	}
+	writeJSON(w, list)
+}
+
+// dbSearch lists the stored encounters matching a query given as key=value
+// arguments, with the keys of the search API.
+func dbSearch(store Store, fs *flag.FlagSet) error {
+	values := url.Values{}
+	for _, arg := range fs.Args() {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return errors.New("usage: db search [boss=|character=|label=|outcome=kill|wipe|from=|to=|min_dps=]...")
+		}
+		values.Set(key, value)
+	}
+	q, err := parseEncounterQuery(values)
+	if err != nil {
+		return err
+	}
+	list, err := store.ListEncounters()
+	if err != nil {
+		return err
+	}
+	for _, e := range searchEncounters(list, q) {
+		fmt.Printf("%v %-24v %v %-8v %-20v %v\n", e.ID, e.File, e.Start.Format("01/02 15:04:05"), e.Duration, e.Boss, e.Label)
+	}
+	return nil
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+func init() {
+	commands["serve"] = runServe
+}
+
+// shareServer is the HTTP API of a shared encounter store.
+type shareServer struct {
+	store   Store
+	metrics *serverMetrics
+	limits  ServerConfig
+	guilds  *workspaces
+	live    *liveRelay
+	// gate keeps uploads of logs with different profiles from swapping
+	// the parser's patterns under each other
+	gate *profileGate
+	// clients are the overlays and live sessions, closed on shutdown
+	clients *wsClients
+	// baselines are built from the public encounters on request
+	baselines baselineCache
+}
+
+var (
+	// encounter IDs as made by encounterID
+	validEncounterID = regexp.MustCompile(`^\d{4}-\d{6}-[0-9a-f]{9}$`)
+	// upload file names, kept short and without paths
+	validFileName = regexp.MustCompile(`^[\w. -]{1,100}$`)
+)
+
+func (s *shareServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /encounters", s.listEncounters)
+	mux.HandleFunc("GET /encounters/{id}", s.getTimeline)
+	mux.HandleFunc("GET /encounters/{id}/targets", s.getTargetDamage)
+	mux.HandleFunc("GET /search", s.search)
+	mux.HandleFunc("POST /upload", s.upload)
+	mux.HandleFunc("GET /live", s.listLiveSessions)
+	mux.HandleFunc("GET /live/{session}", s.spectateLive)
+	mux.HandleFunc("GET /live/{session}/publish", s.publishLive)
+	mux.HandleFunc("GET /guilds/{guild}/encounters", s.guildOnly(roleMember, s.listEncounters))
+	mux.HandleFunc("GET /guilds/{guild}/encounters/{id}", s.guildOnly(roleMember, s.getTimeline))
+	mux.HandleFunc("GET /guilds/{guild}/encounters/{id}/targets", s.guildOnly(roleMember, s.getTargetDamage))
+	mux.HandleFunc("GET /guilds/{guild}/search", s.guildOnly(roleMember, s.search))
+	mux.HandleFunc("POST /guilds/{guild}/upload", s.guildOnly(roleUpload, s.upload))
+	mux.HandleFunc("GET /guilds/{guild}/attendance", s.guildOnly(roleMember, s.getAttendance))
+	mux.HandleFunc("GET /guilds/{guild}/live", s.guildOnly(roleMember, s.listLiveSessions))
+	mux.HandleFunc("GET /guilds/{guild}/live/{session}", s.guildOnly(roleMember, s.spectateLive))
+	mux.HandleFunc("GET /guilds/{guild}/live/{session}/publish", s.guildOnly(roleUpload, s.publishLive))
+	mux.HandleFunc("GET /guilds/{guild}/roster", s.guildOnly(roleMember, s.getRoster))
+	mux.HandleFunc("PUT /guilds/{guild}/roster", s.guildOnly(roleAdmin, s.putRoster))
+	mux.HandleFunc("GET /guilds/{guild}/tokens", s.guildOnly(roleAdmin, s.listTokens))
+	mux.HandleFunc("POST /guilds/{guild}/tokens", s.guildOnly(roleAdmin, s.issueToken))
+	mux.HandleFunc("DELETE /guilds/{guild}/tokens/{token}", s.guildOnly(roleAdmin, s.revokeToken))
+	mux.HandleFunc("GET /baselines", s.getBaselines)
+	mux.HandleFunc("GET /bundle", s.getBundleViewer)
+	mux.HandleFunc("GET /openapi.json", s.getOpenAPI)
+	mux.HandleFunc("GET /schemas/{name}", s.getSchema)
+	mux.HandleFunc("GET /healthz", s.metrics.healthz)
+	mux.HandleFunc("GET /readyz", s.metrics.readyz)
+	mux.HandleFunc("GET /metrics", s.metrics.metrics)
+	return limitRequests(s.limits, mux)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// guildOnly lets through requests whose bearer token grants at least a role
+// in the guild of the URL.
+func (s *shareServer) guildOnly(role string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "missing guild token", http.StatusUnauthorized)
+			return
+		}
+		if !s.guilds.allowed(r.PathValue("guild"), token, role) {
+			http.Error(w, fmt.Sprintf("token lacks the %v role in this guild", role), http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// listEncounters lists the encounters of the guild in the URL, or the public
+// ones outside of guild routes.
+func (s *shareServer) listEncounters(w http.ResponseWriter, r *http.Request) {
+	all, err := s.store.ListEncounters()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	list := []StoredEncounter{}
+	for _, record := range all {
+		if record.Guild == r.PathValue("guild") {
+			list = append(list, record)
+		}
+	}
+	for _, warning := range versionWarnings(list) {
+		w.Header().Add("Warning", fmt.Sprintf("299 - %q", warning))
+	}
+	if r.URL.Query().Get("format") == "html" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		encountersHTML.Execute(w, encountersPage(r.URL.Query(), list))
+		return
+	}
+	writeJSON(w, list)
+}
+
+func (s *shareServer) getTimeline(w http.ResponseWriter, r *http.Request) {
+	if !validEncounterID.MatchString(r.PathValue("id")) {
+		http.Error(w, "invalid encounter id", http.StatusBadRequest)
+		return
+	}
+	record, ok := s.recordIn(r.PathValue("id"), r.PathValue("guild"))
+	if !ok {
+		http.Error(w, fmt.Sprintf("no encounter %q", r.PathValue("id")), http.StatusNotFound)
+		return
+	}
+	lines, err := s.store.GetTimeline(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if r.URL.Query().Get("format") == "html" {
+		enc, err := rebuildEncounter(s.gate, record, lines)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		encounterHTML.Execute(w, encounterPage(r.PathValue("id"), enc))
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, strings.Join(lines, "\n"))
+}
+
+// recordIn returns the record of a stored encounter if it belongs to a
+// guild, "" being the public encounters.
+func (s *shareServer) recordIn(id, guild string) (StoredEncounter, bool) {
+	list, err := s.store.ListEncounters()
+	if err != nil {
+		return StoredEncounter{}, false
+	}
+	for _, record := range list {
+		if record.ID == id {
+			return record, record.Guild == guild
+		}
+	}
+	return StoredEncounter{}, false
+}
+
+// upload takes a log file as the request body and stores its encounters,
+// into the guild of the URL if there is one.
+func (s *shareServer) upload(w http.ResponseWriter, r *http.Request) {
+	s.metrics.inFlight.Add(1)
+	defer s.metrics.inFlight.Add(-1)
+	name := r.URL.Query().Get("file")
+	if name == "" {
+		name = "upload.txt"
+	}
+	if !validFileName.MatchString(name) {
+		http.Error(w, "invalid file name", http.StatusBadRequest)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "text/plain") &&
+		ct != "application/octet-stream" && ct != "application/x-www-form-urlencoded" {
+		http.Error(w, "upload the log as text/plain", http.StatusUnsupportedMediaType)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, s.limits.maxUploadBytes())
+	tmp, err := os.CreateTemp("", "upload-*.txt")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, r.Body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("upload larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.gate.hold()
+	profile, err := sniffLog(tmp.Name())
+	s.gate.leave()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.gate.enter(profile); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// storing records the pattern version, so it stays behind the gate
+	defer s.gate.leave()
+	// uploads skip the parse cache, their lines are only kept as long as
+	// the store's retention allows
+	result, err := parseProfiled(tmp.Name(), profile, ParserOptions{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(result.Entries) == 0 {
+		http.Error(w, fmt.Sprintf("no combat log lines in %d lines", result.Lines), http.StatusUnprocessableEntity)
+		return
+	}
+	guild := r.PathValue("guild")
+	slog.Info("upload", "file", name, "guild", guild, "lines", result.Lines, "errors", result.Errors())
+	earlier, err := s.store.ListEncounters()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	known := map[string]bool{}
+	for _, record := range earlier {
+		known[record.ID], known[record.Hash] = true, true
+	}
+	ids := []string{}
+	for _, enc := range segmentEncounters(result.Entries) {
+		record := guildRecord(guild, name, enc)
+		if guild == "" {
+			record = storedEncounter(name, enc)
+		}
+		id, err := s.store.PutRecord(record, rawLines(enc))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ids = append(ids, id)
+		if known[record.ID] || known[record.Hash] {
+			continue
+		}
+		notify(encounterEvent(guild, id, enc))
+		for _, event := range recordEvents(record, earlier) {
+			notify(event)
+		}
+		earlier = append(earlier, record)
+		known[record.ID], known[record.Hash] = true, true
+	}
+	s.metrics.recordUpload(result, len(ids))
+	writeJSON(w, ids)
+}
+
+func (s *shareServer) listTokens(w http.ResponseWriter, r *http.Request) {
+	type token struct {
+		ID      string    `json:"id"`
+		Role    string    `json:"role"`
+		Label   string    `json:"label,omitempty"`
+		Created time.Time `json:"created"`
+	}
+	tokens, _ := s.guilds.tokens(r.PathValue("guild"))
+	list := make([]token, 0, len(tokens))
+	for _, t := range tokens {
+		list = append(list, token{t.ID(), t.Role, t.Label, t.Created})
+	}
+	writeJSON(w, list)
+}
+
+// issueToken makes a token with the role and label of the query, the
+// response is the only time it is shown.
+func (s *shareServer) issueToken(w http.ResponseWriter, r *http.Request) {
+	role := r.URL.Query().Get("role")
+	if role == "" {
+		role = roleUpload
+	}
+	label := r.URL.Query().Get("label")
+	if len(label) > 100 {
+		http.Error(w, "label longer than 100 bytes", http.StatusBadRequest)
+		return
+	}
+	token, err := s.guilds.issue(r.PathValue("guild"), role, label)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]string{"token": token, "role": role})
+}
+
+func (s *shareServer) revokeToken(w http.ResponseWriter, r *http.Request) {
+	if err := s.guilds.revoke(r.PathValue("guild"), r.PathValue("token")); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runServe runs the share server: an HTTP API over the store that accepts
+// uploaded logs and enforces the retention policy.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	setup := commonFlags(fs)
+	open := storeFlags(fs)
+	addr := fs.String("http", ":8089", "address to listen on")
+	rate := fs.Float64("rate", 0, "requests per second per client (default from config, 0 is unlimited)")
+	guildsPath := fs.String("workspaces", "", "guild registry file (default from config)")
+	maxUpload := fs.Int64("max-upload", 0, "largest upload in bytes (default from config, else 64 MiB)")
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	if *rate > 0 {
+		config.Server.RateLimit = *rate
+	}
+	if *maxUpload > 0 {
+		config.Server.MaxUploadBytes = *maxUpload
+	}
+	if *guildsPath != "" {
+		config.Server.Workspaces = *guildsPath
+	}
+	guilds, err := openWorkspaces(config.Server.Workspaces)
+	if err != nil {
+		return err
+	}
+	store, err := open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ctx, stop := interruptContext()
+	defer stop()
+	done := make(chan struct{})
+	defer close(done)
+	go enforceRetention(store, config.Store.retention(), done, func(n int, err error) {
+		if err != nil {
+			slog.Error("pruning store", "err", err)
+		} else if n > 0 {
+			slog.Info("pruned raw lines", "encounters", n)
+		}
+	})
+
+	go scheduleDigests(store, guilds, config.Digest, done)
+
+	share := &shareServer{store: store, metrics: newServerMetrics(), limits: config.Server, guilds: guilds, live: newLiveRelay(), gate: newProfileGate(), clients: newWSClients()}
+	server := &http.Server{Addr: *addr, Handler: share.routes()}
+	errs := make(chan error, 1)
+	go func() {
+		slog.Info("serving", "addr", *addr)
+		share.metrics.ready.Store(true)
+		errs <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	// let uploads in flight finish storing before the store is closed
+	slog.Info("shutting down")
+	share.metrics.ready.Store(false)
+	shutdown, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err = server.Shutdown(shutdown)
+	if cerr := share.clients.shutdown(shutdown); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// width of the bars in the per-hour activity chart
+	sessionChartWidth = 40
+)
+
+func init() {
+	commands["session"] = runSession
+}
+
+// SessionSummary is the zoomed-out view of a whole log file.
+type SessionSummary struct {
+	Encounters int
+	Span       time.Duration
+	InCombat   time.Duration
+	Damage     int
+	Healing    int
+	Deaths     int
+	// CombatByHour is time in combat per hour of day.
+	CombatByHour map[int]time.Duration
+}
+
+// Idle is the part of the session spent out of combat.
+func (s SessionSummary) Idle() time.Duration {
+	return s.Span - s.InCombat
+}
+
+func summarizeSession(encounters []*Encounter) SessionSummary {
+	summary := SessionSummary{Encounters: len(encounters), CombatByHour: map[int]time.Duration{}}
+	if len(encounters) > 0 {
+		summary.Span = encounters[len(encounters)-1].End.Sub(encounters[0].Start)
+	}
+	for _, enc := range encounters {
+		summary.InCombat += enc.Duration()
+		// spread the encounter over the hours it covers
+		for t := enc.Start; t.Before(enc.End); {
+			next := t.Truncate(time.Hour).Add(time.Hour)
+			if next.After(enc.End) {
+				next = enc.End
+			}
+			summary.CombatByHour[t.Hour()] += next.Sub(t)
+			t = next
+		}
+		for _, s := range actorStats(enc) {
+			if s.Kind != Player {
+				continue
+			}
+			summary.Damage += s.Damage
+			summary.Healing += s.Healing
+			summary.Deaths += s.Deaths
+		}
+	}
+	return summary
+}
+
+func printSessionSummary(summary SessionSummary) {
+	fmt.Println("session:")
+	fmt.Printf("  fights: %v\n", summary.Encounters)
+	fmt.Printf("  span: %v (in combat %v, idle %v)\n", summary.Span, summary.InCombat, summary.Idle())
+	fmt.Printf("  player damage: %v\n", summary.Damage)
+	fmt.Printf("  player healing: %v\n", summary.Healing)
+	fmt.Printf("  player deaths: %v\n", summary.Deaths)
+	hours := make([]int, 0, len(summary.CombatByHour))
+	for hour := range summary.CombatByHour {
+		hours = append(hours, hour)
+	}
+	sort.Ints(hours)
+	fmt.Println("  combat per hour:")
+	for _, hour := range hours {
+		d := summary.CombatByHour[hour]
+		bar := strings.Repeat("#", int(d*sessionChartWidth/time.Hour))
+		fmt.Printf("    %02d:00 %-*v %v\n", hour, sessionChartWidth, bar, d)
+	}
+}
+
+// runSession prints a summary of a whole log file: fights, time in combat,
+// totals, loot and activity per hour.
+func runSession(args []string) error {
+	fs := flag.NewFlagSet("session", flag.ExitOnError)
+	setup := commonFlags(fs)
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	result, err := parseFile(inputPath(fs), ParserOptions{})
+	if err != nil {
+		return err
+	}
+	encounters := segmentEncounters(result.Entries)
+	printSessionSummary(summarizeSession(encounters))
+	printLootSummary(summarizeLoot(result.Entries))
+	printProgressionSummary(summarizeProgression(result.Entries))
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// how long an upload to the share server may take
+	uploadTimeout = 5 * time.Minute
+)
+
+func init() {
+	commands["upload"] = runUpload
+}
+
+// ShareConfig is the share server (see serve) logs are uploaded to.
+type ShareConfig struct {
+	URL string `json:"url,omitempty"`
+	// Guild and Token upload into a guild, public uploads without them.
+	Guild string `json:"guild,omitempty"`
+	Token string `json:"token,omitempty"`
+}
+
+// uploadURL is where the share server takes uploads of a file.
+func (c ShareConfig) uploadURL(file string) string {
+	base := strings.TrimSuffix(c.URL, "/")
+	if c.Guild != "" {
+		base += "/guilds/" + url.PathEscape(c.Guild)
+	}
+	return base + "/upload?file=" + url.QueryEscape(file)
+}
+
+// uploadLog sends a log file to the share server and returns the IDs of the
+// stored encounters.
+func uploadLog(share ShareConfig, path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	req, err := http.NewRequest(http.MethodPost, share.uploadURL(filepath.Base(path)), file)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	if share.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+share.Token)
+	}
+	client := &http.Client{Timeout: uploadTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%v: %v", resp.Status, strings.TrimSpace(string(body)))
+	}
+	ids := []string{}
+	if err := json.NewDecoder(resp.Body).Decode(&ids); err != nil {
+		return nil, fmt.Errorf("reading answer: %w", err)
+	}
+	return ids, nil
+}
+
+// runUpload uploads logs to the share server of the config.
+func runUpload(args []string) error {
+	fs := flag.NewFlagSet("upload", flag.ExitOnError)
+	setup := commonFlags(fs)
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	if config.Share.URL == "" {
+		return fmt.Errorf("no share server, set share.url in the config or run init")
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: upload [flags] logfile...")
+	}
+	for _, path := range fs.Args() {
+		ids, err := uploadLog(config.Share, path)
+		if err != nil {
+			return fmt.Errorf("uploading %v: %w", path, err)
+		}
+		fmt.Printf("%v: %d encounters\n", path, len(ids))
+		for _, id := range ids {
+			fmt.Printf("  %v\n", id)
+		}
+	}
+	return nil
+}
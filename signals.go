@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const (
+	// how long in-flight requests get to finish on shutdown
+	shutdownTimeout = 10 * time.Second
+)
+
+// interruptContext is canceled on SIGINT or SIGTERM, so the long-running
+// commands can finish their work before exiting.
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
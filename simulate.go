@@ -0,0 +1,195 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+func init() {
+	commands["simulate"] = runSimulate
+}
+
+// SkillModel is what was observed of one skill: how often it hit and what
+// its normal and critical hits did.
+type SkillModel struct {
+	Skill  string
+	Rate   float64 // hits per second
+	Normal []int
+	Crits  []int
+}
+
+func (m SkillModel) critRate() float64 {
+	return float64(len(m.Crits)) / float64(len(m.Normal)+len(m.Crits))
+}
+
+// skillModels builds a model of actor's damage skills from encounters.
+func skillModels(encounters []*Encounter, actor string) ([]SkillModel, time.Duration) {
+	models := map[string]*SkillModel{}
+	observed := time.Duration(0)
+	for _, enc := range encounters {
+		seen := false
+		for _, entry := range enc.Entries {
+			if entry.etype != DmgDealt || entry.SourceID != actor || entry.Skill == "" || entry.Value == 0 {
+				continue
+			}
+			seen = true
+			m, ok := models[entry.Skill]
+			if !ok {
+				m = &SkillModel{Skill: entry.Skill}
+				models[entry.Skill] = m
+			}
+			if entry.Crit || entry.Dev {
+				m.Crits = append(m.Crits, entry.Value)
+			} else {
+				m.Normal = append(m.Normal, entry.Value)
+			}
+		}
+		if seen {
+			observed += enc.Duration()
+		}
+	}
+	secs := math.Max(observed.Seconds(), 1)
+	result := make([]SkillModel, 0, len(models))
+	for _, m := range models {
+		m.Rate = float64(len(m.Normal)+len(m.Crits)) / secs
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Skill < result[j].Skill })
+	return result, observed
+}
+
+// poisson draws from a Poisson distribution, using the normal approximation
+// for large means.
+func poisson(rng *rand.Rand, lambda float64) int {
+	if lambda > 30 {
+		return int(math.Max(0, math.Round(lambda+math.Sqrt(lambda)*rng.NormFloat64())))
+	}
+	limit, k, p := math.Exp(-lambda), 0, 1.0
+	for {
+		p *= rng.Float64()
+		if p <= limit {
+			return k
+		}
+		k++
+	}
+}
+
+// SimulationResult is the DPS distribution over simulated fights.
+type SimulationResult struct {
+	Runs     int
+	Duration time.Duration
+	DPS      []float64 // sorted
+}
+
+func (r SimulationResult) percentile(p float64) float64 {
+	return r.DPS[int(float64(len(r.DPS)-1)*p)]
+}
+
+func (r SimulationResult) mean() float64 {
+	sum := 0.0
+	for _, v := range r.DPS {
+		sum += v
+	}
+	return sum / float64(len(r.DPS))
+}
+
+func (r SimulationResult) stddev() float64 {
+	m, sum := r.mean(), 0.0
+	for _, v := range r.DPS {
+		sum += (v - m) * (v - m)
+	}
+	return math.Sqrt(sum / float64(len(r.DPS)))
+}
+
+// simulateDPS plays runs fights of the given length: each skill hits a
+// Poisson number of times at its observed rate, crits at its observed crit
+// rate, and every hit is drawn from the observed hits of its kind.
+func simulateDPS(models []SkillModel, duration time.Duration, runs int, rng *rand.Rand) SimulationResult {
+	result := SimulationResult{Runs: runs, Duration: duration, DPS: make([]float64, runs)}
+	secs := math.Max(duration.Seconds(), 1)
+	for i := range result.DPS {
+		total := 0
+		for _, m := range models {
+			crit := m.critRate()
+			for n := poisson(rng, m.Rate*secs); n > 0; n-- {
+				hits := m.Normal
+				if len(m.Normal) == 0 || len(m.Crits) > 0 && rng.Float64() < crit {
+					hits = m.Crits
+				}
+				total += hits[rng.Intn(len(hits))]
+			}
+		}
+		result.DPS[i] = float64(total) / secs
+	}
+	sort.Float64s(result.DPS)
+	return result
+}
+
+func printSimulation(actor string, models []SkillModel, r SimulationResult) {
+	fmt.Printf("%v, %d skills, %d fights of %v:\n", actor, len(models), r.Runs, r.Duration)
+	fmt.Printf("  mean %.0f/s, stddev %.0f\n", r.mean(), r.stddev())
+	fmt.Printf("  90%% of parses between %.0f/s and %.0f/s, median %.0f/s\n", r.percentile(0.05), r.percentile(0.95), r.percentile(0.5))
+}
+
+// runSimulate estimates the spread of future parses from the observed skill
+// data of an actor.
+func runSimulate(args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	setup := commonFlags(fs)
+	actor := fs.String("actor", "", "actor to simulate (default: the top player)")
+	runs := fs.Int("n", 10000, "number of simulated fights")
+	duration := fs.Duration("duration", 0, "length of the simulated fights (default: mean observed encounter)")
+	seed := fs.Int64("seed", 0, "random seed (default: time based)")
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	if *runs < 1 {
+		return fmt.Errorf("need at least one simulated fight, got -n %d", *runs)
+	}
+	result, err := parseFile(inputPath(fs), ParserOptions{})
+	if err != nil {
+		return err
+	}
+	encounters := segmentEncounters(result.Entries)
+	if *actor == "" {
+		top := map[string]int{}
+		for _, enc := range encounters {
+			for _, s := range actorStats(enc) {
+				if s.Kind == Player {
+					top[s.Actor] += s.Damage
+				}
+			}
+		}
+		for name, dmg := range top {
+			if *actor == "" || dmg > top[*actor] {
+				*actor = name
+			}
+		}
+	}
+	models, observed := skillModels(encounters, *actor)
+	if len(models) == 0 {
+		return fmt.Errorf("no damage by %q to build a model from", *actor)
+	}
+	if *duration == 0 {
+		fights := 0
+		for _, enc := range encounters {
+			for _, entry := range enc.Entries {
+				if entry.SourceID == *actor && entry.etype == DmgDealt {
+					fights++
+					break
+				}
+			}
+		}
+		*duration = observed / time.Duration(fights)
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	printSimulation(*actor, models, simulateDPS(models, *duration, *runs, rand.New(rand.NewSource(*seed))))
+	return nil
+}
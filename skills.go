@@ -0,0 +1,37 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+//go:embed data/skills.json
+var skillsJSON []byte
+
+// SkillInfo is the static metadata we know about a skill.
+type SkillInfo struct {
+	Name         string  `json:"name"`
+	Class        string  `json:"class"`
+	CooldownSecs float64 `json:"cooldown"`
+}
+
+// Cooldown is the base cooldown of the skill, zero if unknown.
+func (s SkillInfo) Cooldown() time.Duration {
+	return time.Duration(s.CooldownSecs * float64(time.Second))
+}
+
+var skillCatalog = mustLoadSkills(skillsJSON)
+
+func mustLoadSkills(data []byte) map[string]SkillInfo {
+	skills := []SkillInfo{}
+	if err := json.Unmarshal(data, &skills); err != nil {
+		panic(fmt.Sprintf("invalid skill catalog: %v", err))
+	}
+	catalog := make(map[string]SkillInfo, len(skills))
+	for _, s := range skills {
+		catalog[s.Name] = s
+	}
+	return catalog
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// EncounterSnapshot is the exported state of an encounter's meter.
+type EncounterSnapshot struct {
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Label    string        `json:"label,omitempty"`
+	Build    string        `json:"build,omitempty"`
+	Actors   []*ActorStats `json:"actors"`
+
+	ParserVersion  int    `json:"parser_version"`
+	PatternVersion string `json:"pattern_version"`
+}
+
+func snapshotOf(enc *Encounter) EncounterSnapshot {
+	return EncounterSnapshot{
+		Start:    enc.Start,
+		Duration: enc.Duration(),
+		Label:    enc.Label,
+		Build:    enc.Build,
+		Actors:   watchedStats(actorStats(enc)),
+
+		ParserVersion:  parserVersion,
+		PatternVersion: patternVersion,
+	}
+}
+
+var snapshotHTML = template.Must(template.New("snapshot").Funcs(themeFuncs).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Encounter {{.Start.Format "15:04:05"}}</title>
+<style>body { background: {{theme.Background}}; color: {{theme.Foreground}}; }</style></head>
+<body>
+<h1>Encounter {{.Start.Format "15:04:05"}} ({{.Duration}}){{if .Label}} {{.Label}}{{end}}</h1>
+{{if .Build}}<p>Build: {{.Build}}</p>
+{{end}}<table>
+<tr><th>Actor</th><th>Kind</th><th>Damage</th><th>Boss damage</th><th>Healing</th><th>Taken</th><th>Deaths</th></tr>
+{{range .Actors}}<tr><td>{{.Actor}}</td><td>{{.Kind}}</td><td>{{.Damage}}</td><td>{{.BossDamage}}</td><td>{{.Healing}}</td><td>{{.DamageTaken}}</td><td>{{.Deaths}}</td></tr>
+{{end}}</table>
+</body></html>
+`))
+
+// writeSnapshotSVG draws the damage column of a snapshot as a bar chart.
+func writeSnapshotSVG(w io.Writer, snap EncounterSnapshot) error {
+	const barHeight, width, labelWidth = 20, 600, 160
+	max := 1
+	for _, a := range snap.Actors {
+		if a.Damage > max {
+			max = a.Damage
+		}
+	}
+	height := barHeight * (len(snap.Actors) + 1)
+	svgOpen(w, width, height, 12)
+	fmt.Fprintf(w, `<text x="4" y="14">Encounter %v (%v)</text>`+"\n", snap.Start.Format("15:04:05"), snap.Duration)
+	for i, a := range snap.Actors {
+		y := barHeight * (i + 1)
+		bar := (width - labelWidth - 80) * a.Damage / max
+		fmt.Fprintf(w, `<text x="4" y="%d">%v</text>`+"\n", y+14, template.HTMLEscapeString(a.Actor))
+		fmt.Fprintf(w, `<rect x="%d" y="%d" width="%d" height="%d" fill="%v"/>`+"\n", labelWidth, y+3, bar, barHeight-6, chartTheme.Damage)
+		fmt.Fprintf(w, `<text x="%d" y="%d">%v</text>`+"\n", labelWidth+bar+4, y+14, a.Damage)
+	}
+	_, err := fmt.Fprintln(w, "</svg>")
+	return err
+}
+
+// writeSnapshot renders the snapshot as json, html or svg.
+func writeSnapshot(w io.Writer, snap EncounterSnapshot, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(snap)
+	case "html":
+		return snapshotHTML.Execute(w, snap)
+	case "svg":
+		return writeSnapshotSVG(w, snap)
+	}
+	return fmt.Errorf("unknown snapshot format %q, want json, html or svg", format)
+}
+
+// saveSnapshot writes the live meter's current encounter into dir and returns
+// the file name.
+func saveSnapshot(meter *LiveMeter, dir, format string) (string, error) {
+	enc := meter.Snapshot()
+	if enc == nil {
+		return "", fmt.Errorf("no encounter yet")
+	}
+	snap := snapshotOf(enc)
+	path := filepath.Join(dir, fmt.Sprintf("encounter-%v.%v", time.Now().Format("20060102-150405"), format))
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if err := writeSnapshot(file, snap, format); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// watchSnapshotKeys saves a snapshot whenever "s" (json), "h" (html) or "i"
+// (svg image) is entered on stdin.
+func watchSnapshotKeys(meter *LiveMeter, dir string, status func(string)) {
+	formats := map[string]string{"s": "json", "h": "html", "i": "svg"}
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		format, ok := formats[strings.TrimSpace(scanner.Text())]
+		if !ok {
+			continue
+		}
+		path, err := saveSnapshot(meter, dir, format)
+		if err != nil {
+			status(fmt.Sprintf("snapshot failed: %v", err))
+			continue
+		}
+		status(fmt.Sprintf("saved %v", path))
+	}
+}
+
+// snapshotHandler serves GET /snapshot?format=json|html|svg with the current
+// encounter, and saves it into dir as well when called with POST.
+func snapshotHandler(meter *LiveMeter, dir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "json"
+		}
+		if r.Method == http.MethodPost {
+			path, err := saveSnapshot(meter, dir, format)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			fmt.Fprintln(w, path)
+			return
+		}
+		enc := meter.Snapshot()
+		if enc == nil {
+			http.Error(w, "no encounter yet", http.StatusConflict)
+			return
+		}
+		contentTypes := map[string]string{"json": "application/json", "html": "text/html", "svg": "image/svg+xml"}
+		w.Header().Set("Content-Type", contentTypes[format])
+		if err := writeSnapshot(w, snapshotOf(enc), format); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}
+}
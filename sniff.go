@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// how many lines sniffLog looks at
+	sniffLines = 500
+	// locale of the embedded patterns
+	defaultLocale = "en"
+)
+
+// localeKeywords are phrases that only show up in combat lines of one client
+// language.
+var localeKeywords = map[string][]string{
+	"en": {" scored a ", " applied a ", " defeated ", " points to Morale"},
+	"de": {" erzielte ", " Schaden ", " Moral", " besiegt"},
+	"fr": {" a infligé ", " dégâts ", " moral", " a vaincu"},
+}
+
+// LogProfile is what sniffLog found out about a log before parsing it.
+type LogProfile struct {
+	Locale      string    `json:"locale"`
+	GameVersion int       `json:"game_version,omitempty"` // from a "### game-version: N" header, 0 if unknown
+	Date        time.Time `json:"date"`                   // from the file name or modification time
+	Clock24     bool      `json:"clock24,omitempty"`      // timestamps without AM/PM
+	Warnings    []string  `json:"warnings,omitempty"`
+}
+
+// appliedProfile is the profile apply selected last, which stored
+// encounters record so they parse alike when rebuilt.
+var appliedProfile = LogProfile{Locale: defaultLocale}
+
+// sniffLog looks at the first lines of a file to detect the client locale,
+// timestamp format and game version. Missing combat channels are found
+// after parsing, see loggingGaps.
+func sniffLog(path string) (LogProfile, error) {
+	profile := LogProfile{Locale: defaultLocale}
+	file, err := openLog(path)
+	if err != nil {
+		return profile, err
+	}
+	defer file.Close()
+
+	votes := map[string]int{}
+	clock12, clock24 := 0, 0
+	scanner := newLineScanner(file)
+	for i := 0; i < sniffLines && scanner.Scan(); i++ {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "###") {
+			key, value := parseCommentMeta(line)
+			if key == "game-version" {
+				profile.GameVersion, _ = strconv.Atoi(regexp.MustCompile(`\d+`).FindString(value))
+			}
+			continue
+		}
+		if m := pattern("timestamp").FindStringSubmatch(line); len(m) > 1 {
+			if strings.HasSuffix(m[1], "M") {
+				clock12++
+			} else {
+				clock24++
+			}
+		}
+		for locale, keywords := range localeKeywords {
+			for _, keyword := range keywords {
+				if strings.Contains(line, keyword) {
+					votes[locale]++
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return profile, err
+	}
+
+	for locale, n := range votes {
+		if n > votes[profile.Locale] {
+			profile.Locale = locale
+		}
+	}
+	if config.Locale != "" {
+		profile.Locale = config.Locale
+	}
+	profile.Clock24 = clock24 > clock12
+
+	if m := regexp.MustCompile(`(\d{8})`).FindStringSubmatch(filepath.Base(path)); m != nil {
+		profile.Date, _ = time.Parse("20060102", m[1])
+	}
+	if profile.Date.IsZero() {
+		if info, err := os.Stat(path); err == nil {
+			profile.Date = info.ModTime()
+		}
+	}
+
+	if profile.Locale != defaultLocale && !havePatternLocale(profile.Locale) {
+		profile.Warnings = append(profile.Warnings, fmt.Sprintf("log looks like a %q client but there are no patterns for it, most lines will not parse", profile.Locale))
+	}
+	return profile, nil
+}
+
+func havePatternLocale(locale string) bool {
+	for _, set := range patternSets {
+		if set.Locale == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// apply configures the parser for the sniffed log.
+func (p LogProfile) apply() error {
+	if p.Clock24 {
+		timestampLayouts = []string{"01/02 15:04:05", "01/02 03:04:05 PM"}
+	} else {
+		timestampLayouts = []string{"01/02 03:04:05 PM", "01/02 15:04:05"}
+	}
+	valueLocale = p.Locale
+	if err := selectPatterns(p.GameVersion, p.Date, p.Locale); err != nil {
+		return err
+	}
+	appliedProfile = p
+	appliedProfile.Warnings = nil
+	return nil
+}
+
+// key names what apply selects, logs with the same key parse alike.
+func (p LogProfile) key() string {
+	key := fmt.Sprintf("%v clock24=%v", p.Locale, p.Clock24)
+	for i, set := range patternSets {
+		if set.Game != nil && (set.Locale == "" || set.Locale == p.Locale) && set.Game.contains(p.GameVersion, p.Date) {
+			key += fmt.Sprintf(" set%d", i)
+		}
+	}
+	return key
+}
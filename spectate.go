@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+func init() {
+	commands["spectate"] = runSpectate
+}
+
+// spectator is the meter of a live session followed from the share server.
+type spectator struct {
+	session string
+	compact bool
+	frame   overlayFrame
+	ok      bool   // a state was received
+	status  string // shown under the meter
+}
+
+// render clears the terminal and draws the meter like LiveMeter.Render.
+func (s *spectator) render(w io.Writer) {
+	fmt.Fprint(w, chartTheme.ansi(), "\033[H\033[2J")
+	defer fmt.Fprint(w, "\033[0m")
+	if !s.ok {
+		fmt.Fprintf(w, "spectating %v, waiting for combat...\n", s.session)
+	} else {
+		// only the duration of the encounter counts for rates
+		enc := &Encounter{Start: s.frame.Start, End: s.frame.Start.Add(s.frame.Duration)}
+		stats := []*ActorStats{}
+		for _, a := range s.frame.Actors {
+			if a.Kind == Player || a.Actor == othersActor || meterAllActors {
+				stats = append(stats, a)
+			}
+		}
+		sort.SliceStable(stats, func(i, j int) bool { return stats[i].Damage > stats[j].Damage })
+		fmt.Fprintf(w, "spectating %v, encounter %d: %v\n", s.session, s.frame.Encounter, s.frame.Duration)
+		if s.compact {
+			printTopStatsTo(w, stats, enc)
+		} else {
+			fmt.Fprintln(w, "meter:")
+			printStatsTo(w, stats, enc)
+		}
+	}
+	if s.status != "" {
+		fmt.Fprintln(w, s.status)
+	}
+}
+
+// follow reads a spectating connection until the session ends, the
+// connection drops or ctx is done. ended reports the end of the session.
+func (s *spectator) follow(ctx context.Context, ws *wsConn, w io.Writer) (ended bool, err error) {
+	stop := context.AfterFunc(ctx, func() { ws.close(1000, "done spectating") })
+	defer stop()
+	hello := OverlayMessage{Type: overlayHello, V: overlayVersion, Capabilities: []string{overlayState, overlayDiff}}
+	if err := ws.writeJSON(hello); err != nil {
+		return false, err
+	}
+	for {
+		_, data, err := ws.readMessage()
+		if err != nil {
+			return false, err
+		}
+		var msg OverlayMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return false, fmt.Errorf("invalid message from the share server: %w", err)
+		}
+		switch {
+		case msg.Type == overlayHello && !slices.Contains(msg.Capabilities, overlayDiff):
+			return false, fmt.Errorf("the share server speaks an unknown overlay protocol, version %d", msg.V)
+		case msg.Type == overlayEnd:
+			return true, nil
+		case msg.Type == overlayError:
+			s.status = "share server: " + msg.Error
+		case s.frame.apply(msg):
+			s.ok, s.status = true, ""
+		default:
+			continue
+		}
+		s.render(w)
+	}
+}
+
+// listLive prints the live sessions on the share server.
+func listLive(share ShareConfig) error {
+	base := strings.TrimSuffix(share.liveURL(""), "/")
+	req, err := http.NewRequest(http.MethodGet, base, nil)
+	if err != nil {
+		return err
+	}
+	req.Header = share.shareHeader()
+	client := &http.Client{Timeout: websocketWriteTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%v: %v", resp.Status, strings.TrimSpace(string(body)))
+	}
+	sessions := []LiveSessionInfo{}
+	if err := json.NewDecoder(resp.Body).Decode(&sessions); err != nil {
+		return fmt.Errorf("reading answer: %w", err)
+	}
+	if len(sessions) == 0 {
+		fmt.Println("no live sessions")
+	}
+	for _, session := range sessions {
+		fmt.Printf("%-24v live for %v, encounter %d, %d spectators\n", session.Name,
+			time.Since(session.Started).Round(time.Minute), session.Encounter, session.Spectators)
+	}
+	return nil
+}
+
+// runSpectate follows a live session someone publishes to the share server
+// with follow -share, drawing its meter here. Without a session it lists
+// the live ones.
+func runSpectate(args []string) error {
+	fs := flag.NewFlagSet("spectate", flag.ExitOnError)
+	setup := commonFlags(fs)
+	top := fs.Bool("top", false, fmt.Sprintf("show a compact table of the top %d damage and healing instead of the meter", liveTopN))
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	if config.Share.URL == "" {
+		return fmt.Errorf("no share server, set share.url in the config or run init")
+	}
+	switch fs.NArg() {
+	case 0:
+		return listLive(config.Share)
+	case 1:
+	default:
+		return fmt.Errorf("usage: spectate [flags] [session]")
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	s := &spectator{session: fs.Arg(0), compact: *top}
+	url := config.Share.liveURL(s.session)
+	ws, err := dialWebSocket(url, config.Share.shareHeader())
+	if err != nil {
+		return fmt.Errorf("spectating %v: %w", s.session, err)
+	}
+	s.render(os.Stdout)
+	for {
+		ended, err := s.follow(ctx, ws, os.Stdout)
+		ws.conn.Close()
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case ended:
+			fmt.Println("the session ended")
+			return nil
+		}
+		// the publisher may only have lost its connection for a moment
+		for ws = nil; ws == nil; {
+			s.status = fmt.Sprintf("connection lost: %v, retrying in %v", err, publishRetry)
+			s.render(os.Stdout)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(publishRetry):
+			}
+			ws, err = dialWebSocket(url, config.Share.shareHeader())
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+func init() {
+	commands["split"] = runSplit
+}
+
+// characters that do not belong in file names
+var unsafeFileChars = regexp.MustCompile(`[^\pL\pN._-]+`)
+
+// splitFileName names an encounter's file after its boss, or "trash", and
+// its start, like "Burkhad-0708-173508.txt".
+func splitFileName(enc *Encounter) string {
+	name := encounterBoss(enc)
+	if name == "" {
+		name = "trash"
+	}
+	name = strings.Trim(unsafeFileChars.ReplaceAllString(name, "-"), "-")
+	return fmt.Sprintf("%v-%v.txt", name, enc.Start.Format("0102-150405"))
+}
+
+// runSplit writes the raw lines of each encounter of a log into a file of
+// its own, e.g. to archive single kills.
+func runSplit(args []string) error {
+	fs := flag.NewFlagSet("split", flag.ExitOnError)
+	setup := commonFlags(fs)
+	out := fs.String("o", ".", "directory to write the encounter files to")
+	bossesOnly := fs.Bool("bosses", false, "only write boss encounters")
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	result, err := parseFile(inputPath(fs), ParserOptions{})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		return err
+	}
+	for _, enc := range segmentEncounters(result.Entries) {
+		if *bossesOnly && encounterBoss(enc) == "" {
+			continue
+		}
+		lines := rawLines(enc)
+		path := filepath.Join(*out, splitFileName(enc))
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("wrote %d lines to %v\n", len(lines), path)
+	}
+	return nil
+}
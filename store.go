@@ -0,0 +1,258 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// StoredEncounter is the index record of an encounter in a Store: its
+// aggregates, kept forever, and a reference to its raw lines.
+type StoredEncounter struct {
+	ID       string        `json:"id"`
+	File     string        `json:"file"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Label    string        `json:"label,omitempty"`
+	Build    string        `json:"build,omitempty"`
+	Guild    string        `json:"guild,omitempty"`
+	Boss     string        `json:"boss,omitempty"` // set for boss encounters
+	// Hash is the contentHash the store deduplicates by, empty on records
+	// stored before there was one until they are reindexed.
+	Hash   string        `json:"hash,omitempty"`
+	Stored time.Time     `json:"stored"`
+	Stats  []*ActorStats `json:"stats"`
+	// Classes are the players' classes as far as their skills tell, see
+	// playerClasses.
+	Classes map[string]string `json:"classes,omitempty"`
+	// NormalHits are the players' median normal hits by class skill, see
+	// normalHitMedians.
+	NormalHits map[string]map[string]int `json:"normal_hits,omitempty"`
+	// Profile and Character are what the raw lines were parsed with, so
+	// rebuilding them gives the same encounter. Records stored before they
+	// were kept have no Profile and rebuild with the applied one.
+	Profile   *LogProfile `json:"profile,omitempty"`
+	Character string      `json:"character,omitempty"`
+	// RawPruned is set once retention removed the raw lines.
+	RawPruned bool `json:"raw_pruned,omitempty"`
+	// ParserVersion and PatternVersion are what produced Stats.
+	ParserVersion  int    `json:"parser_version"`
+	PatternVersion string `json:"pattern_version,omitempty"`
+}
+
+// versionWarnings warns when records were parsed by different parser or
+// pattern versions, since their stats do not compare.
+func versionWarnings(records []StoredEncounter) []string {
+	parsers, patterns := map[int]int{}, map[string]int{}
+	for _, r := range records {
+		parsers[r.ParserVersion]++
+		patterns[r.PatternVersion]++
+	}
+	warnings := []string{}
+	if len(parsers) > 1 {
+		warnings = append(warnings, fmt.Sprintf("encounters were parsed by parser versions %v, run reindex to compare them", sortedKeys(parsers)))
+	}
+	if len(patterns) > 1 {
+		warnings = append(warnings, fmt.Sprintf("encounters were parsed with pattern versions %v", sortedKeys(patterns)))
+	}
+	return warnings
+}
+
+func sortedKeys[K int | string](m map[K]int) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// Store persists encounters. The raw lines are the source of truth, the
+// stats are derived from them and can be recomputed.
+type Store interface {
+	// PutEntries stores an encounter of the named log file and returns its
+	// ID. Storing the same encounter twice keeps one copy, see PutRecord.
+	PutEntries(file string, enc *Encounter) (string, error)
+	// ListEncounters returns the stored encounters, oldest first.
+	ListEncounters() ([]StoredEncounter, error)
+	// GetTimeline returns the raw log lines of an encounter.
+	GetTimeline(id string) ([]string, error)
+	// PutRecord stores an index record as is, with its raw lines unless they
+	// were pruned. It is how archives are imported. When a record with the
+	// same ID or Hash is stored already it keeps that one and returns its ID.
+	PutRecord(record StoredEncounter, lines []string) (string, error)
+	// UpdateRecord replaces the index record of a stored encounter, e.g.
+	// with recomputed stats.
+	UpdateRecord(record StoredEncounter) error
+	// PruneRaw drops the raw lines of encounters stored before a time and
+	// returns how many it pruned. The index records stay.
+	PruneRaw(before time.Time) (int, error)
+	Close() error
+}
+
+// batchStore is a Store that writes many puts cheaper as one batch.
+type batchStore interface {
+	batch(fn func() error) error
+}
+
+// storeBatch runs fn, which stores many encounters, as one batch when the
+// store supports it. What fn stored is durable once it returns.
+func storeBatch(store Store, fn func() error) error {
+	if b, ok := store.(batchStore); ok {
+		return b.batch(fn)
+	}
+	return fn()
+}
+
+// StoreConfig selects the storage backend.
+type StoreConfig struct {
+	Backend string `json:"backend,omitempty"` // default "file"
+	Path    string `json:"path,omitempty"`    // directory, file or connection string
+	// RetentionDays is how long raw lines are kept, zero keeps them forever.
+	// Aggregates are always kept.
+	RetentionDays int `json:"retention_days,omitempty"`
+}
+
+// retention is how long raw lines are kept, zero for forever.
+func (c StoreConfig) retention() time.Duration {
+	return time.Duration(c.RetentionDays) * 24 * time.Hour
+}
+
+// storeFlags registers the store flags and returns a function that opens the
+// store once the flags and config are loaded.
+func storeFlags(fs *flag.FlagSet) func() (Store, error) {
+	backend := fs.String("store", "", fmt.Sprintf("store backend, one of %v (default from config, else file)", storeBackendNames()))
+	path := fs.String("store-path", "", "store directory, file or connection string (default from config)")
+	return func() (Store, error) {
+		if *backend != "" {
+			config.Store.Backend = *backend
+		}
+		if *path != "" {
+			config.Store.Path = *path
+		}
+		return openStore(config.Store)
+	}
+}
+
+// storeBackends open a Store from a path, keyed by backend name.
+var storeBackends = map[string]func(path string) (Store, error){}
+
+func storeBackendNames() []string {
+	names := make([]string, 0, len(storeBackends))
+	for name := range storeBackends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// defaultStorePath is the store directory next to the config file.
+func defaultStorePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "store"
+	}
+	return filepath.Join(dir, "SharedCombatGraphs", "store")
+}
+
+// openStore opens the backend named in the config.
+func openStore(cfg StoreConfig) (Store, error) {
+	if cfg.Backend == "" {
+		cfg.Backend = "file"
+	}
+	if cfg.Path == "" {
+		cfg.Path = defaultStorePath()
+	}
+	open, ok := storeBackends[cfg.Backend]
+	if !ok {
+		return nil, fmt.Errorf("unknown store backend %q, have: %v", cfg.Backend, storeBackendNames())
+	}
+	return open(cfg.Path)
+}
+
+// encounterID identifies an encounter by its start and its contentHash, so
+// the same fight uploaded twice gets the same ID.
+func encounterID(enc *Encounter) string {
+	return scopedEncounterID("", enc)
+}
+
+// scopedEncounterID is encounterID within a namespace, like a guild.
+func scopedEncounterID(scope string, enc *Encounter) string {
+	return enc.Start.Format("0102-150405") + "-" + contentHash(scope, enc)[:9]
+}
+
+// contentHash hashes the combat entries of an encounter rather than its
+// lines, so the same fight hashes the same whatever the line endings,
+// encoding, clock format or number format of the log it came from.
+func contentHash(scope string, enc *Encounter) string {
+	h := sha256.New()
+	fmt.Fprintln(h, scope)
+	for _, entry := range enc.Entries {
+		if !entry.etype.isCombat() {
+			continue
+		}
+		fmt.Fprintf(h, "%v|%v|%v|%v|%v|%v|%v|%v|%v\n", entry.Timestamp.Format("01/02 15:04:05"), entry.etype,
+			entityName(entry.Source), entityName(entry.Target), entry.Skill, entry.Value, entry.ValueType, entry.Crit, entry.Avoided)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// rawLines returns the original log lines of an encounter.
+func rawLines(enc *Encounter) []string {
+	lines := make([]string, 0, len(enc.Entries))
+	for _, entry := range enc.Entries {
+		if entry.RawMessage != "" {
+			lines = append(lines, entry.RawMessage)
+		}
+	}
+	return lines
+}
+
+// encounterBoss is the name of the boss of a boss encounter, "" for trash.
+func encounterBoss(enc *Encounter) string {
+	if !segmentation.hasBoss(enc) {
+		return ""
+	}
+	if boss := bossEntity(enc); boss != nil {
+		return boss.Name
+	}
+	return ""
+}
+
+// encounterKilled reports whether the boss of a boss encounter died.
+func encounterKilled(enc *Encounter) bool {
+	if encounterBoss(enc) == "" {
+		return false
+	}
+	boss := bossEntity(enc)
+	return boss != nil && !boss.Died.IsZero()
+}
+
+// storedEncounter builds the index record of an encounter.
+func storedEncounter(file string, enc *Encounter) StoredEncounter {
+	profile := appliedProfile
+	return StoredEncounter{
+		ID:         encounterID(enc),
+		Hash:       contentHash("", enc),
+		File:       filepath.Base(file),
+		Start:      enc.Start,
+		Duration:   enc.Duration(),
+		Label:      enc.Label,
+		Build:      enc.Build,
+		Stored:     time.Now(),
+		Stats:      actorStats(enc),
+		Classes:    playerClasses(enc),
+		NormalHits: normalHitMedians(enc),
+		Boss:       encounterBoss(enc),
+		Profile:    &profile,
+		Character:  enc.Character,
+
+		ParserVersion:  parserVersion,
+		PatternVersion: patternVersion,
+	}
+}
@@ -0,0 +1,200 @@
+//go:build bolt
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func init() {
+	storeBackends["bolt"] = openBoltStore
+}
+
+var (
+	// boltEncounters maps IDs to index records
+	boltEncounters = []byte("encounters")
+	// boltRaw maps IDs to raw lines, until they are pruned
+	boltRaw = []byte("raw")
+	// boltHashes maps content hashes to the ID stored under them
+	boltHashes = []byte("hashes")
+)
+
+// boltStore keeps the store in a single bbolt file, for users who want a
+// database without cgo. The path is the file, or a directory to keep
+// encounters.db in.
+//
+// The default build has no dependencies, so this backend is left out unless
+// built with "-tags bolt" in a module that requires it:
+//
+//	go mod init scg
+//	go get go.etcd.io/bbolt
+//	go build -tags bolt
+type boltStore struct {
+	db *bolt.DB
+}
+
+func openBoltStore(path string) (Store, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, "encounters.db")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("opening bolt store: %w", err)
+	}
+	// a second process waits a moment for the file lock instead of hanging
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening bolt store: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltEncounters, boltRaw, boltHashes} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("opening bolt store: %w", err)
+	}
+	return &boltStore{db: db}, nil
+}
+
+// putBoltRecord writes an index record and the hash pointing at it.
+func putBoltRecord(tx *bolt.Tx, record StoredEncounter) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltEncounters).Put([]byte(record.ID), data); err != nil {
+		return err
+	}
+	if record.Hash == "" {
+		return nil
+	}
+	// the oldest ID wins, like the other backends
+	hashes := tx.Bucket(boltHashes)
+	if old := hashes.Get([]byte(record.Hash)); old != nil && string(old) < record.ID {
+		return nil
+	}
+	return hashes.Put([]byte(record.Hash), []byte(record.ID))
+}
+
+func (s *boltStore) PutEntries(file string, enc *Encounter) (string, error) {
+	return s.PutRecord(storedEncounter(file, enc), rawLines(enc))
+}
+
+func (s *boltStore) PutRecord(record StoredEncounter, lines []string) (string, error) {
+	id := record.ID
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltEncounters).Get([]byte(record.ID)) != nil {
+			return nil
+		}
+		if stored := tx.Bucket(boltHashes).Get([]byte(record.Hash)); stored != nil && record.Hash != "" {
+			id = string(stored)
+			return nil
+		}
+		if !record.RawPruned {
+			if err := tx.Bucket(boltRaw).Put([]byte(record.ID), []byte(strings.Join(lines, "\n"))); err != nil {
+				return err
+			}
+		}
+		return putBoltRecord(tx, record)
+	})
+	if err != nil {
+		return "", fmt.Errorf("storing encounter: %w", err)
+	}
+	return id, nil
+}
+
+func (s *boltStore) ListEncounters() ([]StoredEncounter, error) {
+	list := []StoredEncounter{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltEncounters).ForEach(func(_, data []byte) error {
+			record := StoredEncounter{}
+			if err := json.Unmarshal(data, &record); err != nil {
+				return err
+			}
+			list = append(list, record)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Stored.Before(list[j].Stored) || list[i].Stored.Equal(list[j].Stored) && list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+func (s *boltStore) GetTimeline(id string) ([]string, error) {
+	var lines []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltRaw).Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("no raw lines of %q in the store, unknown or pruned", id)
+		}
+		// data is only valid in the transaction, Split copies it
+		lines = strings.Split(string(data), "\n")
+		return nil
+	})
+	return lines, err
+}
+
+func (s *boltStore) UpdateRecord(record StoredEncounter) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltEncounters).Get([]byte(record.ID)) == nil {
+			return fmt.Errorf("no encounter %q in the store", record.ID)
+		}
+		return putBoltRecord(tx, record)
+	})
+}
+
+func (s *boltStore) PruneRaw(before time.Time) (int, error) {
+	pruned := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		records := []StoredEncounter{}
+		err := tx.Bucket(boltEncounters).ForEach(func(_, data []byte) error {
+			record := StoredEncounter{}
+			if err := json.Unmarshal(data, &record); err != nil {
+				return err
+			}
+			if !record.RawPruned && record.Stored.Before(before) {
+				records = append(records, record)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// buckets must not change while ForEach walks them
+		for _, record := range records {
+			if err := tx.Bucket(boltRaw).Delete([]byte(record.ID)); err != nil {
+				return err
+			}
+			record.RawPruned = true
+			if err := putBoltRecord(tx, record); err != nil {
+				return err
+			}
+		}
+		pruned = len(records)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return pruned, nil
+}
+
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
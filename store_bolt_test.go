@@ -0,0 +1,11 @@
+//go:build bolt
+
+package main
+
+import "testing"
+
+func TestBoltStore(t *testing.T) {
+	// a directory gets an encounters.db in it
+	dir := t.TempDir()
+	testStore(t, func() (Store, error) { return openBoltStore(dir) })
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	storeBackends["file"] = openFileStore
+}
+
+// fileStore keeps an index.json of the encounters and one file of raw lines
+// per encounter in a directory. It needs no database and no cgo.
+type fileStore struct {
+	mu    sync.Mutex
+	dir   string
+	index map[string]StoredEncounter
+	// hashes maps content hashes to the ID stored under them
+	hashes map[string]string
+	// batches counts the open batches, dirty is set when the index changed
+	// in one and still has to be written
+	batches int
+	dirty   bool
+}
+
+func openFileStore(dir string) (Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "raw"), 0o755); err != nil {
+		return nil, fmt.Errorf("opening store: %w", err)
+	}
+	s := &fileStore{dir: dir, index: map[string]StoredEncounter{}, hashes: map[string]string{}}
+	data, err := os.ReadFile(s.indexPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening store: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.index); err != nil {
+		return nil, fmt.Errorf("reading store index: %w", err)
+	}
+	for id, record := range s.index {
+		s.indexHash(id, record.Hash)
+	}
+	return s, nil
+}
+
+func (s *fileStore) indexPath() string {
+	return filepath.Join(s.dir, "index.json")
+}
+
+func (s *fileStore) rawPath(id string) string {
+	return filepath.Join(s.dir, "raw", id+".log")
+}
+
+// saveIndex writes the index through a temporary file so a crash never
+// leaves half of it behind.
+func (s *fileStore) saveIndex() error {
+	data, err := json.MarshalIndent(s.index, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.indexPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.indexPath())
+}
+
+// indexChanged saves the index, or leaves that to the end of the open batch.
+func (s *fileStore) indexChanged() error {
+	if s.batches > 0 {
+		s.dirty = true
+		return nil
+	}
+	return s.saveIndex()
+}
+
+// batch runs fn with the index written once at its end instead of on every
+// put, so the puts of fn are durable once batch returns. Raw files are
+// written as usual, so a crash in a batch only loses index records, and
+// storing them again overwrites the raw files.
+func (s *fileStore) batch(fn func() error) error {
+	s.mu.Lock()
+	s.batches++
+	s.mu.Unlock()
+	err := fn()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches--
+	// batches of other goroutines may still be open, their puts so far are
+	// written too
+	if s.dirty {
+		s.dirty = false
+		if serr := s.saveIndex(); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// indexHash remembers the ID of a hash, the oldest ID wins so lookups do
+// not depend on map order.
+func (s *fileStore) indexHash(id, hash string) {
+	if hash == "" {
+		return
+	}
+	if old, ok := s.hashes[hash]; !ok || id < old {
+		s.hashes[hash] = id
+	}
+}
+
+func (s *fileStore) PutEntries(file string, enc *Encounter) (string, error) {
+	return s.PutRecord(storedEncounter(file, enc), rawLines(enc))
+}
+
+func (s *fileStore) PutRecord(record StoredEncounter, lines []string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.index[record.ID]; ok {
+		return record.ID, nil
+	}
+	if id, ok := s.hashes[record.Hash]; ok && record.Hash != "" {
+		return id, nil
+	}
+	if !record.RawPruned {
+		if err := os.WriteFile(s.rawPath(record.ID), []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+			return "", fmt.Errorf("storing encounter: %w", err)
+		}
+	}
+	s.index[record.ID] = record
+	s.indexHash(record.ID, record.Hash)
+	return record.ID, s.indexChanged()
+}
+
+func (s *fileStore) ListEncounters() ([]StoredEncounter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]StoredEncounter, 0, len(s.index))
+	for _, record := range s.index {
+		list = append(list, record)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Stored.Before(list[j].Stored) || list[i].Stored.Equal(list[j].Stored) && list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+func (s *fileStore) GetTimeline(id string) ([]string, error) {
+	s.mu.Lock()
+	record, ok := s.index[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no encounter %q in the store", id)
+	}
+	if record.RawPruned {
+		return nil, fmt.Errorf("raw lines of %q were pruned", id)
+	}
+	data, err := os.ReadFile(s.rawPath(id))
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+}
+
+func (s *fileStore) UpdateRecord(record StoredEncounter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.index[record.ID]; !ok {
+		return fmt.Errorf("no encounter %q in the store", record.ID)
+	}
+	s.index[record.ID] = record
+	s.indexHash(record.ID, record.Hash)
+	return s.indexChanged()
+}
+
+func (s *fileStore) PruneRaw(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pruned := 0
+	for id, record := range s.index {
+		if record.RawPruned || !record.Stored.Before(before) {
+			continue
+		}
+		if err := os.Remove(s.rawPath(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return pruned, err
+		}
+		record.RawPruned = true
+		s.index[id] = record
+		pruned++
+	}
+	if pruned == 0 {
+		return 0, nil
+	}
+	return pruned, s.indexChanged()
+}
+
+func (s *fileStore) Close() error {
+	return nil
+}
@@ -0,0 +1,54 @@
+//go:build postgres
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withSearchPath adds a schema search path to a connection string of either
+// form lib/pq takes.
+func withSearchPath(dsn, schema string) string {
+	switch {
+	case !strings.Contains(dsn, "://"):
+		return dsn + " search_path=" + schema
+	case strings.Contains(dsn, "?"):
+		return dsn + "&search_path=" + schema
+	default:
+		return dsn + "?search_path=" + schema
+	}
+}
+
+// TestPostgresStore runs in a schema of its own, dropped afterwards, so the
+// migrations start from an empty database.
+func TestPostgresStore(t *testing.T) {
+	dsn := os.Getenv("SCG_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("set SCG_POSTGRES_DSN to a scratch database to test the postgres store")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	schema := fmt.Sprintf("scg_test_%d", time.Now().UnixNano())
+	if _, err := db.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+
+	testStore(t, func() (Store, error) { return openPostgresStore(withSearchPath(dsn, schema)) })
+
+	applied := 0
+	if err := db.QueryRow(`SELECT MAX(version) FROM ` + schema + `.schema_migrations`).Scan(&applied); err != nil {
+		t.Fatal(err)
+	}
+	if applied != len(postgresMigrations) {
+		t.Errorf("applied migrations up to %d, want %d", applied, len(postgresMigrations))
+	}
+}
@@ -0,0 +1,203 @@
+//go:build sqlite
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	storeBackends["sqlite"] = openSQLiteStore
+}
+
+// sqliteMigrations are applied in order, each once. Never edit one that has
+// shipped, append a new one instead.
+var sqliteMigrations = []string{
+	`CREATE TABLE encounters (
+		id     TEXT PRIMARY KEY,
+		file   TEXT NOT NULL,
+		stored TIMESTAMP NOT NULL,
+		record TEXT NOT NULL
+	)`,
+	`CREATE TABLE encounter_raw (
+		id    TEXT PRIMARY KEY REFERENCES encounters (id) ON DELETE CASCADE,
+		lines TEXT NOT NULL
+	)`,
+	`CREATE INDEX encounters_stored ON encounters (stored)`,
+	`ALTER TABLE encounters ADD COLUMN hash TEXT`,
+	`CREATE INDEX encounters_hash ON encounters (hash)`,
+}
+
+// sqliteStore keeps the store in a single SQLite file. The path is the file,
+// or a directory to keep encounters.sqlite in.
+//
+// The driver needs cgo and the default build has no dependencies, so this
+// backend is left out unless built with "-tags sqlite" in a module that
+// requires it:
+//
+//	go mod init scg
+//	go get github.com/mattn/go-sqlite3
+//	CGO_ENABLED=1 go build -tags sqlite
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func openSQLiteStore(path string) (Store, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, "encounters.sqlite")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("opening sqlite store: %w", err)
+	}
+	// a second process waits a moment for the write lock instead of failing
+	db, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=1000&_foreign_keys=on")
+	if err != nil {
+		return nil, fmt.Errorf("opening sqlite store: %w", err)
+	}
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+// migrateSQLite brings the schema up to date in one transaction.
+func migrateSQLite(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("migrating sqlite store: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("migrating sqlite store: %w", err)
+	}
+	applied := 0
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&applied); err != nil {
+		return fmt.Errorf("migrating sqlite store: %w", err)
+	}
+	for version := applied + 1; version <= len(sqliteMigrations); version++ {
+		if _, err := tx.Exec(sqliteMigrations[version-1]); err != nil {
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) PutEntries(file string, enc *Encounter) (string, error) {
+	return s.PutRecord(storedEncounter(file, enc), rawLines(enc))
+}
+
+func (s *sqliteStore) PutRecord(record StoredEncounter, lines []string) (string, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("storing encounter: %w", err)
+	}
+	defer tx.Rollback()
+	existing := ""
+	err = tx.QueryRow(`SELECT id FROM encounters WHERE id = ? OR (hash = ? AND hash <> '') ORDER BY id LIMIT 1`,
+		record.ID, record.Hash).Scan(&existing)
+	if err == nil {
+		return existing, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", fmt.Errorf("storing encounter: %w", err)
+	}
+	// stored is kept in UTC so it orders and compares as text
+	res, err := tx.Exec(`INSERT INTO encounters (id, file, stored, record, hash) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO NOTHING`, record.ID, record.File, record.Stored.UTC(), data, record.Hash)
+	if err != nil {
+		return "", fmt.Errorf("storing encounter: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 || record.RawPruned {
+		return record.ID, tx.Commit()
+	}
+	if _, err := tx.Exec(`INSERT INTO encounter_raw (id, lines) VALUES (?, ?)`, record.ID, strings.Join(lines, "\n")); err != nil {
+		return "", fmt.Errorf("storing encounter: %w", err)
+	}
+	return record.ID, tx.Commit()
+}
+
+func (s *sqliteStore) ListEncounters() ([]StoredEncounter, error) {
+	rows, err := s.db.Query(`SELECT record FROM encounters ORDER BY stored, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []StoredEncounter{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		record := StoredEncounter{}
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, err
+		}
+		list = append(list, record)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqliteStore) GetTimeline(id string) ([]string, error) {
+	lines := ""
+	err := s.db.QueryRow(`SELECT lines FROM encounter_raw WHERE id = ?`, id).Scan(&lines)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no raw lines of %q in the store, unknown or pruned", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(lines, "\n"), nil
+}
+
+func (s *sqliteStore) UpdateRecord(record StoredEncounter) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.Exec(`UPDATE encounters SET record = ?, hash = ? WHERE id = ?`, data, record.Hash, record.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("no encounter %q in the store", record.ID)
+	}
+	return nil
+}
+
+func (s *sqliteStore) PruneRaw(before time.Time) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`DELETE FROM encounter_raw WHERE id IN (SELECT id FROM encounters WHERE stored < ?)`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE encounters SET record = json_set(record, '$.raw_pruned', json('true'))
+		WHERE stored < ? AND NOT COALESCE(json_extract(record, '$.raw_pruned'), 0)`, before.UTC()); err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), tx.Commit()
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
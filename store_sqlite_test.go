@@ -0,0 +1,13 @@
+//go:build sqlite
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "encounters.sqlite")
+	testStore(t, func() (Store, error) { return openSQLiteStore(path) })
+}
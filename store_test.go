@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// storedHit is a hit secs seconds into an encounter with its raw line.
+func storedHit(secs float64) *LogEntry {
+	entry := skillUseAt(secs, "Starlaf", "Thrash")
+	entry.RawMessage = fmt.Sprintf("[07/08 %v] Starlaf scored a hit with Thrash on Burkhad for 100 Common damage.",
+		entry.Timestamp.Format("03:04:05 PM"))
+	return entry
+}
+
+// testStore runs a Store backend through what the server and the db command
+// rely on. open is called again to check the encounters are kept.
+func testStore(t *testing.T, open func() (Store, error)) {
+	store, err := open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { store.Close() }()
+
+	stored := time.Date(2024, 7, 8, 18, 0, 0, 0, time.UTC)
+	first := StoredEncounter{ID: "0708-173508-012345678", Hash: "aaa", File: "combat.txt", Stored: stored, ParserVersion: parserVersion}
+	second := StoredEncounter{ID: "0708-174408-abcdef012", Hash: "bbb", File: "combat.txt", Stored: stored.Add(time.Hour), ParserVersion: parserVersion}
+	firstLines := []string{"[07/08 05:35:08 PM] Starlaf scored a hit.", "[07/08 05:35:09 PM] Starlaf scored a hit."}
+	secondLines := []string{"[07/08 05:44:08 PM] Starlaf scored a hit."}
+	for _, put := range []struct {
+		record StoredEncounter
+		lines  []string
+		want   string
+	}{
+		{first, firstLines, first.ID},
+		{second, secondLines, second.ID},
+		// the same ID or the same hash keeps the stored copy
+		{first, secondLines, first.ID},
+		{StoredEncounter{ID: "0708-173508-fedcba987", Hash: "aaa", Stored: stored}, secondLines, first.ID},
+	} {
+		id, err := store.PutRecord(put.record, put.lines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != put.want {
+			t.Errorf("storing %v: got id %v, want %v", put.record.ID, id, put.want)
+		}
+	}
+
+	list, err := store.ListEncounters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := recordIDs(list); !slices.Equal(ids, []string{first.ID, second.ID}) {
+		t.Fatalf("got encounters %v, want %v and %v, oldest first", ids, first.ID, second.ID)
+	}
+	if lines, err := store.GetTimeline(first.ID); err != nil || !slices.Equal(lines, firstLines) {
+		t.Errorf("got timeline %q, %v, want %q", lines, err, firstLines)
+	}
+
+	first.Label = "Attempt 3"
+	if err := store.UpdateRecord(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpdateRecord(StoredEncounter{ID: "0708-180000-000000000"}); err == nil {
+		t.Errorf("updating an unknown encounter: no error")
+	}
+
+	pruned, err := store.PruneRaw(second.Stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 1 {
+		t.Errorf("pruned %d encounters, want 1", pruned)
+	}
+	if _, err := store.GetTimeline(first.ID); err == nil {
+		t.Errorf("got the timeline of a pruned encounter")
+	}
+	if lines, err := store.GetTimeline(second.ID); err != nil || !slices.Equal(lines, secondLines) {
+		t.Errorf("got timeline %q, %v, want %q", lines, err, secondLines)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if store, err = open(); err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	list, err = store.ListEncounters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := recordIDs(list); !slices.Equal(ids, []string{first.ID, second.ID}) {
+		t.Fatalf("after reopening got encounters %v, want %v and %v", ids, first.ID, second.ID)
+	}
+	if list[0].Label != first.Label || !list[0].RawPruned || list[1].RawPruned {
+		t.Errorf("after reopening got label %q and pruned %v, %v, want %q, true, false",
+			list[0].Label, list[0].RawPruned, list[1].RawPruned, first.Label)
+	}
+
+	// a parsed encounter is stored once under its own ID
+	enc := encounterOf(storedHit(0), storedHit(1))
+	for range 2 {
+		id, err := store.PutEntries("combat.txt", enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != encounterID(enc) {
+			t.Errorf("got id %v, want %v", id, encounterID(enc))
+		}
+	}
+	if lines, err := store.GetTimeline(encounterID(enc)); err != nil || !slices.Equal(lines, rawLines(enc)) {
+		t.Errorf("got timeline %q, %v, want %q", lines, err, rawLines(enc))
+	}
+
+	// archives bring records whose raw lines were pruned already
+	imported := StoredEncounter{ID: "0708-200000-012345678", Hash: "ccc", Stored: stored, RawPruned: true}
+	if _, err := store.PutRecord(imported, secondLines); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetTimeline(imported.ID); err == nil {
+		t.Errorf("got the timeline of an encounter imported without raw lines")
+	}
+}
+
+func recordIDs(list []StoredEncounter) []string {
+	ids := []string{}
+	for _, record := range list {
+		ids = append(ids, record.ID)
+	}
+	return ids
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	testStore(t, func() (Store, error) { return openFileStore(dir) })
+}
+
+func TestFileStoreBatch(t *testing.T) {
+	dir := t.TempDir()
+	store, err := openFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	stored := time.Date(2024, 7, 8, 18, 0, 0, 0, time.UTC)
+	err = storeBatch(store, func() error {
+		for i := range 3 {
+			record := StoredEncounter{ID: fmt.Sprintf("0708-1735%02d-012345678", i), Hash: fmt.Sprint(i), Stored: stored}
+			if _, err := store.PutRecord(record, []string{"[07/08 05:35:08 PM] Starlaf scored a hit."}); err != nil {
+				return err
+			}
+		}
+		// the index is written once, when the batch ends
+		if _, err := os.Stat(filepath.Join(dir, "index.json")); err == nil {
+			t.Errorf("the index was written in the batch")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := openFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if list, err := reopened.ListEncounters(); err != nil || len(list) != 3 {
+		t.Errorf("got %d encounters, %v after reopening, want 3", len(list), err)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// width of the windows the damage into a target is summed over
+	targetDPSWindow = 5 * time.Second
+	// attackers listed per target by the analysis
+	targetDPSAttackers = 5
+	// targets drawn by the chart, those that took the most damage
+	targetChartRows  = 8
+	targetChartWidth = 800
+	targetChartRow   = 60
+	targetChartLabel = 180
+)
+
+func init() {
+	analyses["targetdps"] = printTargetDPS
+	charts["targetdps"] = writeTargetDPSChartSVG
+}
+
+// TargetDamage is the damage every attacker together dealt to one target
+// over the encounter, to see whether an add went down in time.
+type TargetDamage struct {
+	Target string `json:"target"` // entity ID, see assignEntities
+	Name   string `json:"name"`
+	Total  int    `json:"total"`
+	// FirstHit and Died are since the encounter start, Died is zero if the
+	// target survived.
+	FirstHit time.Duration `json:"first_hit"`
+	Died     time.Duration `json:"died,omitempty"`
+	// DPS is the damage per second taken in each targetDPSWindow of the
+	// encounter.
+	DPS       []float64        `json:"dps"`
+	Attackers []AttackerDamage `json:"attackers"` // by damage
+}
+
+// AttackerDamage is what one attacker dealt to a target.
+type AttackerDamage struct {
+	Actor  string `json:"actor"`
+	Damage int    `json:"damage"`
+}
+
+// Lifetime is how long the target was under attack.
+func (t *TargetDamage) Lifetime(enc *Encounter) time.Duration {
+	if t.Died > 0 {
+		return t.Died - t.FirstHit
+	}
+	return enc.Duration() - t.FirstHit
+}
+
+// targetDamages sums the damage to every target that is not a player, by
+// total damage taken.
+func targetDamages(enc *Encounter) []*TargetDamage {
+	windows := int(enc.Duration()/targetDPSWindow) + 1
+	targets := map[string]*TargetDamage{}
+	attackers := map[string]map[string]int{}
+	for _, entry := range enc.Entries {
+		if entry.etype != DmgDealt || entry.Value == 0 || entry.TargetID == "" || enc.kindOf(entry.TargetID) == Player {
+			continue
+		}
+		at := entry.Timestamp.Sub(enc.Start)
+		t, ok := targets[entry.TargetID]
+		if !ok {
+			t = &TargetDamage{Target: entry.TargetID, Name: entry.TargetID, FirstHit: at, DPS: make([]float64, windows)}
+			if e := enc.Entities[entry.TargetID]; e != nil {
+				t.Name = e.Name
+				if !e.Died.IsZero() {
+					t.Died = e.Died.Sub(enc.Start)
+				}
+			}
+			targets[entry.TargetID] = t
+			attackers[entry.TargetID] = map[string]int{}
+		}
+		t.Total += entry.Value
+		t.DPS[min(int(at/targetDPSWindow), windows-1)] += float64(entry.Value) / targetDPSWindow.Seconds()
+		attackers[entry.TargetID][entry.SourceID] += entry.Value
+	}
+	result := make([]*TargetDamage, 0, len(targets))
+	for id, t := range targets {
+		for i := range t.DPS {
+			t.DPS[i] = math.Round(t.DPS[i])
+		}
+		for actor, damage := range attackers[id] {
+			t.Attackers = append(t.Attackers, AttackerDamage{Actor: actor, Damage: damage})
+		}
+		sort.Slice(t.Attackers, func(i, j int) bool {
+			if t.Attackers[i].Damage != t.Attackers[j].Damage {
+				return t.Attackers[i].Damage > t.Attackers[j].Damage
+			}
+			return t.Attackers[i].Actor < t.Attackers[j].Actor
+		})
+		result = append(result, t)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Total != result[j].Total {
+			return result[i].Total > result[j].Total
+		}
+		return result[i].Target < result[j].Target
+	})
+	return result
+}
+
+// matchTargets keeps the targets whose ID or name is target, so a name
+// matches every life of a respawning add.
+func matchTargets(targets []*TargetDamage, target string) []*TargetDamage {
+	matched := []*TargetDamage{}
+	for _, t := range targets {
+		if strings.EqualFold(t.Target, target) || strings.EqualFold(t.Name, target) {
+			matched = append(matched, t)
+		}
+	}
+	return matched
+}
+
+// getTargetDamage serves the damage to the targets of a stored encounter,
+// those named by the target parameter if given.
+func (s *shareServer) getTargetDamage(w http.ResponseWriter, r *http.Request) {
+	if !validEncounterID.MatchString(r.PathValue("id")) {
+		http.Error(w, "invalid encounter id", http.StatusBadRequest)
+		return
+	}
+	record, ok := s.recordIn(r.PathValue("id"), r.PathValue("guild"))
+	if !ok {
+		http.Error(w, fmt.Sprintf("no encounter %q", r.PathValue("id")), http.StatusNotFound)
+		return
+	}
+	lines, err := s.store.GetTimeline(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	enc, err := rebuildEncounter(s.gate, record, lines)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	targets := targetDamages(enc)
+	if target := r.URL.Query().Get("target"); target != "" {
+		if targets = matchTargets(targets, target); len(targets) == 0 {
+			http.Error(w, fmt.Sprintf("no target %q", target), http.StatusNotFound)
+			return
+		}
+	}
+	writeJSON(w, targets)
+}
+
+func writeTargetDPSChartSVG(w io.Writer, enc *Encounter) error {
+	targets := targetDamages(enc)
+	targets = targets[:min(len(targets), targetChartRows)]
+	peak := 1.0
+	for _, t := range targets {
+		for _, dps := range t.DPS {
+			peak = max(peak, dps)
+		}
+	}
+	height := 24 + targetChartRow*len(targets)
+	svgOpen(w, targetChartWidth, height, 11)
+	fmt.Fprintf(w, `<text x="4" y="14">Damage taken per second by target, in %v windows, peak %.0f/s</text>`+"\n", targetDPSWindow, peak)
+	secs := max(enc.Duration().Seconds(), 1)
+	scale := float64(targetChartWidth-targetChartLabel-10) / secs
+	barWidth := max(targetDPSWindow.Seconds()*scale-1, 1)
+	for i, t := range targets {
+		top := 24 + i*targetChartRow
+		bottom := top + targetChartRow - 8
+		fate := "survived"
+		if t.Died > 0 {
+			fate = fmt.Sprintf("died after %v", t.Lifetime(enc).Round(time.Second))
+		}
+		fmt.Fprintf(w, `<text x="4" y="%d">%v</text>`+"\n", top+14, svgText(t.Target))
+		fmt.Fprintf(w, `<text x="4" y="%d" fill="%v">%v, %v</text>`+"\n", top+28, chartTheme.Muted, shortNumber(float64(t.Total)), fate)
+		fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%v"/>`+"\n", targetChartLabel, bottom, targetChartWidth-10, bottom, chartTheme.Muted)
+		for b, dps := range t.DPS {
+			if dps == 0 {
+				continue
+			}
+			h := float64(bottom-top) * dps / peak
+			fmt.Fprintf(w, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%v"><title>%v +%v: %.0f/s</title></rect>`+"\n",
+				float64(targetChartLabel)+float64(b)*targetDPSWindow.Seconds()*scale, float64(bottom)-h, barWidth, h,
+				chartTheme.Damage, svgText(t.Target), time.Duration(b)*targetDPSWindow, dps)
+		}
+		if t.Died > 0 {
+			x := float64(targetChartLabel) + t.Died.Seconds()*scale
+			fmt.Fprintf(w, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="%v" stroke-width="2"><title>%v died at +%v</title></line>`+"\n",
+				x, top, x, bottom, chartTheme.Foreground, svgText(t.Target), t.Died)
+		}
+	}
+	_, err := fmt.Fprintln(w, "</svg>")
+	return err
+}
+
+func printTargetDPS(enc *Encounter) {
+	fmt.Println("damage by target:")
+	for _, t := range targetDamages(enc) {
+		fate := "survived"
+		if t.Died > 0 {
+			fate = fmt.Sprintf("died at +%v", t.Died)
+		}
+		lifetime := max(t.Lifetime(enc).Seconds(), 1)
+		fmt.Printf("  %-28v took %-10v (%8.0f/s over %v from +%v) %v\n",
+			t.Target, t.Total, float64(t.Total)/lifetime, t.Lifetime(enc), t.FirstHit, fate)
+		for _, a := range t.Attackers[:min(len(t.Attackers), targetDPSAttackers)] {
+			fmt.Printf("    %-24v %-10v %5.1f%%\n", a.Actor, a.Damage, 100*float64(a.Damage)/float64(t.Total))
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// targets first hit later than this into an encounter count as adds
+	addSpawnDelay = 5 * time.Second
+	// width of the windows in the per-actor target timeline
+	targetWindow = 30 * time.Second
+)
+
+func init() {
+	analyses["targets"] = printTargets
+}
+
+// TargetSplit is how one actor spread its damage over targets.
+type TargetSplit struct {
+	Actor    string
+	Total    int
+	ByTarget map[string]int
+	// Windows holds the damage per target for each targetWindow of the encounter.
+	Windows  []map[string]int
+	Switches int
+	// ReactionTime is the delay between an add's first hit by anyone and the
+	// actor's first hit on it.
+	ReactionTime map[string]time.Duration
+}
+
+func targetSplits(enc *Encounter) []*TargetSplit {
+	firstHit := map[string]time.Time{}
+	splits := map[string]*TargetSplit{}
+	// primary target per actor per second, used to count switches
+	lastSecond := map[string]time.Time{}
+	perSecond := map[string]map[string]int{}
+	primary := map[string]string{}
+
+	closeSecond := func(actor string) {
+		best, bestDmg := "", -1
+		for target, dmg := range perSecond[actor] {
+			if dmg > bestDmg || (dmg == bestDmg && target < best) {
+				best, bestDmg = target, dmg
+			}
+		}
+		if best != "" && primary[actor] != "" && primary[actor] != best {
+			splits[actor].Switches++
+		}
+		if best != "" {
+			primary[actor] = best
+		}
+		perSecond[actor] = map[string]int{}
+	}
+
+	for _, entry := range enc.Entries {
+		if entry.etype != DmgDealt || entry.Value == 0 || entry.Source == "" {
+			continue
+		}
+		if _, ok := firstHit[entry.Target]; !ok {
+			firstHit[entry.Target] = entry.Timestamp
+		}
+		s, ok := splits[entry.Source]
+		if !ok {
+			s = &TargetSplit{Actor: entry.Source, ByTarget: map[string]int{}, ReactionTime: map[string]time.Duration{}}
+			splits[entry.Source] = s
+			perSecond[entry.Source] = map[string]int{}
+		}
+		if _, hit := s.ByTarget[entry.Target]; !hit && isPriorityTarget(entry.Target, firstHit[entry.Target].Sub(enc.Start)) {
+			s.ReactionTime[entry.Target] = entry.Timestamp.Sub(firstHit[entry.Target])
+		}
+		if !entry.Timestamp.Equal(lastSecond[entry.Source]) {
+			closeSecond(entry.Source)
+			lastSecond[entry.Source] = entry.Timestamp
+		}
+		perSecond[entry.Source][entry.Target] += entry.Value
+		w := int(entry.Timestamp.Sub(enc.Start) / targetWindow)
+		for len(s.Windows) <= w {
+			s.Windows = append(s.Windows, map[string]int{})
+		}
+		s.Windows[w][entry.Target] += entry.Value
+		s.ByTarget[entry.Target] += entry.Value
+		s.Total += entry.Value
+	}
+	result := make([]*TargetSplit, 0, len(splits))
+	for actor, s := range splits {
+		closeSecond(actor)
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Total > result[j].Total })
+	return result
+}
+
+// isPriorityTarget reports whether a target is one of the configured priority
+// adds. Without configuration every late-spawning target counts.
+func isPriorityTarget(target string, spawnedAt time.Duration) bool {
+	if len(config.PriorityTargets) == 0 {
+		return spawnedAt > addSpawnDelay
+	}
+	for _, p := range config.PriorityTargets {
+		if p == target {
+			return true
+		}
+	}
+	return false
+}
+
+// windowPrimary returns the target that took the most damage in a window.
+func windowPrimary(window map[string]int) string {
+	best, bestDmg := "", 0
+	for target, dmg := range window {
+		if dmg > bestDmg || (dmg == bestDmg && target < best) {
+			best, bestDmg = target, dmg
+		}
+	}
+	return best
+}
+
+func printTargets(enc *Encounter) {
+	fmt.Println("target split:")
+	for _, s := range targetSplits(enc) {
+		if !isWatched(s.Actor) {
+			continue
+		}
+		fmt.Printf("  %v: %v damage over %d targets, %d target switches\n", s.Actor, s.Total, len(s.ByTarget), s.Switches)
+		targets := make([]string, 0, len(s.ByTarget))
+		for target := range s.ByTarget {
+			targets = append(targets, target)
+		}
+		sort.Slice(targets, func(i, j int) bool { return s.ByTarget[targets[i]] > s.ByTarget[targets[j]] })
+		for _, target := range targets {
+			fmt.Printf("    %-24v %5.1f%%", target, float64(s.ByTarget[target])*100/float64(s.Total))
+			if rt, ok := s.ReactionTime[target]; ok {
+				fmt.Printf("  switched after %v", rt)
+			}
+			fmt.Println()
+		}
+		fmt.Printf("    timeline:")
+		for i, window := range s.Windows {
+			if primary := windowPrimary(window); primary != "" {
+				fmt.Printf(" %v %v;", time.Duration(i)*targetWindow, primary)
+			}
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// hitOn is a hit by source on target secs seconds into an encounter.
+func hitOn(secs float64, source, target string, value int) *LogEntry {
+	entry := skillUseAt(secs, source, "Thrash")
+	entry.Target, entry.Value = target, value
+	return entry
+}
+
+func splitOf(splits []*TargetSplit, actor string) *TargetSplit {
+	for _, s := range splits {
+		if s.Actor == actor {
+			return s
+		}
+	}
+	return nil
+}
+
+func TestTargetReactionTimes(t *testing.T) {
+	enc := encounterOf(
+		hitOn(0, "Starlaf", "Burkhad", 100),
+		hitOn(0, "Huya", "Burkhad", 100),
+		hitOn(1, "Starlaf", "Burkhad", 100),
+		hitOn(10, "Huya", "Naxam", 100),
+		hitOn(11, "Starlaf", "Burkhad", 100),
+		hitOn(12, "Starlaf", "Naxam", 100),
+		hitOn(13, "Starlaf", "Naxam", 100),
+		// missed and avoided hits do not count as reacting
+		hitOn(14, "Azmaul", "Naxam", 0),
+		hitOn(16, "Azmaul", "Naxam", 100),
+	)
+	splits := targetSplits(enc)
+	starlaf, huya, azmaul := splitOf(splits, "Starlaf"), splitOf(splits, "Huya"), splitOf(splits, "Azmaul")
+	if starlaf == nil || huya == nil || azmaul == nil {
+		t.Fatalf("got %+v, want splits for Starlaf, Huya and Azmaul", splits)
+	}
+	if rt, ok := starlaf.ReactionTime["Naxam"]; !ok || rt != 2*time.Second {
+		t.Errorf("got Starlaf's reaction %v, %v, want 2s", rt, ok)
+	}
+	if rt, ok := huya.ReactionTime["Naxam"]; !ok || rt != 0 {
+		t.Errorf("got Huya's reaction %v, %v, want 0s for spawning it", rt, ok)
+	}
+	if rt := azmaul.ReactionTime["Naxam"]; rt != 6*time.Second {
+		t.Errorf("got Azmaul's reaction %v, want 6s", rt)
+	}
+	if _, ok := starlaf.ReactionTime["Burkhad"]; ok {
+		t.Errorf("Burkhad was there from the pull but has a reaction time")
+	}
+	if starlaf.Switches != 1 || starlaf.Total != 500 || starlaf.ByTarget["Naxam"] != 200 {
+		t.Errorf("got %d switches, %d total, %d on Naxam, want 1, 500 and 200", starlaf.Switches, starlaf.Total, starlaf.ByTarget["Naxam"])
+	}
+	if splits[0] != starlaf {
+		t.Errorf("got %v first, want Starlaf with the most damage", splits[0].Actor)
+	}
+}
+
+func TestPriorityTargetReactionTimes(t *testing.T) {
+	defer func(cfg Config) { config = cfg }(config)
+	config.PriorityTargets = []string{"Burkhad"}
+	enc := encounterOf(
+		hitOn(0, "Huya", "Burkhad", 100),
+		hitOn(3, "Starlaf", "Burkhad", 100),
+		hitOn(10, "Huya", "Naxam", 100),
+		hitOn(12, "Starlaf", "Naxam", 100),
+	)
+	starlaf := splitOf(targetSplits(enc), "Starlaf")
+	if rt, ok := starlaf.ReactionTime["Burkhad"]; !ok || rt != 3*time.Second {
+		t.Errorf("got %v, %v on the priority target, want 3s", rt, ok)
+	}
+	if _, ok := starlaf.ReactionTime["Naxam"]; ok {
+		t.Errorf("got a reaction time on an add that is not a priority")
+	}
+}
+
+func TestTargetWindows(t *testing.T) {
+	enc := encounterOf(
+		hitOn(0, "Starlaf", "Burkhad", 100),
+		hitOn(29, "Starlaf", "Naxam", 300),
+		hitOn(31, "Starlaf", "Burkhad", 100),
+	)
+	s := splitOf(targetSplits(enc), "Starlaf")
+	if len(s.Windows) != 2 || windowPrimary(s.Windows[0]) != "Naxam" || windowPrimary(s.Windows[1]) != "Burkhad" {
+		t.Errorf("got windows %v, want Naxam then Burkhad", s.Windows)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"regexp"
+)
+
+func init() {
+	commands["testpattern"] = runTestPattern
+}
+
+// runTestPattern tries a regex against the lines of a log that no parser
+// handles yet and shows what its named groups capture, to try out new
+// patterns and pattern overrides without touching the code. Like the
+// parsers, the regex sees the message after the timestamp.
+func runTestPattern(args []string) error {
+	fs := flag.NewFlagSet("testpattern", flag.ExitOnError)
+	setup := commonFlags(fs)
+	expr := fs.String("e", "", "regex to try, named groups like (?P<skill>...) are shown")
+	name := fs.String("name", "", "try the active pattern of this name instead of -e, e.g. with overrides from -patterns")
+	all := fs.Bool("all", false, "try every line, not only the ones no parser handles")
+	limit := fs.Int("n", 20, "matching lines to show, 0 for all")
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	path := inputPath(fs)
+	profile, err := sniffLog(path)
+	if err != nil {
+		return err
+	}
+	if err := profile.apply(); err != nil {
+		return err
+	}
+	var re *regexp.Regexp
+	switch {
+	case *name != "":
+		var ok bool
+		if re, ok = patterns[*name]; !ok {
+			return fmt.Errorf("no pattern named %q", *name)
+		}
+	case *expr != "":
+		if re, err = regexp.Compile(*expr); err != nil {
+			return err
+		}
+	default:
+		return errors.New("give a regex with -e or a pattern name with -name")
+	}
+
+	file, err := openLog(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	tried, matched := 0, 0
+	scanner := newLineScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if _, ok := filterNoise(line); ok {
+			continue
+		}
+		if !*all {
+			if _, err := parseLogLine(line); err == nil {
+				continue
+			}
+		}
+		tried++
+		_, msg, err := extractTimestamp(line)
+		if err != nil {
+			msg = line
+		}
+		match := re.FindStringSubmatch(msg)
+		if match == nil {
+			continue
+		}
+		matched++
+		if *limit > 0 && matched > *limit {
+			continue
+		}
+		fmt.Printf("line %d: %v\n", scanner.line, line)
+		for i, group := range re.SubexpNames() {
+			if group != "" {
+				fmt.Printf("  %-12v %q\n", group, match[i])
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	kind := "unparsed"
+	if *all {
+		kind = "non-chat"
+	}
+	if *limit > 0 && matched > *limit {
+		fmt.Printf("(%d more not shown)\n", matched-*limit)
+	}
+	fmt.Printf("matched %d of %d %v lines\n", matched, tried, kind)
+	return nil
+}
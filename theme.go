@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"sort"
+	"strconv"
+)
+
+// Theme is the palette of reports, charts and the live meter. Colors are
+// "#rrggbb".
+type Theme struct {
+	Background string
+	Foreground string
+	Muted      string // axes, bars that are context rather than the point
+	Damage     string
+	Heal       string
+	Death      string
+	Revive     string
+}
+
+// themes are selectable with -theme or "theme" in the config. The colorblind
+// ones use the Okabe-Ito palette and never pair red with green.
+var themes = map[string]Theme{
+	"light": {
+		Background: "#ffffff", Foreground: "#222222", Muted: "#95a5a6",
+		Damage: "#c0392b", Heal: "#27ae60", Death: "#c0392b", Revive: "#27ae60",
+	},
+	"dark": {
+		Background: "#1e1e1e", Foreground: "#e0e0e0", Muted: "#5d6d7e",
+		Damage: "#e74c3c", Heal: "#2ecc71", Death: "#e74c3c", Revive: "#2ecc71",
+	},
+	"colorblind": {
+		Background: "#ffffff", Foreground: "#000000", Muted: "#999999",
+		Damage: "#d55e00", Heal: "#009e73", Death: "#d55e00", Revive: "#0072b2",
+	},
+	"colorblind-dark": {
+		Background: "#1e1e1e", Foreground: "#e0e0e0", Muted: "#666666",
+		Damage: "#e69f00", Heal: "#56b4e9", Death: "#e69f00", Revive: "#56b4e9",
+	},
+}
+
+// chartTheme is the theme in use.
+var chartTheme = themes["light"]
+
+// themeFuncs give HTML templates the current theme as {{theme}}.
+var themeFuncs = template.FuncMap{"theme": func() Theme { return chartTheme }}
+
+func themeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// selectTheme makes the named theme current.
+func selectTheme(name string) error {
+	theme, ok := themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme %q, have: %v", name, themeNames())
+	}
+	chartTheme = theme
+	return nil
+}
+
+// ansi returns the escape sequence setting the terminal colors to the theme.
+func (t Theme) ansi() string {
+	rgb := func(hex string) (r, g, b uint64) {
+		v, _ := strconv.ParseUint(hex[1:], 16, 32)
+		return v >> 16, v >> 8 & 0xff, v & 0xff
+	}
+	br, bg, bb := rgb(t.Background)
+	fr, fg, fb := rgb(t.Foreground)
+	return fmt.Sprintf("\033[48;2;%d;%d;%dm\033[38;2;%d;%d;%dm", br, bg, bb, fr, fg, fb)
+}
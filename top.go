@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	commands["top"] = runTop
+}
+
+// Contributor is an actor's share of damage or healing in a window.
+type Contributor struct {
+	Actor string  `json:"actor"`
+	Total int     `json:"total"`
+	Share float64 `json:"share"`
+}
+
+// TopQuery selects the window and metric of a top contributors query.
+type TopQuery struct {
+	From, To time.Duration // offsets from the encounter start, To zero means the end
+	By       string        // "damage" or "healing"
+	Target   string        // only count events on this target
+	N        int
+}
+
+// parseOffset reads an encounter offset as "m:ss" or a Go duration.
+func parseOffset(s string) (time.Duration, error) {
+	if mins, secs, found := strings.Cut(s, ":"); found {
+		m, err1 := strconv.Atoi(mins)
+		sc, err2 := strconv.Atoi(secs)
+		if err1 == nil && err2 == nil {
+			return time.Duration(m)*time.Minute + time.Duration(sc)*time.Second, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid offset %q, want m:ss", s)
+	}
+	return d, nil
+}
+
+func topContributors(enc *Encounter, q TopQuery) ([]Contributor, error) {
+	var etype EventType
+	switch q.By {
+	case "damage", "":
+		etype = DmgDealt
+	case "healing":
+		etype = Heal
+	default:
+		return nil, fmt.Errorf("unknown metric %q, want damage or healing", q.By)
+	}
+	totals := map[string]int{}
+	sum := 0
+	for _, entry := range enc.Entries {
+		offset := entry.Timestamp.Sub(enc.Start)
+		if entry.etype != etype || entry.SourceID == "" || offset < q.From || (q.To > 0 && offset > q.To) {
+			continue
+		}
+		if q.Target != "" && entityName(entry.Target) != q.Target && entry.TargetID != q.Target {
+			continue
+		}
+		totals[entry.SourceID] += entry.Value
+		sum += entry.Value
+	}
+	result := make([]Contributor, 0, len(totals))
+	for actor, total := range totals {
+		result = append(result, Contributor{Actor: actor, Total: total, Share: float64(total) / float64(sum)})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Total > result[j].Total })
+	if q.N > 0 && len(result) > q.N {
+		result = result[:q.N]
+	}
+	return result, nil
+}
+
+// topHandler serves GET /top?from=m:ss&to=m:ss&by=damage&n=5&target=X for the
+// live meter's current encounter.
+func topHandler(meter *LiveMeter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		q := TopQuery{By: params.Get("by"), Target: params.Get("target"), N: 10}
+		var err error
+		if s := params.Get("from"); s != "" {
+			if q.From, err = parseOffset(s); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if s := params.Get("to"); s != "" {
+			if q.To, err = parseOffset(s); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if s := params.Get("n"); s != "" {
+			if q.N, err = strconv.Atoi(s); err != nil {
+				http.Error(w, "invalid n", http.StatusBadRequest)
+				return
+			}
+		}
+		enc := meter.Snapshot()
+		if enc == nil {
+			http.Error(w, "no encounter yet", http.StatusConflict)
+			return
+		}
+		top, err := topContributors(enc, q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(top)
+	}
+}
+
+// runTop answers "who did the most damage between 2:10 and 2:25?" for one
+// encounter of a log.
+func runTop(args []string) error {
+	fs := flag.NewFlagSet("top", flag.ExitOnError)
+	setup := commonFlags(fs)
+	encounter := fs.Int("encounter", 1, "encounter to query (1-based)")
+	from := fs.String("from", "0:00", "window start as offset into the encounter (m:ss)")
+	to := fs.String("to", "", "window end as offset into the encounter (m:ss), default the end")
+	by := fs.String("by", "damage", "damage or healing")
+	target := fs.String("target", "", "only count events on this target")
+	n := fs.Int("n", 10, "number of contributors to show")
+	fs.Parse(args)
+	if err := setup(); err != nil {
+		return err
+	}
+	q := TopQuery{By: *by, Target: *target, N: *n}
+	var err error
+	if q.From, err = parseOffset(*from); err != nil {
+		return err
+	}
+	if *to != "" {
+		if q.To, err = parseOffset(*to); err != nil {
+			return err
+		}
+	}
+	result, err := parseFile(inputPath(fs), ParserOptions{})
+	if err != nil {
+		return err
+	}
+	encounters := segmentEncounters(result.Entries)
+	if *encounter < 1 || *encounter > len(encounters) {
+		return fmt.Errorf("log has %d encounters, asked for %d", len(encounters), *encounter)
+	}
+	top, err := topContributors(encounters[*encounter-1], q)
+	if err != nil {
+		return err
+	}
+	for i, c := range top {
+		fmt.Printf("%2d. %-24v %-12v %5.1f%%\n", i+1, c.Actor, c.Total, c.Share*100)
+	}
+	return nil
+}
+
+// printTopTo draws the players with the most damage and healing side by
+// side, narrow enough for a small terminal.
+func printTopTo(w io.Writer, enc *Encounter) {
+	stats := []*ActorStats{}
+	for _, s := range watchedStats(actorStats(enc)) {
+		if s.Kind == Player || meterAllActors {
+			stats = append(stats, s)
+		}
+	}
+	printTopStatsTo(w, stats, enc)
+}
+
+// printTopStatsTo draws the top table of stats over the encounter.
+func printTopStatsTo(w io.Writer, stats []*ActorStats, enc *Encounter) {
+	column := func(value func(*ActorStats) int) []string {
+		sorted := slices.Clone(stats)
+		sort.SliceStable(sorted, func(i, j int) bool { return value(sorted[i]) > value(sorted[j]) })
+		sum := 0
+		for _, s := range sorted {
+			sum += value(s)
+		}
+		cells := []string{}
+		for i, s := range sorted {
+			if i == liveTopN || value(s) == 0 {
+				break
+			}
+			cells = append(cells, fmt.Sprintf("%2d %-14.14v %7v/s %3.0f%%", i+1, s.Actor,
+				shortNumber(perSecond(value(s), enc)), 100*float64(value(s))/float64(sum)))
+		}
+		return cells
+	}
+	damage := column(func(s *ActorStats) int { return s.Damage })
+	healing := column(func(s *ActorStats) int { return s.Healing })
+	fmt.Fprintf(w, "%-34v  %v\n", "damage", "healing")
+	for i := 0; i < max(len(damage), len(healing)); i++ {
+		d, h := "", ""
+		if i < len(damage) {
+			d = damage[i]
+		}
+		if i < len(healing) {
+			h = healing[i]
+		}
+		fmt.Fprintf(w, "%-34v  %v\n", d, h)
+	}
+}
+
+// shortNumber writes big numbers with a k or M suffix.
+func shortNumber(v float64) string {
+	switch {
+	case v >= 1e6:
+		return fmt.Sprintf("%.1fM", v/1e6)
+	case v >= 1e4:
+		return fmt.Sprintf("%.1fk", v/1e3)
+	}
+	return fmt.Sprintf("%.0f", v)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseOffset(t *testing.T) {
+	tests := []struct {
+		s    string
+		want time.Duration
+		ok   bool
+	}{
+		{"2:10", 2*time.Minute + 10*time.Second, true},
+		{"0:05", 5 * time.Second, true},
+		{"90s", 90 * time.Second, true},
+		{"later", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseOffset(tt.s)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseOffset(%q) = %v, %v, want %v", tt.s, got, err, tt.want)
+		}
+	}
+}
+
+func TestTopContributors(t *testing.T) {
+	hit := func(secs float64, source, target string, value int) *LogEntry {
+		entry := hitOn(secs, source, target, value)
+		entry.SourceID = source + "#1"
+		return entry
+	}
+	enc := encounterOf(
+		hit(0, "Starlaf", "Burkhad", 100),
+		hit(5, "Huya", "the Sea-shadow", 300),
+		hit(10, "Starlaf", "Burkhad", 100),
+		hit(12, "Azmaul", "Burkhad", 50),
+		&LogEntry{Timestamp: testStart.Add(12 * time.Second), etype: Heal, Source: "Huya", SourceID: "Huya#1", Target: "Starlaf", Value: 500},
+		hit(20, "Huya", "Burkhad", 1000),
+	)
+	tests := []struct {
+		q     TopQuery
+		want  []string
+		share float64
+	}{
+		{TopQuery{}, []string{"Huya#1", "Starlaf#1", "Azmaul#1"}, 1300.0 / 1550},
+		{TopQuery{From: 5 * time.Second, To: 12 * time.Second}, []string{"Huya#1", "Starlaf#1", "Azmaul#1"}, 300.0 / 450},
+		{TopQuery{To: 12 * time.Second, N: 1}, []string{"Huya#1"}, 300.0 / 550},
+		{TopQuery{Target: "Sea-shadow"}, []string{"Huya#1"}, 1},
+		{TopQuery{By: "healing"}, []string{"Huya#1"}, 1},
+	}
+	for _, tt := range tests {
+		top, err := topContributors(enc, tt.q)
+		if err != nil {
+			t.Errorf("%+v: %v", tt.q, err)
+			continue
+		}
+		var actors []string
+		for _, c := range top {
+			actors = append(actors, c.Actor)
+		}
+		if !slices.Equal(actors, tt.want) || top[0].Share != tt.share {
+			t.Errorf("%+v: got %v with share %v, want %v with %v", tt.q, actors, top[0].Share, tt.want, tt.share)
+		}
+	}
+	if _, err := topContributors(enc, TopQuery{By: "threat"}); err == nil {
+		t.Errorf("got no error for an unknown metric")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// InvariantError is an entry a parser produced that breaks a rule every
+// entry of its event type must follow, which means the parser is wrong.
+type InvariantError struct {
+	Rule  string
+	Entry *LogEntry
+}
+
+func (e *InvariantError) Error() string {
+	return fmt.Sprintf("%v entry %v: <%v>", e.Entry.etype, e.Rule, e.Entry.RawMessage)
+}
+
+// hitQualifier finds how the game says a hit was avoided, to check the
+// parsed Avoided against.
+var hitQualifier = regexp.MustCompile(`scored a (partially )?(blocked|parried|evaded) hit`)
+
+// entryInvariants are the rules checked per event type. Each returns what is
+// wrong with an entry, or "" when it is fine.
+var entryInvariants = map[EventType][]func(e *LogEntry) string{
+	DmgDealt: {
+		needTarget,
+		func(e *LogEntry) string {
+			if e.Value < 0 {
+				return "has a negative value"
+			}
+			return ""
+		},
+		func(e *LogEntry) string {
+			if e.Crit && e.Dev {
+				return "is both critical and devastating"
+			}
+			return ""
+		},
+		func(e *LogEntry) string {
+			m := hitQualifier.FindStringSubmatch(e.RawMessage)
+			switch {
+			case m == nil:
+				if e.Avoided != UnknownAvoid && e.Value > 0 {
+					return "landed in full but has Avoided set"
+				}
+			case e.Avoided != hitAvoids[m[2]]:
+				return fmt.Sprintf("was %v but Avoided does not say so", m[2])
+			case e.Partial != (m[1] != ""):
+				return "does not match the partially qualifier"
+			}
+			return ""
+		},
+	},
+	Heal:          {needTarget, needValue},
+	PowerRestored: {needTarget, needValue},
+	Death:         {needTarget},
+	Revive:        {needTarget},
+}
+
+func needTarget(e *LogEntry) string {
+	if e.Target == "" {
+		return "has no target"
+	}
+	return ""
+}
+
+func needValue(e *LogEntry) string {
+	if e.Value <= 0 {
+		return "has no positive value"
+	}
+	return ""
+}
+
+// validateEntry checks a parsed entry against the invariants of its event
+// type, and its timestamp against the timestamp pattern. parseFile runs it
+// in strict mode.
+func validateEntry(entry *LogEntry) error {
+	if rule := checkTimestamp(entry.RawMessage); rule != "" {
+		return &InvariantError{Rule: rule, Entry: entry}
+	}
+	for _, check := range entryInvariants[entry.etype] {
+		if rule := check(entry); rule != "" {
+			return &InvariantError{Rule: rule, Entry: entry}
+		}
+	}
+	return nil
+}
+
+// checkTimestamp compares the fixed-width timestamp scanner with the
+// timestamp pattern it stands in for.
+func checkTimestamp(line string) string {
+	if !fastTimestamps {
+		return ""
+	}
+	scanned, n, ok := scanTimestamp(line)
+	if !ok {
+		return ""
+	}
+	matched, rest, err := matchTimestamp(line)
+	if err != nil || !matched.Equal(scanned) || rest != line[n:] {
+		return fmt.Sprintf("has a timestamp scanned as %v that the timestamp pattern reads as %v (%v)", scanned, matched, err)
+	}
+	return ""
+}
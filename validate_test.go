@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestParsersKeepInvariants(t *testing.T) {
+	lines := []string{
+		"[07/08 05:35:08 PM] Starlaf applied a benefit with Man-form on Starlaf.",
+		"[07/08 05:35:09 PM] Hearten applied a heal to Starlaf restoring 2,508 points to Morale.",
+		"[07/08 05:35:23 PM] Azmaul applied a heal with Beacon of Hope to Starlaf restoring 11,240 points to Morale.",
+		"[07/08 05:35:23 PM] Azmaul applied a critical heal with Beacon of Hope to Starlaf restoring 22,480 points to Morale.",
+		"[07/08 05:35:34 PM] Starlaf scored a hit with Thrash - Tier 1 on Burkhad for 20,022 Beleriand damage to Morale.",
+		"[07/08 05:35:34 PM] Starlaf scored a critical hit with Thrash - Tier 1 on Burkhad for 40,044 Beleriand damage to Morale.",
+		"[07/08 05:35:34 PM] Starlaf scored a devastating hit with a melee attack on Burkhad for 4,011 Beleriand damage to Morale.",
+		"[07/08 05:36:00 PM] Ishakhar scored a partially evaded hit with Routing Cry on Starlaf for 63,776 Shadow damage to Morale.",
+		"[07/08 05:36:36 PM] Ishakhar scored a partially parried hit with Routing Cry on Starlaf for 74,718 Shadow damage to Morale.",
+		"[07/08 05:36:36 PM] Ishakhar scored a partially blocked hit with Routing Cry on Starlaf for 74,718 Shadow damage to Morale.",
+		"[07/08 06:03:57 PM] Burkhad scored a hit with Tar Spit on Starlaf.",
+		"[07/08 06:03:57 PM] Burkhad scored a blocked hit with Tar Spit on Starlaf.",
+		"[07/08 05:35:41 PM] Starlaf tried to use Knockback on Burkhad but he was immune to the attempt.",
+		"[07/08 05:35:41 PM] Starlaf tried to use Cleave on Burkhad but he evaded the attempt.",
+		"[07/08 05:38:54 PM] Starlaf missed trying to use a ranged attack on Nuralai.",
+		"[07/08 05:35:44 PM] You have lost 92,388 points of temporary Morale!",
+		"[07/08 05:36:48 PM] Huya defeated Burkhad.",
+		"[07/08 05:39:17 PM] Nuralai incapacitated you.",
+		"[07/08 05:38:13 PM] Huya has been revived.",
+		"[07/08 05:39:49 PM] Truancy has succumbed to his wounds.",
+		"[07/08 05:37:48 PM] You have dispelled Shanty: Resolve from Naxam.",
+		"[07/08 05:49:57 PM] You have released Burkhad from being immobilized!",
+		"[07/08 17:35:34] Starlaf scored a critical hit with Thrash - Tier 1 on Burkhad for 40,044 Beleriand damage to Morale.",
+	}
+	for _, line := range lines {
+		entry, err := parseLogLine(line)
+		if err != nil {
+			t.Errorf("%v", err)
+			continue
+		}
+		if err := validateEntry(entry); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestValidateEntryCatchesBrokenEntries(t *testing.T) {
+	const line = "[07/08 05:36:00 PM] Ishakhar scored a partially evaded hit with Routing Cry on Starlaf for 63,776 Shadow damage to Morale."
+	tests := []struct {
+		name  string
+		entry LogEntry
+	}{
+		{
+			// pDmg once read the avoidance from the crit group
+			name:  "avoidance read from the crit group",
+			entry: LogEntry{etype: DmgDealt, Target: "Starlaf", Value: 63776, RawMessage: line},
+		},
+		{
+			name:  "partial lost",
+			entry: LogEntry{etype: DmgDealt, Target: "Starlaf", Value: 63776, Avoided: Evaded, RawMessage: line},
+		},
+		{
+			name:  "critical and devastating",
+			entry: LogEntry{etype: DmgDealt, Target: "Burkhad", Value: 1, Crit: true, Dev: true, RawMessage: "[07/08 05:35:34 PM] x"},
+		},
+		{
+			name:  "heal without a value",
+			entry: LogEntry{etype: Heal, Target: "Starlaf", RawMessage: "[07/08 05:35:09 PM] x"},
+		},
+		{
+			name:  "death without a target",
+			entry: LogEntry{etype: Death, RawMessage: "[07/08 05:36:48 PM] x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var invariant *InvariantError
+			if err := validateEntry(&tt.entry); !errors.As(err, &invariant) {
+				t.Errorf("got %v, want an InvariantError", err)
+			}
+		})
+	}
+}
+
+func TestInputKeepsInvariants(t *testing.T) {
+	file, err := os.Open("test/input.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		entry, err := parseLogLine(scanner.Text())
+		if err != nil {
+			continue
+		}
+		if err := validateEntry(entry); err != nil {
+			t.Errorf("line %d: %v", n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	// how long a webhook endpoint gets to answer
+	webhookTimeout = 10 * time.Second
+)
+
+// webhook events
+const (
+	eventEncounter = "encounter" // an encounter ended, or was uploaded
+	eventDeath     = "death"     // a player died, in the live modes
+	eventRecord    = "record"    // an uploaded boss kill beat a player's best DPS
+)
+
+// WebhookConfig is an endpoint notified of events. Without a template the
+// body is JSON with the event and its text under "text" and "content", which
+// Slack and Discord incoming webhooks both accept.
+type WebhookConfig struct {
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url"`
+	// Events are the events to send, all of them when empty.
+	Events []string `json:"events,omitempty"`
+	// Guild limits upload events to one guild of serve mode.
+	Guild string `json:"guild,omitempty"`
+	// Template is a Go text/template of the body, executed with the
+	// WebhookEvent. The json function renders a value as JSON, e.g.
+	// {"chat_id": "123", "text": {{json .Text}}} for Telegram.
+	Template    string            `json:"template,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
+
+// WebhookEvent is what a webhook is told about.
+type WebhookEvent struct {
+	Event     string        `json:"event"`
+	Text      string        `json:"text"`
+	Guild     string        `json:"guild,omitempty"`
+	ID        string        `json:"id,omitempty"`
+	Boss      string        `json:"boss,omitempty"`
+	Start     time.Time     `json:"start"`
+	Duration  time.Duration `json:"duration"`
+	Actor     string        `json:"actor,omitempty"`
+	At        time.Duration `json:"at,omitempty"` // of a death, since the start
+	DPS       float64       `json:"dps,omitempty"`
+	Previous  float64       `json:"previous,omitempty"` // best DPS before a record
+	Stats     []*ActorStats `json:"stats,omitempty"`
+	Character string        `json:"character,omitempty"`
+
+	// enc is the encounter of encounter events when it is at hand, for
+	// notifiers that attach charts.
+	enc *Encounter
+}
+
+// webhook is a configured endpoint with its template parsed.
+type webhook struct {
+	WebhookConfig
+	body *template.Template
+}
+
+// notifier is somewhere events are sent: a webhook or a chat bot.
+type notifier interface {
+	name() string
+	wants(event WebhookEvent) bool
+	send(event WebhookEvent) error
+}
+
+var (
+	// activeNotifiers are set up from the config by commonFlags.
+	activeNotifiers []notifier
+	// notificationsSending counts the events still being sent, see
+	// flushNotifications.
+	notificationsSending sync.WaitGroup
+)
+
+var webhookFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// compileWebhooks checks the configured webhooks and parses their templates.
+func compileWebhooks(cfgs []WebhookConfig) ([]*webhook, error) {
+	hooks := []*webhook{}
+	for i, cfg := range cfgs {
+		name := cfg.Name
+		if name == "" {
+			name = fmt.Sprintf("webhook %d", i+1)
+		}
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("%v has no url", name)
+		}
+		if err := checkEvents(name, cfg.Events); err != nil {
+			return nil, err
+		}
+		hook := &webhook{WebhookConfig: cfg}
+		hook.Name = name
+		if cfg.Template != "" {
+			body, err := template.New(name).Funcs(webhookFuncs).Parse(cfg.Template)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w", name, err)
+			}
+			hook.body = body
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, nil
+}
+
+// checkEvents rejects unknown event names in a notifier's event filter.
+func checkEvents(name string, events []string) error {
+	for _, event := range events {
+		if event != eventEncounter && event != eventDeath && event != eventRecord {
+			return fmt.Errorf("%v: unknown event %q, want %v, %v or %v", name, event, eventEncounter, eventDeath, eventRecord)
+		}
+	}
+	return nil
+}
+
+// wantsEvent reports whether an event passes a notifier's guild and event
+// filters, empty filters letting everything through.
+func wantsEvent(guild string, events []string, event WebhookEvent) bool {
+	if guild != "" && guild != event.Guild {
+		return false
+	}
+	if len(events) == 0 {
+		return true
+	}
+	for _, e := range events {
+		if e == event.Event {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *webhook) name() string {
+	return h.Name
+}
+
+func (h *webhook) wants(event WebhookEvent) bool {
+	return wantsEvent(h.Guild, h.Events, event)
+}
+
+// send posts an event to the webhook.
+func (h *webhook) send(event WebhookEvent) error {
+	var body bytes.Buffer
+	contentType := h.ContentType
+	if h.body != nil {
+		if err := h.body.Execute(&body, event); err != nil {
+			return err
+		}
+	} else {
+		data, err := json.Marshal(map[string]any{"text": event.Text, "content": event.Text, "event": event})
+		if err != nil {
+			return err
+		}
+		body.Write(data)
+	}
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	req, err := http.NewRequest(http.MethodPost, h.URL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range h.Headers {
+		req.Header.Set(k, v)
+	}
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%v", resp.Status)
+	}
+	return nil
+}
+
+// notify sends an event to the notifiers that want it, in the background.
+// Failures are logged.
+func notify(event WebhookEvent) {
+	for _, n := range activeNotifiers {
+		if !n.wants(event) {
+			continue
+		}
+		notificationsSending.Add(1)
+		go func(n notifier) {
+			defer notificationsSending.Done()
+			if err := n.send(event); err != nil {
+				slog.Error("sending notification", "to", n.name(), "event", event.Event, "err", err)
+			}
+		}(n)
+	}
+}
+
+// flushNotifications waits for the events being sent, so they are not lost
+// when the program exits.
+func flushNotifications() {
+	notificationsSending.Wait()
+}
+
+// encounterEvent describes an ended or uploaded encounter.
+func encounterEvent(guild, id string, enc *Encounter) WebhookEvent {
+	boss := encounterBoss(enc)
+	event := WebhookEvent{
+		Event: eventEncounter, Guild: guild, ID: id, Boss: boss,
+		Start: enc.Start, Duration: enc.Duration(), Character: enc.Character,
+		Stats: actorStats(enc), enc: enc,
+	}
+	what := "Trash"
+	if boss != "" {
+		what = boss
+	}
+	parts := []string{}
+	for _, s := range event.Stats {
+		if s.Kind == Player && s.Damage > 0 && len(parts) < 3 {
+			parts = append(parts, fmt.Sprintf("%v %.0f dps", s.Actor, perSecond(s.Damage, enc)))
+		}
+	}
+	event.Text = fmt.Sprintf("%v at %v (%v)", what, enc.Start.Format("15:04:05"), enc.Duration())
+	if len(parts) > 0 {
+		event.Text += ": " + strings.Join(parts, ", ")
+	}
+	return event
+}
+
+// deathEvent describes a player death in the current encounter.
+func deathEvent(enc *Encounter, entry *LogEntry) WebhookEvent {
+	at := entry.Timestamp.Sub(enc.Start)
+	return WebhookEvent{
+		Event: eventDeath, Boss: encounterBoss(enc), Start: enc.Start, Duration: enc.Duration(),
+		Actor: entry.TargetID, At: at, Character: enc.Character,
+		Text: fmt.Sprintf("%v died at +%v", entry.TargetID, at),
+	}
+}
+
+// recordEvents compares the players of a stored boss kill with their best
+// DPS on the same boss in earlier records of the guild.
+func recordEvents(record StoredEncounter, earlier []StoredEncounter) []WebhookEvent {
+	if record.Boss == "" || !bossKilled(record) || record.Duration <= 0 {
+		return nil
+	}
+	dps := func(r StoredEncounter, s *ActorStats) float64 { return float64(s.Damage) / r.Duration.Seconds() }
+	best := map[string]float64{}
+	for _, r := range earlier {
+		if r.Boss != record.Boss || r.Guild != record.Guild || r.Duration <= 0 || !bossKilled(r) {
+			continue
+		}
+		for _, s := range r.Stats {
+			if s.Kind == Player {
+				best[s.Actor] = max(best[s.Actor], dps(r, s))
+			}
+		}
+	}
+	events := []WebhookEvent{}
+	for _, s := range record.Stats {
+		previous, seen := best[s.Actor]
+		if s.Kind != Player || !seen || dps(record, s) <= previous {
+			continue
+		}
+		events = append(events, WebhookEvent{
+			Event: eventRecord, Guild: record.Guild, ID: record.ID, Boss: record.Boss,
+			Start: record.Start, Duration: record.Duration, Actor: s.Actor,
+			DPS: dps(record, s), Previous: previous,
+			Text: fmt.Sprintf("%v beat their best on %v: %.0f dps, up from %.0f", s.Actor, record.Boss, dps(record, s), previous),
+		})
+	}
+	return events
+}
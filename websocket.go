@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The parts of RFC 6455 the overlay needs: a server that sends unfragmented
+// text frames and reads small client messages, and a client for publishing
+// and spectating live sessions on the share server.
+
+const (
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// biggest client message read, the overlay only gets small commands
+	websocketMaxMessage = 64 << 10
+	// how long a frame may take to write
+	websocketWriteTimeout = 10 * time.Second
+)
+
+// websocket opcodes
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// wsConn is an upgraded WebSocket connection.
+type wsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	mu   sync.Mutex // serializes writes
+	// client is set on connections this side dialed, which mask their
+	// frames and read unmasked ones.
+	client bool
+}
+
+// wsClients are the WebSocket connections of a server. http.Server.Shutdown
+// leaves hijacked connections alone, so the server tells their handlers to
+// close them, with a going away code, and waits until they have.
+type wsClients struct {
+	mu      sync.Mutex
+	open    int
+	closing chan struct{}
+	once    sync.Once
+}
+
+func newWSClients() *wsClients {
+	return &wsClients{closing: make(chan struct{})}
+}
+
+// track counts a connection's handler until the returned func is called.
+func (c *wsClients) track() (untrack func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.open++
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.open--
+	}
+}
+
+// shutdown closes closing and waits until every handler returned or ctx is
+// done.
+func (c *wsClients) shutdown(ctx context.Context) error {
+	c.once.Do(func() { close(c.closing) })
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		c.mu.Lock()
+		open := c.open
+		c.mu.Unlock()
+		if open == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// upgradeWebSocket answers a WebSocket handshake and takes over the
+// connection. A request that is no handshake gets a 400.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerContains(r.Header, "Connection", "upgrade") || !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		http.Error(w, "websocket handshake expected", http.StatusBadRequest)
+		return nil, errors.New("not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("unsupported websocket version")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket unsupported", http.StatusInternalServerError)
+		return nil, errors.New("connection cannot be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %v\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, r: rw.Reader}, nil
+}
+
+// dialWebSocket opens a WebSocket to an http or https URL.
+func dialWebSocket(rawURL string, header http.Header) (*wsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), map[string]string{"http": "80", "https": "443"}[u.Scheme])
+	}
+	dialer := &net.Dialer{Timeout: websocketWriteTimeout}
+	var conn net.Conn
+	switch u.Scheme {
+	case "http":
+		conn, err = dialer.Dial("tcp", host)
+	case "https":
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q, want http or https", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req := &http.Request{Method: http.MethodGet, URL: u, Host: u.Host, Header: header.Clone()}
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	conn.SetDeadline(time.Now().Add(websocketWriteTimeout))
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		conn.Close()
+		return nil, fmt.Errorf("%v: %v", resp.Status, strings.TrimSpace(string(body)))
+	}
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		conn.Close()
+		return nil, errors.New("server answered the websocket handshake wrongly")
+	}
+	conn.SetDeadline(time.Time{})
+	return &wsConn{conn: conn, r: r, client: true}, nil
+}
+
+// headerContains reports whether a comma separated header has a token.
+func headerContains(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeFrame sends one final frame, masked if this side is the client.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, maskBit|byte(n))
+	case n <= 0xFFFF:
+		header = append(header, maskBit|126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, maskBit|127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	if c.client {
+		var mask [4]byte
+		rand.Read(mask[:])
+		header = append(header, mask[:]...)
+		masked := make([]byte, len(payload))
+		for i := range payload {
+			masked[i] = payload[i] ^ mask[i%4]
+		}
+		payload = masked
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout))
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// writeJSON sends a value as a text message.
+func (c *wsConn) writeJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(wsText, data)
+}
+
+// close sends a close frame with a status code and closes the connection.
+func (c *wsConn) close(code uint16, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, code)
+	c.writeFrame(wsClose, append(payload, reason...))
+	return c.conn.Close()
+}
+
+// readMessage returns the next text or binary message, joining fragments
+// and answering pings on the way. It returns io.EOF when the other side
+// closes the connection.
+func (c *wsConn) readMessage() (opcode byte, message []byte, err error) {
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch op {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			c.writeFrame(wsClose, payload)
+			return 0, nil, io.EOF
+		case wsText, wsBinary:
+			opcode, message = op, payload
+		case wsContinuation:
+			if opcode == 0 {
+				return 0, nil, errors.New("websocket continuation without a message")
+			}
+			message = append(message, payload...)
+		default:
+			return 0, nil, fmt.Errorf("unknown websocket opcode %#x", op)
+		}
+		if len(message) > websocketMaxMessage {
+			return 0, nil, errors.New("websocket message too big")
+		}
+		if fin {
+			return opcode, message, nil
+		}
+	}
+}
+
+// readFrame reads one frame and unmasks its payload. Clients must mask,
+// servers must not.
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode = head[0]&0x80 != 0, head[0]&0x0F
+	masked := head[1]&0x80 != 0
+	if masked == c.client {
+		if c.client {
+			return false, 0, nil, errors.New("masked websocket frame from server")
+		}
+		return false, 0, nil, errors.New("unmasked websocket frame from client")
+	}
+	n := uint64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > websocketMaxMessage {
+		return false, 0, nil, errors.New("websocket frame too big")
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	commands["guild"] = runGuild
+}
+
+// Token roles, each can do what the ones before it can.
+const (
+	roleMember = "member" // browse the guild's encounters
+	roleUpload = "upload" // and upload logs into the guild
+	roleAdmin  = "admin"  // and manage the guild's tokens
+)
+
+var roleRanks = map[string]int{roleMember: 1, roleUpload: 2, roleAdmin: 3}
+
+// guild names, also used in URLs
+var validGuildName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,39}$`)
+
+// Workspace is a guild's namespace on a share server. Its encounters are only
+// visible with one of its tokens.
+type Workspace struct {
+	Name    string           `json:"name"`
+	Created time.Time        `json:"created"`
+	Tokens  []WorkspaceToken `json:"tokens"`
+	// Roster is the guild's raiding characters.
+	Roster []string `json:"roster,omitempty"`
+}
+
+// WorkspaceToken is an access token of a guild. Only its hash is kept, the
+// token itself is shown once when it is made.
+type WorkspaceToken struct {
+	Hash    string    `json:"hash"`
+	Role    string    `json:"role"`
+	Label   string    `json:"label,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// ID is the short form of the token's hash used to revoke it.
+func (t WorkspaceToken) ID() string {
+	return t.Hash[:12]
+}
+
+// workspaces is the guild registry, kept in a JSON file. The guild command
+// changes the file under a running server, so every use first reads it
+// again if it changed, see refresh.
+type workspaces struct {
+	mu     sync.Mutex
+	path   string
+	guilds map[string]*Workspace
+	// loaded is the file as last read or written
+	loaded fs.FileInfo
+}
+
+// defaultWorkspacesPath is workspaces.json next to the config file.
+func defaultWorkspacesPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "workspaces.json"
+	}
+	return filepath.Join(dir, "SharedCombatGraphs", "workspaces.json")
+}
+
+// openWorkspaces reads the guild registry. A missing file is an empty one.
+func openWorkspaces(path string) (*workspaces, error) {
+	if path == "" {
+		path = defaultWorkspacesPath()
+	}
+	w := &workspaces{path: path, guilds: map[string]*Workspace{}}
+	if err := w.refresh(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// refresh reads the registry again if the file changed since it was last
+// read or written, callers hold the lock.
+func (w *workspaces) refresh() error {
+	info, err := os.Stat(w.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading workspaces: %w", err)
+	}
+	if w.loaded != nil && info.ModTime().Equal(w.loaded.ModTime()) && info.Size() == w.loaded.Size() {
+		return nil
+	}
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return fmt.Errorf("reading workspaces: %w", err)
+	}
+	guilds := map[string]*Workspace{}
+	if err := json.Unmarshal(data, &guilds); err != nil {
+		return fmt.Errorf("parsing workspaces %v: %w", w.path, err)
+	}
+	w.guilds, w.loaded = guilds, info
+	return nil
+}
+
+// current refreshes the registry for a read. A file that cannot be read
+// leaves the registry as it was.
+func (w *workspaces) current() {
+	if err := w.refresh(); err != nil {
+		slog.Warn("keeping the guild registry as it was", "err", err)
+	}
+}
+
+// save writes the registry through a temporary file, callers hold the lock.
+func (w *workspaces) save() error {
+	data, err := json.MarshalIndent(w.guilds, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(w.path), 0o755); err != nil {
+		return err
+	}
+	tmp := w.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, w.path); err != nil {
+		return err
+	}
+	w.loaded, err = os.Stat(w.path)
+	return err
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// create adds a guild and returns its first admin token.
+func (w *workspaces) create(name string) (string, error) {
+	if !validGuildName.MatchString(name) {
+		return "", fmt.Errorf("invalid guild name %q, use lowercase letters, digits and dashes", name)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.refresh(); err != nil {
+		return "", err
+	}
+	if _, ok := w.guilds[name]; ok {
+		return "", fmt.Errorf("guild %q already exists", name)
+	}
+	w.guilds[name] = &Workspace{Name: name, Created: time.Now()}
+	return w.addToken(name, roleAdmin, "created with the guild")
+}
+
+// issue makes a new token of a guild and returns it.
+func (w *workspaces) issue(guild, role, label string) (string, error) {
+	if roleRanks[role] == 0 {
+		return "", fmt.Errorf("unknown role %q, have: %v, %v, %v", role, roleMember, roleUpload, roleAdmin)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.refresh(); err != nil {
+		return "", err
+	}
+	if _, ok := w.guilds[guild]; !ok {
+		return "", fmt.Errorf("no guild %q", guild)
+	}
+	return w.addToken(guild, role, label)
+}
+
+func (w *workspaces) addToken(guild, role, label string) (string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := guild + "_" + hex.EncodeToString(secret)
+	ws := w.guilds[guild]
+	ws.Tokens = append(ws.Tokens, WorkspaceToken{Hash: hashToken(token), Role: role, Label: label, Created: time.Now()})
+	return token, w.save()
+}
+
+// revoke removes the token of a guild with the given ID.
+func (w *workspaces) revoke(guild, id string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.refresh(); err != nil {
+		return err
+	}
+	ws, ok := w.guilds[guild]
+	if !ok {
+		return fmt.Errorf("no guild %q", guild)
+	}
+	for i, t := range ws.Tokens {
+		if t.ID() == id {
+			ws.Tokens = append(ws.Tokens[:i], ws.Tokens[i+1:]...)
+			return w.save()
+		}
+	}
+	return fmt.Errorf("no token %q in guild %q", id, guild)
+}
+
+// tokens returns a copy of a guild's tokens.
+func (w *workspaces) tokens(guild string) ([]WorkspaceToken, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.current()
+	ws, ok := w.guilds[guild]
+	if !ok {
+		return nil, false
+	}
+	return append([]WorkspaceToken(nil), ws.Tokens...), true
+}
+
+// roster returns a copy of a guild's roster.
+func (w *workspaces) roster(guild string) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.current()
+	ws, ok := w.guilds[guild]
+	if !ok {
+		return nil
+	}
+	return append([]string(nil), ws.Roster...)
+}
+
+// setRoster replaces a guild's roster.
+func (w *workspaces) setRoster(guild string, roster []string) error {
+	for _, name := range roster {
+		if name == "" || len(name) > 40 {
+			return fmt.Errorf("invalid character name %q", name)
+		}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.refresh(); err != nil {
+		return err
+	}
+	ws, ok := w.guilds[guild]
+	if !ok {
+		return fmt.Errorf("no guild %q", guild)
+	}
+	ws.Roster = append([]string(nil), roster...)
+	sort.Strings(ws.Roster)
+	return w.save()
+}
+
+// allowed reports whether a token grants at least a role in a guild.
+func (w *workspaces) allowed(guild, token, role string) bool {
+	if token == "" {
+		return false
+	}
+	hash := hashToken(token)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.current()
+	ws, ok := w.guilds[guild]
+	if !ok {
+		return false
+	}
+	for _, t := range ws.Tokens {
+		if t.Hash == hash {
+			return roleRanks[t.Role] >= roleRanks[role]
+		}
+	}
+	return false
+}
+
+func (w *workspaces) names() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.current()
+	names := make([]string, 0, len(w.guilds))
+	for name := range w.guilds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// guildRecord is the index record of an encounter uploaded into a guild. The
+// guild is part of the ID so two guilds uploading the same fight each keep
+// their own copy.
+func guildRecord(guild, file string, enc *Encounter) StoredEncounter {
+	record := storedEncounter(file, enc)
+	record.Guild = guild
+	record.ID = scopedEncounterID(guild, enc)
+	record.Hash = contentHash(guild, enc)
+	return record
+}
+
+// guildCommands are the subcommands of "guild".
+var guildCommands = map[string]func(ws *workspaces, fs *flag.FlagSet) error{
+	"create": guildCreate,
+	"list":   guildList,
+	"token":  guildToken,
+	"revoke": guildRevoke,
+	"roster": guildRoster,
+}
+
+func guildCreate(ws *workspaces, fs *flag.FlagSet) error {
+	if fs.NArg() != 1 {
+		return errors.New("usage: guild create <name>")
+	}
+	token, err := ws.create(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created guild %v, admin token (shown once):\n%v\n", fs.Arg(0), token)
+	return nil
+}
+
+func guildList(ws *workspaces, fs *flag.FlagSet) error {
+	for _, name := range ws.names() {
+		tokens, _ := ws.tokens(name)
+		fmt.Printf("%v\n", name)
+		for _, t := range tokens {
+			fmt.Printf("  %v %-6v %v %v\n", t.ID(), t.Role, t.Created.Format("2006-01-02"), t.Label)
+		}
+		if roster := ws.roster(name); len(roster) > 0 {
+			fmt.Printf("  roster: %v\n", strings.Join(roster, ", "))
+		}
+	}
+	return nil
+}
+
+func guildToken(ws *workspaces, fs *flag.FlagSet) error {
+	if fs.NArg() != 1 {
+		return errors.New("usage: guild token [-role upload] [-label text] <name>")
+	}
+	token, err := ws.issue(fs.Arg(0), fs.Lookup("role").Value.String(), fs.Lookup("label").Value.String())
+	if err != nil {
+		return err
+	}
+	fmt.Println(token)
+	return nil
+}
+
+func guildRevoke(ws *workspaces, fs *flag.FlagSet) error {
+	if fs.NArg() != 2 {
+		return errors.New("usage: guild revoke <name> <token id>")
+	}
+	return ws.revoke(fs.Arg(0), fs.Arg(1))
+}
+
+// guildRoster prints a guild's roster, or replaces it with the names given.
+func guildRoster(ws *workspaces, fs *flag.FlagSet) error {
+	if fs.NArg() < 1 {
+		return errors.New("usage: guild roster <name> [character...]")
+	}
+	if fs.NArg() > 1 {
+		return ws.setRoster(fs.Arg(0), fs.Args()[1:])
+	}
+	for _, name := range ws.roster(fs.Arg(0)) {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+// runGuild manages the guild workspaces of a share server:
+// "guild <subcommand> [flags] [args]".
+func runGuild(args []string) error {
+	names := make([]string, 0, len(guildCommands))
+	for name := range guildCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(args) == 0 || guildCommands[args[0]] == nil {
+		return fmt.Errorf("usage: guild <%v> [flags] [args]", strings.Join(names, "|"))
+	}
+	fs := flag.NewFlagSet("guild "+args[0], flag.ExitOnError)
+	setup := commonFlags(fs)
+	path := fs.String("workspaces", "", "guild registry file (default from config)")
+	fs.String("role", roleUpload, "role of a new token: member, upload or admin")
+	fs.String("label", "", "note on a new token, e.g. who has it")
+	fs.Parse(args[1:])
+	if err := setup(); err != nil {
+		return err
+	}
+	if *path != "" {
+		config.Server.Workspaces = *path
+	}
+	ws, err := openWorkspaces(config.Server.Workspaces)
+	if err != nil {
+		return err
+	}
+	return guildCommands[args[0]](ws, fs)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func openTestWorkspaces(t *testing.T, path string) *workspaces {
+	t.Helper()
+	ws, err := openWorkspaces(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+// getRoster requests a guild's roster from the server with a token.
+func getRoster(t *testing.T, s *shareServer, guild, token string) int {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/guilds/"+guild+"/roster", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestRevokeTakesEffectOnRunningServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workspaces.json")
+	server := &shareServer{metrics: newServerMetrics(), guilds: openTestWorkspaces(t, path)}
+
+	// guild create and guild revoke run as separate processes
+	token, err := openTestWorkspaces(t, path).create("fellowship")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := getRoster(t, server, "fellowship", token); code != http.StatusOK {
+		t.Fatalf("before revoking: status %v, want %v", code, http.StatusOK)
+	}
+	cli := openTestWorkspaces(t, path)
+	tokens, _ := cli.tokens("fellowship")
+	if err := cli.revoke("fellowship", tokens[0].ID()); err != nil {
+		t.Fatal(err)
+	}
+	if code := getRoster(t, server, "fellowship", token); code != http.StatusForbidden {
+		t.Fatalf("after revoking: status %v, want %v", code, http.StatusForbidden)
+	}
+}
+
+func TestServerKeepsGuildsCreatedByTheCLI(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workspaces.json")
+	server := openTestWorkspaces(t, path)
+	if _, err := server.create("first"); err != nil {
+		t.Fatal(err)
+	}
+	second, err := openTestWorkspaces(t, path).create("second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a change on the server must not write back its stale registry
+	if _, err := server.issue("first", roleMember, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !openTestWorkspaces(t, path).allowed("second", second, roleAdmin) {
+		t.Errorf("guild created by the CLI lost after the server issued a token")
+	}
+	if err := server.setRoster("second", []string{"Starlaf"}); err != nil {
+		t.Errorf("server does not see the guild created by the CLI: %v", err)
+	}
+}